@@ -4,12 +4,17 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"testing"
 	"time"
 
 	"github.com/ipfs/boxo/bitswap"
+	"github.com/ipfs/boxo/bitswap/client"
 	"github.com/ipfs/boxo/bitswap/client/internal/session"
 	"github.com/ipfs/boxo/bitswap/client/traceability"
+	"github.com/ipfs/boxo/bitswap/internal/testutil"
 	testinstance "github.com/ipfs/boxo/bitswap/testinstance"
 	tn "github.com/ipfs/boxo/bitswap/testnet"
 	mockrouting "github.com/ipfs/boxo/routing/mock"
@@ -19,6 +24,7 @@ import (
 	delay "github.com/ipfs/go-ipfs-delay"
 	tu "github.com/libp2p/go-libp2p-testing/etc"
 	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
 )
 
 func getVirtualNetwork() tn.Network {
@@ -497,3 +503,84 @@ func TestWantlistClearsOnCancel(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+type httpRouter struct {
+	providers []peer.AddrInfo
+}
+
+func (r *httpRouter) FindProvidersAsync(ctx context.Context, k cid.Cid, max int) <-chan peer.AddrInfo {
+	ch := make(chan peer.AddrInfo, len(r.providers))
+	for _, ai := range r.providers {
+		ch <- ai
+	}
+	close(ch)
+	return ch
+}
+
+func TestHTTPRetrieval(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	bgen := blocksutil.NewBlockGenerator()
+	blks := bgen.Blocks(10)
+	served := make(map[string][]byte)
+	var cids []cid.Cid
+	for _, blk := range blks {
+		served["/ipfs/"+blk.Cid().String()] = blk.RawData()
+		cids = append(cids, blk.Cid())
+	}
+
+	// A trustless gateway serving raw blocks
+	gw := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := served[r.URL.Path]
+		if !ok || r.URL.Query().Get("format") != "raw" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/vnd.ipld.raw")
+		_, _ = w.Write(data)
+	}))
+	defer gw.Close()
+	gwURL, err := url.Parse(gw.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gwPeer := testutil.GeneratePeers(1)[0]
+	router := &httpRouter{providers: []peer.AddrInfo{{
+		ID:    gwPeer,
+		Addrs: []multiaddr.Multiaddr{multiaddr.StringCast("/ip4/127.0.0.1/tcp/" + gwURL.Port() + "/http")},
+	}}}
+
+	// The node has no libp2p peer, the blocks can only come from the gateway
+	vnet := getVirtualNetwork()
+	ig := testinstance.NewTestInstanceGenerator(vnet, nil, []bitswap.Option{
+		bitswap.ProviderSearchDelay(50 * time.Millisecond),
+		bitswap.WithHTTPRetrieval(router, client.HTTPRetrievalConfig{}),
+	})
+	defer ig.Close()
+	a := ig.Instances(1)[0]
+
+	ses := a.Exchange.NewSession(ctx)
+	ch, err := ses.GetBlocks(ctx, cids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []blocks.Block
+	for b := range ch {
+		got = append(got, b)
+		if traceBlock, ok := b.(traceability.Block); !ok || traceBlock.From != gwPeer {
+			t.Fatal("expected block to come from the HTTP peer")
+		}
+	}
+	if len(got) != len(blks) {
+		t.Fatalf("expected %d blocks, got %d", len(blks), len(got))
+	}
+
+	st, err := a.Exchange.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.BlocksReceived != uint64(len(blks)) {
+		t.Fatalf("expected %d blocks received, got %d", len(blks), st.BlocksReceived)
+	}
+}
@@ -5,11 +5,13 @@ package client
 import (
 	"context"
 	"errors"
+	"net/http"
 	"sync"
 	"time"
 
 	bsbpm "github.com/ipfs/boxo/bitswap/client/internal/blockpresencemanager"
 	bsgetter "github.com/ipfs/boxo/bitswap/client/internal/getter"
+	"github.com/ipfs/boxo/bitswap/client/internal/httpfetcher"
 	bsmq "github.com/ipfs/boxo/bitswap/client/internal/messagequeue"
 	"github.com/ipfs/boxo/bitswap/client/internal/notifications"
 	bspm "github.com/ipfs/boxo/bitswap/client/internal/peermanager"
@@ -99,6 +101,42 @@ func WithoutDuplicatedBlockStats() Option {
 	}
 }
 
+// HTTPProviderRouter finds the providers of a CID along with their addresses.
+// The delegated routing client of routing/http, wrapped by
+// [github.com/ipfs/boxo/routing/http/contentrouter], is one.
+type HTTPProviderRouter interface {
+	FindProvidersAsync(context.Context, cid.Cid, int) <-chan peer.AddrInfo
+}
+
+// HTTPRetrievalConfig tunes the retrieval of blocks from HTTP gateways. Zero
+// fields take their default value.
+type HTTPRetrievalConfig struct {
+	// Client sends the requests, http.DefaultClient if nil.
+	Client *http.Client
+	// MaxConcurrentRequestsPerHost caps the requests in flight to a gateway,
+	// 8 by default.
+	MaxConcurrentRequestsPerHost int
+	// MaxRetries is how many times a request failing with a network error
+	// or a 429 or 5xx status is retried, 2 by default. A negative value
+	// disables retries.
+	MaxRetries int
+	// RequestTimeout bounds each request, 30s by default.
+	RequestTimeout time.Duration
+}
+
+// WithHTTPRetrieval makes sessions look for providers with router too, and
+// use those with /http or /https addresses as peers: their blocks are
+// fetched from the trustless gateway at that address, with ?format=raw
+// requests, and verified against their CID. Failed requests count as
+// DONT_HAVEs, and how fast gateways answer is tracked with the latency of
+// libp2p peers when choosing where to send want-blocks.
+func WithHTTPRetrieval(router HTTPProviderRouter, cfg HTTPRetrievalConfig) Option {
+	return func(bs *Client) {
+		bs.httpRouter = router
+		bs.httpConfig = cfg
+	}
+}
+
 type BlockReceivedNotifier interface {
 	// ReceivedBlocks notifies the decision engine that a peer is well-behaving
 	// and gave us useful data, potentially increasing its score and making us
@@ -141,34 +179,13 @@ func New(parent context.Context, network bsnet.BitSwapNetwork, bstore blockstore
 	pm := bspm.New(ctx, peerQueueFactory, network.Self())
 	pqm := bspqm.New(ctx, network)
 
-	sessionFactory := func(
-		sessctx context.Context,
-		sessmgr bssession.SessionManager,
-		id uint64,
-		spm bssession.SessionPeerManager,
-		sim *bssim.SessionInterestManager,
-		pm bssession.PeerManager,
-		bpm *bsbpm.BlockPresenceManager,
-		notif notifications.PubSub,
-		provSearchDelay time.Duration,
-		rebroadcastDelay delay.D,
-		self peer.ID,
-	) bssm.Session {
-		return bssession.New(sessctx, sessmgr, id, spm, pqm, sim, pm, bpm, notif, provSearchDelay, rebroadcastDelay, self)
-	}
-	sessionPeerManagerFactory := func(ctx context.Context, id uint64) bssession.SessionPeerManager {
-		return bsspm.New(id, network.ConnectionManager())
-	}
 	notif := notifications.New()
-	sm = bssm.New(ctx, sessionFactory, sim, sessionPeerManagerFactory, bpm, pm, notif, network.Self())
-
 	bs = &Client{
 		blockstore:                 bstore,
 		network:                    network,
 		process:                    px,
 		pm:                         pm,
 		pqm:                        pqm,
-		sm:                         sm,
 		sim:                        sim,
 		notif:                      notif,
 		counters:                   new(counters),
@@ -184,6 +201,48 @@ func New(parent context.Context, network bsnet.BitSwapNetwork, bstore blockstore
 		option(bs)
 	}
 
+	// Sessions see HTTP peers through the PeerManager and ProviderFinder
+	var sessionPm bssession.PeerManager = pm
+	var providerFinder bssession.ProviderFinder = pqm
+	if bs.httpRouter != nil {
+		fetcher := httpfetcher.New(httpfetcher.Config{
+			Client:                       bs.httpConfig.Client,
+			MaxConcurrentRequestsPerHost: bs.httpConfig.MaxConcurrentRequestsPerHost,
+			MaxRetries:                   bs.httpConfig.MaxRetries,
+			RequestTimeout:               bs.httpConfig.RequestTimeout,
+		})
+		sessionPm = httpfetcher.NewPeerManager(ctx, pm, fetcher, func(from peer.ID, blks []blocks.Block, dontHaves []cid.Cid) {
+			if len(blks) > 0 {
+				bs.updateReceiveCounters(blks)
+			}
+			if err := bs.receiveBlocksFrom(ctx, from, blks, nil, dontHaves); err != nil {
+				log.Warnf("receiveBlocksFrom HTTP peer error: %s", err)
+			}
+		})
+		providerFinder = httpfetcher.NewProviderFinder(pqm, bs.httpRouter, fetcher)
+	}
+
+	sessionFactory := func(
+		sessctx context.Context,
+		sessmgr bssession.SessionManager,
+		id uint64,
+		spm bssession.SessionPeerManager,
+		sim *bssim.SessionInterestManager,
+		pm bssession.PeerManager,
+		bpm *bsbpm.BlockPresenceManager,
+		notif notifications.PubSub,
+		provSearchDelay time.Duration,
+		rebroadcastDelay delay.D,
+		self peer.ID,
+	) bssm.Session {
+		return bssession.New(sessctx, sessmgr, id, spm, providerFinder, sim, pm, bpm, notif, provSearchDelay, rebroadcastDelay, self)
+	}
+	sessionPeerManagerFactory := func(ctx context.Context, id uint64) bssession.SessionPeerManager {
+		return bsspm.New(id, network.ConnectionManager())
+	}
+	sm = bssm.New(ctx, sessionFactory, sim, sessionPeerManagerFactory, bpm, sessionPm, notif, network.Self())
+	bs.sm = sm
+
 	bs.pqm.Startup()
 
 	// bind the context and process.
@@ -249,6 +308,10 @@ type Client struct {
 
 	// dupMetric will stay at 0
 	skipDuplicatedBlocksStats bool
+
+	// finds HTTP peers, HTTP retrieval is disabled if nil
+	httpRouter HTTPProviderRouter
+	httpConfig HTTPRetrievalConfig
 }
 
 type counters struct {
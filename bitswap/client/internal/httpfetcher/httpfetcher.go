@@ -0,0 +1,301 @@
+// Package httpfetcher retrieves blocks from trustless HTTP gateways, so that
+// bitswap sessions can use providers only reachable over HTTP as peers.
+package httpfetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/golang-lru/v2/simplelru"
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	logging "github.com/ipfs/go-log/v2"
+	peer "github.com/libp2p/go-libp2p/core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+var log = logging.Logger("bitswap/httpfetcher")
+
+const (
+	// DefaultMaxConcurrentRequestsPerHost is the default
+	// Config.MaxConcurrentRequestsPerHost.
+	DefaultMaxConcurrentRequestsPerHost = 8
+	// DefaultMaxRetries is the default Config.MaxRetries.
+	DefaultMaxRetries = 2
+	// DefaultRequestTimeout is the default Config.RequestTimeout.
+	DefaultRequestTimeout = 30 * time.Second
+
+	// maxBlockSize is the largest block accepted from a gateway, the limit
+	// bitswap peers apply to the blocks they send.
+	maxBlockSize = 2 << 20
+	// retryBackoff is the wait before the first retry, doubled each time.
+	retryBackoff = 100 * time.Millisecond
+	// maxPeers is the number of HTTP peers whose gateways are remembered,
+	// the least recently used ones are forgotten first.
+	maxPeers = 1024
+)
+
+// ErrNotFound is returned by Fetch when no gateway of the peer has the block.
+var ErrNotFound = errors.New("block not found on gateway")
+
+var errUnknownPeer = errors.New("peer has no HTTP endpoint")
+
+// Config tunes a Fetcher. Zero fields take their default value.
+type Config struct {
+	// Client sends the requests, http.DefaultClient if nil.
+	Client *http.Client
+	// MaxConcurrentRequestsPerHost caps the requests in flight to a host.
+	MaxConcurrentRequestsPerHost int
+	// MaxRetries is how many times a request failing with a network error
+	// or a 429 or 5xx status is retried, a negative value disables retries.
+	MaxRetries int
+	// RequestTimeout bounds each request.
+	RequestTimeout time.Duration
+}
+
+// Fetcher gets raw blocks from the gateways of HTTP peers.
+type Fetcher struct {
+	client         *http.Client
+	maxPerHost     int
+	maxRetries     int
+	requestTimeout time.Duration
+
+	lk        sync.Mutex
+	endpoints *simplelru.LRU[peer.ID, []*url.URL]
+	hosts     map[string]chan struct{}
+}
+
+// New creates a Fetcher.
+func New(cfg Config) *Fetcher {
+	endpoints, err := simplelru.NewLRU[peer.ID, []*url.URL](maxPeers, nil)
+	if err != nil {
+		panic(err)
+	}
+	f := &Fetcher{
+		client:         cfg.Client,
+		maxPerHost:     cfg.MaxConcurrentRequestsPerHost,
+		maxRetries:     cfg.MaxRetries,
+		requestTimeout: cfg.RequestTimeout,
+		endpoints:      endpoints,
+		hosts:          make(map[string]chan struct{}),
+	}
+	if f.client == nil {
+		f.client = http.DefaultClient
+	}
+	if f.maxPerHost <= 0 {
+		f.maxPerHost = DefaultMaxConcurrentRequestsPerHost
+	}
+	if f.maxRetries < 0 {
+		f.maxRetries = 0
+	} else if f.maxRetries == 0 {
+		f.maxRetries = DefaultMaxRetries
+	}
+	if f.requestTimeout <= 0 {
+		f.requestTimeout = DefaultRequestTimeout
+	}
+	return f
+}
+
+// AddPeer records the gateways among the addresses of ai. It returns false if
+// none of the addresses is an HTTP one. Only the gateways of the most
+// recently used peers are kept.
+func (f *Fetcher) AddPeer(ai peer.AddrInfo) bool {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+
+	endpoints, _ := f.endpoints.Get(ai.ID)
+	n := len(endpoints)
+	for _, a := range ai.Addrs {
+		u, ok := addrURL(a)
+		if !ok {
+			continue
+		}
+		known := false
+		for _, e := range endpoints {
+			if e.String() == u.String() {
+				known = true
+				break
+			}
+		}
+		if !known {
+			endpoints = append(endpoints, u)
+		}
+	}
+	if len(endpoints) > n {
+		f.endpoints.Add(ai.ID, endpoints)
+	}
+	return len(endpoints) > 0
+}
+
+// IsHTTPPeer returns true if p was added with at least one gateway.
+func (f *Fetcher) IsHTTPPeer(p peer.ID) bool {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	return f.endpoints.Contains(p)
+}
+
+// Fetch gets the block c from the gateways of p, trying each in turn until
+// one returns it. The block is checked against c before being returned.
+func (f *Fetcher) Fetch(ctx context.Context, p peer.ID, c cid.Cid) (blocks.Block, error) {
+	f.lk.Lock()
+	endpoints, _ := f.endpoints.Get(p)
+	f.lk.Unlock()
+
+	err := errUnknownPeer
+	for _, u := range endpoints {
+		var blk blocks.Block
+		blk, err = f.fetchFrom(ctx, u, c)
+		if err == nil {
+			return blk, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		log.Debugw("fetching block over HTTP", "peer", p, "endpoint", u, "cid", c, "error", err)
+	}
+	return nil, err
+}
+
+// fetchFrom gets c from the gateway at u, retrying transient failures.
+func (f *Fetcher) fetchFrom(ctx context.Context, u *url.URL, c cid.Cid) (blocks.Block, error) {
+	for attempt := 0; ; attempt++ {
+		blk, err := f.get(ctx, u, c)
+		var rerr *retryableError
+		if err == nil || !errors.As(err, &rerr) || attempt >= f.maxRetries {
+			return blk, err
+		}
+
+		timer := time.NewTimer(retryBackoff << attempt)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (f *Fetcher) get(ctx context.Context, u *url.URL, c cid.Cid) (blocks.Block, error) {
+	sem := f.hostSemaphore(u.Host)
+	select {
+	case sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-sem }()
+
+	ctx, cancel := context.WithTimeout(ctx, f.requestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.JoinPath("ipfs", c.String()).String()+"?format=raw", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.ipld.raw")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		return nil, &retryableError{err: err}
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return nil, ErrNotFound
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return nil, &retryableError{err: fmt.Errorf("unexpected status %d", resp.StatusCode)}
+	default:
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxBlockSize+1))
+	if err != nil {
+		return nil, &retryableError{err: err}
+	}
+	if len(data) > maxBlockSize {
+		return nil, fmt.Errorf("block larger than %d bytes", maxBlockSize)
+	}
+	got, err := c.Prefix().Sum(data)
+	if err != nil {
+		return nil, err
+	}
+	if !got.Equals(c) {
+		return nil, fmt.Errorf("block verification failed: got %s", got)
+	}
+	return blocks.NewBlockWithCid(data, c)
+}
+
+func (f *Fetcher) hostSemaphore(host string) chan struct{} {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+
+	sem, ok := f.hosts[host]
+	if !ok {
+		sem = make(chan struct{}, f.maxPerHost)
+		f.hosts[host] = sem
+	}
+	return sem
+}
+
+// retryableError is a failure that may not happen again.
+type retryableError struct {
+	err error
+}
+
+func (e *retryableError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryableError) Unwrap() error {
+	return e.err
+}
+
+// addrURL converts a multiaddr like /dns4/example.com/tcp/443/https or
+// /ip4/127.0.0.1/tcp/8080/http to the URL of the gateway.
+func addrURL(a ma.Multiaddr) (*url.URL, bool) {
+	var host, port, scheme string
+	var tls, ip6 bool
+	ma.ForEach(a, func(c ma.Component) bool {
+		switch c.Protocol().Code {
+		case ma.P_IP4, ma.P_DNS, ma.P_DNS4, ma.P_DNS6:
+			host = c.Value()
+		case ma.P_IP6:
+			host, ip6 = c.Value(), true
+		case ma.P_TCP:
+			port = c.Value()
+		case ma.P_TLS:
+			tls = true
+		case ma.P_HTTPS:
+			scheme = "https"
+		case ma.P_HTTP:
+			scheme = "http"
+			if tls {
+				scheme = "https"
+			}
+		default:
+			// SNI and other components are not needed to reach the gateway
+		}
+		return true
+	})
+	if host == "" || scheme == "" {
+		return nil, false
+	}
+	switch {
+	case port != "" && !(scheme == "http" && port == "80") && !(scheme == "https" && port == "443"):
+		host = net.JoinHostPort(host, port)
+	case ip6:
+		host = "[" + host + "]"
+	}
+	return &url.URL{Scheme: scheme, Host: host}, true
+}
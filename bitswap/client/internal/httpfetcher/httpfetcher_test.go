@@ -0,0 +1,424 @@
+package httpfetcher
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	bspm "github.com/ipfs/boxo/bitswap/client/internal/peermanager"
+	"github.com/ipfs/boxo/bitswap/internal/testutil"
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	peer "github.com/libp2p/go-libp2p/core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// gateway serves blocks like a trustless gateway.
+type gateway struct {
+	*httptest.Server
+	blocks map[string][]byte
+
+	// failures is the number of requests answered with 503 before serving
+	failures atomic.Int32
+	// delay is how long each request takes
+	delay time.Duration
+
+	lk          sync.Mutex
+	active      int
+	maxActive   int
+	requestsFor map[string]int
+}
+
+func newGateway(t *testing.T, blks ...blocks.Block) *gateway {
+	g := &gateway{
+		blocks:      make(map[string][]byte),
+		requestsFor: make(map[string]int),
+	}
+	for _, b := range blks {
+		g.blocks[b.Cid().String()] = b.RawData()
+	}
+	g.Server = httptest.NewServer(http.HandlerFunc(g.serve))
+	t.Cleanup(g.Close)
+	return g
+}
+
+func (g *gateway) serve(w http.ResponseWriter, r *http.Request) {
+	c := strings.TrimPrefix(r.URL.Path, "/ipfs/")
+	g.lk.Lock()
+	g.requestsFor[c]++
+	g.active++
+	if g.active > g.maxActive {
+		g.maxActive = g.active
+	}
+	g.lk.Unlock()
+	defer func() {
+		g.lk.Lock()
+		g.active--
+		g.lk.Unlock()
+	}()
+
+	time.Sleep(g.delay)
+	if r.URL.Query().Get("format") != "raw" || r.Header.Get("Accept") != "application/vnd.ipld.raw" {
+		http.Error(w, "not a raw block request", http.StatusBadRequest)
+		return
+	}
+	if g.failures.Add(-1) >= 0 {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	data, ok := g.blocks[c]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/vnd.ipld.raw")
+	_, _ = w.Write(data)
+}
+
+func (g *gateway) addrInfo(t *testing.T, p peer.ID) peer.AddrInfo {
+	u, err := url.Parse(g.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := ma.NewMultiaddr("/ip4/127.0.0.1/tcp/" + u.Port() + "/http")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return peer.AddrInfo{ID: p, Addrs: []ma.Multiaddr{a}}
+}
+
+func (g *gateway) requests(c cid.Cid) int {
+	g.lk.Lock()
+	defer g.lk.Unlock()
+	return g.requestsFor[c.String()]
+}
+
+func TestAddrURL(t *testing.T) {
+	for addr, expected := range map[string]string{
+		"/ip4/127.0.0.1/tcp/8080/http":                        "http://127.0.0.1:8080",
+		"/ip6/::1/tcp/8080/http":                              "http://[::1]:8080",
+		"/ip6/::1/tcp/443/tls/http":                           "https://[::1]",
+		"/dns4/example.com/tcp/443/https":                     "https://example.com",
+		"/dns/example.com/tcp/443/tls/http":                   "https://example.com",
+		"/dns6/example.com/tcp/80/http":                       "http://example.com",
+		"/ip4/127.0.0.1/tcp/4001":                             "",
+		"/ip4/127.0.0.1/udp/4001/quic-v1":                     "",
+		"/dns4/example.com/tcp/8443/tls/sni/example.com/http": "https://example.com:8443",
+	} {
+		u, ok := addrURL(ma.StringCast(addr))
+		if expected == "" {
+			if ok {
+				t.Fatalf("expected %s not to be an HTTP address, got %s", addr, u)
+			}
+			continue
+		}
+		if !ok {
+			t.Fatalf("expected %s to be an HTTP address", addr)
+		}
+		if u.String() != expected {
+			t.Fatalf("expected %s for %s, got %s", expected, addr, u)
+		}
+	}
+}
+
+func TestFetch(t *testing.T) {
+	ctx := context.Background()
+	blks := testutil.GenerateBlocksOfSize(2, 100)
+	p := testutil.GeneratePeers(1)[0]
+	g := newGateway(t, blks[0])
+
+	f := New(Config{})
+	if f.IsHTTPPeer(p) {
+		t.Fatal("expected peer to be unknown")
+	}
+	if _, err := f.Fetch(ctx, p, blks[0].Cid()); err == nil {
+		t.Fatal("expected error fetching from unknown peer")
+	}
+	if !f.AddPeer(g.addrInfo(t, p)) || !f.IsHTTPPeer(p) {
+		t.Fatal("expected peer to be an HTTP peer")
+	}
+	if f.AddPeer(peer.AddrInfo{ID: "other", Addrs: []ma.Multiaddr{ma.StringCast("/ip4/127.0.0.1/tcp/4001")}}) {
+		t.Fatal("expected peer without HTTP address to be ignored")
+	}
+
+	blk, err := f.Fetch(ctx, p, blks[0].Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !blk.Cid().Equals(blks[0].Cid()) || string(blk.RawData()) != string(blks[0].RawData()) {
+		t.Fatal("got wrong block")
+	}
+
+	if _, err := f.Fetch(ctx, p, blks[1].Cid()); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestFetchVerifiesBlocks(t *testing.T) {
+	blks := testutil.GenerateBlocksOfSize(2, 100)
+	p := testutil.GeneratePeers(1)[0]
+	g := newGateway(t)
+	// Serve the data of another block
+	g.blocks[blks[0].Cid().String()] = blks[1].RawData()
+
+	f := New(Config{})
+	f.AddPeer(g.addrInfo(t, p))
+	if _, err := f.Fetch(context.Background(), p, blks[0].Cid()); err == nil {
+		t.Fatal("expected block verification to fail")
+	}
+	if g.requests(blks[0].Cid()) != 1 {
+		t.Fatal("expected corrupted block not to be requested again")
+	}
+}
+
+func TestFetchRetries(t *testing.T) {
+	ctx := context.Background()
+	blks := testutil.GenerateBlocksOfSize(1, 100)
+	p := testutil.GeneratePeers(1)[0]
+	g := newGateway(t, blks...)
+
+	g.failures.Store(2)
+	f := New(Config{MaxRetries: 2})
+	f.AddPeer(g.addrInfo(t, p))
+	if _, err := f.Fetch(ctx, p, blks[0].Cid()); err != nil {
+		t.Fatal(err)
+	}
+	if g.requests(blks[0].Cid()) != 3 {
+		t.Fatalf("expected 3 requests, got %d", g.requests(blks[0].Cid()))
+	}
+
+	g.failures.Store(1)
+	f = New(Config{MaxRetries: -1})
+	f.AddPeer(g.addrInfo(t, p))
+	if _, err := f.Fetch(ctx, p, blks[0].Cid()); err == nil {
+		t.Fatal("expected error without retries")
+	}
+}
+
+func TestFetchLimitsConcurrencyPerHost(t *testing.T) {
+	blks := testutil.GenerateBlocksOfSize(10, 100)
+	p := testutil.GeneratePeers(1)[0]
+	g := newGateway(t, blks...)
+	g.delay = 20 * time.Millisecond
+
+	f := New(Config{MaxConcurrentRequestsPerHost: 3})
+	f.AddPeer(g.addrInfo(t, p))
+
+	var wg sync.WaitGroup
+	for _, b := range blks {
+		wg.Add(1)
+		go func(c cid.Cid) {
+			defer wg.Done()
+			if _, err := f.Fetch(context.Background(), p, c); err != nil {
+				t.Error(err)
+			}
+		}(b.Cid())
+	}
+	wg.Wait()
+
+	if g.maxActive > 3 {
+		t.Fatalf("expected at most 3 concurrent requests, got %d", g.maxActive)
+	}
+}
+
+type fakePeerManager struct {
+	lk        sync.Mutex
+	connected map[peer.ID]bool
+	wants     map[peer.ID][]cid.Cid
+	cancelled []cid.Cid
+}
+
+func (pm *fakePeerManager) IsConnected(p peer.ID) bool {
+	pm.lk.Lock()
+	defer pm.lk.Unlock()
+	return pm.connected[p]
+}
+
+func (*fakePeerManager) RegisterSession(peer.ID, bspm.Session)         {}
+func (*fakePeerManager) UnregisterSession(uint64)                      {}
+func (*fakePeerManager) BroadcastWantHaves(context.Context, []cid.Cid) {}
+func (pm *fakePeerManager) SendWants(_ context.Context, p peer.ID, wantBlocks []cid.Cid, wantHaves []cid.Cid) {
+	pm.lk.Lock()
+	defer pm.lk.Unlock()
+	pm.wants[p] = append(pm.wants[p], wantBlocks...)
+	pm.wants[p] = append(pm.wants[p], wantHaves...)
+}
+
+func (pm *fakePeerManager) SendCancels(_ context.Context, cancelKs []cid.Cid) {
+	pm.lk.Lock()
+	defer pm.lk.Unlock()
+	pm.cancelled = append(pm.cancelled, cancelKs...)
+}
+
+type response struct {
+	from      peer.ID
+	blks      []blocks.Block
+	dontHaves []cid.Cid
+}
+
+func TestPeerManager(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	blks := testutil.GenerateBlocksOfSize(3, 100)
+	peers := testutil.GeneratePeers(3)
+	httpPeer, libp2pPeer, bothPeer := peers[0], peers[1], peers[2]
+	g := newGateway(t, blks[0])
+	f := New(Config{})
+	f.AddPeer(g.addrInfo(t, httpPeer))
+	f.AddPeer(g.addrInfo(t, bothPeer))
+
+	responses := make(chan response, 2)
+	fpm := &fakePeerManager{
+		connected: map[peer.ID]bool{libp2pPeer: true, bothPeer: true},
+		wants:     make(map[peer.ID][]cid.Cid),
+	}
+	pm := NewPeerManager(ctx, fpm, f, func(from peer.ID, blks []blocks.Block, dontHaves []cid.Cid) {
+		responses <- response{from, blks, dontHaves}
+	})
+
+	pm.SendWants(ctx, libp2pPeer, []cid.Cid{blks[0].Cid()}, nil)
+	pm.SendWants(ctx, bothPeer, []cid.Cid{blks[0].Cid()}, nil)
+	pm.SendWants(ctx, httpPeer, []cid.Cid{blks[0].Cid(), blks[1].Cid()}, []cid.Cid{blks[2].Cid()})
+
+	var gotBlock, gotDontHave bool
+	for i := 0; i < 2; i++ {
+		select {
+		case r := <-responses:
+			if r.from != httpPeer {
+				t.Fatal("expected response from HTTP peer")
+			}
+			if len(r.blks) == 1 && r.blks[0].Cid().Equals(blks[0].Cid()) {
+				gotBlock = true
+			}
+			if len(r.dontHaves) == 1 && r.dontHaves[0].Equals(blks[1].Cid()) {
+				gotDontHave = true
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for responses")
+		}
+	}
+	if !gotBlock || !gotDontHave {
+		t.Fatal("expected a block and a DONT_HAVE")
+	}
+	if g.requests(blks[2].Cid()) != 0 {
+		t.Fatal("expected want-have not to be sent to gateway")
+	}
+	if g.requests(blks[0].Cid()) != 1 {
+		t.Fatal("expected wants to connected HTTP peers not to be sent to gateway")
+	}
+
+	fpm.lk.Lock()
+	defer fpm.lk.Unlock()
+	if len(fpm.wants[libp2pPeer]) != 1 || len(fpm.wants[bothPeer]) != 1 || len(fpm.wants[httpPeer]) != 0 {
+		t.Fatal("expected only wants to libp2p peers to be passed on")
+	}
+}
+
+func TestFetcherForgetsLeastRecentlyUsedPeers(t *testing.T) {
+	g := newGateway(t)
+	f := New(Config{})
+	peers := testutil.GeneratePeers(maxPeers + 1)
+	for _, p := range peers {
+		f.AddPeer(g.addrInfo(t, p))
+	}
+
+	if f.IsHTTPPeer(peers[0]) {
+		t.Fatal("expected least recently used peer to be forgotten")
+	}
+	for _, p := range peers[1:] {
+		if !f.IsHTTPPeer(p) {
+			t.Fatal("expected most recently used peers to be kept")
+		}
+	}
+}
+
+func TestPeerManagerCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	blks := testutil.GenerateBlocksOfSize(1, 100)
+	p := testutil.GeneratePeers(1)[0]
+	g := newGateway(t, blks...)
+	g.delay = 200 * time.Millisecond
+	f := New(Config{})
+	f.AddPeer(g.addrInfo(t, p))
+
+	responses := make(chan response, 1)
+	fpm := &fakePeerManager{wants: make(map[peer.ID][]cid.Cid)}
+	pm := NewPeerManager(ctx, fpm, f, func(from peer.ID, blks []blocks.Block, dontHaves []cid.Cid) {
+		responses <- response{from, blks, dontHaves}
+	})
+
+	pm.SendWants(ctx, p, []cid.Cid{blks[0].Cid()}, nil)
+	time.Sleep(50 * time.Millisecond)
+	pm.SendCancels(ctx, []cid.Cid{blks[0].Cid()})
+
+	select {
+	case <-responses:
+		t.Fatal("expected no response for cancelled want")
+	case <-time.After(400 * time.Millisecond):
+	}
+	fpm.lk.Lock()
+	defer fpm.lk.Unlock()
+	if len(fpm.cancelled) != 1 {
+		t.Fatal("expected cancels to be passed on")
+	}
+}
+
+type fakeProviderFinder struct {
+	providers []peer.ID
+}
+
+func (pf *fakeProviderFinder) FindProvidersAsync(ctx context.Context, k cid.Cid) <-chan peer.ID {
+	ch := make(chan peer.ID, len(pf.providers))
+	for _, p := range pf.providers {
+		ch <- p
+	}
+	close(ch)
+	return ch
+}
+
+type fakeRouter struct {
+	providers []peer.AddrInfo
+}
+
+func (r *fakeRouter) FindProvidersAsync(ctx context.Context, k cid.Cid, max int) <-chan peer.AddrInfo {
+	ch := make(chan peer.AddrInfo, len(r.providers))
+	for _, ai := range r.providers {
+		ch <- ai
+	}
+	close(ch)
+	return ch
+}
+
+func TestProviderFinder(t *testing.T) {
+	peers := testutil.GeneratePeers(3)
+	g := newGateway(t)
+	f := New(Config{})
+	pf := NewProviderFinder(
+		&fakeProviderFinder{providers: peers[:1]},
+		&fakeRouter{providers: []peer.AddrInfo{
+			g.addrInfo(t, peers[1]),
+			{ID: peers[2], Addrs: []ma.Multiaddr{ma.StringCast("/ip4/127.0.0.1/tcp/4001")}},
+		}},
+		f,
+	)
+
+	var found []peer.ID
+	for p := range pf.FindProvidersAsync(context.Background(), testutil.GenerateCids(1)[0]) {
+		found = append(found, p)
+	}
+	if !testutil.MatchPeersIgnoreOrder(found, peers[:2]) {
+		t.Fatalf("expected libp2p and HTTP providers, got %s", found)
+	}
+	if !f.IsHTTPPeer(peers[1]) || f.IsHTTPPeer(peers[2]) {
+		t.Fatal("expected only the provider with an HTTP address to be an HTTP peer")
+	}
+}
@@ -0,0 +1,132 @@
+package httpfetcher
+
+import (
+	"context"
+	"sync"
+
+	bssession "github.com/ipfs/boxo/bitswap/client/internal/session"
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	peer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+// ResponseFunc is called with the outcome of the want-blocks sent to an HTTP
+// peer: the blocks it returned, and the CIDs it failed to return as
+// DONT_HAVEs.
+type ResponseFunc func(from peer.ID, blks []blocks.Block, dontHaves []cid.Cid)
+
+// ConnectedPeerManager is the PeerManager of the sessions, which knows the
+// peers connected over libp2p.
+type ConnectedPeerManager interface {
+	bssession.PeerManager
+	// IsConnected returns whether p is connected over libp2p.
+	IsConnected(p peer.ID) bool
+}
+
+// PeerManager wraps the PeerManager of the sessions. It turns the
+// want-blocks sent to HTTP peers which are not connected over libp2p into
+// requests to a Fetcher, and passes everything else to the wrapped
+// PeerManager.
+type PeerManager struct {
+	ConnectedPeerManager
+
+	ctx        context.Context
+	fetcher    *Fetcher
+	onResponse ResponseFunc
+
+	lk       sync.Mutex
+	inflight map[cid.Cid]map[peer.ID]*fetch
+}
+
+type fetch struct {
+	cancel context.CancelFunc
+}
+
+// NewPeerManager creates a PeerManager whose requests run until ctx is
+// cancelled.
+func NewPeerManager(ctx context.Context, pm ConnectedPeerManager, f *Fetcher, onResponse ResponseFunc) *PeerManager {
+	return &PeerManager{
+		ConnectedPeerManager: pm,
+		ctx:                  ctx,
+		fetcher:              f,
+		onResponse:           onResponse,
+		inflight:             make(map[cid.Cid]map[peer.ID]*fetch),
+	}
+}
+
+// SendWants fetches the want-blocks of HTTP peers, or sends the wants to
+// peers connected over libp2p, including the HTTP peers which are.
+// Want-haves are not sent to HTTP peers: asking a gateway whether it has a
+// block costs about as much as fetching it.
+func (pm *PeerManager) SendWants(ctx context.Context, p peer.ID, wantBlocks []cid.Cid, wantHaves []cid.Cid) {
+	if pm.IsConnected(p) || !pm.fetcher.IsHTTPPeer(p) {
+		pm.ConnectedPeerManager.SendWants(ctx, p, wantBlocks, wantHaves)
+		return
+	}
+	for _, c := range wantBlocks {
+		pm.fetch(p, c)
+	}
+}
+
+// SendCancels aborts the requests for the CIDs and sends the cancels to
+// other peers.
+func (pm *PeerManager) SendCancels(ctx context.Context, cancelKs []cid.Cid) {
+	pm.lk.Lock()
+	for _, c := range cancelKs {
+		for _, f := range pm.inflight[c] {
+			f.cancel()
+		}
+		delete(pm.inflight, c)
+	}
+	pm.lk.Unlock()
+
+	pm.ConnectedPeerManager.SendCancels(ctx, cancelKs)
+}
+
+func (pm *PeerManager) fetch(p peer.ID, c cid.Cid) {
+	pm.lk.Lock()
+	defer pm.lk.Unlock()
+
+	// The session may ask again for a block that is already being fetched
+	if _, ok := pm.inflight[c][p]; ok {
+		return
+	}
+	ctx, cancel := context.WithCancel(pm.ctx)
+	f := &fetch{cancel: cancel}
+	if pm.inflight[c] == nil {
+		pm.inflight[c] = make(map[peer.ID]*fetch)
+	}
+	pm.inflight[c][p] = f
+
+	go func() {
+		defer pm.done(p, c, f)
+
+		blk, err := pm.fetcher.Fetch(ctx, p, c)
+		if ctx.Err() != nil {
+			// Cancelled, the block is no longer wanted
+			return
+		}
+		if err != nil {
+			log.Debugw("HTTP peer did not return block", "peer", p, "cid", c, "error", err)
+			pm.onResponse(p, nil, []cid.Cid{c})
+			return
+		}
+		pm.onResponse(p, []blocks.Block{blk}, nil)
+	}()
+}
+
+func (pm *PeerManager) done(p peer.ID, c cid.Cid, f *fetch) {
+	f.cancel()
+
+	pm.lk.Lock()
+	defer pm.lk.Unlock()
+
+	// The fetch may have been cancelled and replaced by a newer one
+	if pm.inflight[c][p] != f {
+		return
+	}
+	delete(pm.inflight[c], p)
+	if len(pm.inflight[c]) == 0 {
+		delete(pm.inflight, c)
+	}
+}
@@ -0,0 +1,77 @@
+package httpfetcher
+
+import (
+	"context"
+	"sync"
+
+	bssession "github.com/ipfs/boxo/bitswap/client/internal/session"
+	cid "github.com/ipfs/go-cid"
+	peer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+// maxProviders is the number of providers asked from the router per search.
+const maxProviders = 10
+
+// ProviderRouter finds the providers of a CID along with their addresses,
+// like the delegated routing client of routing/http does.
+type ProviderRouter interface {
+	FindProvidersAsync(context.Context, cid.Cid, int) <-chan peer.AddrInfo
+}
+
+// ProviderFinder adds the HTTP peers found by a ProviderRouter to the
+// providers found by the wrapped ProviderFinder of the sessions.
+type ProviderFinder struct {
+	bssession.ProviderFinder
+
+	router  ProviderRouter
+	fetcher *Fetcher
+}
+
+// NewProviderFinder creates a ProviderFinder. The HTTP peers it finds are
+// added to f.
+func NewProviderFinder(pf bssession.ProviderFinder, router ProviderRouter, f *Fetcher) *ProviderFinder {
+	return &ProviderFinder{
+		ProviderFinder: pf,
+		router:         router,
+		fetcher:        f,
+	}
+}
+
+// FindProvidersAsync searches for peers that provide k, among libp2p peers and
+// HTTP gateways.
+func (pf *ProviderFinder) FindProvidersAsync(ctx context.Context, k cid.Cid) <-chan peer.ID {
+	out := make(chan peer.ID)
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+		for p := range pf.ProviderFinder.FindProvidersAsync(ctx, k) {
+			select {
+			case out <- p:
+			case <-ctx.Done():
+			}
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+		// Providers reachable over libp2p are found by the wrapped
+		// ProviderFinder, which also connects to them
+		for ai := range pf.router.FindProvidersAsync(ctx, k, maxProviders) {
+			if !pf.fetcher.AddPeer(ai) {
+				continue
+			}
+			select {
+			case out <- ai.ID:
+			case <-ctx.Done():
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
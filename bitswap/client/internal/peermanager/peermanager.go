@@ -82,6 +82,15 @@ func (pm *PeerManager) ConnectedPeers() []peer.ID {
 	return peers
 }
 
+// IsConnected returns whether p is in the pool.
+func (pm *PeerManager) IsConnected(p peer.ID) bool {
+	pm.pqLk.RLock()
+	defer pm.pqLk.RUnlock()
+
+	_, ok := pm.peerQueues[p]
+	return ok
+}
+
 // Connected is called to add a new peer to the pool, and send it an initial set
 // of wants.
 func (pm *PeerManager) Connected(p peer.ID) {
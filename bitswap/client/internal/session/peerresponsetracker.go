@@ -3,19 +3,32 @@ package session
 import (
 	"fmt"
 	"math/rand"
+	"time"
 
 	peer "github.com/libp2p/go-libp2p/core/peer"
 )
 
+const (
+	// latencyAlpha is the alpha of the EWMA of the latency of each peer
+	latencyAlpha = 0.3
+	// maxLatencyFactor bounds how much more (or less) likely to be chosen a
+	// peer is made by its latency
+	maxLatencyFactor = 4.0
+)
+
 // peerResponseTracker keeps track of how many times each peer was the first
-// to send us a block for a given CID (used to rank peers)
+// to send us a block for a given CID, and of how fast each peer sends a block
+// after a want-block (used to rank peers). Peers reached over libp2p and
+// over HTTP are tracked alike.
 type peerResponseTracker struct {
 	firstResponder map[peer.ID]int
+	latency        map[peer.ID]time.Duration
 }
 
 func newPeerResponseTracker() *peerResponseTracker {
 	return &peerResponseTracker{
 		firstResponder: make(map[peer.ID]int),
+		latency:        make(map[peer.ID]time.Duration),
 	}
 }
 
@@ -27,8 +40,20 @@ func (prt *peerResponseTracker) receivedBlockFrom(from peer.ID) {
 	fmt.Println("Received Block response from: ", from)
 }
 
+// receivedBlockLatency is called when a peer sends a block in response to a
+// want-block we sent it, with the time elapsed since the want-block was sent
+func (prt *peerResponseTracker) receivedBlockLatency(from peer.ID, elapsed time.Duration) {
+	lat, ok := prt.latency[from]
+	if !ok {
+		prt.latency[from] = elapsed
+		return
+	}
+	prt.latency[from] = time.Duration(latencyAlpha*float64(elapsed) + (1-latencyAlpha)*float64(lat))
+}
+
 // choose picks a peer from the list of candidate peers, favouring those peers
-// that were first to send us previous blocks
+// that were first to send us previous blocks, and those that are faster than
+// the other candidates
 func (prt *peerResponseTracker) choose(peers []peer.ID) peer.ID {
 	if len(peers) == 0 {
 		return ""
@@ -36,10 +61,24 @@ func (prt *peerResponseTracker) choose(peers []peer.ID) peer.ID {
 
 	rnd := rand.Float64()
 
-	// Find the total received blocks for all candidate peers
-	total := 0
+	// Find the average latency of the candidate peers
+	var totalLatency time.Duration
+	withLatency := 0
+	for _, p := range peers {
+		if lat, ok := prt.latency[p]; ok && lat > 0 {
+			totalLatency += lat
+			withLatency++
+		}
+	}
+	var avgLatency time.Duration
+	if withLatency > 0 {
+		avgLatency = totalLatency / time.Duration(withLatency)
+	}
+
+	// Find the total weight of all candidate peers
+	total := 0.0
 	for _, p := range peers {
-		total += prt.getPeerCount(p)
+		total += prt.getPeerWeight(p, avgLatency)
 	}
 
 	// Choose one of the peers with a chance proportional to the number
@@ -48,7 +87,7 @@ func (prt *peerResponseTracker) choose(peers []peer.ID) peer.ID {
 	fmt.Println("Choosing best peer...")
 	for _, p := range peers {
 		peerCount := prt.getPeerCount(p)
-		peerProbability := float64(prt.getPeerCount(p)) / float64(total)
+		peerProbability := prt.getPeerWeight(p, avgLatency) / total
 
 		fmt.Println("Peer: ", p, ", Peer Count: ", peerCount, ", Total: ", total, ", Peer Probability: ", peerProbability, ", Cumulative: ", counted + peerProbability, ", Threshold: ", rnd)
 
@@ -73,3 +112,20 @@ func (prt *peerResponseTracker) getPeerCount(p peer.ID) int {
 	// will be chosen
 	return prt.firstResponder[p] + 1
 }
+
+// getPeerWeight returns the peer count scaled by how much faster than the
+// average the peer is. Peers with unknown latency are deemed average.
+func (prt *peerResponseTracker) getPeerWeight(p peer.ID, avgLatency time.Duration) float64 {
+	weight := float64(prt.getPeerCount(p))
+	lat, ok := prt.latency[p]
+	if !ok || lat <= 0 || avgLatency <= 0 {
+		return weight
+	}
+	factor := float64(avgLatency) / float64(lat)
+	if factor > maxLatencyFactor {
+		factor = maxLatencyFactor
+	} else if factor < 1/maxLatencyFactor {
+		factor = 1 / maxLatencyFactor
+	}
+	return weight * factor
+}
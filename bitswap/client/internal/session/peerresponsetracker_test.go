@@ -3,6 +3,7 @@ package session
 import (
 	"math"
 	"testing"
+	"time"
 
 	"github.com/ipfs/boxo/bitswap/internal/testutil"
 	peer "github.com/libp2p/go-libp2p/core/peer"
@@ -115,3 +116,42 @@ func TestPeerResponseTrackerProbabilityProportional(t *testing.T) {
 		}
 	}
 }
+
+func TestPeerResponseTrackerFavoursLowLatency(t *testing.T) {
+	peers := testutil.GeneratePeers(3)
+	prt := newPeerResponseTracker()
+
+	prt.receivedBlockLatency(peers[0], 10*time.Millisecond)
+	prt.receivedBlockLatency(peers[1], 40*time.Millisecond)
+
+	// The peers are as likely to be chosen, but for their latency: with an
+	// average of 25ms the first peer is 2.5 times more likely to be chosen,
+	// and the second peer 0.625 times.
+	chooseFirst := 0
+	count := 1000
+	for i := 0; i < count; i++ {
+		if prt.choose(peers[:2]) == peers[0] {
+			chooseFirst++
+		}
+	}
+	if math.Abs(float64(chooseFirst)-0.8*float64(count)) > 0.1*float64(count) {
+		t.Fatalf("expected low latency peer to be chosen about 80%% of the time, got %d/%d", chooseFirst, count)
+	}
+
+	// Peers with unknown latency are deemed average
+	chooseUnknown := 0
+	for i := 0; i < count; i++ {
+		if prt.choose([]peer.ID{peers[1], peers[2]}) == peers[2] {
+			chooseUnknown++
+		}
+	}
+	if math.Abs(float64(chooseUnknown)-0.5*float64(count)) > 0.1*float64(count) {
+		t.Fatalf("expected unknown latency peer to be chosen about 50%% of the time, got %d/%d", chooseUnknown, count)
+	}
+
+	// The latency is smoothed
+	prt.receivedBlockLatency(peers[0], 110*time.Millisecond)
+	if lat := prt.latency[peers[0]]; lat != 40*time.Millisecond {
+		t.Fatalf("expected smoothed latency of 40ms, got %s", lat)
+	}
+}
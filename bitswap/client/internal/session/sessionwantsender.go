@@ -1,8 +1,9 @@
 package session
 
 import (
-	"fmt"
 	"context"
+	"fmt"
+	"time"
 
 	bsbpm "github.com/ipfs/boxo/bitswap/client/internal/blockpresencemanager"
 
@@ -384,6 +385,12 @@ func (sws *sessionWantSender) processUpdates(updates []update) []cid.Cid {
 				// us the block
 				sws.peerRspTrkr.receivedBlockFrom(upd.from)
 
+				// If the block answers the want-block we sent the peer, record
+				// how long the peer took
+				if removed.sentTo == upd.from && !removed.sentAt.IsZero() {
+					sws.peerRspTrkr.receivedBlockLatency(upd.from, time.Since(removed.sentAt))
+				}
+
 				// Protect the connection to this peer so that we can ensure
 				// that the connection doesn't get pruned by the connection
 				// manager
@@ -700,6 +707,9 @@ func (sws *sessionWantSender) getWantSentTo(c cid.Cid) (peer.ID, bool) {
 func (sws *sessionWantSender) setWantSentTo(c cid.Cid, p peer.ID) {
 	if wi, ok := sws.wants[c]; ok {
 		wi.sentTo = p
+		if p != "" {
+			wi.sentAt = time.Now()
+		}
 	}
 }
 
@@ -709,6 +719,8 @@ type wantInfo struct {
 	blockPresence map[peer.ID]BlockPresence
 	// The peer that we've sent a want-block to (cleared when we get a response)
 	sentTo peer.ID
+	// When the want-block was sent to sentTo
+	sentAt time.Time
 	// The "best" peer to send the want to next
 	bestPeer peer.ID
 	// Keeps track of how many hits / misses each peer has sent us for wants
@@ -44,3 +44,11 @@ func PendingBlocksGauge(ctx context.Context) metrics.Gauge {
 func ActiveBlocksGauge(ctx context.Context) metrics.Gauge {
 	return metrics.NewCtx(ctx, "active_block_tasks", "Total number of active blockstore tasks").Gauge()
 }
+
+func ThrottledWantsCounter(ctx context.Context) metrics.Counter {
+	return metrics.NewCtx(ctx, "throttled_wants_total", "Total number of wantlist entries dropped by the rate limits").Counter()
+}
+
+func ThrottledSendsCounter(ctx context.Context) metrics.Counter {
+	return metrics.NewCtx(ctx, "throttled_messages_total", "Total number of messages delayed by the rate limits").Counter()
+}
@@ -67,6 +67,10 @@ func WithTaskComparator(comparator server.TaskComparator) Option {
 	return Option{server.WithTaskComparator(comparator)}
 }
 
+func WithRateLimits(limits server.RateLimits) Option {
+	return Option{server.WithRateLimits(limits)}
+}
+
 func ProviderSearchDelay(newProvSearchDelay time.Duration) Option {
 	return Option{client.ProviderSearchDelay(newProvSearchDelay)}
 }
@@ -79,6 +83,10 @@ func SetSimulateDontHavesOnTimeout(send bool) Option {
 	return Option{client.SetSimulateDontHavesOnTimeout(send)}
 }
 
+func WithHTTPRetrieval(router client.HTTPProviderRouter, cfg client.HTTPRetrievalConfig) Option {
+	return Option{client.WithHTTPRetrieval(router, cfg)}
+}
+
 func WithTracer(tap tracer.Tracer) Option {
 	// Only trace the server, both receive the same messages anyway
 	return Option{
@@ -189,6 +189,9 @@ type Engine struct {
 
 	maxQueuedWantlistEntriesPerPeer uint
 	maxCidSize                      uint
+
+	rateLimits  RateLimits
+	rateLimiter *rateLimiter
 }
 
 // TaskInfo represents the details of a request from a peer.
@@ -288,6 +291,14 @@ func WithMaxCidSize(n uint) Option {
 	}
 }
 
+// WithRateLimits limits the rates at which wants are accepted from peers and
+// messages are sent to them.
+func WithRateLimits(limits RateLimits) Option {
+	return func(e *Engine) {
+		e.rateLimits = limits
+	}
+}
+
 func WithSetSendDontHave(send bool) Option {
 	return func(e *Engine) {
 		e.sendDontHaves = send
@@ -374,6 +385,7 @@ func newEngine(
 	}
 
 	e.bsm = newBlockstoreManager(bs, e.bstoreWorkerCount, bmetrics.PendingBlocksGauge(ctx), bmetrics.ActiveBlocksGauge(ctx))
+	e.rateLimiter = newRateLimiter(e.rateLimits, bmetrics.ThrottledWantsCounter(ctx), bmetrics.ThrottledSendsCounter(ctx))
 
 	// default peer task queue options
 	peerTaskQueueOpts := []peertaskqueue.Option{
@@ -594,6 +606,14 @@ func (e *Engine) nextEnvelope(ctx context.Context) (*Envelope, error) {
 			continue
 		}
 
+		// Put the tasks of a throttled peer back in the queue once its rate
+		// limits allow it, rather than blocking the worker.
+		if delay := e.rateLimiter.reserveSend(p, msg.Size()); delay > 0 {
+			e.peerRequestQueue.TasksDone(p, nextTasks...)
+			e.requeueTasks(p, nextTasks, delay)
+			continue
+		}
+
 		log.Debugw("Bitswap engine -> msg", "local", e.self, "to", p, "blockCount", len(msg.Blocks()), "presenceCount", len(msg.BlockPresences()), "size", msg.Size())
 		return &Envelope{
 			Peer:    p,
@@ -610,6 +630,29 @@ func (e *Engine) nextEnvelope(ctx context.Context) (*Envelope, error) {
 	}
 }
 
+// requeueTasks pushes the tasks of p back in the request queue after the
+// given delay, unless they were cancelled meanwhile.
+func (e *Engine) requeueTasks(p peer.ID, tasks []*peertask.Task, delay time.Duration) {
+	time.AfterFunc(delay, func() {
+		e.lock.RLock()
+		wanted := make([]peertask.Task, 0, len(tasks))
+		for _, t := range tasks {
+			td := t.Data.(*taskData)
+			// DONT_HAVEs of denied blocks are not in the ledger.
+			if td.HaveBlock && !e.peerLedger.IsWanted(p, t.Topic.(cid.Cid)) {
+				continue
+			}
+			wanted = append(wanted, *t)
+		}
+		e.lock.RUnlock()
+
+		if len(wanted) > 0 {
+			e.peerRequestQueue.PushTasks(p, wanted...)
+			e.signalNewWork()
+		}
+	})
+}
+
 // Outbox returns a channel of one-time use Envelope channels.
 func (e *Engine) Outbox() <-chan (<-chan *Envelope) {
 	return e.outbox
@@ -656,6 +699,7 @@ func (e *Engine) MessageReceived(ctx context.Context, p peer.ID, m bsmsg.BitSwap
 	// Dispatch entries
 	wants, cancels := e.splitWantsCancels(entries)
 	wants, denials := e.splitWantsDenials(p, wants)
+	wants, throttled := e.rateLimiter.limitWants(p, wants)
 
 	// Get block sizes
 	wantKs := cid.NewSet()
@@ -760,6 +804,12 @@ func (e *Engine) MessageReceived(ctx context.Context, p peer.ID, m bsmsg.BitSwap
 		sendDontHave(entry)
 	}
 
+	// Answer the wants over the rate limits
+	for _, entry := range throttled {
+		log.Debugw("Bitswap engine: want throttled", "local", e.self, "from", p, "cid", entry.Cid, "sendDontHave", entry.SendDontHave)
+		sendDontHave(entry)
+	}
+
 	// For each want-have / want-block
 	for _, entry := range wants {
 		c := entry.Cid
@@ -953,6 +1003,7 @@ func (e *Engine) PeerDisconnected(p peer.ID) {
 
 	e.peerLedger.PeerDisconnected(p)
 	e.scoreLedger.PeerDisconnected(p)
+	e.rateLimiter.peerDisconnected(p)
 }
 
 // If the want is a want-have, and it's below a certain size, send the full
@@ -1733,3 +1733,168 @@ func TestKillConnectionForInlineCid(t *testing.T) {
 		t.Fatal("connection was not killed when receiving inline in cancel")
 	}
 }
+
+func TestRateLimitsWants(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	riga := libp2ptest.RandPeerIDFatal(t)
+	oslo := libp2ptest.RandPeerIDFatal(t)
+	vilnius := libp2ptest.RandPeerIDFatal(t)
+	warsaw := newTestEngine(ctx, "warsaw", WithRateLimits(RateLimits{
+		WantsPerSecond:     15,
+		PeerWantsPerSecond: 10,
+		Allowlist:          []peer.ID{vilnius},
+	}))
+
+	wantBlocks := func(p peer.ID, prefix string, n int) {
+		m := message.New(false)
+		for i := 0; i < n; i++ {
+			m.AddEntry(blocks.NewBlock([]byte(fmt.Sprint(prefix, i))).Cid(), 0, pb.Message_Wantlist_Block, true)
+		}
+		warsaw.Engine.MessageReceived(ctx, p, m)
+	}
+
+	// The peer limit applies first, then the global one.
+	wantBlocks(riga, "riga", 20)
+	if wl := warsaw.Engine.WantlistForPeer(riga); len(wl) != 10 {
+		t.Fatal("wantlist does not match the peer rate limit", len(wl))
+	}
+	wantBlocks(oslo, "oslo", 20)
+	if wl := warsaw.Engine.WantlistForPeer(oslo); len(wl) != 5 {
+		t.Fatal("wantlist does not match the global rate limit", len(wl))
+	}
+
+	// The wants rejected by the global limit don't use the tokens of the peer.
+	if tokens := warsaw.Engine.rateLimiter.peer(oslo).wants.Tokens(); tokens < 4.5 {
+		t.Fatal("wants rejected by the global rate limit used the tokens of the peer", tokens)
+	}
+
+	// Allowlisted peers are not limited.
+	wantBlocks(vilnius, "vilnius", 20)
+	if wl := warsaw.Engine.WantlistForPeer(vilnius); len(wl) != 20 {
+		t.Fatal("allowlisted peer was rate limited", len(wl))
+	}
+}
+
+func TestRateLimitsWantsDontHave(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	const wantCount = 10
+	var keys []string
+	var blks []blocks.Block
+	for i := 0; i < wantCount; i++ {
+		keys = append(keys, fmt.Sprint("block", i))
+		blks = append(blks, blocks.NewBlock([]byte(keys[i])))
+	}
+	warsaw := newTestEngine(ctx, "warsaw", WithRateLimits(RateLimits{PeerWantsPerSecond: wantCount / 2}))
+	if err := warsaw.Blockstore.PutMany(ctx, blks); err != nil {
+		t.Fatal(err)
+	}
+
+	// The throttled wants are answered with DONT_HAVEs so that the peer
+	// doesn't wait for the blocks.
+	riga := libp2ptest.RandPeerIDFatal(t)
+	partnerWantBlocksHaves(warsaw.Engine, keys, nil, true, riga)
+	var received, dontHaves int
+	for received+dontHaves < wantCount {
+		_, env := getNextEnvelope(warsaw.Engine, nil, time.Second)
+		if env == nil {
+			t.Fatalf("received %d blocks and %d DONT_HAVEs, expected %d answers", received, dontHaves, wantCount)
+		}
+		received += len(env.Message.Blocks())
+		dontHaves += len(env.Message.DontHaves())
+		env.Sent()
+	}
+	if received != wantCount/2 || dontHaves != wantCount/2 {
+		t.Fatalf("received %d blocks and %d DONT_HAVEs, expected %d of each", received, dontHaves, wantCount/2)
+	}
+}
+
+func TestRateLimitsBytes(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	const (
+		blockCount = 3
+		blockSize  = 6000
+		rateLimit  = 10000
+	)
+	var keys []string
+	var blks []blocks.Block
+	for i := 0; i < blockCount; i++ {
+		data := bytes.Repeat([]byte{byte(i)}, blockSize)
+		keys = append(keys, string(data))
+		blks = append(blks, blocks.NewBlock(data))
+	}
+	bs := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	if err := bs.PutMany(ctx, blks); err != nil {
+		t.Fatal(err)
+	}
+
+	allowed := libp2ptest.RandPeerIDFatal(t)
+	e := newEngineForTesting(ctx, bs, &fakePeerTagger{}, "localhost", 0, WithScoreLedger(NewTestScoreLedger(shortTerm, nil, clock.New())),
+		WithBlockstoreWorkerCount(4), WithTaskWorkerCount(1), WithTargetMessageSize(blockSize),
+		WithRateLimits(RateLimits{PeerBytesPerSecond: rateLimit, Allowlist: []peer.ID{allowed}}))
+	e.StartWorkers(ctx, process.WithTeardown(func() error { return nil }))
+
+	receiveAll := func(p peer.ID) time.Duration {
+		start := time.Now()
+		partnerWantBlocks(e, keys, p)
+		received := 0
+		for received < blockCount {
+			next := <-e.Outbox()
+			env := <-next
+			if env == nil {
+				t.Fatal("outbox closed")
+			}
+			received += len(env.Message.Blocks())
+			env.Sent()
+		}
+		return time.Since(start)
+	}
+
+	// The first message fits in the burst, the others have to wait for the
+	// bucket to refill.
+	minDuration := time.Duration(blockCount*blockSize-rateLimit) * time.Second / rateLimit
+	if d := receiveAll(libp2ptest.RandPeerIDFatal(t)); d < minDuration {
+		t.Fatalf("blocks were sent in %s, expected at least %s", d, minDuration)
+	}
+	if d := receiveAll(allowed); d >= minDuration {
+		t.Fatalf("blocks were sent to an allowlisted peer in %s", d)
+	}
+}
+
+func TestRateLimitsBytesDoNotStallOtherPeers(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	slowBlock := blocks.NewBlock(bytes.Repeat([]byte{1}, 6000))
+	fastBlock := blocks.NewBlock(bytes.Repeat([]byte{2}, 6000))
+	bs := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	if err := bs.PutMany(ctx, []blocks.Block{slowBlock, fastBlock}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Sending the block to the slow peer takes several seconds.
+	slow := libp2ptest.RandPeerIDFatal(t)
+	fast := libp2ptest.RandPeerIDFatal(t)
+	e := newEngineForTesting(ctx, bs, &fakePeerTagger{}, "localhost", 0, WithScoreLedger(NewTestScoreLedger(shortTerm, nil, clock.New())),
+		WithBlockstoreWorkerCount(4), WithTaskWorkerCount(1),
+		WithRateLimits(RateLimits{PeerBytesPerSecond: 1000, Allowlist: []peer.ID{fast}}))
+	e.StartWorkers(ctx, process.WithTeardown(func() error { return nil }))
+
+	partnerWantBlocks(e, []string{string(slowBlock.RawData())}, slow)
+	partnerWantBlocks(e, []string{string(fastBlock.RawData())}, fast)
+
+	// The only task worker is not blocked by the slow peer.
+	_, env := getNextEnvelope(e, nil, time.Second)
+	if env == nil {
+		t.Fatal("the throttled peer stalled the other peers")
+	}
+	if env.Peer != fast {
+		t.Fatal("the throttled peer was not delayed")
+	}
+	env.Sent()
+}
@@ -115,6 +115,12 @@ func (l *peerLedger) CollectPeerIDs() []peer.ID {
 	return peers
 }
 
+// IsWanted returns whether p wants k.
+func (l *peerLedger) IsWanted(p peer.ID, k cid.Cid) bool {
+	_, ok := l.peers[p][k]
+	return ok
+}
+
 func (l *peerLedger) WantlistSizeForPeer(p peer.ID) int {
 	return len(l.peers[p])
 }
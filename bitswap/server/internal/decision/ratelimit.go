@@ -0,0 +1,210 @@
+package decision
+
+import (
+	"sync"
+	"time"
+
+	bsmsg "github.com/ipfs/boxo/bitswap/message"
+	"github.com/ipfs/go-metrics-interface"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"golang.org/x/time/rate"
+)
+
+// RateLimits configures token bucket limits on the work done for peers, both
+// for each peer and for all of them. A zero rate disables the corresponding
+// limit.
+type RateLimits struct {
+	// BytesPerSecond limits the size of the messages sent to all peers.
+	BytesPerSecond int
+	// PeerBytesPerSecond limits the size of the messages sent to each peer.
+	PeerBytesPerSecond int
+	// WantsPerSecond limits the wantlist entries accepted from all peers.
+	// Wants over the limit are answered with a DONT_HAVE if the peer asked
+	// for one, and are dropped otherwise.
+	WantsPerSecond int
+	// PeerWantsPerSecond limits the wantlist entries accepted from each peer.
+	PeerWantsPerSecond int
+	// Allowlist lists the peers which are not limited, and don't count
+	// towards the global limits.
+	Allowlist []peer.ID
+}
+
+// rateLimiter enforces [RateLimits]. A nil rateLimiter doesn't limit
+// anything.
+type rateLimiter struct {
+	limits    RateLimits
+	allowlist map[peer.ID]struct{}
+
+	bytes, wants *rate.Limiter
+
+	lk    sync.Mutex
+	peers map[peer.ID]*peerRateLimiter
+
+	throttledWants metrics.Counter
+	throttledSends metrics.Counter
+}
+
+type peerRateLimiter struct {
+	bytes, wants *rate.Limiter
+
+	// reserved is the amount of bytes reserved for a delayed message,
+	// guarded by the lock of the rateLimiter.
+	reserved int
+}
+
+func newRateLimiter(limits RateLimits, throttledWants, throttledSends metrics.Counter) *rateLimiter {
+	if limits.BytesPerSecond <= 0 && limits.PeerBytesPerSecond <= 0 &&
+		limits.WantsPerSecond <= 0 && limits.PeerWantsPerSecond <= 0 {
+		return nil
+	}
+
+	allowlist := make(map[peer.ID]struct{}, len(limits.Allowlist))
+	for _, p := range limits.Allowlist {
+		allowlist[p] = struct{}{}
+	}
+	return &rateLimiter{
+		limits:         limits,
+		allowlist:      allowlist,
+		bytes:          newLimiter(limits.BytesPerSecond),
+		wants:          newLimiter(limits.WantsPerSecond),
+		peers:          make(map[peer.ID]*peerRateLimiter),
+		throttledWants: throttledWants,
+		throttledSends: throttledSends,
+	}
+}
+
+// newLimiter returns a limiter allowing bursts of one second worth of tokens,
+// or nil if r is not a positive rate.
+func newLimiter(r int) *rate.Limiter {
+	if r <= 0 {
+		return nil
+	}
+	return rate.NewLimiter(rate.Limit(r), r)
+}
+
+// peer returns the limiters of p, or nil if p is allowlisted.
+func (rl *rateLimiter) peer(p peer.ID) *peerRateLimiter {
+	if _, ok := rl.allowlist[p]; ok {
+		return nil
+	}
+
+	rl.lk.Lock()
+	defer rl.lk.Unlock()
+
+	pl, ok := rl.peers[p]
+	if !ok {
+		pl = &peerRateLimiter{
+			bytes: newLimiter(rl.limits.PeerBytesPerSecond),
+			wants: newLimiter(rl.limits.PeerWantsPerSecond),
+		}
+		rl.peers[p] = pl
+	}
+	return pl
+}
+
+// limitWants splits the wants of p between the ones which are within the
+// limits and the ones which are throttled.
+func (rl *rateLimiter) limitWants(p peer.ID, wants []bsmsg.Entry) (allowed, throttled []bsmsg.Entry) {
+	if rl == nil || len(wants) == 0 {
+		return wants, nil
+	}
+	pl := rl.peer(p)
+	if pl == nil {
+		return wants, nil
+	}
+
+	n := 0
+	for n < len(wants) && allow(pl.wants, rl.wants) {
+		n++
+	}
+
+	if dropped := len(wants) - n; dropped > 0 {
+		log.Debugw("throttling wants", "remote", p, "dropped", dropped)
+		rl.throttledWants.Add(float64(dropped))
+	}
+	return wants[:n], wants[n:]
+}
+
+// allow takes a token from each of the limiters which are not nil, or none
+// if any of them has none left.
+func allow(limiters ...*rate.Limiter) bool {
+	now := time.Now()
+	reserved := make([]*rate.Reservation, 0, len(limiters))
+	for _, lim := range limiters {
+		if lim == nil {
+			continue
+		}
+		r := lim.ReserveN(now, 1)
+		if !r.OK() || r.DelayFrom(now) > 0 {
+			r.CancelAt(now)
+			for _, r := range reserved {
+				r.CancelAt(now)
+			}
+			return false
+		}
+		reserved = append(reserved, r)
+	}
+	return true
+}
+
+// reserveSend reserves the tokens needed to send a message of the given
+// size to p, and returns how long the message must be delayed for them to be
+// available. A delayed message keeps its tokens, which are used for the next
+// message sent to p instead of being reserved again.
+func (rl *rateLimiter) reserveSend(p peer.ID, size int) time.Duration {
+	if rl == nil {
+		return 0
+	}
+	pl := rl.peer(p)
+	if pl == nil {
+		return 0
+	}
+
+	rl.lk.Lock()
+	defer rl.lk.Unlock()
+
+	if pl.reserved >= size {
+		pl.reserved -= size
+		return 0
+	}
+
+	var delay time.Duration
+	now := time.Now()
+	for _, lim := range []*rate.Limiter{pl.bytes, rl.bytes} {
+		if lim == nil {
+			continue
+		}
+		// Messages can be larger than the burst, reserve the tokens in
+		// chunks. Each reservation is delayed by the previous ones.
+		for remaining := size - pl.reserved; remaining > 0; {
+			n := remaining
+			if b := lim.Burst(); n > b {
+				n = b
+			}
+			if d := lim.ReserveN(now, n).DelayFrom(now); d > delay {
+				delay = d
+			}
+			remaining -= n
+		}
+	}
+
+	if delay > 0 {
+		log.Debugw("throttled message", "remote", p, "size", size, "delay", delay)
+		rl.throttledSends.Inc()
+		pl.reserved = size
+	} else {
+		pl.reserved = 0
+	}
+	return delay
+}
+
+// peerDisconnected forgets the limiters of p.
+func (rl *rateLimiter) peerDisconnected(p peer.ID) {
+	if rl == nil {
+		return
+	}
+
+	rl.lk.Lock()
+	defer rl.lk.Unlock()
+	delete(rl.peers, p)
+}
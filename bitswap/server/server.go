@@ -233,6 +233,18 @@ func MaxCidSize(n uint) Option {
 	}
 }
 
+// WithRateLimits limits the rates at which wants are accepted from peers and
+// blocks are sent to them, globally and for each peer. Wants over the limits
+// are answered with a DONT_HAVE if the peer asked for one, and the messages
+// to a peer are deferred until they fit the bandwidth limits, without holding
+// up the other peers. Allowlisted peers are exempt.
+func WithRateLimits(limits RateLimits) Option {
+	o := decision.WithRateLimits(limits)
+	return func(bs *Server) {
+		bs.engineOptions = append(bs.engineOptions, o)
+	}
+}
+
 // HasBlockBufferSize configure how big the new blocks buffer should be.
 func HasBlockBufferSize(count int) Option {
 	if count < 0 {
@@ -0,0 +1,397 @@
+package simulator
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	bsmsg "github.com/ipfs/boxo/bitswap/message"
+	bsnet "github.com/ipfs/boxo/bitswap/network"
+	cid "github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/connmgr"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/p2p/protocol/ping"
+)
+
+var errNotConnected = errors.New("peer is not connected")
+
+// event is something that happens at a point of virtual time.
+type event struct {
+	at  time.Duration
+	seq uint64
+	run func()
+}
+
+type eventQueue []*event
+
+func (q eventQueue) Len() int { return len(q) }
+
+func (q eventQueue) Less(i, j int) bool {
+	if q[i].at != q[j].at {
+		return q[i].at < q[j].at
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q eventQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *eventQueue) Push(x any) { *q = append(*q, x.(*event)) }
+
+func (q *eventQueue) Pop() any {
+	old := *q
+	e := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return e
+}
+
+// link is one direction of the connection between two peers.
+type link struct {
+	latency   time.Duration
+	bandwidth int64
+	// busyUntil is when the previous message has been fully transmitted.
+	busyUntil time.Duration
+}
+
+// network is a virtual network whose messages are delivered according to a
+// virtual clock, which only moves forward when the runner advances it.
+type network struct {
+	mu      sync.Mutex
+	now     time.Duration
+	seq     uint64
+	events  eventQueue
+	links   map[[2]peer.ID]*link
+	nodes   map[peer.ID]*networkClient
+	online  map[peer.ID]bool
+	conns   map[[2]peer.ID]struct{}
+	defLink Link
+
+	// activity is bumped whenever a node does something that might lead to
+	// more events, so that the runner knows when the nodes are idle.
+	activity atomic.Uint64
+}
+
+func newNetwork(defLink Link) *network {
+	return &network{
+		links:   make(map[[2]peer.ID]*link),
+		nodes:   make(map[peer.ID]*networkClient),
+		online:  make(map[peer.ID]bool),
+		conns:   make(map[[2]peer.ID]struct{}),
+		defLink: defLink,
+	}
+}
+
+// Now returns the virtual time elapsed since the start of the simulation.
+func (n *network) Now() time.Duration {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.now
+}
+
+// schedule runs f at the virtual time at.
+func (n *network) schedule(at time.Duration, f func()) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.scheduleLocked(at, f)
+}
+
+func (n *network) scheduleLocked(at time.Duration, f func()) {
+	n.seq++
+	heap.Push(&n.events, &event{at: at, seq: n.seq, run: f})
+	n.activity.Add(1)
+}
+
+// next advances the clock to the earliest scheduled time and returns all the
+// events due then. It returns nil when nothing is scheduled before deadline.
+func (n *network) next(deadline time.Duration) []*event {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if len(n.events) == 0 || n.events[0].at > deadline {
+		return nil
+	}
+	n.now = n.events[0].at
+	var due []*event
+	for len(n.events) > 0 && n.events[0].at == n.now {
+		due = append(due, heap.Pop(&n.events).(*event))
+	}
+	return due
+}
+
+func (n *network) setLink(a, b peer.ID, l Link) {
+	n.links[[2]peer.ID{a, b}] = &link{latency: time.Duration(l.Latency), bandwidth: l.Bandwidth}
+	n.links[[2]peer.ID{b, a}] = &link{latency: time.Duration(l.Latency), bandwidth: l.Bandwidth}
+}
+
+func (n *network) linkLocked(from, to peer.ID) *link {
+	key := [2]peer.ID{from, to}
+	l, ok := n.links[key]
+	if !ok {
+		l = &link{latency: time.Duration(n.defLink.Latency), bandwidth: n.defLink.Bandwidth}
+		n.links[key] = l
+	}
+	return l
+}
+
+func connKey(a, b peer.ID) [2]peer.ID {
+	if a < b {
+		return [2]peer.ID{a, b}
+	}
+	return [2]peer.ID{b, a}
+}
+
+func (n *network) adapter(p peer.ID) *networkClient {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	nc := &networkClient{local: p, network: n}
+	n.nodes[p] = nc
+	return nc
+}
+
+func (n *network) send(from, to peer.ID, msg bsmsg.BitSwapMessage) error {
+	msg = msg.Clone()
+	size := msg.ToProtoV1().Size()
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if _, ok := n.conns[connKey(from, to)]; !ok {
+		return errNotConnected
+	}
+
+	// Messages are serialized on the link, then travel for its latency.
+	l := n.linkLocked(from, to)
+	start := n.now
+	if l.busyUntil > start {
+		start = l.busyUntil
+	}
+	if l.bandwidth > 0 {
+		l.busyUntil = start + time.Duration(int64(size)*int64(time.Second)/l.bandwidth)
+	} else {
+		l.busyUntil = start
+	}
+
+	sender, receiver := n.nodes[from], n.nodes[to]
+	sender.counters.record(msg, size, true)
+	n.scheduleLocked(l.busyUntil+l.latency, func() {
+		n.mu.Lock()
+		_, connected := n.conns[connKey(from, to)]
+		n.mu.Unlock()
+		// The message is lost if the connection closed while in flight.
+		if !connected {
+			return
+		}
+		receiver.counters.record(msg, size, false)
+		for _, r := range receiver.receivers {
+			r.ReceiveMessage(context.Background(), from, msg)
+		}
+	})
+	return nil
+}
+
+func (n *network) connect(a, b peer.ID) {
+	n.mu.Lock()
+	key := connKey(a, b)
+	_, ok := n.conns[key]
+	if ok || !n.online[a] || !n.online[b] {
+		n.mu.Unlock()
+		return
+	}
+	n.conns[key] = struct{}{}
+	na, nb := n.nodes[a], n.nodes[b]
+	n.mu.Unlock()
+
+	n.activity.Add(1)
+	na.peerConnected(b)
+	nb.peerConnected(a)
+}
+
+func (n *network) disconnect(a, b peer.ID) {
+	n.mu.Lock()
+	key := connKey(a, b)
+	if _, ok := n.conns[key]; !ok {
+		n.mu.Unlock()
+		return
+	}
+	delete(n.conns, key)
+	na, nb := n.nodes[a], n.nodes[b]
+	n.mu.Unlock()
+
+	n.activity.Add(1)
+	na.peerDisconnected(b)
+	nb.peerDisconnected(a)
+}
+
+// join brings p online and connects it to every online peer.
+func (n *network) join(p peer.ID) {
+	n.mu.Lock()
+	n.online[p] = true
+	var others []peer.ID
+	for o := range n.nodes {
+		if o != p && n.online[o] {
+			others = append(others, o)
+		}
+	}
+	n.mu.Unlock()
+
+	sortPeers(others)
+	for _, o := range others {
+		n.connect(p, o)
+	}
+}
+
+// leave disconnects p from every peer and takes it offline.
+func (n *network) leave(p peer.ID) {
+	n.mu.Lock()
+	n.online[p] = false
+	var others []peer.ID
+	for key := range n.conns {
+		if key[0] == p {
+			others = append(others, key[1])
+		} else if key[1] == p {
+			others = append(others, key[0])
+		}
+	}
+	n.mu.Unlock()
+
+	sortPeers(others)
+	for _, o := range others {
+		n.disconnect(p, o)
+	}
+}
+
+// counters are the traffic statistics of a peer.
+type counters struct {
+	mu            sync.Mutex
+	messagesSent  uint64
+	messagesRecvd uint64
+	bytesSent     uint64
+	bytesRecvd    uint64
+	wantsSent     uint64
+	blocksSent    uint64
+}
+
+func (c *counters) record(msg bsmsg.BitSwapMessage, size int, sent bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !sent {
+		c.messagesRecvd++
+		c.bytesRecvd += uint64(size)
+		return
+	}
+	c.messagesSent++
+	c.bytesSent += uint64(size)
+	if len(msg.Wantlist()) > 0 {
+		c.wantsSent++
+	}
+	c.blocksSent += uint64(len(msg.Blocks()))
+}
+
+var _ bsnet.BitSwapNetwork = (*networkClient)(nil)
+
+type networkClient struct {
+	local     peer.ID
+	network   *network
+	receivers []bsnet.Receiver
+	counters  counters
+}
+
+func (nc *networkClient) Self() peer.ID {
+	return nc.local
+}
+
+func (nc *networkClient) SendMessage(ctx context.Context, to peer.ID, msg bsmsg.BitSwapMessage) error {
+	return nc.network.send(nc.local, to, msg)
+}
+
+func (nc *networkClient) Start(r ...bsnet.Receiver) {
+	nc.receivers = r
+}
+
+func (nc *networkClient) Stop() {}
+
+func (nc *networkClient) ConnectTo(_ context.Context, p peer.ID) error {
+	nc.network.connect(nc.local, p)
+	return nil
+}
+
+func (nc *networkClient) DisconnectFrom(_ context.Context, p peer.ID) error {
+	nc.network.disconnect(nc.local, p)
+	return nil
+}
+
+func (nc *networkClient) peerConnected(p peer.ID) {
+	for _, r := range nc.receivers {
+		r.PeerConnected(p)
+	}
+}
+
+func (nc *networkClient) peerDisconnected(p peer.ID) {
+	for _, r := range nc.receivers {
+		r.PeerDisconnected(p)
+	}
+}
+
+func (nc *networkClient) NewMessageSender(ctx context.Context, p peer.ID, opts *bsnet.MessageSenderOpts) (bsnet.MessageSender, error) {
+	return &messageSender{net: nc, target: p}, nil
+}
+
+func (nc *networkClient) ConnectionManager() connmgr.ConnManager {
+	return &connmgr.NullConnMgr{}
+}
+
+func (nc *networkClient) Stats() bsnet.Stats {
+	nc.counters.mu.Lock()
+	defer nc.counters.mu.Unlock()
+	return bsnet.Stats{
+		MessagesSent:  nc.counters.messagesSent,
+		MessagesRecvd: nc.counters.messagesRecvd,
+	}
+}
+
+// FindProvidersAsync finds nothing, peers only learn about each other
+// through their connections.
+func (nc *networkClient) FindProvidersAsync(context.Context, cid.Cid, int) <-chan peer.ID {
+	out := make(chan peer.ID)
+	close(out)
+	return out
+}
+
+func (nc *networkClient) Provide(context.Context, cid.Cid) error {
+	return nil
+}
+
+func (nc *networkClient) Ping(_ context.Context, p peer.ID) ping.Result {
+	return ping.Result{RTT: 2 * nc.Latency(p)}
+}
+
+func (nc *networkClient) Latency(p peer.ID) time.Duration {
+	nc.network.mu.Lock()
+	defer nc.network.mu.Unlock()
+	return nc.network.linkLocked(nc.local, p).latency
+}
+
+type messageSender struct {
+	net    *networkClient
+	target peer.ID
+}
+
+func (ms *messageSender) SendMsg(ctx context.Context, msg bsmsg.BitSwapMessage) error {
+	return ms.net.SendMessage(ctx, ms.target, msg)
+}
+
+func (ms *messageSender) Close() error {
+	return nil
+}
+
+func (ms *messageSender) Reset() error {
+	return nil
+}
+
+func (ms *messageSender) SupportsHave() bool {
+	return true
+}
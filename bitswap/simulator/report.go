@@ -0,0 +1,75 @@
+package simulator
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Report is the outcome of a simulation. Times are virtual.
+type Report struct {
+	Scenario string
+	// Duration is the virtual time at which the simulation ended.
+	Duration time.Duration
+	Peers    []PeerReport
+	Requests []RequestReport
+}
+
+// PeerReport holds the traffic statistics of a peer.
+type PeerReport struct {
+	Name string
+	ID   peer.ID
+
+	BlocksReceived    uint64
+	DataReceived      uint64
+	DupBlocksReceived uint64
+	DupDataReceived   uint64
+	BlocksSent        uint64
+
+	MessagesSent     uint64
+	MessagesReceived uint64
+	// WantMessagesSent counts the messages that carried wantlist entries.
+	WantMessagesSent uint64
+	BytesSent        uint64
+	BytesReceived    uint64
+}
+
+// RequestReport describes how a request went.
+type RequestReport struct {
+	Peer string
+	DAG  string
+	Mode string
+	// Start is when the request was made.
+	Start time.Duration
+	// TimeToFirstBlock and TimeToLastBlock are relative to Start.
+	TimeToFirstBlock time.Duration
+	TimeToLastBlock  time.Duration
+	// Blocks is the size of the DAG and Received how many blocks of it were
+	// fetched.
+	Blocks    int
+	Received  int
+	Completed bool
+}
+
+// WriteText writes the report as human readable tables.
+func (r *Report) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "scenario %q finished after %s\n\n", r.Scenario, r.Duration)
+
+	fmt.Fprintln(tw, "PEER\tDAG\tMODE\tSTART\tFIRST BLOCK\tLAST BLOCK\tBLOCKS\tCOMPLETED")
+	for _, q := range r.Requests {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%d/%d\t%t\n",
+			q.Peer, q.DAG, q.Mode, q.Start, q.TimeToFirstBlock, q.TimeToLastBlock, q.Received, q.Blocks, q.Completed)
+	}
+
+	fmt.Fprintln(tw, "\nPEER\tBLOCKS RECV\tDUP BLOCKS\tDUP BYTES\tBLOCKS SENT\tMSGS SENT\tWANT MSGS\tBYTES SENT\tBYTES RECV")
+	for _, p := range r.Peers {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t%d\n",
+			p.Name, p.BlocksReceived, p.DupBlocksReceived, p.DupDataReceived, p.BlocksSent,
+			p.MessagesSent, p.WantMessagesSent, p.BytesSent, p.BytesReceived)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,256 @@
+package simulator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Duration is a time.Duration that is written as a string such as "250ms" in
+// scenario files.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Scenario describes a simulation: the peers taking part, the links between
+// them, the DAGs they hold and what happens when.
+type Scenario struct {
+	Name string `json:"name"`
+	// Seed makes peer identities and DAG contents reproducible.
+	Seed int64 `json:"seed"`
+	// Timeout is the virtual time after which the simulation stops, even if
+	// requests are still pending. It defaults to DefaultTimeout.
+	Timeout Duration `json:"timeout,omitempty"`
+
+	// Link is used for every pair of peers without an entry in Links.
+	Link Link `json:"link"`
+	// Links overrides Link for specific pairs of peers.
+	Links []Link `json:"links,omitempty"`
+
+	DAGs     []DAG     `json:"dags"`
+	Peers    []Peer    `json:"peers"`
+	Requests []Request `json:"requests"`
+	Events   []Event   `json:"events,omitempty"`
+}
+
+// Link describes the connection between two peers, in each direction.
+type Link struct {
+	// Peers names the two peers the link connects. It is ignored for the
+	// scenario default link.
+	Peers [2]string `json:"peers,omitempty"`
+	// Latency is the one-way delay of a message.
+	Latency Duration `json:"latency"`
+	// Bandwidth is in bytes per second, zero means unlimited.
+	Bandwidth int64 `json:"bandwidth,omitempty"`
+}
+
+// DAG describes a tree of dag-pb nodes over raw leaves filled with random
+// data.
+type DAG struct {
+	Name string `json:"name"`
+	// Blocks is the number of leaves.
+	Blocks int `json:"blocks"`
+	// BlockSize is the size of each leaf. It defaults to DefaultBlockSize.
+	BlockSize int `json:"blockSize,omitempty"`
+	// Fanout is the number of links of intermediate nodes. It defaults to
+	// DefaultFanout.
+	Fanout int `json:"fanout,omitempty"`
+}
+
+// Peer describes a bitswap node.
+type Peer struct {
+	Name string `json:"name"`
+	// Has lists the DAGs the peer holds from the start.
+	Has []string `json:"has,omitempty"`
+	// Offline peers only join the network with a join event.
+	Offline bool `json:"offline,omitempty"`
+}
+
+// Fetch modes of a Request.
+const (
+	// FetchWalk fetches the DAG level by level, as a traversal would.
+	FetchWalk = "walk"
+	// FetchAll asks for all blocks of the DAG at once.
+	FetchAll = "all"
+)
+
+// Request makes a peer fetch a DAG.
+type Request struct {
+	At   Duration `json:"at"`
+	Peer string   `json:"peer"`
+	DAG  string   `json:"dag"`
+	// Mode is FetchWalk or FetchAll, FetchWalk by default.
+	Mode string `json:"mode,omitempty"`
+}
+
+// Event types.
+const (
+	// EventLeave disconnects a peer from all others and keeps it offline.
+	EventLeave = "leave"
+	// EventJoin brings a peer online and connects it to all online peers.
+	EventJoin = "join"
+	// EventDisconnect closes the connection between Peer and With.
+	EventDisconnect = "disconnect"
+	// EventConnect opens a connection between Peer and With.
+	EventConnect = "connect"
+)
+
+// Event changes the network during the simulation.
+type Event struct {
+	At   Duration `json:"at"`
+	Type string   `json:"type"`
+	Peer string   `json:"peer"`
+	// With is the other peer of connect and disconnect events.
+	With string `json:"with,omitempty"`
+}
+
+const (
+	DefaultTimeout   = 10 * time.Minute
+	DefaultBlockSize = 256 << 10
+	DefaultFanout    = 174
+)
+
+// LoadScenario reads and validates the scenario file at path.
+func LoadScenario(path string) (*Scenario, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseScenario(f)
+}
+
+// ParseScenario reads a JSON scenario from r and validates it.
+func ParseScenario(r io.Reader) (*Scenario, error) {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	var s Scenario
+	if err := dec.Decode(&s); err != nil {
+		return nil, fmt.Errorf("parsing scenario: %w", err)
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Validate checks that the scenario is consistent and fills in defaults.
+func (s *Scenario) Validate() error {
+	if s.Timeout == 0 {
+		s.Timeout = Duration(DefaultTimeout)
+	}
+	if s.Timeout < 0 || s.Link.Latency < 0 || s.Link.Bandwidth < 0 {
+		return errors.New("timeout, latency and bandwidth must not be negative")
+	}
+
+	dags := make(map[string]struct{}, len(s.DAGs))
+	for i := range s.DAGs {
+		d := &s.DAGs[i]
+		if _, ok := dags[d.Name]; ok || d.Name == "" {
+			return fmt.Errorf("dag %d: missing or duplicate name %q", i, d.Name)
+		}
+		dags[d.Name] = struct{}{}
+		if d.BlockSize == 0 {
+			d.BlockSize = DefaultBlockSize
+		}
+		if d.Fanout == 0 {
+			d.Fanout = DefaultFanout
+		}
+		if d.Blocks <= 0 || d.BlockSize < 0 || d.Fanout < 2 {
+			return fmt.Errorf("dag %q: blocks must be positive and fanout at least 2", d.Name)
+		}
+	}
+
+	peers := make(map[string]struct{}, len(s.Peers))
+	for i, p := range s.Peers {
+		if _, ok := peers[p.Name]; ok || p.Name == "" {
+			return fmt.Errorf("peer %d: missing or duplicate name %q", i, p.Name)
+		}
+		peers[p.Name] = struct{}{}
+		for _, d := range p.Has {
+			if _, ok := dags[d]; !ok {
+				return fmt.Errorf("peer %q: unknown dag %q", p.Name, d)
+			}
+		}
+	}
+	checkPeer := func(what, name string) error {
+		if _, ok := peers[name]; !ok {
+			return fmt.Errorf("%s: unknown peer %q", what, name)
+		}
+		return nil
+	}
+
+	for i, l := range s.Links {
+		what := fmt.Sprintf("link %d", i)
+		if err := checkPeer(what, l.Peers[0]); err != nil {
+			return err
+		}
+		if err := checkPeer(what, l.Peers[1]); err != nil {
+			return err
+		}
+		if l.Peers[0] == l.Peers[1] || l.Latency < 0 || l.Bandwidth < 0 {
+			return fmt.Errorf("%s: invalid link", what)
+		}
+	}
+
+	for i := range s.Requests {
+		r := &s.Requests[i]
+		what := fmt.Sprintf("request %d", i)
+		if err := checkPeer(what, r.Peer); err != nil {
+			return err
+		}
+		if _, ok := dags[r.DAG]; !ok {
+			return fmt.Errorf("%s: unknown dag %q", what, r.DAG)
+		}
+		if r.Mode == "" {
+			r.Mode = FetchWalk
+		}
+		if r.Mode != FetchWalk && r.Mode != FetchAll {
+			return fmt.Errorf("%s: unknown mode %q", what, r.Mode)
+		}
+		if r.At < 0 {
+			return fmt.Errorf("%s: negative time", what)
+		}
+	}
+
+	for i, e := range s.Events {
+		what := fmt.Sprintf("event %d", i)
+		if err := checkPeer(what, e.Peer); err != nil {
+			return err
+		}
+		switch e.Type {
+		case EventLeave, EventJoin:
+		case EventConnect, EventDisconnect:
+			if err := checkPeer(what, e.With); err != nil {
+				return err
+			}
+			if e.Peer == e.With {
+				return fmt.Errorf("%s: peer cannot %s itself", what, e.Type)
+			}
+		default:
+			return fmt.Errorf("%s: unknown type %q", what, e.Type)
+		}
+		if e.At < 0 {
+			return fmt.Errorf("%s: negative time", what)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,390 @@
+// Package simulator runs bitswap nodes on a virtual network described by a
+// declarative Scenario and reports how they performed.
+//
+// The network delivers messages on a virtual clock, honouring the latency and
+// bandwidth of each link, so results are expressed in virtual time and do not
+// depend on the speed of the machine. The clock only moves forward once the
+// nodes are idle, that is when they have not sent anything for
+// Options.SettleTime of real time. Bitswap timers that run on the real clock
+// (provider searches, rebroadcasts and simulated DONT_HAVEs) are disabled so
+// they do not interfere with the virtual schedule.
+//
+// Peer identities and DAG contents are derived from the scenario seed, and
+// events happening at the same virtual time run in a fixed order. How bitswap
+// batches wants and blocks into messages still depends on the scheduling of
+// its goroutines, so figures can vary slightly between runs of a scenario.
+package simulator
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ipfs/boxo/bitswap"
+	"github.com/ipfs/boxo/blockstore"
+	"github.com/ipfs/boxo/exchange"
+	"github.com/ipfs/boxo/ipld/merkledag"
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	delay "github.com/ipfs/go-ipfs-delay"
+	ipld "github.com/ipfs/go-ipld-format"
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+var log = logging.Logger("bitswap/simulator")
+
+// DefaultSettleTime is the default Options.SettleTime.
+const DefaultSettleTime = 10 * time.Millisecond
+
+// Options tune a simulation run.
+type Options struct {
+	// SettleTime is how long, in real time, the nodes must stay idle before
+	// the virtual clock moves forward. Raise it on slow or busy machines if
+	// results vary between runs.
+	SettleTime time.Duration
+	// BitswapOptions are passed to every node, after the simulator's own.
+	BitswapOptions []bitswap.Option
+}
+
+type node struct {
+	name     string
+	id       peer.ID
+	net      *networkClient
+	bstore   blockstore.Blockstore
+	exchange *bitswap.Bitswap
+}
+
+type simulation struct {
+	scenario *Scenario
+	opts     Options
+	net      *network
+	nodes    map[string]*node
+	dags     map[string]*dag
+
+	mu       sync.Mutex
+	requests []*RequestReport
+	pending  int
+}
+
+// dag is a generated DAG.
+type dag struct {
+	root   cid.Cid
+	blocks []blocks.Block
+}
+
+// Run simulates the scenario and returns its report. The scenario must have
+// been validated.
+func Run(ctx context.Context, s *Scenario, opts Options) (*Report, error) {
+	if opts.SettleTime <= 0 {
+		opts.SettleTime = DefaultSettleTime
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	sim := &simulation{
+		scenario: s,
+		opts:     opts,
+		net:      newNetwork(s.Link),
+		nodes:    make(map[string]*node, len(s.Peers)),
+		dags:     make(map[string]*dag, len(s.DAGs)),
+	}
+	rng := rand.New(rand.NewSource(s.Seed))
+
+	for _, d := range s.DAGs {
+		g, err := generateDAG(rng, d)
+		if err != nil {
+			return nil, fmt.Errorf("generating dag %q: %w", d.Name, err)
+		}
+		sim.dags[d.Name] = g
+	}
+
+	for _, p := range s.Peers {
+		n, err := sim.newNode(ctx, rng, p)
+		if err != nil {
+			return nil, fmt.Errorf("creating peer %q: %w", p.Name, err)
+		}
+		defer n.exchange.Close()
+	}
+	for _, l := range s.Links {
+		sim.net.setLink(sim.nodes[l.Peers[0]].id, sim.nodes[l.Peers[1]].id, l)
+	}
+	for _, p := range s.Peers {
+		if !p.Offline {
+			sim.net.join(sim.nodes[p.Name].id)
+		}
+	}
+
+	for _, r := range s.Requests {
+		r := r
+		sim.pending++
+		sim.net.schedule(time.Duration(r.At), func() { sim.startRequest(ctx, r) })
+	}
+	for _, e := range s.Events {
+		e := e
+		sim.net.schedule(time.Duration(e.At), func() { sim.applyEvent(e) })
+	}
+
+	sim.loop(ctx)
+	return sim.report(), ctx.Err()
+}
+
+// loop runs events until all requests are done, nothing is left to do or the
+// timeout is reached.
+func (sim *simulation) loop(ctx context.Context) {
+	for ctx.Err() == nil {
+		sim.settle(ctx)
+
+		sim.mu.Lock()
+		done := sim.pending == 0
+		sim.mu.Unlock()
+		if done {
+			return
+		}
+
+		due := sim.net.next(time.Duration(sim.scenario.Timeout))
+		if due == nil {
+			log.Debugw("simulation stopped with pending requests", "time", sim.net.Now())
+			return
+		}
+		for _, e := range due {
+			e.run()
+		}
+	}
+}
+
+// settle waits until the nodes have been idle for the settle time.
+func (sim *simulation) settle(ctx context.Context) {
+	t := time.NewTimer(sim.opts.SettleTime)
+	defer t.Stop()
+	for {
+		before := sim.net.activity.Load()
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		if sim.net.activity.Load() == before {
+			return
+		}
+		t.Reset(sim.opts.SettleTime)
+	}
+}
+
+func (sim *simulation) newNode(ctx context.Context, rng *rand.Rand, p Peer) (*node, error) {
+	_, pub, err := crypto.GenerateEd25519Key(rng)
+	if err != nil {
+		return nil, err
+	}
+	id, err := peer.IDFromPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+
+	bstore := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	for _, name := range p.Has {
+		if err := bstore.PutMany(ctx, sim.dags[name].blocks); err != nil {
+			return nil, err
+		}
+	}
+
+	nc := sim.net.adapter(id)
+	bsOpts := append([]bitswap.Option{
+		bitswap.ProvideEnabled(false),
+		bitswap.ProviderSearchDelay(time.Duration(sim.scenario.Timeout) + time.Hour),
+		bitswap.RebroadcastDelay(delay.Fixed(time.Duration(sim.scenario.Timeout) + time.Hour)),
+		bitswap.SetSimulateDontHavesOnTimeout(false),
+	}, sim.opts.BitswapOptions...)
+
+	n := &node{
+		name:     p.Name,
+		id:       id,
+		net:      nc,
+		bstore:   bstore,
+		exchange: bitswap.New(ctx, nc, bstore, bsOpts...),
+	}
+	sim.nodes[p.Name] = n
+	return n, nil
+}
+
+func (sim *simulation) applyEvent(e Event) {
+	n := sim.nodes[e.Peer]
+	switch e.Type {
+	case EventLeave:
+		sim.net.leave(n.id)
+	case EventJoin:
+		sim.net.join(n.id)
+	case EventConnect:
+		sim.net.connect(n.id, sim.nodes[e.With].id)
+	case EventDisconnect:
+		sim.net.disconnect(n.id, sim.nodes[e.With].id)
+	}
+}
+
+func (sim *simulation) startRequest(ctx context.Context, r Request) {
+	d := sim.dags[r.DAG]
+	rep := &RequestReport{
+		Peer:   r.Peer,
+		DAG:    r.DAG,
+		Mode:   r.Mode,
+		Start:  sim.net.Now(),
+		Blocks: len(d.blocks),
+	}
+	sim.mu.Lock()
+	sim.requests = append(sim.requests, rep)
+	sim.mu.Unlock()
+
+	n := sim.nodes[r.Peer]
+	session := n.exchange.NewSession(ctx)
+	go func() {
+		var err error
+		if r.Mode == FetchAll {
+			keys := make([]cid.Cid, len(d.blocks))
+			for i, b := range d.blocks {
+				keys[i] = b.Cid()
+			}
+			_, err = sim.fetch(ctx, session, rep, keys)
+		} else {
+			err = sim.walk(ctx, session, rep, d.root)
+		}
+
+		sim.mu.Lock()
+		defer sim.mu.Unlock()
+		if err == nil {
+			rep.Completed = true
+		}
+		sim.pending--
+		sim.net.activity.Add(1)
+	}()
+}
+
+// walk fetches a DAG one level at a time.
+func (sim *simulation) walk(ctx context.Context, session exchange.Fetcher, rep *RequestReport, root cid.Cid) error {
+	level := []cid.Cid{root}
+	for len(level) > 0 {
+		blks, err := sim.fetch(ctx, session, rep, level)
+		if err != nil {
+			return err
+		}
+		level = level[:0:0]
+		for _, b := range blks {
+			if b.Cid().Type() != cid.DagProtobuf {
+				continue
+			}
+			nd, err := merkledag.DecodeProtobufBlock(b)
+			if err != nil {
+				return err
+			}
+			for _, l := range nd.Links() {
+				level = append(level, l.Cid)
+			}
+		}
+	}
+	return nil
+}
+
+// fetch gets keys and records the progress of the request.
+func (sim *simulation) fetch(ctx context.Context, session exchange.Fetcher, rep *RequestReport, keys []cid.Cid) ([]blocks.Block, error) {
+	ch, err := session.GetBlocks(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	blks := make([]blocks.Block, 0, len(keys))
+	for b := range ch {
+		blks = append(blks, b)
+
+		now := sim.net.Now()
+		sim.mu.Lock()
+		if rep.Received == 0 {
+			rep.TimeToFirstBlock = now - rep.Start
+		}
+		rep.Received++
+		rep.TimeToLastBlock = now - rep.Start
+		sim.mu.Unlock()
+		sim.net.activity.Add(1)
+	}
+	if len(blks) < len(keys) {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, ipld.ErrNotFound{}
+	}
+	return blks, nil
+}
+
+func (sim *simulation) report() *Report {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+
+	rep := &Report{
+		Scenario: sim.scenario.Name,
+		Duration: sim.net.Now(),
+	}
+	for _, r := range sim.requests {
+		rep.Requests = append(rep.Requests, *r)
+	}
+	for _, p := range sim.scenario.Peers {
+		n := sim.nodes[p.Name]
+		pr := PeerReport{Name: p.Name, ID: n.id}
+		if st, err := n.exchange.Stat(); err == nil {
+			pr.BlocksReceived = st.BlocksReceived
+			pr.DataReceived = st.DataReceived
+			pr.DupBlocksReceived = st.DupBlksReceived
+			pr.DupDataReceived = st.DupDataReceived
+		}
+		c := &n.net.counters
+		c.mu.Lock()
+		pr.MessagesSent = c.messagesSent
+		pr.MessagesReceived = c.messagesRecvd
+		pr.BytesSent = c.bytesSent
+		pr.BytesReceived = c.bytesRecvd
+		pr.WantMessagesSent = c.wantsSent
+		pr.BlocksSent = c.blocksSent
+		c.mu.Unlock()
+		rep.Peers = append(rep.Peers, pr)
+	}
+	return rep
+}
+
+// generateDAG builds a tree with d.Fanout links per node over d.Blocks raw
+// leaves of random data.
+func generateDAG(rng *rand.Rand, d DAG) (*dag, error) {
+	g := &dag{}
+	level := make([]ipld.Node, d.Blocks)
+	for i := range level {
+		data := make([]byte, d.BlockSize)
+		rng.Read(data)
+		level[i] = merkledag.NewRawNode(data)
+		g.blocks = append(g.blocks, level[i])
+	}
+	for len(level) > 1 {
+		var parents []ipld.Node
+		for i := 0; i < len(level); i += d.Fanout {
+			end := i + d.Fanout
+			if end > len(level) {
+				end = len(level)
+			}
+			nd := merkledag.NodeWithData(nil)
+			for _, child := range level[i:end] {
+				if err := nd.AddNodeLink("", child); err != nil {
+					return nil, err
+				}
+			}
+			parents = append(parents, nd)
+			g.blocks = append(g.blocks, nd)
+		}
+		level = parents
+	}
+	g.root = level[0].Cid()
+	return g, nil
+}
+
+func sortPeers(peers []peer.ID) {
+	sort.Slice(peers, func(i, j int) bool { return peers[i] < peers[j] })
+}
@@ -0,0 +1,115 @@
+package simulator
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testScenario = `{
+	"name": "two seeds",
+	"seed": 1,
+	"timeout": "1m",
+	"link": {"latency": "50ms", "bandwidth": 1000000},
+	"links": [{"peers": ["fetcher", "slow-seed"], "latency": "500ms"}],
+	"dags": [{"name": "file", "blocks": 20, "blockSize": 1024, "fanout": 5}],
+	"peers": [
+		{"name": "seed", "has": ["file"]},
+		{"name": "slow-seed", "has": ["file"]},
+		{"name": "fetcher"}
+	],
+	"requests": [{"at": "100ms", "peer": "fetcher", "dag": "file"}]
+}`
+
+func runTestScenario(t *testing.T, edit func(*Scenario)) *Report {
+	s, err := ParseScenario(strings.NewReader(testScenario))
+	require.NoError(t, err)
+	if edit != nil {
+		edit(s)
+		require.NoError(t, s.Validate())
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	rep, err := Run(ctx, s, Options{})
+	require.NoError(t, err)
+	return rep
+}
+
+func TestRun(t *testing.T) {
+	for _, mode := range []string{FetchWalk, FetchAll} {
+		mode := mode
+		t.Run(mode, func(t *testing.T) {
+			rep := runTestScenario(t, func(s *Scenario) { s.Requests[0].Mode = mode })
+
+			require.Len(t, rep.Requests, 1)
+			req := rep.Requests[0]
+			require.True(t, req.Completed)
+			require.Equal(t, 25, req.Blocks)
+			require.Equal(t, req.Blocks, req.Received)
+			require.Equal(t, 100*time.Millisecond, req.Start)
+			// A want and its block need at least a round trip to the closest
+			// seed.
+			require.GreaterOrEqual(t, req.TimeToFirstBlock, 100*time.Millisecond)
+			require.GreaterOrEqual(t, req.TimeToLastBlock, req.TimeToFirstBlock)
+			// The slow seed is never needed.
+			require.Less(t, req.TimeToLastBlock, 500*time.Millisecond)
+
+			fetcher := rep.Peers[2]
+			require.Equal(t, "fetcher", fetcher.Name)
+			require.EqualValues(t, 25, fetcher.BlocksReceived)
+			require.NotZero(t, fetcher.WantMessagesSent)
+			require.NotZero(t, fetcher.BytesReceived)
+			require.EqualValues(t, fetcher.BlocksReceived, rep.Peers[0].BlocksSent+rep.Peers[1].BlocksSent)
+
+			var buf bytes.Buffer
+			require.NoError(t, rep.WriteText(&buf))
+			require.Contains(t, buf.String(), "two seeds")
+		})
+	}
+}
+
+func TestRunChurn(t *testing.T) {
+	// Without seeds the request can never complete, the simulation stops
+	// once nothing is left to happen.
+	rep := runTestScenario(t, func(s *Scenario) {
+		s.Events = []Event{
+			{At: 0, Type: EventLeave, Peer: "seed"},
+			{At: 0, Type: EventDisconnect, Peer: "fetcher", With: "slow-seed"},
+		}
+	})
+	require.False(t, rep.Requests[0].Completed)
+	require.Zero(t, rep.Requests[0].Received)
+	require.Less(t, rep.Duration, time.Minute)
+
+	// The seed coming back allows the request to complete.
+	rep = runTestScenario(t, func(s *Scenario) {
+		s.Peers[0].Offline = true
+		s.Events = []Event{
+			{At: 0, Type: EventDisconnect, Peer: "fetcher", With: "slow-seed"},
+			{At: Duration(2 * time.Second), Type: EventJoin, Peer: "seed"},
+		}
+	})
+	require.True(t, rep.Requests[0].Completed)
+	require.GreaterOrEqual(t, rep.Requests[0].TimeToFirstBlock, 1900*time.Millisecond)
+}
+
+func TestParseScenarioErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name, scenario string
+	}{
+		{"unknown field", `{"nope": 1}`},
+		{"unknown dag", `{"dags": [], "peers": [{"name": "a", "has": ["x"]}]}`},
+		{"duplicate peer", `{"peers": [{"name": "a"}, {"name": "a"}]}`},
+		{"unknown request peer", `{"dags": [{"name": "d", "blocks": 1}], "requests": [{"peer": "a", "dag": "d"}]}`},
+		{"bad duration", `{"timeout": "soon"}`},
+		{"unknown event", `{"peers": [{"name": "a"}], "events": [{"type": "explode", "peer": "a"}]}`},
+		{"self connect", `{"peers": [{"name": "a"}], "events": [{"type": "connect", "peer": "a", "with": "a"}]}`},
+	} {
+		_, err := ParseScenario(strings.NewReader(tc.scenario))
+		require.Error(t, err, tc.name)
+	}
+}
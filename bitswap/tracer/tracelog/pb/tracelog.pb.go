@@ -0,0 +1,632 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.31.0
+// 	protoc        v3.21.12
+// source: tracelog.proto
+
+package pb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Event_Direction int32
+
+const (
+	Event_SENT     Event_Direction = 0
+	Event_RECEIVED Event_Direction = 1
+)
+
+// Enum value maps for Event_Direction.
+var (
+	Event_Direction_name = map[int32]string{
+		0: "SENT",
+		1: "RECEIVED",
+	}
+	Event_Direction_value = map[string]int32{
+		"SENT":     0,
+		"RECEIVED": 1,
+	}
+)
+
+func (x Event_Direction) Enum() *Event_Direction {
+	p := new(Event_Direction)
+	*p = x
+	return p
+}
+
+func (x Event_Direction) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Event_Direction) Descriptor() protoreflect.EnumDescriptor {
+	return file_tracelog_proto_enumTypes[0].Descriptor()
+}
+
+func (Event_Direction) Type() protoreflect.EnumType {
+	return &file_tracelog_proto_enumTypes[0]
+}
+
+func (x Event_Direction) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Event_Direction.Descriptor instead.
+func (Event_Direction) EnumDescriptor() ([]byte, []int) {
+	return file_tracelog_proto_rawDescGZIP(), []int{0, 0}
+}
+
+type WantEntry_WantType int32
+
+const (
+	WantEntry_BLOCK WantEntry_WantType = 0
+	WantEntry_HAVE  WantEntry_WantType = 1
+)
+
+// Enum value maps for WantEntry_WantType.
+var (
+	WantEntry_WantType_name = map[int32]string{
+		0: "BLOCK",
+		1: "HAVE",
+	}
+	WantEntry_WantType_value = map[string]int32{
+		"BLOCK": 0,
+		"HAVE":  1,
+	}
+)
+
+func (x WantEntry_WantType) Enum() *WantEntry_WantType {
+	p := new(WantEntry_WantType)
+	*p = x
+	return p
+}
+
+func (x WantEntry_WantType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (WantEntry_WantType) Descriptor() protoreflect.EnumDescriptor {
+	return file_tracelog_proto_enumTypes[1].Descriptor()
+}
+
+func (WantEntry_WantType) Type() protoreflect.EnumType {
+	return &file_tracelog_proto_enumTypes[1]
+}
+
+func (x WantEntry_WantType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use WantEntry_WantType.Descriptor instead.
+func (WantEntry_WantType) EnumDescriptor() ([]byte, []int) {
+	return file_tracelog_proto_rawDescGZIP(), []int{1, 0}
+}
+
+type BlockPresence_Type int32
+
+const (
+	BlockPresence_HAVE      BlockPresence_Type = 0
+	BlockPresence_DONT_HAVE BlockPresence_Type = 1
+)
+
+// Enum value maps for BlockPresence_Type.
+var (
+	BlockPresence_Type_name = map[int32]string{
+		0: "HAVE",
+		1: "DONT_HAVE",
+	}
+	BlockPresence_Type_value = map[string]int32{
+		"HAVE":      0,
+		"DONT_HAVE": 1,
+	}
+)
+
+func (x BlockPresence_Type) Enum() *BlockPresence_Type {
+	p := new(BlockPresence_Type)
+	*p = x
+	return p
+}
+
+func (x BlockPresence_Type) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (BlockPresence_Type) Descriptor() protoreflect.EnumDescriptor {
+	return file_tracelog_proto_enumTypes[2].Descriptor()
+}
+
+func (BlockPresence_Type) Type() protoreflect.EnumType {
+	return &file_tracelog_proto_enumTypes[2]
+}
+
+func (x BlockPresence_Type) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use BlockPresence_Type.Descriptor instead.
+func (BlockPresence_Type) EnumDescriptor() ([]byte, []int) {
+	return file_tracelog_proto_rawDescGZIP(), []int{3, 0}
+}
+
+type Event struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Timestamp    int64            `protobuf:"varint,1,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Direction    Event_Direction  `protobuf:"varint,2,opt,name=direction,proto3,enum=github.com.boxo.bitswap.tracer.tracelog.pb.Event_Direction" json:"direction,omitempty"`
+	Peer         []byte           `protobuf:"bytes,3,opt,name=peer,proto3" json:"peer,omitempty"`
+	Wants        []*WantEntry     `protobuf:"bytes,4,rep,name=wants,proto3" json:"wants,omitempty"`
+	Full         bool             `protobuf:"varint,5,opt,name=full,proto3" json:"full,omitempty"`
+	Blocks       []*Block         `protobuf:"bytes,6,rep,name=blocks,proto3" json:"blocks,omitempty"`
+	Presences    []*BlockPresence `protobuf:"bytes,7,rep,name=presences,proto3" json:"presences,omitempty"`
+	PendingBytes int32            `protobuf:"varint,8,opt,name=pendingBytes,proto3" json:"pendingBytes,omitempty"`
+}
+
+func (x *Event) Reset() {
+	*x = Event{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_tracelog_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Event) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Event) ProtoMessage() {}
+
+func (x *Event) ProtoReflect() protoreflect.Message {
+	mi := &file_tracelog_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Event.ProtoReflect.Descriptor instead.
+func (*Event) Descriptor() ([]byte, []int) {
+	return file_tracelog_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Event) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *Event) GetDirection() Event_Direction {
+	if x != nil {
+		return x.Direction
+	}
+	return Event_SENT
+}
+
+func (x *Event) GetPeer() []byte {
+	if x != nil {
+		return x.Peer
+	}
+	return nil
+}
+
+func (x *Event) GetWants() []*WantEntry {
+	if x != nil {
+		return x.Wants
+	}
+	return nil
+}
+
+func (x *Event) GetFull() bool {
+	if x != nil {
+		return x.Full
+	}
+	return false
+}
+
+func (x *Event) GetBlocks() []*Block {
+	if x != nil {
+		return x.Blocks
+	}
+	return nil
+}
+
+func (x *Event) GetPresences() []*BlockPresence {
+	if x != nil {
+		return x.Presences
+	}
+	return nil
+}
+
+func (x *Event) GetPendingBytes() int32 {
+	if x != nil {
+		return x.PendingBytes
+	}
+	return 0
+}
+
+type WantEntry struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Cid          []byte             `protobuf:"bytes,1,opt,name=cid,proto3" json:"cid,omitempty"`
+	Priority     int32              `protobuf:"varint,2,opt,name=priority,proto3" json:"priority,omitempty"`
+	Cancel       bool               `protobuf:"varint,3,opt,name=cancel,proto3" json:"cancel,omitempty"`
+	WantType     WantEntry_WantType `protobuf:"varint,4,opt,name=wantType,proto3,enum=github.com.boxo.bitswap.tracer.tracelog.pb.WantEntry_WantType" json:"wantType,omitempty"`
+	SendDontHave bool               `protobuf:"varint,5,opt,name=sendDontHave,proto3" json:"sendDontHave,omitempty"`
+}
+
+func (x *WantEntry) Reset() {
+	*x = WantEntry{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_tracelog_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *WantEntry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WantEntry) ProtoMessage() {}
+
+func (x *WantEntry) ProtoReflect() protoreflect.Message {
+	mi := &file_tracelog_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WantEntry.ProtoReflect.Descriptor instead.
+func (*WantEntry) Descriptor() ([]byte, []int) {
+	return file_tracelog_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *WantEntry) GetCid() []byte {
+	if x != nil {
+		return x.Cid
+	}
+	return nil
+}
+
+func (x *WantEntry) GetPriority() int32 {
+	if x != nil {
+		return x.Priority
+	}
+	return 0
+}
+
+func (x *WantEntry) GetCancel() bool {
+	if x != nil {
+		return x.Cancel
+	}
+	return false
+}
+
+func (x *WantEntry) GetWantType() WantEntry_WantType {
+	if x != nil {
+		return x.WantType
+	}
+	return WantEntry_BLOCK
+}
+
+func (x *WantEntry) GetSendDontHave() bool {
+	if x != nil {
+		return x.SendDontHave
+	}
+	return false
+}
+
+type Block struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Cid  []byte `protobuf:"bytes,1,opt,name=cid,proto3" json:"cid,omitempty"`
+	Size uint64 `protobuf:"varint,2,opt,name=size,proto3" json:"size,omitempty"`
+}
+
+func (x *Block) Reset() {
+	*x = Block{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_tracelog_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Block) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Block) ProtoMessage() {}
+
+func (x *Block) ProtoReflect() protoreflect.Message {
+	mi := &file_tracelog_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Block.ProtoReflect.Descriptor instead.
+func (*Block) Descriptor() ([]byte, []int) {
+	return file_tracelog_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Block) GetCid() []byte {
+	if x != nil {
+		return x.Cid
+	}
+	return nil
+}
+
+func (x *Block) GetSize() uint64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+type BlockPresence struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Cid  []byte             `protobuf:"bytes,1,opt,name=cid,proto3" json:"cid,omitempty"`
+	Type BlockPresence_Type `protobuf:"varint,2,opt,name=type,proto3,enum=github.com.boxo.bitswap.tracer.tracelog.pb.BlockPresence_Type" json:"type,omitempty"`
+}
+
+func (x *BlockPresence) Reset() {
+	*x = BlockPresence{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_tracelog_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *BlockPresence) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BlockPresence) ProtoMessage() {}
+
+func (x *BlockPresence) ProtoReflect() protoreflect.Message {
+	mi := &file_tracelog_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BlockPresence.ProtoReflect.Descriptor instead.
+func (*BlockPresence) Descriptor() ([]byte, []int) {
+	return file_tracelog_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *BlockPresence) GetCid() []byte {
+	if x != nil {
+		return x.Cid
+	}
+	return nil
+}
+
+func (x *BlockPresence) GetType() BlockPresence_Type {
+	if x != nil {
+		return x.Type
+	}
+	return BlockPresence_HAVE
+}
+
+var File_tracelog_proto protoreflect.FileDescriptor
+
+var file_tracelog_proto_rawDesc = []byte{
+	0x0a, 0x0e, 0x74, 0x72, 0x61, 0x63, 0x65, 0x6c, 0x6f, 0x67, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x12, 0x2a, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2e, 0x62, 0x6f, 0x78,
+	0x6f, 0x2e, 0x62, 0x69, 0x74, 0x73, 0x77, 0x61, 0x70, 0x2e, 0x74, 0x72, 0x61, 0x63, 0x65, 0x72,
+	0x2e, 0x74, 0x72, 0x61, 0x63, 0x65, 0x6c, 0x6f, 0x67, 0x2e, 0x70, 0x62, 0x22, 0xe2, 0x03, 0x0a,
+	0x05, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x12, 0x1c, 0x0a, 0x09, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74,
+	0x61, 0x6d, 0x70, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x09, 0x74, 0x69, 0x6d, 0x65, 0x73,
+	0x74, 0x61, 0x6d, 0x70, 0x12, 0x59, 0x0a, 0x09, 0x64, 0x69, 0x72, 0x65, 0x63, 0x74, 0x69, 0x6f,
+	0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x3b, 0x2e, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62,
+	0x2e, 0x63, 0x6f, 0x6d, 0x2e, 0x62, 0x6f, 0x78, 0x6f, 0x2e, 0x62, 0x69, 0x74, 0x73, 0x77, 0x61,
+	0x70, 0x2e, 0x74, 0x72, 0x61, 0x63, 0x65, 0x72, 0x2e, 0x74, 0x72, 0x61, 0x63, 0x65, 0x6c, 0x6f,
+	0x67, 0x2e, 0x70, 0x62, 0x2e, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x2e, 0x44, 0x69, 0x72, 0x65, 0x63,
+	0x74, 0x69, 0x6f, 0x6e, 0x52, 0x09, 0x64, 0x69, 0x72, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12,
+	0x12, 0x0a, 0x04, 0x70, 0x65, 0x65, 0x72, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x70,
+	0x65, 0x65, 0x72, 0x12, 0x4b, 0x0a, 0x05, 0x77, 0x61, 0x6e, 0x74, 0x73, 0x18, 0x04, 0x20, 0x03,
+	0x28, 0x0b, 0x32, 0x35, 0x2e, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2e,
+	0x62, 0x6f, 0x78, 0x6f, 0x2e, 0x62, 0x69, 0x74, 0x73, 0x77, 0x61, 0x70, 0x2e, 0x74, 0x72, 0x61,
+	0x63, 0x65, 0x72, 0x2e, 0x74, 0x72, 0x61, 0x63, 0x65, 0x6c, 0x6f, 0x67, 0x2e, 0x70, 0x62, 0x2e,
+	0x57, 0x61, 0x6e, 0x74, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x05, 0x77, 0x61, 0x6e, 0x74, 0x73,
+	0x12, 0x12, 0x0a, 0x04, 0x66, 0x75, 0x6c, 0x6c, 0x18, 0x05, 0x20, 0x01, 0x28, 0x08, 0x52, 0x04,
+	0x66, 0x75, 0x6c, 0x6c, 0x12, 0x49, 0x0a, 0x06, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x73, 0x18, 0x06,
+	0x20, 0x03, 0x28, 0x0b, 0x32, 0x31, 0x2e, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f,
+	0x6d, 0x2e, 0x62, 0x6f, 0x78, 0x6f, 0x2e, 0x62, 0x69, 0x74, 0x73, 0x77, 0x61, 0x70, 0x2e, 0x74,
+	0x72, 0x61, 0x63, 0x65, 0x72, 0x2e, 0x74, 0x72, 0x61, 0x63, 0x65, 0x6c, 0x6f, 0x67, 0x2e, 0x70,
+	0x62, 0x2e, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x52, 0x06, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x73, 0x12,
+	0x57, 0x0a, 0x09, 0x70, 0x72, 0x65, 0x73, 0x65, 0x6e, 0x63, 0x65, 0x73, 0x18, 0x07, 0x20, 0x03,
+	0x28, 0x0b, 0x32, 0x39, 0x2e, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2e,
+	0x62, 0x6f, 0x78, 0x6f, 0x2e, 0x62, 0x69, 0x74, 0x73, 0x77, 0x61, 0x70, 0x2e, 0x74, 0x72, 0x61,
+	0x63, 0x65, 0x72, 0x2e, 0x74, 0x72, 0x61, 0x63, 0x65, 0x6c, 0x6f, 0x67, 0x2e, 0x70, 0x62, 0x2e,
+	0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x50, 0x72, 0x65, 0x73, 0x65, 0x6e, 0x63, 0x65, 0x52, 0x09, 0x70,
+	0x72, 0x65, 0x73, 0x65, 0x6e, 0x63, 0x65, 0x73, 0x12, 0x22, 0x0a, 0x0c, 0x70, 0x65, 0x6e, 0x64,
+	0x69, 0x6e, 0x67, 0x42, 0x79, 0x74, 0x65, 0x73, 0x18, 0x08, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0c,
+	0x70, 0x65, 0x6e, 0x64, 0x69, 0x6e, 0x67, 0x42, 0x79, 0x74, 0x65, 0x73, 0x22, 0x23, 0x0a, 0x09,
+	0x44, 0x69, 0x72, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x08, 0x0a, 0x04, 0x53, 0x45, 0x4e,
+	0x54, 0x10, 0x00, 0x12, 0x0c, 0x0a, 0x08, 0x52, 0x45, 0x43, 0x45, 0x49, 0x56, 0x45, 0x44, 0x10,
+	0x01, 0x22, 0xf2, 0x01, 0x0a, 0x09, 0x57, 0x61, 0x6e, 0x74, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12,
+	0x10, 0x0a, 0x03, 0x63, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x03, 0x63, 0x69,
+	0x64, 0x12, 0x1a, 0x0a, 0x08, 0x70, 0x72, 0x69, 0x6f, 0x72, 0x69, 0x74, 0x79, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x05, 0x52, 0x08, 0x70, 0x72, 0x69, 0x6f, 0x72, 0x69, 0x74, 0x79, 0x12, 0x16, 0x0a,
+	0x06, 0x63, 0x61, 0x6e, 0x63, 0x65, 0x6c, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52, 0x06, 0x63,
+	0x61, 0x6e, 0x63, 0x65, 0x6c, 0x12, 0x5a, 0x0a, 0x08, 0x77, 0x61, 0x6e, 0x74, 0x54, 0x79, 0x70,
+	0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x3e, 0x2e, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62,
+	0x2e, 0x63, 0x6f, 0x6d, 0x2e, 0x62, 0x6f, 0x78, 0x6f, 0x2e, 0x62, 0x69, 0x74, 0x73, 0x77, 0x61,
+	0x70, 0x2e, 0x74, 0x72, 0x61, 0x63, 0x65, 0x72, 0x2e, 0x74, 0x72, 0x61, 0x63, 0x65, 0x6c, 0x6f,
+	0x67, 0x2e, 0x70, 0x62, 0x2e, 0x57, 0x61, 0x6e, 0x74, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x2e, 0x57,
+	0x61, 0x6e, 0x74, 0x54, 0x79, 0x70, 0x65, 0x52, 0x08, 0x77, 0x61, 0x6e, 0x74, 0x54, 0x79, 0x70,
+	0x65, 0x12, 0x22, 0x0a, 0x0c, 0x73, 0x65, 0x6e, 0x64, 0x44, 0x6f, 0x6e, 0x74, 0x48, 0x61, 0x76,
+	0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0c, 0x73, 0x65, 0x6e, 0x64, 0x44, 0x6f, 0x6e,
+	0x74, 0x48, 0x61, 0x76, 0x65, 0x22, 0x1f, 0x0a, 0x08, 0x57, 0x61, 0x6e, 0x74, 0x54, 0x79, 0x70,
+	0x65, 0x12, 0x09, 0x0a, 0x05, 0x42, 0x4c, 0x4f, 0x43, 0x4b, 0x10, 0x00, 0x12, 0x08, 0x0a, 0x04,
+	0x48, 0x41, 0x56, 0x45, 0x10, 0x01, 0x22, 0x2d, 0x0a, 0x05, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x12,
+	0x10, 0x0a, 0x03, 0x63, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x03, 0x63, 0x69,
+	0x64, 0x12, 0x12, 0x0a, 0x04, 0x73, 0x69, 0x7a, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52,
+	0x04, 0x73, 0x69, 0x7a, 0x65, 0x22, 0x96, 0x01, 0x0a, 0x0d, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x50,
+	0x72, 0x65, 0x73, 0x65, 0x6e, 0x63, 0x65, 0x12, 0x10, 0x0a, 0x03, 0x63, 0x69, 0x64, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x0c, 0x52, 0x03, 0x63, 0x69, 0x64, 0x12, 0x52, 0x0a, 0x04, 0x74, 0x79, 0x70,
+	0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x3e, 0x2e, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62,
+	0x2e, 0x63, 0x6f, 0x6d, 0x2e, 0x62, 0x6f, 0x78, 0x6f, 0x2e, 0x62, 0x69, 0x74, 0x73, 0x77, 0x61,
+	0x70, 0x2e, 0x74, 0x72, 0x61, 0x63, 0x65, 0x72, 0x2e, 0x74, 0x72, 0x61, 0x63, 0x65, 0x6c, 0x6f,
+	0x67, 0x2e, 0x70, 0x62, 0x2e, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x50, 0x72, 0x65, 0x73, 0x65, 0x6e,
+	0x63, 0x65, 0x2e, 0x54, 0x79, 0x70, 0x65, 0x52, 0x04, 0x74, 0x79, 0x70, 0x65, 0x22, 0x1f, 0x0a,
+	0x04, 0x54, 0x79, 0x70, 0x65, 0x12, 0x08, 0x0a, 0x04, 0x48, 0x41, 0x56, 0x45, 0x10, 0x00, 0x12,
+	0x0d, 0x0a, 0x09, 0x44, 0x4f, 0x4e, 0x54, 0x5f, 0x48, 0x41, 0x56, 0x45, 0x10, 0x01, 0x42, 0x06,
+	0x5a, 0x04, 0x2e, 0x3b, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_tracelog_proto_rawDescOnce sync.Once
+	file_tracelog_proto_rawDescData = file_tracelog_proto_rawDesc
+)
+
+func file_tracelog_proto_rawDescGZIP() []byte {
+	file_tracelog_proto_rawDescOnce.Do(func() {
+		file_tracelog_proto_rawDescData = protoimpl.X.CompressGZIP(file_tracelog_proto_rawDescData)
+	})
+	return file_tracelog_proto_rawDescData
+}
+
+var file_tracelog_proto_enumTypes = make([]protoimpl.EnumInfo, 3)
+var file_tracelog_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_tracelog_proto_goTypes = []interface{}{
+	(Event_Direction)(0),    // 0: github.com.boxo.bitswap.tracer.tracelog.pb.Event.Direction
+	(WantEntry_WantType)(0), // 1: github.com.boxo.bitswap.tracer.tracelog.pb.WantEntry.WantType
+	(BlockPresence_Type)(0), // 2: github.com.boxo.bitswap.tracer.tracelog.pb.BlockPresence.Type
+	(*Event)(nil),           // 3: github.com.boxo.bitswap.tracer.tracelog.pb.Event
+	(*WantEntry)(nil),       // 4: github.com.boxo.bitswap.tracer.tracelog.pb.WantEntry
+	(*Block)(nil),           // 5: github.com.boxo.bitswap.tracer.tracelog.pb.Block
+	(*BlockPresence)(nil),   // 6: github.com.boxo.bitswap.tracer.tracelog.pb.BlockPresence
+}
+var file_tracelog_proto_depIdxs = []int32{
+	0, // 0: github.com.boxo.bitswap.tracer.tracelog.pb.Event.direction:type_name -> github.com.boxo.bitswap.tracer.tracelog.pb.Event.Direction
+	4, // 1: github.com.boxo.bitswap.tracer.tracelog.pb.Event.wants:type_name -> github.com.boxo.bitswap.tracer.tracelog.pb.WantEntry
+	5, // 2: github.com.boxo.bitswap.tracer.tracelog.pb.Event.blocks:type_name -> github.com.boxo.bitswap.tracer.tracelog.pb.Block
+	6, // 3: github.com.boxo.bitswap.tracer.tracelog.pb.Event.presences:type_name -> github.com.boxo.bitswap.tracer.tracelog.pb.BlockPresence
+	1, // 4: github.com.boxo.bitswap.tracer.tracelog.pb.WantEntry.wantType:type_name -> github.com.boxo.bitswap.tracer.tracelog.pb.WantEntry.WantType
+	2, // 5: github.com.boxo.bitswap.tracer.tracelog.pb.BlockPresence.type:type_name -> github.com.boxo.bitswap.tracer.tracelog.pb.BlockPresence.Type
+	6, // [6:6] is the sub-list for method output_type
+	6, // [6:6] is the sub-list for method input_type
+	6, // [6:6] is the sub-list for extension type_name
+	6, // [6:6] is the sub-list for extension extendee
+	0, // [0:6] is the sub-list for field type_name
+}
+
+func init() { file_tracelog_proto_init() }
+func file_tracelog_proto_init() {
+	if File_tracelog_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_tracelog_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Event); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_tracelog_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*WantEntry); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_tracelog_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Block); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_tracelog_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*BlockPresence); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_tracelog_proto_rawDesc,
+			NumEnums:      3,
+			NumMessages:   4,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_tracelog_proto_goTypes,
+		DependencyIndexes: file_tracelog_proto_depIdxs,
+		EnumInfos:         file_tracelog_proto_enumTypes,
+		MessageInfos:      file_tracelog_proto_msgTypes,
+	}.Build()
+	File_tracelog_proto = out.File
+	file_tracelog_proto_rawDesc = nil
+	file_tracelog_proto_goTypes = nil
+	file_tracelog_proto_depIdxs = nil
+}
@@ -0,0 +1,146 @@
+package tracelog
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ipfs/boxo/bitswap/tracer/tracelog/pb"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Counts aggregates the contents of messages.
+type Counts struct {
+	Messages   int
+	WantBlocks int
+	WantHaves  int
+	Cancels    int
+	Blocks     int
+	BlockBytes uint64
+	Haves      int
+	DontHaves  int
+}
+
+// Add counts the contents of ev.
+func (c *Counts) Add(ev *pb.Event) {
+	c.Messages++
+	for _, w := range ev.Wants {
+		switch {
+		case w.Cancel:
+			c.Cancels++
+		case w.WantType == pb.WantEntry_HAVE:
+			c.WantHaves++
+		default:
+			c.WantBlocks++
+		}
+	}
+	c.Blocks += len(ev.Blocks)
+	for _, b := range ev.Blocks {
+		c.BlockBytes += b.Size
+	}
+	for _, p := range ev.Presences {
+		if p.Type == pb.BlockPresence_DONT_HAVE {
+			c.DontHaves++
+		} else {
+			c.Haves++
+		}
+	}
+}
+
+// PeerSummary sums up the messages exchanged with a peer.
+type PeerSummary struct {
+	Peer     peer.ID
+	Sent     Counts
+	Received Counts
+	// First and Last are the times of the first and last messages.
+	First, Last time.Time
+}
+
+// Summary sums up a log.
+type Summary struct {
+	Events      int
+	First, Last time.Time
+	Sent        Counts
+	Received    Counts
+	// Peers are sorted by number of messages, busiest first.
+	Peers []PeerSummary
+}
+
+// Summarize reads the log until its end and sums it up per peer.
+func Summarize(r *Reader) (*Summary, error) {
+	s := &Summary{}
+	peers := make(map[peer.ID]*PeerSummary)
+	for {
+		ev, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		t := time.Unix(0, ev.Timestamp)
+		if s.Events == 0 || t.Before(s.First) {
+			s.First = t
+		}
+		if t.After(s.Last) {
+			s.Last = t
+		}
+		s.Events++
+
+		p := peer.ID(ev.Peer)
+		ps, ok := peers[p]
+		if !ok {
+			ps = &PeerSummary{Peer: p, First: t}
+			peers[p] = ps
+		}
+		if t.Before(ps.First) {
+			ps.First = t
+		}
+		if t.After(ps.Last) {
+			ps.Last = t
+		}
+		if ev.Direction == pb.Event_SENT {
+			ps.Sent.Add(ev)
+			s.Sent.Add(ev)
+		} else {
+			ps.Received.Add(ev)
+			s.Received.Add(ev)
+		}
+	}
+
+	for _, ps := range peers {
+		s.Peers = append(s.Peers, *ps)
+	}
+	sort.Slice(s.Peers, func(i, j int) bool {
+		a, b := s.Peers[i], s.Peers[j]
+		na, nb := a.Sent.Messages+a.Received.Messages, b.Sent.Messages+b.Received.Messages
+		if na != nb {
+			return na > nb
+		}
+		return a.Peer < b.Peer
+	})
+	return s, nil
+}
+
+// WriteText writes the summary as a human readable table.
+func (s *Summary) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "%d events from %s to %s (%s), %d peers\n\n",
+		s.Events, s.First.UTC().Format(time.RFC3339Nano), s.Last.UTC().Format(time.RFC3339Nano), s.Last.Sub(s.First), len(s.Peers))
+
+	fmt.Fprintln(tw, "PEER\tDIR\tMSGS\tWANT-BLOCK\tWANT-HAVE\tCANCEL\tBLOCKS\tBLOCK BYTES\tHAVE\tDONT-HAVE")
+	row := func(name, dir string, c Counts) {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t%d\n",
+			name, dir, c.Messages, c.WantBlocks, c.WantHaves, c.Cancels, c.Blocks, c.BlockBytes, c.Haves, c.DontHaves)
+	}
+	for _, p := range s.Peers {
+		row(p.Peer.String(), "sent", p.Sent)
+		row("", "recv", p.Received)
+	}
+	row("total", "sent", s.Sent)
+	row("", "recv", s.Received)
+	return tw.Flush()
+}
@@ -0,0 +1,193 @@
+// Package tracelog records the messages seen by a bitswap node to a compact
+// log, and reads and summarizes such logs. The cmd/bitswap-tracelog tool also
+// replays them.
+//
+// A log is a sequence of [pb.Event] protobufs, each prefixed by its size as an
+// unsigned varint. Events record the wantlist entries, the block CIDs and
+// sizes, and the HAVEs and DONT_HAVEs of a message, but not the block data.
+package tracelog
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	bsmsg "github.com/ipfs/boxo/bitswap/message"
+	bsmsgpb "github.com/ipfs/boxo/bitswap/message/pb"
+	"github.com/ipfs/boxo/bitswap/tracer"
+	"github.com/ipfs/boxo/bitswap/tracer/tracelog/pb"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"google.golang.org/protobuf/proto"
+)
+
+//go:generate protoc -I=pb --go_out=pb pb/tracelog.proto
+
+// MaxEventSize is the size of the largest event a Reader accepts.
+const MaxEventSize = 4 << 20
+
+// ErrEventTooLarge is returned by Reader.Next for events over MaxEventSize.
+var ErrEventTooLarge = errors.New("tracelog: event too large")
+
+var _ tracer.Tracer = (*Recorder)(nil)
+
+// Recorder is a [tracer.Tracer] that writes every message to a log.
+//
+// Writes are buffered, Flush or Close must be called to write out the end of
+// the log. The first write error is kept and returned by Err, Flush and Close,
+// the messages traced after it are dropped.
+type Recorder struct {
+	mu  sync.Mutex
+	w   *bufio.Writer
+	c   io.Closer
+	buf []byte
+	err error
+}
+
+// NewRecorder returns a Recorder writing to w. Close closes w if it is an
+// [io.Closer].
+func NewRecorder(w io.Writer) *Recorder {
+	r := &Recorder{w: bufio.NewWriter(w)}
+	if c, ok := w.(io.Closer); ok {
+		r.c = c
+	}
+	return r
+}
+
+// MessageReceived records a message received from p.
+func (r *Recorder) MessageReceived(p peer.ID, msg bsmsg.BitSwapMessage) {
+	r.record(NewEvent(time.Now(), pb.Event_RECEIVED, p, msg))
+}
+
+// MessageSent records a message sent to p.
+func (r *Recorder) MessageSent(p peer.ID, msg bsmsg.BitSwapMessage) {
+	r.record(NewEvent(time.Now(), pb.Event_SENT, p, msg))
+}
+
+func (r *Recorder) record(ev *pb.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.err != nil {
+		return
+	}
+	var err error
+	r.buf, err = proto.MarshalOptions{}.MarshalAppend(r.buf[:0], ev)
+	if err != nil {
+		r.err = err
+		return
+	}
+	var prefix [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(prefix[:], uint64(len(r.buf)))
+	if _, err = r.w.Write(prefix[:n]); err == nil {
+		_, err = r.w.Write(r.buf)
+	}
+	r.err = err
+}
+
+// Err returns the first error met while recording.
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Flush writes out the buffered events.
+func (r *Recorder) Flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.err == nil {
+		r.err = r.w.Flush()
+	}
+	return r.err
+}
+
+// Close flushes the log and closes the underlying writer.
+func (r *Recorder) Close() error {
+	err := r.Flush()
+	if r.c != nil {
+		if cerr := r.c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// NewEvent converts a message sent to or received from p at time t.
+func NewEvent(t time.Time, dir pb.Event_Direction, p peer.ID, msg bsmsg.BitSwapMessage) *pb.Event {
+	ev := &pb.Event{
+		Timestamp:    t.UnixNano(),
+		Direction:    dir,
+		Peer:         []byte(p),
+		Full:         msg.Full(),
+		PendingBytes: msg.PendingBytes(),
+	}
+	for _, e := range msg.Wantlist() {
+		want := &pb.WantEntry{
+			Cid:          e.Cid.Bytes(),
+			Priority:     e.Priority,
+			Cancel:       e.Cancel,
+			SendDontHave: e.SendDontHave,
+		}
+		if e.WantType == bsmsgpb.Message_Wantlist_Have {
+			want.WantType = pb.WantEntry_HAVE
+		}
+		ev.Wants = append(ev.Wants, want)
+	}
+	for _, b := range msg.Blocks() {
+		ev.Blocks = append(ev.Blocks, &pb.Block{
+			Cid:  b.Cid().Bytes(),
+			Size: uint64(len(b.RawData())),
+		})
+	}
+	for _, bp := range msg.BlockPresences() {
+		presence := &pb.BlockPresence{Cid: bp.Cid.Bytes()}
+		if bp.Type == bsmsgpb.Message_DontHave {
+			presence.Type = pb.BlockPresence_DONT_HAVE
+		}
+		ev.Presences = append(ev.Presences, presence)
+	}
+	return ev
+}
+
+// Reader reads the events of a log.
+type Reader struct {
+	r   *bufio.Reader
+	buf []byte
+}
+
+// NewReader returns a Reader reading the log from r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+// Next returns the next event of the log, or [io.EOF] at its end. A log cut
+// in the middle of an event returns [io.ErrUnexpectedEOF].
+func (r *Reader) Next() (*pb.Event, error) {
+	size, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return nil, err
+	}
+	if size > MaxEventSize {
+		return nil, ErrEventTooLarge
+	}
+	if uint64(cap(r.buf)) < size {
+		r.buf = make([]byte, size)
+	}
+	r.buf = r.buf[:size]
+	if _, err := io.ReadFull(r.r, r.buf); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	ev := new(pb.Event)
+	if err := proto.Unmarshal(r.buf, ev); err != nil {
+		return nil, fmt.Errorf("tracelog: decoding event: %w", err)
+	}
+	return ev, nil
+}
@@ -0,0 +1,117 @@
+package tracelog
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	bsmsg "github.com/ipfs/boxo/bitswap/message"
+	bsmsgpb "github.com/ipfs/boxo/bitswap/message/pb"
+	"github.com/ipfs/boxo/bitswap/tracer/tracelog/pb"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	peerA = peer.ID("peer-a")
+	peerB = peer.ID("peer-b")
+)
+
+var (
+	present = blocks.NewBlock([]byte("present"))
+	missing = blocks.NewBlock([]byte("missing"))
+)
+
+func wantMessage() bsmsg.BitSwapMessage {
+	msg := bsmsg.New(false)
+	msg.AddEntry(present.Cid(), 10, bsmsgpb.Message_Wantlist_Block, true)
+	msg.AddEntry(missing.Cid(), 5, bsmsgpb.Message_Wantlist_Have, true)
+	return msg
+}
+
+func responseMessage() bsmsg.BitSwapMessage {
+	msg := bsmsg.New(false)
+	msg.AddBlock(present)
+	msg.AddDontHave(missing.Cid())
+	return msg
+}
+
+func recordTestLog(t *testing.T) []byte {
+	var buf bytes.Buffer
+	rec := NewRecorder(&buf)
+	rec.MessageReceived(peerA, wantMessage())
+	rec.MessageSent(peerA, responseMessage())
+	cancel := bsmsg.New(false)
+	cancel.Cancel(missing.Cid())
+	rec.MessageReceived(peerB, cancel)
+	require.NoError(t, rec.Close())
+	return buf.Bytes()
+}
+
+func TestRecordAndRead(t *testing.T) {
+	log := recordTestLog(t)
+	r := NewReader(bytes.NewReader(log))
+
+	ev, err := r.Next()
+	require.NoError(t, err)
+	require.Equal(t, pb.Event_RECEIVED, ev.Direction)
+	require.Equal(t, peerA, peer.ID(ev.Peer))
+	require.WithinDuration(t, time.Now(), time.Unix(0, ev.Timestamp), time.Minute)
+	require.Len(t, ev.Wants, 2)
+	for _, w := range ev.Wants {
+		if bytes.Equal(w.Cid, present.Cid().Bytes()) {
+			require.Equal(t, pb.WantEntry_BLOCK, w.WantType)
+			require.EqualValues(t, 10, w.Priority)
+		} else {
+			require.Equal(t, missing.Cid().Bytes(), w.Cid)
+			require.Equal(t, pb.WantEntry_HAVE, w.WantType)
+		}
+		require.True(t, w.SendDontHave)
+	}
+
+	ev, err = r.Next()
+	require.NoError(t, err)
+	require.Equal(t, pb.Event_SENT, ev.Direction)
+	require.Len(t, ev.Blocks, 1)
+	require.Equal(t, present.Cid().Bytes(), ev.Blocks[0].Cid)
+	require.EqualValues(t, len(present.RawData()), ev.Blocks[0].Size)
+	require.Len(t, ev.Presences, 1)
+	require.Equal(t, pb.BlockPresence_DONT_HAVE, ev.Presences[0].Type)
+
+	ev, err = r.Next()
+	require.NoError(t, err)
+	require.Equal(t, peerB, peer.ID(ev.Peer))
+	require.True(t, ev.Wants[0].Cancel)
+
+	_, err = r.Next()
+	require.ErrorIs(t, err, io.EOF)
+
+	// A log cut short is reported.
+	r = NewReader(bytes.NewReader(log[:len(log)-1]))
+	for i := 0; i < 2; i++ {
+		_, err = r.Next()
+		require.NoError(t, err)
+	}
+	_, err = r.Next()
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestSummarize(t *testing.T) {
+	s, err := Summarize(NewReader(bytes.NewReader(recordTestLog(t))))
+	require.NoError(t, err)
+
+	require.Equal(t, 3, s.Events)
+	require.Len(t, s.Peers, 2)
+	a := s.Peers[0]
+	require.Equal(t, peerA, a.Peer)
+	require.Equal(t, Counts{Messages: 1, WantBlocks: 1, WantHaves: 1}, a.Received)
+	require.Equal(t, Counts{Messages: 1, Blocks: 1, BlockBytes: uint64(len(present.RawData())), DontHaves: 1}, a.Sent)
+	require.Equal(t, Counts{Messages: 1, Cancels: 1}, s.Peers[1].Received)
+	require.Equal(t, 2, s.Received.Messages)
+
+	var buf bytes.Buffer
+	require.NoError(t, s.WriteText(&buf))
+	require.Contains(t, buf.String(), "3 events")
+}
@@ -13,6 +13,13 @@ type CacheOpts struct {
 	HasBloomFilterSize   int // 1 byte
 	HasBloomFilterHashes int // No size, 7 is usually best, consult bloom papers
 	HasTwoQueueCacheSize int // 32 bytes
+
+	// BlockDataCacheSize is the total size in bytes of the block data kept in
+	// memory to serve Get, GetSize and View. Zero disables the data cache.
+	BlockDataCacheSize int // 1 byte
+	// BlockDataCacheMaxBlockSize is the size of the largest block admitted
+	// into the data cache.
+	BlockDataCacheMaxBlockSize int // No size
 }
 
 // DefaultCacheOpts returns a CacheOpts initialized with default values.
@@ -21,11 +28,15 @@ func DefaultCacheOpts() CacheOpts {
 		HasBloomFilterSize:   512 << 10,
 		HasBloomFilterHashes: 7,
 		HasTwoQueueCacheSize: 64 << 10,
+
+		BlockDataCacheSize:         0,
+		BlockDataCacheMaxBlockSize: 64 << 10,
 	}
 }
 
-// CachedBlockstore returns a blockstore wrapped in an TwoQueueCache and
-// then in a bloom filter cache, if the options indicate it.
+// CachedBlockstore returns a blockstore wrapped in a block data cache, then in
+// an TwoQueueCache and then in a bloom filter cache, if the options indicate
+// it.
 func CachedBlockstore(
 	ctx context.Context,
 	bs Blockstore,
@@ -34,7 +45,8 @@ func CachedBlockstore(
 	cbs = bs
 
 	if opts.HasBloomFilterSize < 0 || opts.HasBloomFilterHashes < 0 ||
-		opts.HasTwoQueueCacheSize < 0 {
+		opts.HasTwoQueueCacheSize < 0 || opts.BlockDataCacheSize < 0 ||
+		opts.BlockDataCacheMaxBlockSize < 0 {
 		return nil, errors.New("all options for cache need to be greater than zero")
 	}
 
@@ -44,6 +56,12 @@ func CachedBlockstore(
 
 	ctx = metrics.CtxSubScope(ctx, "bs.cache")
 
+	if opts.BlockDataCacheSize > 0 {
+		cbs, err = newDataCachedBS(ctx, cbs, opts.BlockDataCacheSize, opts.BlockDataCacheMaxBlockSize)
+		if err != nil {
+			return nil, err
+		}
+	}
 	if opts.HasTwoQueueCacheSize > 0 {
 		cbs, err = newTwoQueueCachedBS(ctx, cbs, opts.HasTwoQueueCacheSize)
 	}
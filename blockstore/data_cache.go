@@ -0,0 +1,255 @@
+package blockstore
+
+import (
+	"context"
+	"math"
+	"sync"
+
+	"github.com/hashicorp/golang-lru/v2/simplelru"
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	metrics "github.com/ipfs/go-metrics-interface"
+)
+
+// datacache wraps a BlockStore with an LRU cache of block data. Unlike
+// [tqcache], the cache is bounded by the total size of the blocks it holds
+// rather than by a number of entries, and only blocks up to a maximum size are
+// admitted, so that a few large blocks cannot evict many small hot ones.
+//
+// Blocks are keyed by multihash. The cache is filled on reads and invalidated
+// on writes and deletions.
+type datacache struct {
+	keyLocks
+
+	lk      sync.Mutex
+	cache   *simplelru.LRU[string, []byte]
+	size    int
+	maxSize int
+
+	maxBlockSize int
+
+	blockstore Blockstore
+	viewer     Viewer
+
+	hits  metrics.Counter
+	total metrics.Counter
+	bytes metrics.Gauge
+}
+
+var (
+	_ Blockstore = (*datacache)(nil)
+	_ Viewer     = (*datacache)(nil)
+)
+
+func newDataCachedBS(ctx context.Context, bs Blockstore, cacheSize, maxBlockSize int) (*datacache, error) {
+	c := &datacache{
+		blockstore:   bs,
+		maxSize:      cacheSize,
+		maxBlockSize: maxBlockSize,
+	}
+	if c.maxBlockSize > c.maxSize {
+		c.maxBlockSize = c.maxSize
+	}
+
+	// The number of entries is unbounded, the size is enforced in add.
+	cache, err := simplelru.NewLRU[string, []byte](math.MaxInt, func(_ string, data []byte) {
+		c.size -= len(data)
+	})
+	if err != nil {
+		return nil, err
+	}
+	c.cache = cache
+
+	c.hits = metrics.NewCtx(ctx, "boxo_blockstore.data_cache_hits", "Number of blockstore data cache hits").Counter()
+	c.total = metrics.NewCtx(ctx, "boxo_blockstore.data_cache_total", "Total number of blockstore data cache requests").Counter()
+	c.bytes = metrics.NewCtx(ctx, "boxo_blockstore.data_cache_bytes", "Size of the blocks held by the blockstore data cache").Gauge()
+	if v, ok := bs.(Viewer); ok {
+		c.viewer = v
+	}
+	return c, nil
+}
+
+// get returns the cached data for key, if any. The returned slice must not be
+// modified.
+func (b *datacache) get(key string) ([]byte, bool) {
+	b.total.Inc()
+
+	b.lk.Lock()
+	data, ok := b.cache.Get(key)
+	b.lk.Unlock()
+	if ok {
+		b.hits.Inc()
+	}
+	return data, ok
+}
+
+// add caches data under key if it is small enough to be admitted. The cache
+// takes ownership of data.
+func (b *datacache) add(key string, data []byte) {
+	if len(data) > b.maxBlockSize {
+		return
+	}
+
+	b.lk.Lock()
+	defer b.lk.Unlock()
+
+	b.cache.Remove(key)
+	b.cache.Add(key, data)
+	b.size += len(data)
+	for b.size > b.maxSize {
+		b.cache.RemoveOldest()
+	}
+	b.bytes.Set(float64(b.size))
+}
+
+func (b *datacache) invalidate(key string) {
+	b.lk.Lock()
+	defer b.lk.Unlock()
+
+	if b.cache.Remove(key) {
+		b.bytes.Set(float64(b.size))
+	}
+}
+
+func (b *datacache) DeleteBlock(ctx context.Context, k cid.Cid) error {
+	if !k.Defined() {
+		return nil
+	}
+
+	key := cacheKey(k)
+
+	b.lock(key, true)
+	defer b.unlock(key, true)
+
+	b.invalidate(key)
+	return b.blockstore.DeleteBlock(ctx, k)
+}
+
+func (b *datacache) Has(ctx context.Context, k cid.Cid) (bool, error) {
+	return b.blockstore.Has(ctx, k)
+}
+
+func (b *datacache) GetSize(ctx context.Context, k cid.Cid) (int, error) {
+	if k.Defined() {
+		if data, ok := b.get(cacheKey(k)); ok {
+			return len(data), nil
+		}
+	}
+	return b.blockstore.GetSize(ctx, k)
+}
+
+func (b *datacache) View(ctx context.Context, k cid.Cid, callback func([]byte) error) error {
+	// shortcircuit and fall back to Get if the underlying store
+	// doesn't support Viewer.
+	if b.viewer == nil {
+		blk, err := b.Get(ctx, k)
+		if err != nil {
+			return err
+		}
+		return callback(blk.RawData())
+	}
+
+	if !k.Defined() {
+		return ipld.ErrNotFound{Cid: k}
+	}
+
+	key := cacheKey(k)
+
+	if data, ok := b.get(key); ok {
+		return callback(data)
+	}
+
+	b.lock(key, false)
+	defer b.unlock(key, false)
+
+	return b.viewer.View(ctx, k, func(buf []byte) error {
+		// buf must not be retained, cache a copy.
+		if len(buf) <= b.maxBlockSize {
+			b.add(key, append([]byte(nil), buf...))
+		}
+		return callback(buf)
+	})
+}
+
+func (b *datacache) Get(ctx context.Context, k cid.Cid) (blocks.Block, error) {
+	if !k.Defined() {
+		return nil, ipld.ErrNotFound{Cid: k}
+	}
+
+	key := cacheKey(k)
+
+	if data, ok := b.get(key); ok {
+		// The data was verified against the multihash when it was read or
+		// written, the CID only differs by its version or codec.
+		return blocks.NewBlockWithCid(data, k)
+	}
+
+	b.lock(key, false)
+	defer b.unlock(key, false)
+
+	bl, err := b.blockstore.Get(ctx, k)
+	if err != nil {
+		return nil, err
+	}
+	b.add(key, bl.RawData())
+	return bl, nil
+}
+
+func (b *datacache) Put(ctx context.Context, bl blocks.Block) error {
+	key := cacheKey(bl.Cid())
+
+	b.lock(key, true)
+	defer b.unlock(key, true)
+
+	b.invalidate(key)
+	return b.blockstore.Put(ctx, bl)
+}
+
+func (b *datacache) PutMany(ctx context.Context, bs []blocks.Block) error {
+	keyed := newKeyedBlocks(len(bs))
+	for _, blk := range bs {
+		keyed.append(cacheKey(blk.Cid()), blk)
+	}
+
+	if keyed.isEmpty() {
+		return nil
+	}
+
+	keyed.sortAndDedup()
+
+	for _, key := range keyed.keys {
+		b.lock(key, true)
+	}
+
+	defer func() {
+		for _, key := range keyed.keys {
+			b.unlock(key, true)
+		}
+	}()
+
+	for _, key := range keyed.keys {
+		b.invalidate(key)
+	}
+	return b.blockstore.PutMany(ctx, bs)
+}
+
+func (b *datacache) HashOnRead(enabled bool) {
+	b.blockstore.HashOnRead(enabled)
+}
+
+func (b *datacache) AllKeysChan(ctx context.Context) (<-chan cid.Cid, error) {
+	return b.blockstore.AllKeysChan(ctx)
+}
+
+func (b *datacache) GCLock(ctx context.Context) Unlocker {
+	return b.blockstore.(GCBlockstore).GCLock(ctx)
+}
+
+func (b *datacache) PinLock(ctx context.Context) Unlocker {
+	return b.blockstore.(GCBlockstore).PinLock(ctx)
+}
+
+func (b *datacache) GCRequested(ctx context.Context) bool {
+	return b.blockstore.(GCBlockstore).GCRequested(ctx)
+}
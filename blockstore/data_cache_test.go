@@ -0,0 +1,176 @@
+package blockstore
+
+import (
+	"context"
+	"testing"
+
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	syncds "github.com/ipfs/go-datastore/sync"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// viewerBlockstore adds a copying View to a Blockstore.
+type viewerBlockstore struct {
+	Blockstore
+}
+
+func (v viewerBlockstore) View(ctx context.Context, k cid.Cid, callback func([]byte) error) error {
+	blk, err := v.Get(ctx, k)
+	if err != nil {
+		return err
+	}
+	return callback(blk.RawData())
+}
+
+func createDataCachedStores(t testing.TB, cacheSize, maxBlockSize int) (*datacache, *callbackDatastore) {
+	cd := &callbackDatastore{f: func() {}, ds: ds.NewMapDatastore()}
+	bs := viewerBlockstore{NewBlockstore(syncds.MutexWrap(cd))}
+	opts := CacheOpts{
+		BlockDataCacheSize:         cacheSize,
+		BlockDataCacheMaxBlockSize: maxBlockSize,
+	}
+	cbs, err := CachedBlockstore(context.TODO(), bs, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cbs.(*datacache), cd
+}
+
+func TestDataCacheServesReadsFromMemory(t *testing.T) {
+	c, cd := createDataCachedStores(t, 1<<10, 1<<10)
+
+	if err := c.Put(bg, exampleBlock); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get(bg, exampleBlock.Cid()); err != nil {
+		t.Fatal(err)
+	}
+
+	trap("read hit datastore", cd, t)
+	blk, err := c.Get(bg, exampleBlock.Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(blk.RawData()) != "foo" {
+		t.Fatalf("unexpected block data %q", blk.RawData())
+	}
+	size, err := c.GetSize(bg, exampleBlock.Cid())
+	if err != nil || size != 3 {
+		t.Fatalf("unexpected size %d, err: %v", size, err)
+	}
+	err = c.View(bg, exampleBlock.Cid(), func(buf []byte) error {
+		if string(buf) != "foo" {
+			t.Fatalf("unexpected view data %q", buf)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A CID with the same multihash is served from the cache too.
+	v0 := cid.NewCidV0(exampleBlock.Cid().Hash())
+	blk, err = c.Get(bg, v0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !blk.Cid().Equals(v0) {
+		t.Fatalf("expected block with CID %s, got %s", v0, blk.Cid())
+	}
+	untrap(cd)
+}
+
+func TestDataCacheFilledByView(t *testing.T) {
+	c, cd := createDataCachedStores(t, 1<<10, 1<<10)
+
+	if err := c.Put(bg, exampleBlock); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.View(bg, exampleBlock.Cid(), func([]byte) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	trap("read hit datastore", cd, t)
+	if _, err := c.Get(bg, exampleBlock.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	untrap(cd)
+}
+
+func TestDataCacheInvalidatedOnDelete(t *testing.T) {
+	c, _ := createDataCachedStores(t, 1<<10, 1<<10)
+
+	if err := c.Put(bg, exampleBlock); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get(bg, exampleBlock.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.DeleteBlock(bg, exampleBlock.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get(bg, exampleBlock.Cid()); !ipld.IsNotFound(err) {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+	if _, err := c.GetSize(bg, exampleBlock.Cid()); !ipld.IsNotFound(err) {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+}
+
+func TestDataCacheByteBudget(t *testing.T) {
+	c, cd := createDataCachedStores(t, 10, 8)
+
+	b1 := blocks.NewBlock([]byte("1111"))
+	b2 := blocks.NewBlock([]byte("2222"))
+	b3 := blocks.NewBlock([]byte("3333"))
+	large := blocks.NewBlock([]byte("larger than 8"))
+	for _, b := range []blocks.Block{b1, b2, b3, large} {
+		if err := c.Put(bg, b); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.Get(bg, b.Cid()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if c.size > 10 {
+		t.Fatalf("cache holds %d bytes, over its budget", c.size)
+	}
+
+	var reads int
+	cd.SetFunc(func() { reads++ })
+	for _, b := range []blocks.Block{b2, b3} {
+		if _, err := c.Get(bg, b.Cid()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if reads != 0 {
+		t.Fatalf("expected recent blocks to be cached, got %d datastore reads", reads)
+	}
+	// b1 was evicted and large was never admitted.
+	for _, b := range []blocks.Block{b1, large} {
+		if _, err := c.Get(bg, b.Cid()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if reads != 2 {
+		t.Fatalf("expected 2 datastore reads, got %d", reads)
+	}
+}
+
+func TestDataCacheOptsLessThanZero(t *testing.T) {
+	opts := DefaultCacheOpts()
+	opts.BlockDataCacheSize = -1
+
+	if _, err := CachedBlockstore(context.TODO(), nil, opts); err == nil {
+		t.Error("negative data cache size was not detected")
+	}
+
+	opts = DefaultCacheOpts()
+	opts.BlockDataCacheMaxBlockSize = -1
+
+	if _, err := CachedBlockstore(context.TODO(), nil, opts); err == nil {
+		t.Error("negative data cache block size was not detected")
+	}
+}
@@ -22,6 +22,13 @@ type lock struct {
 	refcnt int
 }
 
+// keyLocks hands out reference counted read-write locks per cache key. The
+// zero value is ready to use.
+type keyLocks struct {
+	lklk sync.Mutex
+	lks  map[string]*lock
+}
+
 // tqcache wraps a BlockStore with an [TwoQueueCache] that
 // does not store the actual blocks, just metadata about them: existence and
 // size. This provides block access-time improvements, allowing
@@ -29,8 +36,7 @@ type lock struct {
 //
 // [TwoQueueCache]: https://pkg.go.dev/github.com/hashicorp/golang-lru/v2#TwoQueueCache
 type tqcache struct {
-	lklk sync.Mutex
-	lks  map[string]*lock
+	keyLocks
 
 	cache *lru.TwoQueueCache[string, any]
 
@@ -52,7 +58,7 @@ func newTwoQueueCachedBS(ctx context.Context, bs Blockstore, lruSize int) (*tqca
 		return nil, err
 	}
 
-	c := &tqcache{cache: cache, blockstore: bs, lks: make(map[string]*lock)}
+	c := &tqcache{cache: cache, blockstore: bs}
 	c.hits = metrics.NewCtx(ctx, "boxo_blockstore.cache_hits", "Number of blockstore cache hits").Counter()
 	c.total = metrics.NewCtx(ctx, "boxo_blockstore.cache_total", "Total number of blockstore cache requests").Counter()
 	if v, ok := bs.(Viewer); ok {
@@ -61,10 +67,13 @@ func newTwoQueueCachedBS(ctx context.Context, bs Blockstore, lruSize int) (*tqca
 	return c, nil
 }
 
-func (b *tqcache) lock(k string, write bool) {
+func (b *keyLocks) lock(k string, write bool) {
 	b.lklk.Lock()
 	lk, ok := b.lks[k]
 	if !ok {
+		if b.lks == nil {
+			b.lks = make(map[string]*lock)
+		}
 		lk = new(lock)
 		b.lks[k] = lk
 	}
@@ -77,7 +86,7 @@ func (b *tqcache) lock(k string, write bool) {
 	}
 }
 
-func (b *tqcache) unlock(key string, write bool) {
+func (b *keyLocks) unlock(key string, write bool) {
 	b.lklk.Lock()
 	lk := b.lks[key]
 	lk.refcnt--
@@ -0,0 +1,387 @@
+// Package car imports CAR (Content Addressable aRchive) files into a
+// [blockstore.Blockstore].
+//
+// It is the counterpart of the CAR export of the gateway: blocks are streamed
+// from a CARv1 or CARv2 into the blockstore in batches, after verifying each
+// of them against its CID, and the roots declared by the CAR can be checked to
+// be complete DAGs.
+package car
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ipfs/boxo/blockstore"
+	"github.com/ipfs/boxo/verifcid"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	format "github.com/ipfs/go-ipld-format"
+	logging "github.com/ipfs/go-log/v2"
+	carv2 "github.com/ipld/go-car/v2"
+	_ "github.com/ipld/go-codec-dagpb"
+	_ "github.com/ipld/go-ipld-prime/codec/dagcbor"
+	_ "github.com/ipld/go-ipld-prime/codec/dagjson"
+	_ "github.com/ipld/go-ipld-prime/codec/raw"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipld/go-ipld-prime/multicodec"
+	basicnode "github.com/ipld/go-ipld-prime/node/basic"
+	"github.com/ipld/go-ipld-prime/traversal"
+	mh "github.com/multiformats/go-multihash"
+)
+
+var log = logging.Logger("car")
+
+const (
+	// DefaultBatchSize is the default maximum number of blocks written to the
+	// blockstore with a single PutMany.
+	DefaultBatchSize = 128
+
+	// maxBatchBytes is the maximum size of the blocks written with a single
+	// PutMany, regardless of the batch size.
+	maxBatchBytes = 16 << 20
+)
+
+var (
+	// ErrTruncated is returned when the CAR ends in the middle of a section.
+	// A CAR truncated between two blocks is only detected by verifying its
+	// roots, see [WithVerifyRoots].
+	ErrTruncated = errors.New("truncated CAR")
+
+	// ErrBlockMismatch is returned when the data of a block doesn't match its
+	// CID.
+	ErrBlockMismatch = errors.New("block data does not match its CID")
+
+	// ErrIncompleteRoots is returned when [WithVerifyRoots] is set and some
+	// blocks of the DAGs of the roots are missing.
+	ErrIncompleteRoots = errors.New("CAR roots are incomplete")
+)
+
+// BlockError is the error returned for an invalid block of a CAR.
+type BlockError struct {
+	// Index is the position of the block in the CAR, starting at 0.
+	Index int
+	Cid   cid.Cid
+	Err   error
+}
+
+func (e *BlockError) Error() string {
+	return fmt.Sprintf("block %d (%s): %s", e.Index, e.Cid, e.Err)
+}
+
+func (e *BlockError) Unwrap() error {
+	return e.Err
+}
+
+// RootStats describes the DAG of one of the roots of a CAR, as found in the
+// blockstore after the import.
+type RootStats struct {
+	Root cid.Cid
+	// Blocks is the number of unique blocks of the DAG that are present.
+	Blocks int
+	// Size is the total size of these blocks.
+	Size uint64
+	// Missing lists the CIDs of the blocks that are missing, under which the
+	// DAG couldn't be traversed. The DAG is complete when it is empty.
+	Missing []cid.Cid
+}
+
+// Complete reports whether every block of the DAG is present.
+func (s RootStats) Complete() bool {
+	return len(s.Missing) == 0
+}
+
+// Result describes an import.
+type Result struct {
+	// Version is the version of the CAR, 1 or 2.
+	Version uint64
+	// Roots lists the roots declared in the CAR header, in order. Their stats
+	// are only set if the roots are verified.
+	Roots []RootStats
+	// Blocks is the number of blocks read from the CAR and written to the
+	// blockstore, and Size their total size.
+	Blocks int
+	Size   uint64
+}
+
+type options struct {
+	batchSize   int
+	allowlist   verifcid.Allowlist
+	verifyRoots bool
+	maxBlock    uint64
+	checkRoots  func([]cid.Cid) error
+}
+
+// Option configures [Import].
+type Option func(*options)
+
+// WithBatchSize sets the maximum number of blocks written with a single
+// PutMany. Default is [DefaultBatchSize].
+func WithBatchSize(n int) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.batchSize = n
+		}
+	}
+}
+
+// WithAllowlist sets the [verifcid.Allowlist] of the hash functions accepted
+// in the CIDs of the blocks. Default is [verifcid.DefaultAllowlist].
+func WithAllowlist(allowlist verifcid.Allowlist) Option {
+	return func(o *options) {
+		o.allowlist = allowlist
+	}
+}
+
+// WithVerifyRoots traverses the DAGs of the roots declared by the CAR after
+// the import, filling in their [RootStats]. [Import] returns an error wrapping
+// [ErrIncompleteRoots] if a block is missing. Blocks that were already in the
+// blockstore count as present.
+func WithVerifyRoots() Option {
+	return func(o *options) {
+		o.verifyRoots = true
+	}
+}
+
+// WithCheckRoots calls check with the roots declared in the CAR header before
+// any block is imported. If it returns an error, [Import] returns it without
+// writing anything to the blockstore.
+func WithCheckRoots(check func(roots []cid.Cid) error) Option {
+	return func(o *options) {
+		o.checkRoots = check
+	}
+}
+
+// WithMaxBlockSize sets the maximum size of a CAR section, i.e. of a block
+// and its CID. Default is the go-car default of 8MiB.
+func WithMaxBlockSize(size uint64) Option {
+	return func(o *options) {
+		o.maxBlock = size
+	}
+}
+
+// Import streams the CARv1 or CARv2 read from r into bs.
+//
+// Blocks are verified against their CID, whose hash function must be allowed,
+// and written in batches. If the CAR is invalid, the blocks preceding the
+// invalid section have been written to bs when the error is returned, along
+// with a [Result] describing them.
+func Import(ctx context.Context, r io.Reader, bs blockstore.Blockstore, opts ...Option) (*Result, error) {
+	o := options{
+		batchSize: DefaultBatchSize,
+		allowlist: verifcid.DefaultAllowlist,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	// The blocks are verified below, with the allowlist.
+	readerOpts := []carv2.Option{carv2.WithTrustedCAR(true)}
+	if o.maxBlock > 0 {
+		readerOpts = append(readerOpts, carv2.MaxAllowedSectionSize(o.maxBlock))
+	}
+
+	br, err := carv2.NewBlockReader(r, readerOpts...)
+	if err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, fmt.Errorf("%w: %w", ErrTruncated, err)
+		}
+		return nil, fmt.Errorf("invalid CAR header: %w", err)
+	}
+	if o.checkRoots != nil {
+		if err := o.checkRoots(br.Roots); err != nil {
+			return nil, err
+		}
+	}
+
+	res := &Result{
+		Version: br.Version,
+		Roots:   make([]RootStats, len(br.Roots)),
+	}
+	for i, root := range br.Roots {
+		res.Roots[i].Root = root
+	}
+
+	if err := importBlocks(ctx, br, bs, o, res); err != nil {
+		return res, err
+	}
+
+	if o.verifyRoots {
+		return res, verifyRoots(ctx, bs, res)
+	}
+	return res, nil
+}
+
+func importBlocks(ctx context.Context, br *carv2.BlockReader, bs blockstore.Blockstore, o options, res *Result) error {
+	var (
+		batch      []blocks.Block
+		batchBytes int
+	)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := bs.PutMany(ctx, batch); err != nil {
+			return err
+		}
+		for _, blk := range batch {
+			res.Blocks++
+			res.Size += uint64(len(blk.RawData()))
+		}
+		batch, batchBytes = nil, 0
+		return nil
+	}
+
+	for i := 0; ; i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		blk, err := br.Next()
+		if err == io.EOF {
+			return flush()
+		}
+		if err == nil {
+			err = verifyBlock(o.allowlist, blk)
+			if err != nil {
+				err = &BlockError{Index: i, Cid: blk.Cid(), Err: err}
+			}
+		} else if errors.Is(err, io.ErrUnexpectedEOF) {
+			err = fmt.Errorf("%w: %w", ErrTruncated, err)
+		}
+		if err != nil {
+			// Keep the valid blocks read so far.
+			if ferr := flush(); ferr != nil {
+				return errors.Join(err, ferr)
+			}
+			return err
+		}
+
+		batch = append(batch, blk)
+		batchBytes += len(blk.RawData())
+		if len(batch) >= o.batchSize || batchBytes >= maxBatchBytes {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func verifyBlock(allowlist verifcid.Allowlist, blk blocks.Block) error {
+	c := blk.Cid()
+	if err := verifcid.ValidateCid(allowlist, c); err != nil {
+		return err
+	}
+
+	hashed, err := c.Prefix().Sum(blk.RawData())
+	if err != nil {
+		return err
+	}
+	if !hashed.Equals(c) {
+		return ErrBlockMismatch
+	}
+	return nil
+}
+
+// verifyRoots traverses the DAGs of the roots from the blockstore and fills
+// in their stats.
+func verifyRoots(ctx context.Context, bs blockstore.Blockstore, res *Result) error {
+	var incomplete []string
+	for i := range res.Roots {
+		stats := &res.Roots[i]
+		if err := walk(ctx, bs, stats); err != nil {
+			return fmt.Errorf("cannot verify root %s: %w", stats.Root, err)
+		}
+		if !stats.Complete() {
+			incomplete = append(incomplete, fmt.Sprintf("%s (%d missing blocks)", stats.Root, len(stats.Missing)))
+		}
+	}
+
+	if len(incomplete) > 0 {
+		return fmt.Errorf("%w: %v", ErrIncompleteRoots, incomplete)
+	}
+	return nil
+}
+
+func walk(ctx context.Context, bs blockstore.Blockstore, stats *RootStats) error {
+	visited := cid.NewSet()
+	stack := []cid.Cid{stats.Root}
+	for len(stack) > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		c := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if !visited.Visit(c) {
+			continue
+		}
+
+		data, err := blockData(ctx, bs, c)
+		if format.IsNotFound(err) {
+			stats.Missing = append(stats.Missing, c)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		stats.Blocks++
+		stats.Size += uint64(len(data))
+
+		links, err := links(c, data)
+		if err != nil {
+			return err
+		}
+		stack = append(stack, links...)
+	}
+	return nil
+}
+
+// blockData returns the data of the given block, which identity CIDs contain.
+func blockData(ctx context.Context, bs blockstore.Blockstore, c cid.Cid) ([]byte, error) {
+	if c.Prefix().MhType == mh.IDENTITY {
+		dmh, err := mh.Decode(c.Hash())
+		if err != nil {
+			return nil, err
+		}
+		return dmh.Digest, nil
+	}
+
+	blk, err := bs.Get(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	return blk.RawData(), nil
+}
+
+// links returns the CIDs linked from the given block. Blocks with a codec that
+// can't be decoded are considered to have no links.
+func links(c cid.Cid, data []byte) ([]cid.Cid, error) {
+	decoder, err := multicodec.LookupDecoder(c.Prefix().Codec)
+	if err != nil {
+		log.Debugw("cannot decode block, considering it has no links", "cid", c, "error", err)
+		return nil, nil
+	}
+
+	nb := basicnode.Prototype.Any.NewBuilder()
+	if err := decoder(nb, bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("cannot decode block %s: %w", c, err)
+	}
+
+	lnks, err := traversal.SelectLinks(nb.Build())
+	if err != nil {
+		return nil, err
+	}
+
+	cids := make([]cid.Cid, 0, len(lnks))
+	for _, l := range lnks {
+		cl, ok := l.(cidlink.Link)
+		if !ok {
+			return nil, fmt.Errorf("unsupported link type %T", l)
+		}
+		cids = append(cids, cl.Cid)
+	}
+	return cids, nil
+}
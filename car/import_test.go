@@ -0,0 +1,178 @@
+package car
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ipfs/boxo/blockstore"
+	"github.com/ipfs/boxo/ipld/merkledag"
+	"github.com/ipfs/boxo/verifcid"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	carv2 "github.com/ipld/go-car/v2"
+	"github.com/ipld/go-car/v2/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingBlockstore counts the calls to PutMany.
+type countingBlockstore struct {
+	blockstore.Blockstore
+	putMany int
+}
+
+func (bs *countingBlockstore) PutMany(ctx context.Context, blks []blocks.Block) error {
+	bs.putMany++
+	return bs.Blockstore.PutMany(ctx, blks)
+}
+
+func newBlockstore() *countingBlockstore {
+	return &countingBlockstore{Blockstore: blockstore.NewBlockstore(dssync.MutexWrap(datastore.NewMapDatastore()))}
+}
+
+// testDAG returns the blocks of a DAG with a root, two children and a raw
+// leaf, root first.
+func testDAG(t *testing.T) []blocks.Block {
+	leaf := merkledag.NewRawNode([]byte("leaf"))
+	child1 := merkledag.NodeWithData([]byte("child 1"))
+	require.NoError(t, child1.AddNodeLink("leaf", leaf))
+	child2 := merkledag.NodeWithData([]byte("child 2"))
+	root := merkledag.NodeWithData([]byte("root"))
+	require.NoError(t, root.AddNodeLink("1", child1))
+	require.NoError(t, root.AddNodeLink("2", child2))
+	return []blocks.Block{root, child1, leaf, child2}
+}
+
+// writeCARv1 returns a CARv1 with the given roots and blocks.
+func writeCARv1(t *testing.T, roots []cid.Cid, blks []blocks.Block) []byte {
+	var buf bytes.Buffer
+	w, err := storage.NewWritable(&buf, roots, carv2.WriteAsCarV1(true), carv2.AllowDuplicatePuts(true))
+	require.NoError(t, err)
+	for _, blk := range blks {
+		require.NoError(t, w.Put(context.Background(), blk.Cid().KeyString(), blk.RawData()))
+	}
+	require.NoError(t, w.Finalize())
+	return buf.Bytes()
+}
+
+func TestImport(t *testing.T) {
+	ctx := context.Background()
+	dag := testDAG(t)
+	root := dag[0].Cid()
+	v1 := writeCARv1(t, []cid.Cid{root}, dag)
+
+	var dagSize uint64
+	for _, blk := range dag {
+		dagSize += uint64(len(blk.RawData()))
+	}
+
+	requireHas := func(t *testing.T, bs blockstore.Blockstore, blks []blocks.Block, expected bool) {
+		for _, blk := range blks {
+			has, err := bs.Has(ctx, blk.Cid())
+			require.NoError(t, err)
+			require.Equal(t, expected, has, "block %s", blk.Cid())
+		}
+	}
+
+	t.Run("CARv1", func(t *testing.T) {
+		bs := newBlockstore()
+		res, err := Import(ctx, bytes.NewReader(v1), bs, WithVerifyRoots())
+		require.NoError(t, err)
+		requireHas(t, bs, dag, true)
+
+		assert.Equal(t, uint64(1), res.Version)
+		assert.Equal(t, len(dag), res.Blocks)
+		assert.Equal(t, dagSize, res.Size)
+		require.Len(t, res.Roots, 1)
+		assert.Equal(t, RootStats{Root: root, Blocks: len(dag), Size: dagSize}, res.Roots[0])
+		assert.True(t, res.Roots[0].Complete())
+	})
+
+	t.Run("CARv2", func(t *testing.T) {
+		var v2 bytes.Buffer
+		require.NoError(t, carv2.WrapV1(bytes.NewReader(v1), &v2))
+
+		bs := newBlockstore()
+		res, err := Import(ctx, &v2, bs, WithVerifyRoots())
+		require.NoError(t, err)
+		requireHas(t, bs, dag, true)
+		assert.Equal(t, uint64(2), res.Version)
+		assert.Equal(t, len(dag), res.Blocks)
+		assert.True(t, res.Roots[0].Complete())
+	})
+
+	t.Run("Blocks are written in batches", func(t *testing.T) {
+		bs := newBlockstore()
+		_, err := Import(ctx, bytes.NewReader(v1), bs, WithBatchSize(3))
+		require.NoError(t, err)
+		assert.Equal(t, 2, bs.putMany)
+	})
+
+	t.Run("Truncated CAR", func(t *testing.T) {
+		bs := newBlockstore()
+		res, err := Import(ctx, bytes.NewReader(v1[:len(v1)-3]), bs, WithBatchSize(1))
+		require.ErrorIs(t, err, ErrTruncated)
+		requireHas(t, bs, dag[:3], true)
+		requireHas(t, bs, dag[3:], false)
+		assert.Equal(t, 3, res.Blocks)
+
+		_, err = Import(ctx, bytes.NewReader(v1[:5]), bs)
+		require.ErrorIs(t, err, ErrTruncated)
+	})
+
+	t.Run("Roots are checked before the blocks", func(t *testing.T) {
+		errRoots := errors.New("unexpected roots")
+		bs := newBlockstore()
+		var checked []cid.Cid
+		_, err := Import(ctx, bytes.NewReader(v1), bs, WithCheckRoots(func(roots []cid.Cid) error {
+			checked = roots
+			return errRoots
+		}))
+		require.ErrorIs(t, err, errRoots)
+		assert.Equal(t, []cid.Cid{root}, checked)
+		requireHas(t, bs, dag, false)
+	})
+
+	t.Run("Corrupted block", func(t *testing.T) {
+		corrupted := bytes.Clone(v1)
+		corrupted[len(corrupted)-1] ^= 0xff
+
+		bs := newBlockstore()
+		res, err := Import(ctx, bytes.NewReader(corrupted), bs)
+		require.ErrorIs(t, err, ErrBlockMismatch)
+		var blockErr *BlockError
+		require.True(t, errors.As(err, &blockErr))
+		assert.Equal(t, 3, blockErr.Index)
+		assert.Equal(t, dag[3].Cid(), blockErr.Cid)
+		assert.Equal(t, 3, res.Blocks)
+		requireHas(t, bs, dag[:3], true)
+	})
+
+	t.Run("Disallowed hash function", func(t *testing.T) {
+		bs := newBlockstore()
+		_, err := Import(ctx, bytes.NewReader(v1), bs, WithAllowlist(verifcid.NewAllowlist(nil)))
+		require.ErrorIs(t, err, verifcid.ErrPossiblyInsecureHashFunction)
+		requireHas(t, bs, dag, false)
+	})
+
+	t.Run("Incomplete roots", func(t *testing.T) {
+		missing := dag[1]
+		partial := writeCARv1(t, []cid.Cid{root, dag[3].Cid()}, []blocks.Block{dag[0], dag[3]})
+
+		bs := newBlockstore()
+		res, err := Import(ctx, bytes.NewReader(partial), bs, WithVerifyRoots())
+		require.ErrorIs(t, err, ErrIncompleteRoots)
+		require.Len(t, res.Roots, 2)
+		assert.Equal(t, []cid.Cid{missing.Cid()}, res.Roots[0].Missing)
+		assert.Equal(t, 2, res.Roots[0].Blocks)
+		assert.True(t, res.Roots[1].Complete())
+
+		// Without verification, the roots are not checked.
+		_, err = Import(ctx, bytes.NewReader(partial), newBlockstore())
+		require.NoError(t, err)
+	})
+}
@@ -57,3 +57,84 @@ func benchmarkChunkerSize(b *testing.B, ns newSplitter, size int) {
 	}
 	Res = Res + res
 }
+
+func BenchmarkFastCDC(b *testing.B) {
+	benchmarkChunker(b, func(r io.Reader) Splitter {
+		return NewFastCDC(r)
+	})
+}
+
+// contentDefinedSplitters are compared by BenchmarkContentDefined, with the
+// same 256KiB average chunk size.
+var contentDefinedSplitters = []struct {
+	name string
+	ns   newSplitter
+}{
+	{"fastcdc", func(r io.Reader) Splitter { return NewFastCDC(r) }},
+	{"buzhash", func(r io.Reader) Splitter { return NewBuzhash(r) }},
+	{"rabin", func(r io.Reader) Splitter { return NewRabin(r, 256<<10) }},
+}
+
+// BenchmarkContentDefined chunks a dataset and an edited copy of it with each
+// content-defined splitter. Besides the throughput, it reports the share of
+// the edited copy found in the chunks of the original as "dedup-%".
+func BenchmarkContentDefined(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	data := make([]byte, 64<<20)
+	rng.Read(data)
+
+	// Insert, overwrite and delete a few bytes at random offsets
+	edited := append([]byte(nil), data...)
+	for i := 0; i < 32; i++ {
+		at := rng.Intn(len(edited) - 64)
+		patch := make([]byte, 1+rng.Intn(32))
+		rng.Read(patch)
+		switch i % 3 {
+		case 0:
+			edited = append(edited[:at], append(patch, edited[at:]...)...)
+		case 1:
+			copy(edited[at:], patch)
+		case 2:
+			edited = append(edited[:at], edited[at+len(patch):]...)
+		}
+	}
+
+	for _, s := range contentDefinedSplitters {
+		s := s
+		b.Run(s.name, func(b *testing.B) {
+			original := make(map[string]struct{})
+			for _, c := range splitAll(b, s.ns, data) {
+				original[string(c)] = struct{}{}
+			}
+			var reused int
+			for _, c := range splitAll(b, s.ns, edited) {
+				if _, ok := original[string(c)]; ok {
+					reused += len(c)
+				}
+			}
+
+			b.SetBytes(int64(len(edited)))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				Res += uint64(len(splitAll(b, s.ns, edited)))
+			}
+			b.ReportMetric(100*float64(reused)/float64(len(edited)), "dedup-%")
+		})
+	}
+}
+
+func splitAll(b *testing.B, ns newSplitter, data []byte) [][]byte {
+	r := ns(bytes.NewReader(data))
+	var chunks [][]byte
+	for {
+		chunk, err := r.NextBytes()
+		if err != nil {
+			if err == io.EOF {
+				return chunks
+			}
+			b.Fatal(err)
+		}
+		chunks = append(chunks, chunk)
+	}
+}
@@ -0,0 +1,169 @@
+package chunk
+
+import (
+	"io"
+	"math/bits"
+
+	pool "github.com/libp2p/go-buffer-pool"
+)
+
+const (
+	// fastCDCMinLimit is the smallest minimum chunk size, the number of bytes
+	// the gear hash covers.
+	fastCDCMinLimit = 64
+	// fastCDCNormLevel is how many bits the masks add or remove around the
+	// average chunk size, to normalize the chunk sizes.
+	fastCDCNormLevel = 2
+)
+
+// FastCDC implements the Splitter interface and splits content with the
+// FastCDC content-defined chunking algorithm, with normalized chunking.
+//
+// Chunk boundaries are found with a gear rolling hash. Before the average
+// size a boundary needs more hash bits to be zero than after it, which
+// keeps most chunks close to the average size.
+type FastCDC struct {
+	r   io.Reader
+	buf []byte
+	n   int
+	eof bool
+	err error
+
+	min, avg, max int
+	maskS, maskL  uint64
+}
+
+// NewFastCDC creates a new FastCDC splitter with an average chunk size of
+// DefaultBlockSize, a quarter of it as minimum and four times it as maximum.
+func NewFastCDC(r io.Reader) *FastCDC {
+	return NewFastCDCMinAvgMax(r, uint64(DefaultBlockSize/4), uint64(DefaultBlockSize), uint64(DefaultBlockSize*4))
+}
+
+// NewFastCDCMinAvgMax returns a new FastCDC splitter which uses the given
+// min, average and max chunk sizes. They should verify 64 <= min < avg < max:
+// sizes out of range are clamped, raising min to 64, then avg to min+1 and max
+// to avg+1. FromString rejects them instead.
+func NewFastCDCMinAvgMax(r io.Reader, min, avg, max uint64) *FastCDC {
+	if min < fastCDCMinLimit {
+		min = fastCDCMinLimit
+	}
+	if avg <= min {
+		avg = min + 1
+	}
+	if max <= avg {
+		max = avg + 1
+	}
+
+	b := bits.Len64(avg) - 1
+	// Round the average to the closest power of two
+	if avg-1<<b > 1<<(b+1)-avg {
+		b++
+	}
+	return &FastCDC{
+		r:     r,
+		buf:   pool.Get(int(max)),
+		min:   int(min),
+		avg:   int(avg),
+		max:   int(max),
+		maskS: fastCDCMask(b + fastCDCNormLevel),
+		maskL: fastCDCMask(b - fastCDCNormLevel),
+	}
+}
+
+// fastCDCMask returns a mask of the n top bits, the bits of the gear hash
+// that depend on the most bytes.
+func fastCDCMask(n int) uint64 {
+	if n < 1 {
+		n = 1
+	}
+	return ^uint64(0) << (64 - n)
+}
+
+// Reader returns the io.Reader associated to this Splitter.
+func (f *FastCDC) Reader() io.Reader {
+	return f.r
+}
+
+// NextBytes reads the next bytes from the reader and returns a slice.
+func (f *FastCDC) NextBytes() ([]byte, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	if !f.eof {
+		n, err := io.ReadFull(f.r, f.buf[f.n:])
+		f.n += n
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			f.eof = true
+		} else if err != nil {
+			f.err = err
+			f.release()
+			return nil, err
+		}
+	}
+
+	// Read nothing? Don't return an empty block.
+	if f.n == 0 {
+		f.err = io.EOF
+		f.release()
+		return nil, f.err
+	}
+
+	cut := f.cut(f.buf[:f.n])
+	res := make([]byte, cut)
+	copy(res, f.buf)
+	f.n = copy(f.buf, f.buf[cut:f.n])
+
+	return res, nil
+}
+
+func (f *FastCDC) release() {
+	pool.Put(f.buf)
+	f.buf = nil
+}
+
+// cut returns the size of the chunk at the start of data.
+func (f *FastCDC) cut(data []byte) int {
+	n := len(data)
+	if n <= f.min {
+		return n
+	}
+	normal, max := f.avg, f.max
+	if n < normal {
+		normal = n
+	}
+	if n < max {
+		max = n
+	}
+
+	var hash uint64
+	i := f.min
+	for ; i < normal; i++ {
+		hash = hash<<1 + gearTable[data[i]]
+		if hash&f.maskS == 0 {
+			return i + 1
+		}
+	}
+	for ; i < max; i++ {
+		hash = hash<<1 + gearTable[data[i]]
+		if hash&f.maskL == 0 {
+			return i + 1
+		}
+	}
+	return max
+}
+
+// gearTable holds the random values of the gear hash. It is part of the
+// chunking format: changing it changes where chunks are cut.
+var gearTable = func() (t [256]uint64) {
+	// splitmix64, with a fixed seed
+	state := uint64(0x6a09e667f3bcc908)
+	for i := range t {
+		state += 0x9e3779b97f4a7c15
+		z := state
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		t[i] = z ^ (z >> 31)
+	}
+	return t
+}()
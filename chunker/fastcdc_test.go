@@ -0,0 +1,106 @@
+package chunk
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	util "github.com/ipfs/boxo/util"
+)
+
+func chunkAll(t *testing.T, s Splitter) [][]byte {
+	var chunks [][]byte
+	for {
+		chunk, err := s.NextBytes()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			t.Fatal(err)
+		}
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+func TestFastCDCChunking(t *testing.T) {
+	data := make([]byte, 1024*1024*16)
+	n, err := util.NewTimeSeededRand().Read(data)
+	if n < len(data) {
+		t.Fatalf("expected %d bytes, got %d", len(data), n)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const min, avg, max = 64 << 10, 256 << 10, 1 << 20
+	chunks := chunkAll(t, NewFastCDCMinAvgMax(bytes.NewReader(data), min, avg, max))
+	t.Logf("average block size: %d\n", len(data)/len(chunks))
+
+	for i, chunk := range chunks {
+		if len(chunk) == 0 {
+			t.Fatalf("chunk %d/%d is empty", i+1, len(chunks))
+		}
+		if len(chunk) > max {
+			t.Fatalf("chunk %d/%d is more than the maximum size", i+1, len(chunks))
+		}
+		if i < len(chunks)-1 && len(chunk) < min {
+			t.Fatalf("chunk %d/%d is less than the minimum size", i+1, len(chunks))
+		}
+	}
+
+	// Normalized chunking keeps the average close to the requested one
+	if avgSize := len(data) / len(chunks); avgSize < avg/2 || avgSize > avg*2 {
+		t.Fatalf("average block size %d too far from %d", avgSize, avg)
+	}
+
+	unchunked := bytes.Join(chunks, nil)
+	if !bytes.Equal(unchunked, data) {
+		t.Fatal("data was chunked incorrectly")
+	}
+
+	// Chunking is deterministic
+	again := chunkAll(t, NewFastCDCMinAvgMax(bytes.NewReader(data), min, avg, max))
+	if len(again) != len(chunks) {
+		t.Fatal("chunking the same data twice gave different chunks")
+	}
+	for i := range chunks {
+		if !bytes.Equal(chunks[i], again[i]) {
+			t.Fatal("chunking the same data twice gave different chunks")
+		}
+	}
+}
+
+func TestFastCDCSmallInputs(t *testing.T) {
+	if chunks := chunkAll(t, NewFastCDC(bytes.NewReader(nil))); len(chunks) != 0 {
+		t.Fatal("expected no chunk for empty input")
+	}
+
+	data := randBuf(t, 1000)
+	chunks := chunkAll(t, NewFastCDC(bytes.NewReader(data)))
+	if len(chunks) != 1 || !bytes.Equal(chunks[0], data) {
+		t.Fatal("expected input smaller than the minimum size to be a single chunk")
+	}
+}
+
+func TestFastCDCClampsSizes(t *testing.T) {
+	data := randBuf(t, 64<<10)
+	for _, sizes := range [][3]uint64{{0, 0, 0}, {0, 1024, 0}, {4096, 1024, 2048}} {
+		min, avg, max := sizes[0], sizes[1], sizes[2]
+		chunks := chunkAll(t, NewFastCDCMinAvgMax(bytes.NewReader(data), min, avg, max))
+		for i, chunk := range chunks {
+			if i < len(chunks)-1 && len(chunk) < fastCDCMinLimit {
+				t.Fatalf("%v: chunk %d/%d is less than %d bytes", sizes, i+1, len(chunks), fastCDCMinLimit)
+			}
+		}
+		if !bytes.Equal(bytes.Join(chunks, nil), data) {
+			t.Fatalf("%v: data was chunked incorrectly", sizes)
+		}
+	}
+}
+
+func TestFastCDCChunkReuse(t *testing.T) {
+	testReuse(t, func(r io.Reader) Splitter {
+		return NewFastCDC(r)
+	})
+}
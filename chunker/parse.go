@@ -22,11 +22,14 @@ var (
 	ErrRabinMin = errors.New("rabin min must be greater than 16")
 	ErrSize     = errors.New("chunker size must be greater than 0")
 	ErrSizeMax  = fmt.Errorf("chunker parameters may not exceed the maximum chunk size of %d", ChunkSizeLimit)
+
+	ErrFastCDCMin = fmt.Errorf("fastcdc min must be at least %d", fastCDCMinLimit)
 )
 
 // FromString returns a Splitter depending on the given string:
 // it supports "default" (""), "size-{size}", "rabin", "rabin-{blocksize}",
-// "rabin-{min}-{avg}-{max}" and "buzhash".
+// "rabin-{min}-{avg}-{max}", "buzhash", "fastcdc" and
+// "fastcdc-{min}-{avg}-{max}".
 func FromString(r io.Reader, chunker string) (Splitter, error) {
 	switch {
 	case chunker == "" || chunker == "default":
@@ -50,6 +53,9 @@ func FromString(r io.Reader, chunker string) (Splitter, error) {
 	case chunker == "buzhash":
 		return NewBuzhash(r), nil
 
+	case strings.HasPrefix(chunker, "fastcdc"):
+		return parseFastCDCString(r, chunker)
+
 	default:
 		return nil, fmt.Errorf("unrecognized chunker option: %s", chunker)
 	}
@@ -112,3 +118,38 @@ func parseRabinString(r io.Reader, chunker string) (Splitter, error) {
 		return nil, errors.New("incorrect format (expected 'rabin' 'rabin-[avg]' or 'rabin-[min]-[avg]-[max]'")
 	}
 }
+
+func parseFastCDCString(r io.Reader, chunker string) (Splitter, error) {
+	parts := strings.Split(chunker, "-")
+	switch len(parts) {
+	case 1:
+		if parts[0] != "fastcdc" {
+			return nil, fmt.Errorf("unrecognized chunker option: %s", chunker)
+		}
+		return NewFastCDC(r), nil
+	case 4:
+		var sizes [3]int
+		for i, part := range parts[1:] {
+			size, err := strconv.Atoi(part)
+			if err != nil {
+				return nil, err
+			}
+			sizes[i] = size
+		}
+		min, avg, max := sizes[0], sizes[1], sizes[2]
+
+		if min < fastCDCMinLimit {
+			return nil, ErrFastCDCMin
+		} else if min >= avg {
+			return nil, errors.New("incorrect format: fastcdc-min must be smaller than fastcdc-avg")
+		} else if avg >= max {
+			return nil, errors.New("incorrect format: fastcdc-avg must be smaller than fastcdc-max")
+		} else if max > ChunkSizeLimit {
+			return nil, ErrSizeMax
+		}
+
+		return NewFastCDCMinAvgMax(r, uint64(min), uint64(avg), uint64(max)), nil
+	default:
+		return nil, errors.New("incorrect format (expected 'fastcdc' or 'fastcdc-[min]-[avg]-[max]'")
+	}
+}
@@ -77,3 +77,55 @@ func TestParseSize(t *testing.T) {
 		t.Fatalf("Expected 'ErrSizeMax', got: %#v", err)
 	}
 }
+
+func TestParseFastCDC(t *testing.T) {
+	r := bytes.NewReader(randBuf(t, 1000))
+
+	s, err := FromString(r, "fastcdc")
+	if err != nil {
+		t.Fatalf("Expected success, got: %#v", err)
+	}
+	if _, ok := s.(*FastCDC); !ok {
+		t.Fatalf("Expected a FastCDC splitter, got: %T", s)
+	}
+
+	_, err = FromString(r, "fastcdc-64-128-256")
+	if err != nil {
+		t.Fatalf("Expected success, got: %#v", err)
+	}
+
+	_, err = FromString(r, "fastcdc-63-128-256")
+	if err != ErrFastCDCMin {
+		t.Fatalf("Expected an 'ErrFastCDCMin' error, got: %#v", err)
+	}
+
+	_, err = FromString(r, "fastcdc-128-128-256")
+	if err == nil || err.Error() != "incorrect format: fastcdc-min must be smaller than fastcdc-avg" {
+		t.Fatalf("Expected an arg-out-of-order error, got: %#v", err)
+	}
+
+	_, err = FromString(r, "fastcdc-64-256-256")
+	if err == nil || err.Error() != "incorrect format: fastcdc-avg must be smaller than fastcdc-max" {
+		t.Fatalf("Expected an arg-out-of-order error, got: %#v", err)
+	}
+
+	_, err = FromString(r, fmt.Sprintf("fastcdc-64-128-%d", ChunkSizeLimit))
+	if err != nil {
+		t.Fatalf("Expected success, got: %#v", err)
+	}
+
+	_, err = FromString(r, fmt.Sprintf("fastcdc-64-128-%d", 1+ChunkSizeLimit))
+	if err != ErrSizeMax {
+		t.Fatalf("Expected 'ErrSizeMax', got: %#v", err)
+	}
+
+	_, err = FromString(r, "fastcdc-64-128")
+	if err == nil {
+		t.Fatal("Expected an error for a missing size")
+	}
+
+	_, err = FromString(r, "fastcdcx")
+	if err == nil {
+		t.Fatal("Expected an error for an unknown chunker")
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/ipfs/boxo/bitswap/simulator"
+)
+
+func main() {
+	jsonOutput := flag.Bool("json", false, "write the report as JSON")
+	settle := flag.Duration("settle", simulator.DefaultSettleTime, "real time the nodes must stay idle before the virtual clock moves forward")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] scenario.json...\n\nRuns bitswap simulation scenarios and reports how they performed.\n\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	for _, path := range flag.Args() {
+		if err := run(ctx, path, *settle, *jsonOutput); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", path, err)
+			os.Exit(1)
+		}
+	}
+}
+
+func run(ctx context.Context, path string, settle time.Duration, jsonOutput bool) error {
+	s, err := simulator.LoadScenario(path)
+	if err != nil {
+		return err
+	}
+	rep, err := simulator.Run(ctx, s, simulator.Options{SettleTime: settle})
+	if err != nil {
+		return err
+	}
+	if jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rep)
+	}
+	return rep.WriteText(os.Stdout)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/ipfs/boxo/bitswap/tracer/tracelog"
+	"github.com/ipfs/boxo/blockstore"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/ipld/go-car/v2"
+)
+
+const usage = `Usage: %[1]s <command> [flags] <log>
+
+Inspects bitswap message logs written by tracelog.Recorder.
+
+Commands:
+  summary  print per-peer statistics of a log
+  replay   feed the messages received in a log to a bitswap node on a
+           virtual network and compare its responses with the log
+
+Run '%[1]s <command> -h' for the flags of a command.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintf(os.Stderr, usage, os.Args[0])
+		os.Exit(2)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	var err error
+	switch os.Args[1] {
+	case "summary":
+		err = summary(os.Args[2:])
+	case "replay":
+		err = replay(ctx, os.Args[2:])
+	default:
+		fmt.Fprintf(os.Stderr, usage, os.Args[0])
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func openLog(fs *flag.FlagSet) (*os.File, error) {
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	return os.Open(fs.Arg(0))
+}
+
+func summary(args []string) error {
+	fs := flag.NewFlagSet("summary", flag.ExitOnError)
+	fs.Parse(args)
+
+	f, err := openLog(fs)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	s, err := tracelog.Summarize(tracelog.NewReader(f))
+	if err != nil {
+		return err
+	}
+	return s.WriteText(os.Stdout)
+}
+
+func replay(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	carPath := fs.String("car", "", "CAR file with the blocks the replayed node serves")
+	speed := fs.Float64("speed", 0, "replay speed relative to the recording, 0 replays as fast as possible")
+	drain := fs.Duration("drain", defaultDrainTime, "how long to wait for responses after the last message")
+	fs.Parse(args)
+
+	f, err := openLog(fs)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	opts := replayOptions{Speed: *speed, DrainTime: *drain}
+	if *carPath != "" {
+		opts.Blockstore, err = loadCAR(ctx, *carPath)
+		if err != nil {
+			return fmt.Errorf("loading %s: %w", *carPath, err)
+		}
+	}
+
+	start := time.Now()
+	rep, err := replayLog(ctx, tracelog.NewReader(f), opts)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("replayed in %s\n", time.Since(start).Round(time.Millisecond))
+	return rep.WriteText(os.Stdout)
+}
+
+func loadCAR(ctx context.Context, path string) (blockstore.Blockstore, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	br, err := car.NewBlockReader(f)
+	if err != nil {
+		return nil, err
+	}
+	bs := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	for {
+		blk, err := br.Next()
+		if errors.Is(err, io.EOF) {
+			return bs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := bs.Put(ctx, blk); err != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ipfs/boxo/bitswap"
+	bsmsg "github.com/ipfs/boxo/bitswap/message"
+	bsmsgpb "github.com/ipfs/boxo/bitswap/message/pb"
+	bsnet "github.com/ipfs/boxo/bitswap/network"
+	tn "github.com/ipfs/boxo/bitswap/testnet"
+	"github.com/ipfs/boxo/bitswap/tracer/tracelog"
+	"github.com/ipfs/boxo/bitswap/tracer/tracelog/pb"
+	"github.com/ipfs/boxo/blockstore"
+	mockrouting "github.com/ipfs/boxo/routing/mock"
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	delay "github.com/ipfs/go-ipfs-delay"
+	ipld "github.com/ipfs/go-ipld-format"
+	p2ptestutil "github.com/libp2p/go-libp2p-testing/netutil"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// defaultDrainTime is the default replayOptions.DrainTime.
+const defaultDrainTime = time.Second
+
+// replayOptions configure replayLog.
+type replayOptions struct {
+	// Speed scales the delays between the recorded messages: 1 replays them
+	// as they were recorded, 2 twice as fast. Zero or less sends them as fast
+	// as possible.
+	Speed float64
+	// Blockstore is served by the replayed node. Blocks of received messages
+	// are only replayed when they are found in it, since logs do not hold
+	// block data. An empty blockstore is used when nil.
+	Blockstore blockstore.Blockstore
+	// DrainTime is how long to wait for the responses of the node after the
+	// last message. It defaults to defaultDrainTime.
+	DrainTime time.Duration
+	// BitswapOptions configure the replayed node.
+	BitswapOptions []bitswap.Option
+}
+
+// replayedPeer compares what a recorded peer exchanged with the node in the
+// log and during the replay.
+type replayedPeer struct {
+	Peer peer.ID
+	// Replayed is what the peer sent to the node, that is the messages the
+	// node received in the log.
+	Replayed tracelog.Counts
+	// Recorded is what the node sent to the peer in the log.
+	Recorded tracelog.Counts
+	// Responses is what the node sent to the peer during the replay.
+	Responses tracelog.Counts
+}
+
+// replayReport is the outcome of replayLog.
+type replayReport struct {
+	// Peers are sorted by number of replayed messages, busiest first.
+	Peers []replayedPeer
+	// SkippedBlocks counts the received blocks that could not be replayed
+	// because they were missing from replayOptions.Blockstore.
+	SkippedBlocks int
+}
+
+type replayPeer struct {
+	replayedPeer
+	net bsnet.BitSwapNetwork
+}
+
+// replayLog feeds the messages received in a log into a new bitswap node on
+// a [tn.VirtualNetwork], each recorded peer being impersonated by a peer of
+// the virtual network, and reports the responses of the node. Messages sent
+// in the log are not replayed, they are compared with the responses.
+func replayLog(ctx context.Context, r *tracelog.Reader, opts replayOptions) (*replayReport, error) {
+	if opts.DrainTime <= 0 {
+		opts.DrainTime = defaultDrainTime
+	}
+	bstore := opts.Blockstore
+	if bstore == nil {
+		bstore = blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	net := tn.VirtualNetwork(mockrouting.NewServer(), delay.Fixed(0))
+	self, err := p2ptestutil.RandTestBogusIdentity()
+	if err != nil {
+		return nil, err
+	}
+	node := bitswap.New(ctx, net.Adapter(self), bstore, opts.BitswapOptions...)
+	defer node.Close()
+
+	var mu sync.Mutex
+	report := &replayReport{}
+	peers := make(map[peer.ID]*replayPeer)
+	getPeer := func(p peer.ID) (*replayPeer, error) {
+		if rp, ok := peers[p]; ok {
+			return rp, nil
+		}
+		id, err := p2ptestutil.RandTestBogusIdentity()
+		if err != nil {
+			return nil, err
+		}
+		rp := &replayPeer{replayedPeer: replayedPeer{Peer: p}, net: net.Adapter(id)}
+		rp.net.Start(&responseCounter{mu: &mu, counts: &rp.Responses})
+		if err := rp.net.ConnectTo(ctx, self.ID()); err != nil {
+			return nil, err
+		}
+		peers[p] = rp
+		return rp, nil
+	}
+
+	var start, first time.Time
+	for {
+		ev, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		rp, err := getPeer(peer.ID(ev.Peer))
+		if err != nil {
+			return nil, err
+		}
+		if ev.Direction == pb.Event_SENT {
+			rp.Recorded.Add(ev)
+			continue
+		}
+
+		t := time.Unix(0, ev.Timestamp)
+		if first.IsZero() {
+			start, first = time.Now(), t
+		} else if opts.Speed > 0 {
+			wait := time.Until(start.Add(time.Duration(float64(t.Sub(first)) / opts.Speed)))
+			if wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return nil, ctx.Err()
+				case <-timer.C:
+				}
+			}
+		}
+
+		msg, skipped, err := eventMessage(ctx, ev, bstore)
+		if err != nil {
+			return nil, err
+		}
+		report.SkippedBlocks += skipped
+		rp.Replayed.Add(ev)
+		if err := rp.net.SendMessage(ctx, self.ID(), msg); err != nil {
+			return nil, fmt.Errorf("replaying message from %s: %w", rp.Peer, err)
+		}
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(opts.DrainTime):
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, rp := range peers {
+		report.Peers = append(report.Peers, rp.replayedPeer)
+	}
+	sort.Slice(report.Peers, func(i, j int) bool {
+		a, b := report.Peers[i], report.Peers[j]
+		if a.Replayed.Messages != b.Replayed.Messages {
+			return a.Replayed.Messages > b.Replayed.Messages
+		}
+		return a.Peer < b.Peer
+	})
+	return report, nil
+}
+
+// eventMessage rebuilds the message of ev, with the blocks found in bstore.
+// It returns the number of blocks left out.
+func eventMessage(ctx context.Context, ev *pb.Event, bstore blockstore.Blockstore) (bsmsg.BitSwapMessage, int, error) {
+	msg := bsmsg.New(ev.Full)
+	for _, w := range ev.Wants {
+		c, err := cid.Cast(w.Cid)
+		if err != nil {
+			return nil, 0, err
+		}
+		if w.Cancel {
+			msg.Cancel(c)
+			continue
+		}
+		wantType := bsmsgpb.Message_Wantlist_Block
+		if w.WantType == pb.WantEntry_HAVE {
+			wantType = bsmsgpb.Message_Wantlist_Have
+		}
+		msg.AddEntry(c, w.Priority, wantType, w.SendDontHave)
+	}
+
+	var skipped int
+	for _, b := range ev.Blocks {
+		c, err := cid.Cast(b.Cid)
+		if err != nil {
+			return nil, 0, err
+		}
+		blk, err := bstore.Get(ctx, c)
+		if ipld.IsNotFound(err) {
+			skipped++
+			continue
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		msg.AddBlock(blk)
+	}
+
+	for _, p := range ev.Presences {
+		c, err := cid.Cast(p.Cid)
+		if err != nil {
+			return nil, 0, err
+		}
+		if p.Type == pb.BlockPresence_DONT_HAVE {
+			msg.AddDontHave(c)
+		} else {
+			msg.AddHave(c)
+		}
+	}
+	msg.SetPendingBytes(ev.PendingBytes)
+	return msg, skipped, nil
+}
+
+// responseCounter counts the messages a recorded peer gets from the node.
+type responseCounter struct {
+	mu     *sync.Mutex
+	counts *tracelog.Counts
+}
+
+func (rc *responseCounter) ReceiveMessage(_ context.Context, p peer.ID, msg bsmsg.BitSwapMessage) {
+	ev := tracelog.NewEvent(time.Now(), pb.Event_RECEIVED, p, msg)
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.counts.Add(ev)
+}
+
+func (rc *responseCounter) ReceiveError(error) {}
+
+func (rc *responseCounter) PeerConnected(peer.ID) {}
+
+func (rc *responseCounter) PeerDisconnected(peer.ID) {}
+
+// WriteText writes the report as a human readable table.
+func (r *replayReport) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "%d peers replayed, %d blocks skipped\n\n", len(r.Peers), r.SkippedBlocks)
+
+	fmt.Fprintln(tw, "PEER\tMSGS TO NODE\tRECORDED MSGS\tREPLAY MSGS\tRECORDED BLOCKS\tREPLAY BLOCKS\tRECORDED HAVE/DONT-HAVE\tREPLAY HAVE/DONT-HAVE")
+	for _, p := range r.Peers {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%d\t%d/%d\t%d/%d\n",
+			p.Peer, p.Replayed.Messages, p.Recorded.Messages, p.Responses.Messages,
+			p.Recorded.Blocks, p.Responses.Blocks,
+			p.Recorded.Haves, p.Recorded.DontHaves, p.Responses.Haves, p.Responses.DontHaves)
+	}
+	return tw.Flush()
+}
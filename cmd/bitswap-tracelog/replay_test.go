@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	bsmsg "github.com/ipfs/boxo/bitswap/message"
+	bsmsgpb "github.com/ipfs/boxo/bitswap/message/pb"
+	"github.com/ipfs/boxo/bitswap/tracer/tracelog"
+	"github.com/ipfs/boxo/blockstore"
+	blocks "github.com/ipfs/go-block-format"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+const (
+	peerA = peer.ID("peer-a")
+	peerB = peer.ID("peer-b")
+)
+
+var (
+	present = blocks.NewBlock([]byte("present"))
+	missing = blocks.NewBlock([]byte("missing"))
+)
+
+func recordTestLog(t *testing.T) []byte {
+	var buf bytes.Buffer
+	rec := tracelog.NewRecorder(&buf)
+
+	want := bsmsg.New(false)
+	want.AddEntry(present.Cid(), 10, bsmsgpb.Message_Wantlist_Block, true)
+	want.AddEntry(missing.Cid(), 5, bsmsgpb.Message_Wantlist_Have, true)
+	rec.MessageReceived(peerA, want)
+
+	response := bsmsg.New(false)
+	response.AddBlock(present)
+	response.AddDontHave(missing.Cid())
+	rec.MessageSent(peerA, response)
+
+	cancel := bsmsg.New(false)
+	cancel.Cancel(missing.Cid())
+	rec.MessageReceived(peerB, cancel)
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReplayLog(t *testing.T) {
+	ctx := context.Background()
+	bstore := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	if err := bstore.Put(ctx, present); err != nil {
+		t.Fatal(err)
+	}
+
+	rep, err := replayLog(ctx, tracelog.NewReader(bytes.NewReader(recordTestLog(t))), replayOptions{
+		Blockstore: bstore,
+		DrainTime:  500 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rep.Peers) != 2 {
+		t.Fatalf("expected 2 peers, got %d", len(rep.Peers))
+	}
+	a := rep.Peers[0]
+	if a.Peer != peerA || a.Replayed.Messages != 1 || a.Recorded.Blocks != 1 {
+		t.Fatalf("unexpected replay of %s: %+v", peerA, a)
+	}
+	// The node answers the replayed wants as it did when recorded.
+	if a.Responses.Blocks != 1 || a.Responses.DontHaves != 1 {
+		t.Fatalf("unexpected responses to %s: %+v", peerA, a.Responses)
+	}
+}
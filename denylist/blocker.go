@@ -0,0 +1,220 @@
+package denylist
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ipfs/boxo/gateway"
+	"github.com/ipfs/boxo/path"
+	"github.com/ipfs/go-cid"
+)
+
+// DefaultReloadInterval is the default interval at which a [Blocker] checks
+// whether its denylist files changed.
+const DefaultReloadInterval = 10 * time.Second
+
+// ErrContentBlocked is the error returned for content blocked by a denylist.
+// It implements [gateway.BlockedContentError], so the gateway responds to it
+// with 410 Gone.
+type ErrContentBlocked struct {
+	// Content is the blocked CID or path.
+	Content string
+	// Denylist is the file of the denylist, or its name if it was not loaded
+	// from a file.
+	Denylist string
+	// Rule is the rule blocking the content.
+	Rule Rule
+}
+
+func (e *ErrContentBlocked) Error() string {
+	return fmt.Sprintf("%s is blocked and cannot be provided", e.Content)
+}
+
+// ContentBlocked implements [gateway.BlockedContentError].
+func (e *ErrContentBlocked) ContentBlocked() {}
+
+var _ gateway.BlockedContentError = (*ErrContentBlocked)(nil)
+
+type options struct {
+	reloadInterval time.Duration
+}
+
+// Option configures a [Blocker].
+type Option func(*options)
+
+// WithReloadInterval sets the interval at which the denylist files are checked
+// for changes. Zero disables reloading. Default is [DefaultReloadInterval].
+func WithReloadInterval(d time.Duration) Option {
+	return func(o *options) {
+		o.reloadInterval = d
+	}
+}
+
+type loadedDenylist struct {
+	name    string
+	file    string
+	modTime time.Time
+	size    int64
+	list    *Denylist
+}
+
+// Blocker checks content against a set of denylists. Content is blocked if
+// any denylist blocks it.
+type Blocker struct {
+	lk    sync.RWMutex
+	lists []*loadedDenylist
+
+	closing chan struct{}
+	closed  sync.WaitGroup
+	once    sync.Once
+}
+
+// NewBlocker creates a [Blocker] using the denylists in the given files. The
+// files are reloaded when their modification time or size change; a file that
+// fails to parse keeps its previous rules until it is fixed.
+func NewBlocker(files []string, opts ...Option) (*Blocker, error) {
+	o := options{reloadInterval: DefaultReloadInterval}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	b := &Blocker{closing: make(chan struct{})}
+	for _, file := range files {
+		l := &loadedDenylist{name: file, file: file}
+		if err := l.load(); err != nil {
+			return nil, err
+		}
+		b.lists = append(b.lists, l)
+	}
+
+	if o.reloadInterval > 0 && len(files) > 0 {
+		b.closed.Add(1)
+		go b.reloadLoop(o.reloadInterval)
+	}
+	return b, nil
+}
+
+// NewStaticBlocker creates a [Blocker] using the given denylists, which are
+// identified by their [Header] name in the errors.
+func NewStaticBlocker(lists ...*Denylist) *Blocker {
+	b := &Blocker{closing: make(chan struct{})}
+	for _, list := range lists {
+		b.lists = append(b.lists, &loadedDenylist{name: list.Header.Name, list: list})
+	}
+	return b
+}
+
+// load parses the file of the denylist.
+func (l *loadedDenylist) load() error {
+	f, err := os.Open(l.file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	st, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	list, err := Parse(f)
+	if err != nil {
+		return fmt.Errorf("cannot parse denylist %s: %w", l.file, err)
+	}
+	l.list, l.modTime, l.size = list, st.ModTime(), st.Size()
+	return nil
+}
+
+func (b *Blocker) reloadLoop(interval time.Duration) {
+	defer b.closed.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			b.reload()
+		case <-b.closing:
+			return
+		}
+	}
+}
+
+// reload reloads the denylist files that changed.
+func (b *Blocker) reload() {
+	b.lk.RLock()
+	lists := b.lists
+	b.lk.RUnlock()
+
+	for i, l := range lists {
+		st, err := os.Stat(l.file)
+		if err != nil {
+			log.Warnf("cannot check denylist %s: %s", l.file, err)
+			continue
+		}
+		if st.ModTime().Equal(l.modTime) && st.Size() == l.size {
+			continue
+		}
+
+		reloaded := &loadedDenylist{name: l.name, file: l.file}
+		if err := reloaded.load(); err != nil {
+			log.Errorf("keeping the previous version of denylist %s: %s", l.file, err)
+			// Don't retry until the file changes again.
+			reloaded.list, reloaded.modTime, reloaded.size = l.list, st.ModTime(), st.Size()
+		} else {
+			log.Infof("reloaded denylist %s", l.file)
+		}
+
+		b.lk.Lock()
+		b.lists[i] = reloaded
+		b.lk.Unlock()
+	}
+}
+
+// CheckCid returns an [ErrContentBlocked] if the given CID is blocked.
+func (b *Blocker) CheckCid(c cid.Cid) error {
+	b.lk.RLock()
+	defer b.lk.RUnlock()
+
+	for _, l := range b.lists {
+		if r := l.list.MatchCid(c); r != nil && !r.Allow {
+			return &ErrContentBlocked{Content: c.String(), Denylist: l.name, Rule: *r}
+		}
+	}
+	return nil
+}
+
+// CheckPath returns an [ErrContentBlocked] if the given /ipfs/ or /ipns/ path
+// is blocked.
+func (b *Blocker) CheckPath(p path.Path) error {
+	segments := p.Segments()
+	if len(segments) < 2 {
+		return nil
+	}
+	namespace, root := segments[0], segments[1]
+	rest := path.SegmentsToString(segments[2:]...)
+
+	b.lk.RLock()
+	defer b.lk.RUnlock()
+
+	for _, l := range b.lists {
+		r, err := l.list.MatchPath(namespace, root, rest)
+		if err != nil {
+			return err
+		}
+		if r != nil && !r.Allow {
+			return &ErrContentBlocked{Content: p.String(), Denylist: l.name, Rule: *r}
+		}
+	}
+	return nil
+}
+
+// Close stops reloading the denylists.
+func (b *Blocker) Close() error {
+	b.once.Do(func() {
+		close(b.closing)
+	})
+	b.closed.Wait()
+	return nil
+}
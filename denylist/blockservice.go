@@ -0,0 +1,178 @@
+package denylist
+
+import (
+	"context"
+
+	"github.com/ipfs/boxo/blockservice"
+	"github.com/ipfs/boxo/blockstore"
+	"github.com/ipfs/boxo/exchange"
+	"github.com/ipfs/boxo/verifcid"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+)
+
+// NewBlockService wraps a [blockservice.BlockService] so that blocked blocks
+// can't be retrieved or added. [blockservice.NewSession] is also covered, as
+// the blockstore and the exchange of the returned block service are wrapped
+// too.
+func NewBlockService(bs blockservice.BlockService, b *Blocker) blockservice.BoundedBlockService {
+	return &blockService{
+		BlockService: bs,
+		blocker:      b,
+		blockstore:   &blockingBlockstore{Blockstore: bs.Blockstore(), blocker: b},
+		exchange:     newBlockingExchange(bs.Exchange(), b),
+	}
+}
+
+type blockService struct {
+	blockservice.BlockService
+	blocker    *Blocker
+	blockstore blockstore.Blockstore
+	exchange   exchange.Interface
+}
+
+func (s *blockService) Allowlist() verifcid.Allowlist {
+	if bbs, ok := s.BlockService.(blockservice.BoundedBlockService); ok {
+		return bbs.Allowlist()
+	}
+	return verifcid.DefaultAllowlist
+}
+
+func (s *blockService) Blockstore() blockstore.Blockstore {
+	return s.blockstore
+}
+
+func (s *blockService) Exchange() exchange.Interface {
+	return s.exchange
+}
+
+func (s *blockService) GetBlock(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	if err := s.blocker.CheckCid(c); err != nil {
+		return nil, err
+	}
+	return s.BlockService.GetBlock(ctx, c)
+}
+
+func (s *blockService) GetBlocks(ctx context.Context, ks []cid.Cid) <-chan blocks.Block {
+	return s.BlockService.GetBlocks(ctx, s.blocker.filter(ks))
+}
+
+func (s *blockService) AddBlock(ctx context.Context, blk blocks.Block) error {
+	if err := s.blocker.CheckCid(blk.Cid()); err != nil {
+		return err
+	}
+	return s.BlockService.AddBlock(ctx, blk)
+}
+
+func (s *blockService) AddBlocks(ctx context.Context, blks []blocks.Block) error {
+	for _, blk := range blks {
+		if err := s.blocker.CheckCid(blk.Cid()); err != nil {
+			return err
+		}
+	}
+	return s.BlockService.AddBlocks(ctx, blks)
+}
+
+// filter returns the CIDs which are not blocked.
+func (b *Blocker) filter(ks []cid.Cid) []cid.Cid {
+	allowed := make([]cid.Cid, 0, len(ks))
+	for _, c := range ks {
+		if err := b.CheckCid(c); err != nil {
+			log.Debugf("not fetching blocked block: %s", err)
+			continue
+		}
+		allowed = append(allowed, c)
+	}
+	return allowed
+}
+
+// blockingBlockstore is a [blockstore.Blockstore] which doesn't return or
+// store blocked blocks.
+type blockingBlockstore struct {
+	blockstore.Blockstore
+	blocker *Blocker
+}
+
+func (bs *blockingBlockstore) Get(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	if err := bs.blocker.CheckCid(c); err != nil {
+		return nil, err
+	}
+	return bs.Blockstore.Get(ctx, c)
+}
+
+func (bs *blockingBlockstore) GetSize(ctx context.Context, c cid.Cid) (int, error) {
+	if err := bs.blocker.CheckCid(c); err != nil {
+		return -1, err
+	}
+	return bs.Blockstore.GetSize(ctx, c)
+}
+
+func (bs *blockingBlockstore) Put(ctx context.Context, blk blocks.Block) error {
+	if err := bs.blocker.CheckCid(blk.Cid()); err != nil {
+		return err
+	}
+	return bs.Blockstore.Put(ctx, blk)
+}
+
+func (bs *blockingBlockstore) PutMany(ctx context.Context, blks []blocks.Block) error {
+	for _, blk := range blks {
+		if err := bs.blocker.CheckCid(blk.Cid()); err != nil {
+			return err
+		}
+	}
+	return bs.Blockstore.PutMany(ctx, blks)
+}
+
+// newBlockingExchange wraps an exchange, keeping its support of sessions.
+func newBlockingExchange(exch exchange.Interface, b *Blocker) exchange.Interface {
+	if exch == nil {
+		return nil
+	}
+	e := &blockingExchange{Interface: exch, blocker: b}
+	if sessEx, ok := exch.(exchange.SessionExchange); ok {
+		return &blockingSessionExchange{blockingExchange: e, sessEx: sessEx}
+	}
+	return e
+}
+
+// blockingExchange is an [exchange.Interface] which doesn't fetch blocked
+// blocks.
+type blockingExchange struct {
+	exchange.Interface
+	blocker *Blocker
+}
+
+func (e *blockingExchange) GetBlock(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	return blockingFetcher{e.Interface, e.blocker}.GetBlock(ctx, c)
+}
+
+func (e *blockingExchange) GetBlocks(ctx context.Context, ks []cid.Cid) (<-chan blocks.Block, error) {
+	return blockingFetcher{e.Interface, e.blocker}.GetBlocks(ctx, ks)
+}
+
+type blockingSessionExchange struct {
+	*blockingExchange
+	sessEx exchange.SessionExchange
+}
+
+func (e *blockingSessionExchange) NewSession(ctx context.Context) exchange.Fetcher {
+	return blockingFetcher{e.sessEx.NewSession(ctx), e.blocker}
+}
+
+// blockingFetcher is an [exchange.Fetcher] which doesn't fetch blocked
+// blocks.
+type blockingFetcher struct {
+	exchange.Fetcher
+	blocker *Blocker
+}
+
+func (f blockingFetcher) GetBlock(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	if err := f.blocker.CheckCid(c); err != nil {
+		return nil, err
+	}
+	return f.Fetcher.GetBlock(ctx, c)
+}
+
+func (f blockingFetcher) GetBlocks(ctx context.Context, ks []cid.Cid) (<-chan blocks.Block, error) {
+	return f.Fetcher.GetBlocks(ctx, f.blocker.filter(ks))
+}
@@ -0,0 +1,387 @@
+// Package denylist blocks content listed in denylists using the [compact
+// denylist format].
+//
+// A [Denylist] is parsed from a file with [Parse]. A [Blocker] loads a set of
+// denylist files, reloads them when they change, and checks CIDs and content
+// paths against them. It can be plugged into a [blockservice.BlockService]
+// with [NewBlockService] and into a [gateway.IPFSBackend] with [NewBackend],
+// which return an [ErrContentBlocked] for blocked content.
+//
+// [compact denylist format]: https://github.com/ipfs/specs/pull/383
+package denylist
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ipfs/boxo/ipns"
+	"github.com/ipfs/go-cid"
+	logging "github.com/ipfs/go-log/v2"
+	mbase "github.com/multiformats/go-multibase"
+	mh "github.com/multiformats/go-multihash"
+)
+
+var log = logging.Logger("denylist")
+
+// maxLineSize is the maximum length of a line of a denylist.
+const maxLineSize = 2 << 20
+
+// Header is the optional YAML header of a denylist, separated from the rules
+// by a "---" line. Only flat "key: value" entries and the "hints" map are
+// supported.
+type Header struct {
+	Version     int
+	Name        string
+	Description string
+	Author      string
+	// Hints are hints for the implementations, such as the
+	// "gateway_status" to use for the blocked content.
+	Hints map[string]string
+}
+
+// Rule is a rule of a denylist.
+type Rule struct {
+	// Line is the line number of the rule in the denylist, starting at 1.
+	Line int
+	// Raw is the rule as written in the denylist.
+	Raw string
+	// Allow is set for negated rules, starting with "!", which allow content
+	// blocked by other rules.
+	Allow bool
+}
+
+// pathRule is a rule matching the paths under a CID or IPNS name.
+type pathRule struct {
+	*Rule
+	// path is the path matched, without leading or trailing slashes.
+	path string
+	// tree is set if the rule matches the path and every path under it.
+	tree bool
+	// prefix is set if the rule matches every path starting with path.
+	prefix bool
+}
+
+func (r pathRule) match(p string) bool {
+	switch {
+	case r.prefix:
+		return strings.HasPrefix(p, r.path)
+	case r.tree:
+		return r.path == "" || p == r.path || strings.HasPrefix(p, r.path+"/")
+	default:
+		return p == r.path
+	}
+}
+
+// Denylist is a parsed denylist.
+type Denylist struct {
+	Header Header
+
+	// cids are the rules for /ipfs/ paths, by multihash.
+	cids map[string][]pathRule
+	// names are the rules for /ipns/ paths, by normalized name.
+	names map[string][]pathRule
+	// doubleHashes are the double-hashed rules, by multihash or
+	// hex-encoded SHA-256 for legacy entries.
+	doubleHashes map[string]*Rule
+	// hashCodes are the hash functions of the double-hashed rules.
+	hashCodes map[uint64]struct{}
+	hasLegacy bool
+}
+
+// Parse parses a denylist. Invalid rules are reported with the line they are
+// at.
+func Parse(r io.Reader) (*Denylist, error) {
+	d := &Denylist{
+		cids:         make(map[string][]pathRule),
+		names:        make(map[string][]pathRule),
+		doubleHashes: make(map[string]*Rule),
+		hashCodes:    make(map[uint64]struct{}),
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxLineSize)
+	var lines []string
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	start := 0
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "---" {
+			if err := d.parseHeader(lines[:i]); err != nil {
+				return nil, err
+			}
+			start = i + 1
+			break
+		}
+	}
+
+	for i := start; i < len(lines); i++ {
+		if err := d.parseRule(i+1, lines[i]); err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+	}
+	return d, nil
+}
+
+func (d *Denylist) parseHeader(lines []string) error {
+	inHints := false
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		indented := strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")
+		key, value, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if !ok {
+			return fmt.Errorf("line %d: invalid header entry %q", i+1, line)
+		}
+		key, value = strings.TrimSpace(key), strings.Trim(strings.TrimSpace(value), `"'`)
+
+		if indented && inHints {
+			if d.Header.Hints == nil {
+				d.Header.Hints = make(map[string]string)
+			}
+			d.Header.Hints[key] = value
+			continue
+		}
+		inHints = false
+
+		switch key {
+		case "version":
+			if _, err := fmt.Sscan(value, &d.Header.Version); err != nil {
+				return fmt.Errorf("line %d: invalid version %q", i+1, value)
+			}
+		case "name":
+			d.Header.Name = value
+		case "description":
+			d.Header.Description = value
+		case "author":
+			d.Header.Author = value
+		case "hints":
+			inHints = true
+		}
+	}
+	return nil
+}
+
+func (d *Denylist) parseRule(n int, line string) error {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return nil
+	}
+	// Anything after the rule, such as hints, is ignored.
+	if fields := strings.Fields(line); len(fields) > 1 {
+		line = fields[0]
+	}
+
+	rule := &Rule{Line: n, Raw: line}
+	if strings.HasPrefix(line, "!") {
+		rule.Allow = true
+		line = line[1:]
+	}
+
+	switch {
+	case strings.HasPrefix(line, "//"):
+		return d.parseDoubleHash(rule, line[2:])
+	case strings.HasPrefix(line, "/ipfs/"):
+		root, rest := splitRoot(line[len("/ipfs/"):])
+		c, err := cid.Decode(root)
+		if err != nil {
+			return fmt.Errorf("invalid CID %q: %w", root, err)
+		}
+		key := string(c.Hash())
+		d.cids[key] = append(d.cids[key], newPathRule(rule, rest))
+	case strings.HasPrefix(line, "/ipns/"):
+		root, rest := splitRoot(line[len("/ipns/"):])
+		key := normalizeName(root)
+		d.names[key] = append(d.names[key], newPathRule(rule, rest))
+	case !strings.HasPrefix(line, "/"):
+		// A bare CID.
+		c, err := cid.Decode(line)
+		if err != nil {
+			return fmt.Errorf("invalid rule %q: %w", rule.Raw, err)
+		}
+		key := string(c.Hash())
+		d.cids[key] = append(d.cids[key], newPathRule(rule, ""))
+	default:
+		return fmt.Errorf("unsupported rule %q", rule.Raw)
+	}
+	return nil
+}
+
+func (d *Denylist) parseDoubleHash(rule *Rule, s string) error {
+	if len(s) == 2*sha256.Size {
+		if _, err := hex.DecodeString(s); err == nil {
+			d.doubleHashes[strings.ToLower(s)] = rule
+			d.hasLegacy = true
+			return nil
+		}
+	}
+
+	hash, err := mh.FromB58String(s)
+	if err != nil {
+		return fmt.Errorf("invalid double-hash %q: %w", s, err)
+	}
+	decoded, err := mh.Decode(hash)
+	if err != nil {
+		return fmt.Errorf("invalid double-hash %q: %w", s, err)
+	}
+	d.doubleHashes[string(hash)] = rule
+	d.hashCodes[decoded.Code] = struct{}{}
+	return nil
+}
+
+// newPathRule returns the rule matching the given path, relative to a CID or
+// name. An empty path or "*" matches the CID or name and every path under
+// it, "path/*" matches the path and every path under it, "path*" matches
+// every path starting with "path", other paths only match themselves.
+func newPathRule(rule *Rule, p string) pathRule {
+	p = strings.TrimPrefix(p, "/")
+	switch {
+	case p == "" || p == "*":
+		return pathRule{Rule: rule, tree: true}
+	case strings.HasSuffix(p, "/*"):
+		return pathRule{Rule: rule, path: strings.TrimSuffix(p, "/*"), tree: true}
+	case strings.HasSuffix(p, "*"):
+		return pathRule{Rule: rule, path: strings.TrimSuffix(p, "*"), prefix: true}
+	default:
+		return pathRule{Rule: rule, path: strings.TrimSuffix(p, "/")}
+	}
+}
+
+// splitRoot splits "root/rest" into the root and the rest.
+func splitRoot(s string) (string, string) {
+	root, rest, _ := strings.Cut(s, "/")
+	return root, rest
+}
+
+// normalizeName returns the canonical form of an IPNS name: the string form
+// of the [ipns.Name] for keys, the lowercase domain for DNSLink names.
+func normalizeName(name string) string {
+	if n, err := ipns.NameFromString(name); err == nil {
+		return n.String()
+	}
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
+
+// matchPathRules returns the rule applying to p, preferring allow rules.
+func matchPathRules(rules []pathRule, p string) *Rule {
+	var match *Rule
+	for _, r := range rules {
+		if !r.match(p) {
+			continue
+		}
+		if r.Allow {
+			return r.Rule
+		}
+		if match == nil {
+			match = r.Rule
+		}
+	}
+	return match
+}
+
+// MatchCid returns the rule matching the given CID, or nil. The CID matches
+// the rules for itself and for all its paths, regardless of its version and
+// codec.
+func (d *Denylist) MatchCid(c cid.Cid) *Rule {
+	return d.matchCidPath(c, "")
+}
+
+// MatchPath returns the rule matching the given /ipfs/ or /ipns/ path, which
+// is the path relative to the root CID or name, or nil.
+func (d *Denylist) MatchPath(namespace, root, p string) (*Rule, error) {
+	p = strings.Trim(p, "/")
+	switch namespace {
+	case "ipfs":
+		c, err := cid.Decode(root)
+		if err != nil {
+			return nil, err
+		}
+		return d.matchCidPath(c, p), nil
+	case "ipns":
+		name := normalizeName(root)
+		if r := matchPathRules(d.names[name], p); r != nil {
+			return r, nil
+		}
+		return d.matchDoubleHash(name, p), nil
+	default:
+		return nil, fmt.Errorf("unsupported namespace %q", namespace)
+	}
+}
+
+func (d *Denylist) matchCidPath(c cid.Cid, p string) *Rule {
+	if r := matchPathRules(d.cids[string(c.Hash())], p); r != nil {
+		return r
+	}
+
+	if r := d.matchDoubleHash(c.Hash().B58String(), p); r != nil {
+		return r
+	}
+	if d.hasLegacy {
+		return d.matchLegacy(c, p)
+	}
+	return nil
+}
+
+// matchDoubleHash returns the double-hashed rule matching the given root,
+// the base58 multihash of a CID or an IPNS name, or the path under it.
+func (d *Denylist) matchDoubleHash(root, p string) *Rule {
+	if len(d.hashCodes) == 0 {
+		return nil
+	}
+	candidates := []string{root}
+	if p != "" {
+		candidates = append(candidates, root+"/"+p)
+	}
+
+	var match *Rule
+	for code := range d.hashCodes {
+		for _, s := range candidates {
+			hash, err := mh.Sum([]byte(s), code, -1)
+			if err != nil {
+				continue
+			}
+			if r, ok := d.doubleHashes[string(hash)]; ok {
+				if r.Allow {
+					return r
+				}
+				match = r
+			}
+		}
+	}
+	return match
+}
+
+// matchLegacy returns the legacy double-hashed rule matching the CID or the
+// path under it: the hex-encoded SHA-256 of the base32 CIDv1 followed by the
+// path.
+func (d *Denylist) matchLegacy(c cid.Cid, p string) *Rule {
+	v1, err := cid.NewCidV1(c.Type(), c.Hash()).StringOfBase(mbase.Base32)
+	if err != nil {
+		return nil
+	}
+	candidates := []string{v1 + "/"}
+	if p != "" {
+		candidates = append(candidates, v1+"/"+p)
+	}
+
+	var match *Rule
+	for _, s := range candidates {
+		sum := sha256.Sum256([]byte(s))
+		if r, ok := d.doubleHashes[hex.EncodeToString(sum[:])]; ok {
+			if r.Allow {
+				return r
+			}
+			match = r
+		}
+	}
+	return match
+}
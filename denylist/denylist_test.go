@@ -0,0 +1,160 @@
+package denylist
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ipfs/boxo/ipns"
+	"github.com/ipfs/boxo/path"
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/peer"
+	mbase "github.com/multiformats/go-multibase"
+	mh "github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	cid1 = cid.MustParse("bafkreigh2akiscaildcqabsyg3dfr6chu3fgpregiymsck7e7aqa4s52zy")
+	cid2 = cid.MustParse("bafkreiabzmdcnolspz6iqsvypf7yb5bjhyzq5bg7jbryn2zp6x2w4ivhuu")
+	cid3 = cid.MustParse("bafkreih6ccqzrhdjz2hz2c4dkysqqb6m5ufszg5trynrjqlmvfdsqrvzjq")
+)
+
+// doubleHash returns the double-hash entry of s.
+func doubleHash(t *testing.T, s string) string {
+	hash, err := mh.Sum([]byte(s), mh.SHA2_256, -1)
+	require.NoError(t, err)
+	return "//" + hash.B58String()
+}
+
+func mustPath(t *testing.T, s string) path.Path {
+	p, err := path.NewPath(s)
+	require.NoError(t, err)
+	return p
+}
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	pid, err := peer.Decode("12D3KooWRBy97UB99e3J6hiPesre1MZeuNQvfan4gBziswrRJsNK")
+	require.NoError(t, err)
+	name := ipns.NameFromPeer(pid)
+	v1, err := cid3.StringOfBase(mbase.Base32)
+	require.NoError(t, err)
+	legacy := sha256.Sum256([]byte(v1 + "/"))
+	cid2V0 := cid.NewCidV0(cid2.Hash())
+
+	d, err := Parse(strings.NewReader(`version: 1
+name: test list
+description: "A test"
+hints:
+  gateway_status: 410
+  other: value
+---
+# Comment
+/ipfs/` + cid1.String() + `
+/ipfs/` + cid2.String() + `/secret/file
+/ipfs/` + cid2.String() + `/dir/*
+/ipfs/` + cid2.String() + `/prefix*
+!/ipfs/` + cid2.String() + `/dir/public
+/ipns/` + pid.String() + `
+/ipns/example.com/blocked.txt
+` + doubleHash(t, "blocked.example.net") + `
+` + doubleHash(t, cid2.Hash().B58String()+"/hashed") + `
+//` + hex.EncodeToString(legacy[:]) + ` hint:ignored
+`))
+	require.NoError(t, err)
+
+	assert.Equal(t, Header{
+		Version:     1,
+		Name:        "test list",
+		Description: "A test",
+		Hints:       map[string]string{"gateway_status": "410", "other": "value"},
+	}, d.Header)
+
+	b := NewStaticBlocker(d)
+	for _, tc := range []struct {
+		path    string
+		blocked bool
+	}{
+		{"/ipfs/" + cid1.String(), true},
+		{"/ipfs/" + cid1.String() + "/any/path", true},
+		{"/ipfs/" + cid.NewCidV1(cid.DagProtobuf, cid1.Hash()).String(), true},
+		{"/ipfs/" + cid2.String(), false},
+		{"/ipfs/" + cid2V0.String() + "/secret/file", true},
+		{"/ipfs/" + cid2.String() + "/secret", false},
+		{"/ipfs/" + cid2.String() + "/secret/file/under", false},
+		{"/ipfs/" + cid2.String() + "/dir", true},
+		{"/ipfs/" + cid2.String() + "/dir/sub/file", true},
+		{"/ipfs/" + cid2.String() + "/dir/public", false},
+		{"/ipfs/" + cid2.String() + "/prefixed", true},
+		{"/ipfs/" + cid2.String() + "/hashed", true},
+		{"/ipfs/" + cid3.String(), true},
+		{"/ipfs/" + cid3.String() + "/file", true},
+		{"/ipns/" + name.String(), true},
+		{"/ipns/" + name.String() + "/file", true},
+		{"/ipns/example.com", false},
+		{"/ipns/Example.com/blocked.txt", true},
+		{"/ipns/blocked.example.net", true},
+		{"/ipns/allowed.example.net", false},
+	} {
+		err := b.CheckPath(mustPath(t, tc.path))
+		if !tc.blocked {
+			assert.NoError(t, err, tc.path)
+			continue
+		}
+		var blocked *ErrContentBlocked
+		if assert.True(t, errors.As(err, &blocked), tc.path) {
+			assert.Equal(t, "test list", blocked.Denylist)
+			assert.Contains(t, err.Error(), "blocked and cannot be provided")
+		}
+	}
+
+	require.Error(t, b.CheckCid(cid1))
+	require.NoError(t, b.CheckCid(cid2))
+	require.Error(t, b.CheckCid(cid3))
+
+	_, err = Parse(strings.NewReader("/ipfs/notacid"))
+	require.ErrorContains(t, err, "line 1")
+	_, err = Parse(strings.NewReader("/unsupported/rule"))
+	require.Error(t, err)
+}
+
+func TestBlockerReload(t *testing.T) {
+	t.Parallel()
+
+	file := filepath.Join(t.TempDir(), "denylist.deny")
+	require.NoError(t, os.WriteFile(file, []byte("/ipfs/"+cid1.String()+"\n"), 0o644))
+
+	b, err := NewBlocker([]string{file}, WithReloadInterval(10*time.Millisecond))
+	require.NoError(t, err)
+	defer b.Close()
+
+	require.Error(t, b.CheckCid(cid1))
+	require.NoError(t, b.CheckCid(cid2))
+
+	// The file is reloaded when it changes.
+	require.NoError(t, os.WriteFile(file, []byte("/ipfs/"+cid2.String()+"\n"+cid3.String()+"\n"), 0o644))
+	require.Eventually(t, func() bool {
+		return b.CheckCid(cid1) == nil && b.CheckCid(cid2) != nil && b.CheckCid(cid3) != nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Invalid files are ignored.
+	require.NoError(t, os.WriteFile(file, []byte("invalid\n"), 0o644))
+	time.Sleep(100 * time.Millisecond)
+	require.Error(t, b.CheckCid(cid2))
+
+	var blocked *ErrContentBlocked
+	require.ErrorAs(t, b.CheckCid(cid3), &blocked)
+	assert.Equal(t, file, blocked.Denylist)
+	assert.Equal(t, 2, blocked.Rule.Line)
+
+	_, err = NewBlocker([]string{filepath.Join(t.TempDir(), "missing")})
+	require.Error(t, err)
+}
@@ -0,0 +1,170 @@
+package denylist
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/ipfs/boxo/files"
+	"github.com/ipfs/boxo/gateway"
+	"github.com/ipfs/boxo/path"
+	"github.com/ipfs/go-cid"
+)
+
+// NewBackend wraps a [gateway.IPFSBackend] so that blocked paths, and the
+// blocked CIDs they resolve to, are not served. Requests for blocked content
+// fail with an [ErrContentBlocked], for which the gateway returns 410 Gone.
+//
+// Blocked blocks can still be served when reached through paths which are
+// not blocked, unless the backend also uses a block service returned by
+// [NewBlockService].
+func NewBackend(backend gateway.IPFSBackend, b *Blocker) gateway.IPFSBackend {
+	return &blockingBackend{backend: backend, blocker: b}
+}
+
+type blockingBackend struct {
+	backend gateway.IPFSBackend
+	blocker *Blocker
+}
+
+var _ gateway.IPFSBackend = (*blockingBackend)(nil)
+
+// checkResolved checks the CIDs of the path segments of a request, and the
+// CID it was resolved to.
+func (b *blockingBackend) checkResolved(md gateway.ContentPathMetadata) error {
+	for _, c := range md.PathSegmentRoots {
+		if err := b.blocker.CheckCid(c); err != nil {
+			return err
+		}
+	}
+	if c := md.LastSegment.RootCid(); c.Defined() {
+		return b.blocker.CheckCid(c)
+	}
+	return nil
+}
+
+func (b *blockingBackend) Get(ctx context.Context, p path.ImmutablePath, ranges ...gateway.ByteRange) (gateway.ContentPathMetadata, *gateway.GetResponse, error) {
+	if err := b.blocker.CheckPath(p); err != nil {
+		return gateway.ContentPathMetadata{}, nil, err
+	}
+	md, res, err := b.backend.Get(ctx, p, ranges...)
+	if err != nil {
+		return md, res, err
+	}
+	if err := b.checkResolved(md); err != nil {
+		res.Close()
+		return gateway.ContentPathMetadata{}, nil, err
+	}
+	return md, res, nil
+}
+
+func (b *blockingBackend) GetAll(ctx context.Context, p path.ImmutablePath) (gateway.ContentPathMetadata, files.Node, error) {
+	if err := b.blocker.CheckPath(p); err != nil {
+		return gateway.ContentPathMetadata{}, nil, err
+	}
+	md, nd, err := b.backend.GetAll(ctx, p)
+	if err != nil {
+		return md, nd, err
+	}
+	if err := b.checkResolved(md); err != nil {
+		nd.Close()
+		return gateway.ContentPathMetadata{}, nil, err
+	}
+	return md, nd, nil
+}
+
+func (b *blockingBackend) GetBlock(ctx context.Context, p path.ImmutablePath) (gateway.ContentPathMetadata, files.File, error) {
+	if err := b.blocker.CheckPath(p); err != nil {
+		return gateway.ContentPathMetadata{}, nil, err
+	}
+	md, f, err := b.backend.GetBlock(ctx, p)
+	if err != nil {
+		return md, f, err
+	}
+	if err := b.checkResolved(md); err != nil {
+		f.Close()
+		return gateway.ContentPathMetadata{}, nil, err
+	}
+	return md, f, nil
+}
+
+func (b *blockingBackend) Head(ctx context.Context, p path.ImmutablePath) (gateway.ContentPathMetadata, *gateway.HeadResponse, error) {
+	if err := b.blocker.CheckPath(p); err != nil {
+		return gateway.ContentPathMetadata{}, nil, err
+	}
+	md, res, err := b.backend.Head(ctx, p)
+	if err != nil {
+		return md, res, err
+	}
+	if err := b.checkResolved(md); err != nil {
+		res.Close()
+		return gateway.ContentPathMetadata{}, nil, err
+	}
+	return md, res, nil
+}
+
+func (b *blockingBackend) ResolvePath(ctx context.Context, p path.ImmutablePath) (gateway.ContentPathMetadata, error) {
+	if err := b.blocker.CheckPath(p); err != nil {
+		return gateway.ContentPathMetadata{}, err
+	}
+	md, err := b.backend.ResolvePath(ctx, p)
+	if err != nil {
+		return md, err
+	}
+	if err := b.checkResolved(md); err != nil {
+		return gateway.ContentPathMetadata{}, err
+	}
+	return md, nil
+}
+
+func (b *blockingBackend) GetCAR(ctx context.Context, p path.ImmutablePath, params gateway.CarParams) (gateway.ContentPathMetadata, io.ReadCloser, error) {
+	// The metadata returned with a CAR stream only holds its root, the path
+	// is resolved first to check the CIDs it goes through.
+	if _, err := b.ResolvePath(ctx, p); err != nil {
+		return gateway.ContentPathMetadata{}, nil, err
+	}
+	return b.backend.GetCAR(ctx, p, params)
+}
+
+func (b *blockingBackend) IsCached(ctx context.Context, p path.Path) bool {
+	if err := b.blocker.CheckPath(p); err != nil {
+		return false
+	}
+	return b.backend.IsCached(ctx, p)
+}
+
+func (b *blockingBackend) GetIPNSRecord(ctx context.Context, c cid.Cid) ([]byte, error) {
+	p, err := path.NewPath("/ipns/" + c.String())
+	if err != nil {
+		return nil, err
+	}
+	if err := b.blocker.CheckPath(p); err != nil {
+		return nil, err
+	}
+	return b.backend.GetIPNSRecord(ctx, c)
+}
+
+func (b *blockingBackend) ResolveMutable(ctx context.Context, p path.Path) (path.ImmutablePath, time.Duration, time.Time, error) {
+	if err := b.blocker.CheckPath(p); err != nil {
+		return path.ImmutablePath{}, 0, time.Time{}, err
+	}
+	resolved, ttl, lastMod, err := b.backend.ResolveMutable(ctx, p)
+	if err != nil {
+		return resolved, ttl, lastMod, err
+	}
+	if err := b.blocker.CheckPath(resolved); err != nil {
+		return path.ImmutablePath{}, 0, time.Time{}, err
+	}
+	return resolved, ttl, lastMod, nil
+}
+
+func (b *blockingBackend) GetDNSLinkRecord(ctx context.Context, fqdn string) (path.Path, error) {
+	p, err := path.NewPath("/ipns/" + fqdn)
+	if err != nil {
+		return nil, err
+	}
+	if err := b.blocker.CheckPath(p); err != nil {
+		return nil, err
+	}
+	return b.backend.GetDNSLinkRecord(ctx, fqdn)
+}
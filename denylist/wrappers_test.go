@@ -0,0 +1,113 @@
+package denylist
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/boxo/blockservice"
+	"github.com/ipfs/boxo/blockstore"
+	offline "github.com/ipfs/boxo/exchange/offline"
+	"github.com/ipfs/boxo/gateway"
+	"github.com/ipfs/boxo/ipld/merkledag"
+	ft "github.com/ipfs/boxo/ipld/unixfs"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	format "github.com/ipfs/go-ipld-format"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlockService(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	allowed := blocks.NewBlock([]byte("allowed"))
+	denied := blocks.NewBlock([]byte("denied"))
+	d, err := Parse(strings.NewReader("/ipfs/" + denied.Cid().String()))
+	require.NoError(t, err)
+
+	bstore := blockstore.NewBlockstore(dssync.MutexWrap(datastore.NewMapDatastore()))
+	require.NoError(t, bstore.PutMany(ctx, []blocks.Block{allowed, denied}))
+	bs := NewBlockService(blockservice.New(bstore, offline.Exchange(bstore)), NewStaticBlocker(d))
+
+	_, err = bs.GetBlock(ctx, allowed.Cid())
+	require.NoError(t, err)
+	_, err = bs.GetBlock(ctx, denied.Cid())
+	var blocked *ErrContentBlocked
+	require.ErrorAs(t, err, &blocked)
+
+	var got []blocks.Block
+	for blk := range bs.GetBlocks(ctx, []cid.Cid{allowed.Cid(), denied.Cid()}) {
+		got = append(got, blk)
+	}
+	require.Len(t, got, 1)
+	assert.Equal(t, allowed.Cid(), got[0].Cid())
+
+	require.ErrorAs(t, bs.AddBlock(ctx, denied), &blocked)
+
+	// Sessions use the blockstore and the exchange of the block service.
+	ses := blockservice.NewSession(ctx, bs)
+	_, err = ses.GetBlock(ctx, allowed.Cid())
+	require.NoError(t, err)
+	_, err = ses.GetBlock(ctx, denied.Cid())
+	require.ErrorAs(t, err, &blocked)
+}
+
+func TestBackend(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	bstore := blockstore.NewBlockstore(dssync.MutexWrap(datastore.NewMapDatastore()))
+	dserv := merkledag.NewDAGService(blockservice.New(bstore, offline.Exchange(bstore)))
+
+	public := merkledag.NewRawNode([]byte("public"))
+	secret := merkledag.NewRawNode([]byte("secret"))
+	dir := ft.EmptyDirNode()
+	require.NoError(t, dir.AddNodeLink("public.txt", public))
+	require.NoError(t, dir.AddNodeLink("secret.txt", secret))
+	require.NoError(t, dir.AddNodeLink("alias.txt", secret))
+	blockedDir := ft.EmptyDirNode()
+	require.NoError(t, blockedDir.AddNodeLink("public.txt", public))
+	parent := ft.EmptyDirNode()
+	require.NoError(t, parent.AddNodeLink("dir", blockedDir))
+	require.NoError(t, dserv.AddMany(ctx, []format.Node{public, secret, dir, blockedDir, parent}))
+
+	d, err := Parse(strings.NewReader("/ipfs/" + dir.Cid().String() + "/secret.txt\n/ipfs/" + secret.Cid().String() + "\n/ipfs/" + blockedDir.Cid().String() + "\n"))
+	require.NoError(t, err)
+
+	backend, err := gateway.NewBlocksBackend(blockservice.New(bstore, offline.Exchange(bstore)))
+	require.NoError(t, err)
+	srv := httptest.NewServer(gateway.NewHandler(gateway.Config{DeserializedResponses: true}, NewBackend(backend, NewStaticBlocker(d))))
+	t.Cleanup(srv.Close)
+
+	for _, tc := range []struct {
+		path   string
+		status int
+	}{
+		{"/ipfs/" + dir.Cid().String() + "/public.txt", http.StatusOK},
+		{"/ipfs/" + dir.Cid().String() + "/secret.txt", http.StatusGone},
+		// The path is allowed, but it resolves to a blocked CID.
+		{"/ipfs/" + dir.Cid().String() + "/alias.txt", http.StatusGone},
+		{"/ipfs/" + secret.Cid().String(), http.StatusGone},
+		{"/ipfs/" + secret.Cid().String() + "?format=raw", http.StatusGone},
+		{"/ipfs/" + secret.Cid().String() + "?format=car", http.StatusGone},
+		// TAR and CAR responses of allowed paths resolving to blocked CIDs.
+		{"/ipfs/" + dir.Cid().String() + "/alias.txt?format=tar", http.StatusGone},
+		{"/ipfs/" + dir.Cid().String() + "/alias.txt?format=car", http.StatusGone},
+		{"/ipfs/" + dir.Cid().String() + "/public.txt?format=car", http.StatusOK},
+		// Paths going through a blocked CID.
+		{"/ipfs/" + parent.Cid().String() + "/dir/public.txt", http.StatusGone},
+		{"/ipfs/" + parent.Cid().String() + "/dir/public.txt?format=tar", http.StatusGone},
+		{"/ipfs/" + parent.Cid().String() + "/dir/public.txt?format=car", http.StatusGone},
+	} {
+		res, err := http.Get(srv.URL + tc.path)
+		require.NoError(t, err)
+		res.Body.Close()
+		assert.Equal(t, tc.status, res.StatusCode, tc.path)
+	}
+}
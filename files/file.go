@@ -4,6 +4,7 @@ import (
 	"errors"
 	"io"
 	"os"
+	"time"
 )
 
 var (
@@ -21,6 +22,15 @@ type Node interface {
 	// all files stored in the tree should be returned). Some implementations may
 	// choose not to implement this
 	Size() (int64, error)
+
+	// Mode returns the mode of this node, or 0 if it is unknown. The file
+	// type bits (os.ModeDir, os.ModeSymlink) are only set when a mode is
+	// known.
+	Mode() os.FileMode
+
+	// ModTime returns the last modification time of this node, or the zero
+	// time.Time if it is unknown.
+	ModTime() time.Time
 }
 
 // Node represents a regular Unix file
@@ -12,7 +12,7 @@ import (
 var text = "Some text! :)"
 
 func newBytesFileWithPath(abspath string, b []byte) File {
-	return &ReaderFile{abspath, bytesReaderCloser{bytes.NewReader(b)}, nil, int64(len(b))}
+	return &ReaderFile{abspath: abspath, reader: bytesReaderCloser{bytes.NewReader(b)}, fsize: int64(len(b))}
 }
 
 func makeMultiFileReader(t *testing.T, binaryFileName, rawAbsPath bool) (string, *MultiFileReader) {
@@ -5,8 +5,10 @@ import (
 	"mime"
 	"mime/multipart"
 	"net/url"
+	"os"
 	"path"
 	"strings"
+	"time"
 )
 
 const (
@@ -239,4 +241,12 @@ func (f *multipartDirectory) Size() (int64, error) {
 	return 0, ErrNotSupported
 }
 
+func (f *multipartDirectory) Mode() os.FileMode {
+	return 0
+}
+
+func (f *multipartDirectory) ModTime() time.Time {
+	return time.Time{}
+}
+
 var _ Directory = &multipartDirectory{}
@@ -5,6 +5,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"time"
 )
 
 // ReaderFile is a implementation of File created from an `io.Reader`.
@@ -15,10 +16,18 @@ type ReaderFile struct {
 	stat    os.FileInfo
 
 	fsize int64
+	mode  os.FileMode
+	mtime time.Time
 }
 
 func NewBytesFile(b []byte) File {
-	return &ReaderFile{"", bytesReaderCloser{bytes.NewReader(b)}, nil, int64(len(b))}
+	return &ReaderFile{"", bytesReaderCloser{bytes.NewReader(b)}, nil, int64(len(b)), 0, time.Time{}}
+}
+
+// NewBytesFileWithMeta is like NewBytesFile but also records the given mode
+// and modification time.
+func NewBytesFileWithMeta(b []byte, mode os.FileMode, mtime time.Time) File {
+	return &ReaderFile{"", bytesReaderCloser{bytes.NewReader(b)}, nil, int64(len(b)), mode, mtime}
 }
 
 // TODO: Is this the best way to fix this bug?
@@ -42,7 +51,18 @@ func NewReaderStatFile(reader io.Reader, stat os.FileInfo) File {
 		rc = io.NopCloser(reader)
 	}
 
-	return &ReaderFile{"", rc, stat, -1}
+	return &ReaderFile{"", rc, stat, -1, 0, time.Time{}}
+}
+
+// NewReaderFileWithMeta is like NewReaderFile but also records the given mode
+// and modification time.
+func NewReaderFileWithMeta(reader io.Reader, mode os.FileMode, mtime time.Time) File {
+	rc, ok := reader.(io.ReadCloser)
+	if !ok {
+		rc = io.NopCloser(reader)
+	}
+
+	return &ReaderFile{"", rc, nil, -1, mode, mtime}
 }
 
 func NewReaderPathFile(path string, reader io.ReadCloser, stat os.FileInfo) (*ReaderFile, error) {
@@ -51,7 +71,7 @@ func NewReaderPathFile(path string, reader io.ReadCloser, stat os.FileInfo) (*Re
 		return nil, err
 	}
 
-	return &ReaderFile{abspath, reader, stat, -1}, nil
+	return &ReaderFile{abspath, reader, stat, -1, 0, time.Time{}}, nil
 }
 
 func (f *ReaderFile) AbsPath() string {
@@ -80,6 +100,20 @@ func (f *ReaderFile) Size() (int64, error) {
 	return f.stat.Size(), nil
 }
 
+func (f *ReaderFile) Mode() os.FileMode {
+	if f.stat != nil {
+		return f.stat.Mode()
+	}
+	return f.mode
+}
+
+func (f *ReaderFile) ModTime() time.Time {
+	if f.stat != nil {
+		return f.stat.ModTime()
+	}
+	return f.mtime
+}
+
 func (f *ReaderFile) Seek(offset int64, whence int) (int64, error) {
 	if s, ok := f.reader.(io.Seeker); ok {
 		return s.Seek(offset, whence)
@@ -1,6 +1,10 @@
 package files
 
-import "sort"
+import (
+	"os"
+	"sort"
+	"time"
+)
 
 type fileEntry struct {
 	name string
@@ -49,6 +53,8 @@ func (it *sliceIterator) Err() error {
 // SliceFiles are always directories, and can't be read from or closed.
 type SliceFile struct {
 	files []DirEntry
+	mode  os.FileMode
+	mtime time.Time
 }
 
 func NewMapDirectory(f map[string]Node) Directory {
@@ -64,7 +70,13 @@ func NewMapDirectory(f map[string]Node) Directory {
 }
 
 func NewSliceDirectory(files []DirEntry) Directory {
-	return &SliceFile{files}
+	return &SliceFile{files: files}
+}
+
+// NewSliceDirectoryWithMeta is like NewSliceDirectory but also records the
+// given mode and modification time.
+func NewSliceDirectoryWithMeta(files []DirEntry, mode os.FileMode, mtime time.Time) Directory {
+	return &SliceFile{files: files, mode: mode, mtime: mtime}
 }
 
 func (f *SliceFile) Entries() DirIterator {
@@ -79,6 +91,17 @@ func (f *SliceFile) Length() int {
 	return len(f.files)
 }
 
+func (f *SliceFile) Mode() os.FileMode {
+	if f.mode != 0 {
+		return f.mode | os.ModeDir
+	}
+	return 0
+}
+
+func (f *SliceFile) ModTime() time.Time {
+	return f.mtime
+}
+
 func (f *SliceFile) Size() (int64, error) {
 	var size int64
 
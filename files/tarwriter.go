@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"os"
 	"path"
 	"strings"
 	"time"
@@ -26,7 +27,7 @@ func NewTarWriter(w io.Writer) (*TarWriter, error) {
 }
 
 func (w *TarWriter) writeDir(f Directory, fpath string) error {
-	if err := writeDirHeader(w.TarW, fpath); err != nil {
+	if err := writeDirHeader(w.TarW, fpath, f.Mode(), f.ModTime()); err != nil {
 		return err
 	}
 
@@ -45,7 +46,7 @@ func (w *TarWriter) writeFile(f File, fpath string) error {
 		return err
 	}
 
-	if err := writeFileHeader(w.TarW, fpath, uint64(size)); err != nil {
+	if err := writeFileHeader(w.TarW, fpath, uint64(size), f.Mode(), f.ModTime()); err != nil {
 		return err
 	}
 
@@ -89,7 +90,7 @@ func (w *TarWriter) WriteFile(nd Node, fpath string) error {
 
 	switch nd := nd.(type) {
 	case *Symlink:
-		return writeSymlinkHeader(w.TarW, nd.Target, fpath)
+		return writeSymlinkHeader(w.TarW, nd.Target, fpath, nd.ModTime())
 	case File:
 		return w.writeFile(nd, fpath)
 	case Directory:
@@ -104,32 +105,48 @@ func (w *TarWriter) Close() error {
 	return w.TarW.Close()
 }
 
-func writeDirHeader(w *tar.Writer, fpath string) error {
+func writeDirHeader(w *tar.Writer, fpath string, mode os.FileMode, mtime time.Time) error {
 	return w.WriteHeader(&tar.Header{
 		Name:     fpath,
 		Typeflag: tar.TypeDir,
-		Mode:     0o777,
-		ModTime:  time.Now().Truncate(time.Second),
-		// TODO: set mode, dates, etc. when added to unixFS
+		Mode:     tarMode(mode, 0o777),
+		ModTime:  tarModTime(mtime),
 	})
 }
 
-func writeFileHeader(w *tar.Writer, fpath string, size uint64) error {
+func writeFileHeader(w *tar.Writer, fpath string, size uint64, mode os.FileMode, mtime time.Time) error {
 	return w.WriteHeader(&tar.Header{
 		Name:     fpath,
 		Size:     int64(size),
 		Typeflag: tar.TypeReg,
-		Mode:     0o644,
-		ModTime:  time.Now().Truncate(time.Second),
-		// TODO: set mode, dates, etc. when added to unixFS
+		Mode:     tarMode(mode, 0o644),
+		ModTime:  tarModTime(mtime),
 	})
 }
 
-func writeSymlinkHeader(w *tar.Writer, target, fpath string) error {
+func writeSymlinkHeader(w *tar.Writer, target, fpath string, mtime time.Time) error {
 	return w.WriteHeader(&tar.Header{
 		Name:     fpath,
 		Linkname: target,
 		Mode:     0o777,
+		ModTime:  tarModTime(mtime),
 		Typeflag: tar.TypeSymlink,
 	})
 }
+
+// tarMode returns the POSIX permissions of mode, or defaultPerms when the
+// mode is unknown.
+func tarMode(mode os.FileMode, defaultPerms int64) int64 {
+	if perms := ModePermsToUnixPerms(mode); perms != 0 {
+		return int64(perms)
+	}
+	return defaultPerms
+}
+
+// tarModTime returns mtime, or the current time when mtime is unknown.
+func tarModTime(mtime time.Time) time.Time {
+	if mtime.IsZero() {
+		return time.Now().Truncate(time.Second)
+	}
+	return mtime
+}
@@ -2,8 +2,10 @@ package files
 
 import (
 	"archive/tar"
+	"bytes"
 	"errors"
 	"io"
+	"os"
 	"testing"
 	"time"
 )
@@ -85,6 +87,56 @@ func TestTarWriter(t *testing.T) {
 	}
 }
 
+func TestTarWriterWithMeta(t *testing.T) {
+	dirMtime := time.Unix(1638111600, 0)
+	fileMtime := time.Unix(1638025200, 0)
+	tf := NewSliceDirectoryWithMeta([]DirEntry{
+		FileEntry("file.txt", NewBytesFileWithMeta([]byte(text), 0o600|os.ModeSetgid, fileMtime)),
+		FileEntry("default.txt", NewBytesFile([]byte(text))),
+	}, 0o750, dirMtime)
+
+	var buf bytes.Buffer
+	tw, err := NewTarWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.WriteFile(tf, "root"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	checkHeader := func(cur *tar.Header, name string, mode int64, mtime time.Time) {
+		if cur.Name != name {
+			t.Errorf("got wrong name: %s != %s", cur.Name, name)
+		}
+		if cur.Mode != mode {
+			t.Errorf("got wrong mode for %s: %o != %o", name, cur.Mode, mode)
+		}
+		if !mtime.IsZero() && !cur.ModTime.Equal(mtime) {
+			t.Errorf("got wrong modification time for %s: %s != %s", name, cur.ModTime, mtime)
+		}
+	}
+
+	tr := tar.NewReader(&buf)
+	for _, exp := range []struct {
+		name  string
+		mode  int64
+		mtime time.Time
+	}{
+		{"root", 0o750, dirMtime},
+		{"root/file.txt", 0o2600, fileMtime},
+		{"root/default.txt", 0o644, time.Time{}},
+	} {
+		cur, err := tr.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkHeader(cur, exp.name, exp.mode, exp.mtime)
+	}
+}
+
 func TestTarWriterRelativePathInsideRoot(t *testing.T) {
 	tf := NewMapDirectory(map[string]Node{
 		"file.txt": NewBytesFile([]byte(text)),
@@ -1,5 +1,7 @@
 package files
 
+import "os"
+
 // ToFile is an alias for n.(File). If the file isn't a regular file, nil value
 // will be returned
 func ToFile(n Node) File {
@@ -23,3 +25,36 @@ func FileFromEntry(e DirEntry) File {
 func DirFromEntry(e DirEntry) Directory {
 	return ToDir(e.Node())
 }
+
+// ModePermsToUnixPerms converts the permission bits of an os.FileMode,
+// including the setuid, setgid and sticky bits, to the POSIX (lower 12 bits)
+// representation used by UnixFS and tar.
+func ModePermsToUnixPerms(fileMode os.FileMode) uint32 {
+	perms := uint32(fileMode.Perm())
+	if fileMode&os.ModeSetuid != 0 {
+		perms |= 0o4000
+	}
+	if fileMode&os.ModeSetgid != 0 {
+		perms |= 0o2000
+	}
+	if fileMode&os.ModeSticky != 0 {
+		perms |= 0o1000
+	}
+	return perms
+}
+
+// UnixPermsToModePerms converts the POSIX permission bits (lower 12 bits) to
+// the equivalent os.FileMode permission bits.
+func UnixPermsToModePerms(unixPerms uint32) os.FileMode {
+	fileMode := os.FileMode(unixPerms & 0o777)
+	if unixPerms&0o4000 != 0 {
+		fileMode |= os.ModeSetuid
+	}
+	if unixPerms&0o2000 != 0 {
+		fileMode |= os.ModeSetgid
+	}
+	if unixPerms&0o1000 != 0 {
+		fileMode |= os.ModeSticky
+	}
+	return fileMode
+}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"time"
 )
 
 // WebFile is an implementation of File which reads it
@@ -16,6 +17,7 @@ type WebFile struct {
 	body          io.ReadCloser
 	url           *url.URL
 	contentLength int64
+	mtime         time.Time
 }
 
 // NewWebFile creates a WebFile with the given URL, which
@@ -38,6 +40,9 @@ func (wf *WebFile) start() error {
 		}
 		wf.body = resp.Body
 		wf.contentLength = resp.ContentLength
+		if lm := resp.Header.Get("Last-Modified"); lm != "" {
+			wf.mtime, _ = http.ParseTime(lm)
+		}
 	}
 	return nil
 }
@@ -77,6 +82,17 @@ func (wf *WebFile) Size() (int64, error) {
 	return wf.contentLength, nil
 }
 
+func (wf *WebFile) Mode() os.FileMode {
+	return 0
+}
+
+// ModTime returns the Last-Modified time reported by the server, if any. The
+// GET request must have been performed (e.g. by calling Read or Size) for it
+// to be known.
+func (wf *WebFile) ModTime() time.Time {
+	return wf.mtime
+}
+
 func (wf *WebFile) AbsPath() string {
 	return wf.url.String()
 }
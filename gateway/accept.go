@@ -0,0 +1,194 @@
+package gateway
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// errNotAcceptable is returned by [customResponseFormat] when none of the
+// media ranges in the Accept header can be satisfied by the gateway.
+var errNotAcceptable = fmt.Errorf("no acceptable response format, supported formats are: %s", strings.Join(supportedResponseFormats, ", "))
+
+// supportedResponseFormats are the explicit response formats that can be
+// negotiated with the Accept header. The implicit (deserialized) response is
+// negotiated with any other media range.
+var supportedResponseFormats = []string{
+	rawResponseFormat,
+	carResponseFormat,
+	tarResponseFormat,
+	dagJsonResponseFormat,
+	dagCborResponseFormat,
+	jsonResponseFormat,
+	cborResponseFormat,
+	ipnsRecordResponseFormat,
+}
+
+// acceptRange is a single media range from an Accept header.
+type acceptRange struct {
+	mediaType string
+	params    map[string]string
+	q         float64
+}
+
+// parseAccept parses all the Accept header values of a request, in order, as
+// defined in [RFC 9110, Section 12.5.1]. The "q" parameter is removed from the
+// media range parameters.
+//
+// Media ranges that cannot be parsed, or whose weight is invalid, are skipped
+// like clients and proxies may send them. An error is only returned for the
+// media ranges of IPFS and IPLD types (application/vnd.ipfs.* and
+// application/vnd.ipld.*), which must not fall back to another response.
+//
+// [RFC 9110, Section 12.5.1]: https://httpwg.org/specs/rfc9110.html#field.accept
+func parseAccept(r *http.Request) ([]acceptRange, error) {
+	var ranges []acceptRange
+	for _, header := range r.Header.Values("Accept") {
+		for _, value := range strings.Split(header, ",") {
+			value = strings.TrimSpace(value)
+			if value == "" {
+				continue
+			}
+
+			mediaType, params, err := mime.ParseMediaType(value)
+			if err != nil {
+				if mediaType == "" {
+					mediaType, _, _ = strings.Cut(value, ";")
+					mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+				}
+				if isIPFSMediaType(mediaType) {
+					return nil, fmt.Errorf("invalid media range %q: %w", value, err)
+				}
+				continue
+			}
+
+			q := 1.0
+			if qStr, ok := params["q"]; ok {
+				q, err = strconv.ParseFloat(qStr, 64)
+				if err != nil || q < 0 || q > 1 {
+					if isIPFSMediaType(mediaType) {
+						return nil, fmt.Errorf("invalid weight %q for media range %q", qStr, mediaType)
+					}
+					continue
+				}
+				delete(params, "q")
+			}
+
+			ranges = append(ranges, acceptRange{mediaType: mediaType, params: params, q: q})
+		}
+	}
+	return ranges, nil
+}
+
+// isIPFSMediaType returns true if the media type is an IPFS or IPLD one.
+func isIPFSMediaType(mediaType string) bool {
+	return strings.HasPrefix(mediaType, "application/vnd.ipld.") ||
+		strings.HasPrefix(mediaType, "application/vnd.ipfs.")
+}
+
+// isExplicitResponseFormat returns true if the media type is one of the
+// explicit response formats, or belongs to their families (e.g. an unknown
+// application/vnd.ipld.* type), which are never satisfied by the implicit
+// response.
+func isExplicitResponseFormat(mediaType string) bool {
+	return isIPFSMediaType(mediaType) ||
+		mediaType == tarResponseFormat ||
+		mediaType == jsonResponseFormat ||
+		mediaType == cborResponseFormat
+}
+
+// supportedFormatParams returns true if the parameters of a media range for
+// the given (supported) response format can be honoured.
+func supportedFormatParams(mediaType string, params map[string]string) bool {
+	if mediaType != carResponseFormat {
+		return true
+	}
+
+	switch params["version"] {
+	case "", "1":
+	default:
+		return false
+	}
+	switch DagOrder(params["order"]) {
+	case DagOrderUnspecified, DagOrderUnknown, DagOrderDFS:
+	default:
+		return false
+	}
+	_, err := NewDuplicateBlocksPolicy(params["dups"])
+	return err == nil
+}
+
+// negotiateResponseFormat selects the response format for the given Accept
+// media ranges following [RFC 9110, Section 12.5.1]:
+//
+//   - Explicit response formats (see [supportedResponseFormats]) are only
+//     selected when named explicitly, with their parameters supported (e.g.
+//     CAR version, order and dups).
+//   - Any other media range, including wildcards, selects the implicit
+//     response, represented by an empty media type.
+//   - The candidate with the highest weight wins. Explicit formats win ties
+//     over the implicit response and, between them, the first one listed wins.
+//   - Media ranges with a weight of 0 are never selected.
+//
+// If no candidate is acceptable, but a supported format was requested with
+// unsupported parameters, that format is returned so that the caller can report
+// a precise error. Otherwise, errNotAcceptable is returned.
+func negotiateResponseFormat(ranges []acceptRange) (mediaType string, params map[string]string, err error) {
+	type candidate struct {
+		acceptRange
+		explicit bool
+		index    int
+	}
+
+	var (
+		candidates  []candidate
+		unsupported *acceptRange
+	)
+	for i, ar := range ranges {
+		if !isExplicitResponseFormat(ar.mediaType) {
+			candidates = append(candidates, candidate{acceptRange{mediaType: "", q: ar.q}, false, i})
+			continue
+		}
+		if !isSupportedResponseFormat(ar.mediaType) {
+			continue
+		}
+		if !supportedFormatParams(ar.mediaType, ar.params) {
+			if unsupported == nil || ar.q > unsupported.q {
+				unsupported = &ranges[i]
+			}
+			continue
+		}
+		candidates = append(candidates, candidate{ar, true, i})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.q != b.q {
+			return a.q > b.q
+		}
+		if a.explicit != b.explicit {
+			return a.explicit
+		}
+		return a.index < b.index
+	})
+
+	if len(candidates) > 0 && candidates[0].q > 0 {
+		return candidates[0].mediaType, candidates[0].params, nil
+	}
+	if unsupported != nil && unsupported.q > 0 {
+		return unsupported.mediaType, unsupported.params, nil
+	}
+	return "", nil, errNotAcceptable
+}
+
+func isSupportedResponseFormat(mediaType string) bool {
+	for _, f := range supportedResponseFormats {
+		if f == mediaType {
+			return true
+		}
+	}
+	return false
+}
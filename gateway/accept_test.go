@@ -0,0 +1,106 @@
+package gateway
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCustomResponseFormat(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name           string
+		accept         []string
+		query          string
+		expectedFormat string
+		expectedParams map[string]string
+		expectedErr    error
+	}{
+		{"no accept header", nil, "", "", nil, nil},
+		{"browser", []string{"text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,*/*;q=0.8"}, "", "", nil, nil},
+		{"single explicit format", []string{"application/vnd.ipld.raw"}, "", rawResponseFormat, map[string]string{}, nil},
+		{"explicit format wins over wildcard", []string{"*/*, application/vnd.ipld.raw"}, "", rawResponseFormat, map[string]string{}, nil},
+		{"weights are respected", []string{"application/vnd.ipld.dag-json;q=0.1,application/vnd.ipld.car;q=0.2"}, "", carResponseFormat, map[string]string{}, nil},
+		{"first listed wins ties", []string{"application/vnd.ipld.dag-cbor, application/vnd.ipld.dag-json"}, "", dagCborResponseFormat, map[string]string{}, nil},
+		{"multiple header values", []string{"application/vnd.ipld.raw;q=0.5", "application/x-tar"}, "", tarResponseFormat, map[string]string{}, nil},
+		{"implicit response with higher weight", []string{"application/vnd.ipld.raw;q=0.5, text/html"}, "", "", nil, nil},
+		{"zero weight is never selected", []string{"application/vnd.ipld.raw;q=0, */*;q=0.1"}, "", "", nil, nil},
+		{"car parameters", []string{"application/vnd.ipld.car;version=1;order=dfs;dups=y"}, "", carResponseFormat, map[string]string{"version": "1", "order": "dfs", "dups": "y"}, nil},
+		{"unsupported car version is skipped", []string{"application/vnd.ipld.car;version=2, application/vnd.ipld.car;version=1;q=0.5"}, "", carResponseFormat, map[string]string{"version": "1"}, nil},
+		{"unsupported car version falls back to raw", []string{"application/vnd.ipld.car;version=2, application/vnd.ipld.raw;q=0.1"}, "", rawResponseFormat, map[string]string{}, nil},
+		{"only unsupported car parameters", []string{"application/vnd.ipld.car;order=invalid"}, "", carResponseFormat, map[string]string{"order": "invalid"}, nil},
+		{"ipns record", []string{"application/vnd.ipfs.ipns-record"}, "", ipnsRecordResponseFormat, map[string]string{}, nil},
+		{"format query parameter", nil, "format=car", carResponseFormat, nil, nil},
+		{"format query parameter with browser accept", []string{"text/html,*/*;q=0.8"}, "format=raw", rawResponseFormat, nil, nil},
+		{"accept header wins over query parameter", []string{"application/vnd.ipld.raw"}, "format=car", rawResponseFormat, map[string]string{}, nil},
+		{"unknown vendor type", []string{"application/vnd.ipld.unknown"}, "", "", nil, errNotAcceptable},
+		{"everything excluded", []string{"application/vnd.ipld.raw;q=0, text/html;q=0"}, "", "", nil, errNotAcceptable},
+		{"query parameter satisfies unacceptable header", []string{"application/vnd.ipld.unknown"}, "format=raw", rawResponseFormat, nil, nil},
+		{"unparsable media ranges are skipped", []string{"text/html;;, bogus, application/vnd.ipld.raw"}, "", rawResponseFormat, map[string]string{}, nil},
+		{"invalid weights are skipped", []string{"text/html;q=2, */*;q=abc, application/vnd.ipld.car;q=0.5"}, "", carResponseFormat, map[string]string{}, nil},
+		{"only unparsable media ranges", []string{"text/html;;, image/*;q=-1"}, "", "", nil, nil},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := mustNewRequest(t, http.MethodGet, "http://example.com/?"+test.query, nil)
+			for _, v := range test.accept {
+				r.Header.Add("Accept", v)
+			}
+
+			mediaType, params, err := customResponseFormat(r)
+			if test.expectedErr != nil {
+				require.ErrorIs(t, err, test.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedFormat, mediaType)
+			assert.Equal(t, test.expectedParams, params)
+		})
+	}
+
+	t.Run("invalid weight", func(t *testing.T) {
+		t.Parallel()
+
+		r := mustNewRequest(t, http.MethodGet, "http://example.com/", nil)
+		r.Header.Set("Accept", "application/vnd.ipld.raw;q=2")
+		_, _, err := customResponseFormat(r)
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, errNotAcceptable)
+	})
+
+	t.Run("malformed IPFS type", func(t *testing.T) {
+		t.Parallel()
+
+		r := mustNewRequest(t, http.MethodGet, "http://example.com/", nil)
+		r.Header.Set("Accept", "text/html, application/vnd.ipfs.ipns-record;;")
+		_, _, err := customResponseFormat(r)
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, errNotAcceptable)
+	})
+}
+
+func TestNotAcceptable(t *testing.T) {
+	t.Parallel()
+
+	ts, _, root := newTestServerAndNode(t, nil, "fixtures.car")
+
+	req := mustNewRequest(t, http.MethodGet, ts.URL+"/ipfs/"+root.String(), nil)
+	req.Header.Set("Accept", "application/vnd.ipld.unknown, application/vnd.ipld.raw;q=0")
+	res := mustDoWithoutRedirect(t, req)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusNotAcceptable, res.StatusCode)
+
+	req = mustNewRequest(t, http.MethodGet, ts.URL+"/ipfs/"+root.String(), nil)
+	req.Header.Set("Accept", "application/vnd.ipld.dag-json;q=0.1, application/vnd.ipld.raw;q=0.9")
+	res = mustDoWithoutRedirect(t, req)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, rawResponseFormat, res.Header.Get("Content-Type"))
+}
@@ -61,7 +61,7 @@ type BlocksBackend struct {
 	routing routing.ValueStore
 }
 
-var _ IPFSBackend = (*BlocksBackend)(nil)
+var _ WritableBackend = (*BlocksBackend)(nil)
 
 type blocksBackendOptions struct {
 	ns namesys.NameSystem
@@ -152,6 +152,11 @@ func NewBlocksBackend(blockService blockservice.BlockService, opts ...BlocksBack
 	}, nil
 }
 
+// BlockService returns the block service of the backend.
+func (bb *BlocksBackend) BlockService() blockservice.BlockService {
+	return bb.blockService
+}
+
 func (bb *BlocksBackend) Get(ctx context.Context, path path.ImmutablePath, ranges ...ByteRange) (ContentPathMetadata, *GetResponse, error) {
 	md, nd, err := bb.getNode(ctx, path)
 	if err != nil {
@@ -195,6 +200,9 @@ func (bb *BlocksBackend) Get(ctx context.Context, path path.ImmutablePath, range
 		return md, nil, err
 	}
 
+	// Set modification time in ContentPathMetadata if found in dag-pb's optional mtime field (UnixFS 1.5)
+	md.ModTime = f.ModTime()
+
 	if d, ok := f.(files.Directory); ok {
 		dir, err := uio.NewDirectoryFromNode(bb.dagService, nd)
 		if err != nil {
@@ -270,6 +278,9 @@ func (bb *BlocksBackend) Head(ctx context.Context, path path.ImmutablePath) (Con
 		return ContentPathMetadata{}, nil, err
 	}
 
+	// Set modification time in ContentPathMetadata if found in dag-pb's optional mtime field (UnixFS 1.5)
+	md.ModTime = fileNode.ModTime()
+
 	sz, err := fileNode.Size()
 	if err != nil {
 		return ContentPathMetadata{}, nil, err
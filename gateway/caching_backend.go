@@ -0,0 +1,502 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/golang-lru/v2/simplelru"
+	"github.com/ipfs/boxo/files"
+	"github.com/ipfs/boxo/path"
+	"github.com/ipfs/go-cid"
+	prometheus "github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// DefaultResponseCacheSize is the default maximum size, in bytes, of the
+	// responses cached by [NewCachingBackend].
+	DefaultResponseCacheSize = 64 << 20
+
+	// DefaultMaxCachedResponseSize is the default maximum size, in bytes, of a
+	// single response cached by [NewCachingBackend].
+	DefaultMaxCachedResponseSize = 1 << 20
+)
+
+type cachingBackendOptions struct {
+	cacheSize       int64
+	maxResponseSize int64
+}
+
+// CachingBackendOption configures [NewCachingBackend].
+type CachingBackendOption func(options *cachingBackendOptions) error
+
+// WithResponseCacheSize sets the maximum size, in bytes, of the cached
+// responses. Zero disables the cache, but identical requests are still
+// coalesced. Default is [DefaultResponseCacheSize].
+func WithResponseCacheSize(size int64) CachingBackendOption {
+	return func(opts *cachingBackendOptions) error {
+		if size < 0 {
+			return fmt.Errorf("invalid response cache size: %d", size)
+		}
+		opts.cacheSize = size
+		return nil
+	}
+}
+
+// WithMaxCachedResponseSize sets the maximum size, in bytes, of the blocks,
+// files and CARs that are cached and shared between coalesced requests.
+// Larger responses are streamed to each request separately. Default is
+// [DefaultMaxCachedResponseSize].
+func WithMaxCachedResponseSize(size int64) CachingBackendOption {
+	return func(opts *cachingBackendOptions) error {
+		if size <= 0 {
+			return fmt.Errorf("invalid maximum cached response size: %d", size)
+		}
+		opts.maxResponseSize = size
+		return nil
+	}
+}
+
+// NewCachingBackend wraps an [IPFSBackend] so that identical requests which
+// are in flight at the same time are only made once to the backend, and
+// their responses shared.
+//
+// Path resolutions, as well as the blocks, files and CARs that are not larger
+// than [WithMaxCachedResponseSize], are also kept in a size-bounded LRU cache.
+// Responses for immutable paths are cached until they are evicted, and
+// resolutions of mutable paths until their TTL expires, such as the TTL of
+// the IPNS record. Range requests, [IPFSBackend.GetAll], [IPFSBackend.Head]
+// and [IPFSBackend.IsCached] are passed through. Errors are never cached.
+//
+// Cache hits, misses and coalesced requests are counted by the
+// ipfs_gw_backend_cache_requests_total metric.
+func NewCachingBackend(backend IPFSBackend, opts ...CachingBackendOption) (IPFSBackend, error) {
+	compiledOptions := cachingBackendOptions{
+		cacheSize:       DefaultResponseCacheSize,
+		maxResponseSize: DefaultMaxCachedResponseSize,
+	}
+	for _, o := range opts {
+		if err := o(&compiledOptions); err != nil {
+			return nil, err
+		}
+	}
+
+	cache, err := newResponseCache(compiledOptions.cacheSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return &cachingBackend{
+		backend:         backend,
+		cache:           cache,
+		maxResponseSize: compiledOptions.maxResponseSize,
+		requestsMetric:  newCacheRequestsMetric(),
+	}, nil
+}
+
+type cachingBackend struct {
+	backend         IPFSBackend
+	cache           *responseCache
+	flights         singleflight.Group
+	maxResponseSize int64
+
+	requestsMetric *prometheus.CounterVec
+}
+
+var _ IPFSBackend = (*cachingBackend)(nil)
+
+func newCacheRequestsMetric() *prometheus.CounterVec {
+	metric := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "ipfs",
+			Subsystem: "gw_backend",
+			Name:      "cache_requests_total",
+			Help:      "The number of IPFSBackend API calls answered from the response cache (hit), by the backend (miss), or by sharing the response of an identical call in flight (coalesced).",
+		},
+		[]string{"name", "result"},
+	)
+	if err := prometheus.Register(metric); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			metric = are.ExistingCollector.(*prometheus.CounterVec)
+		} else {
+			log.Errorf("failed to register ipfs_gw_backend_cache_requests_total: %v", err)
+		}
+	}
+	return metric
+}
+
+// cachedData is a cached block, file or CAR.
+type cachedData struct {
+	md   ContentPathMetadata
+	data []byte
+}
+
+func (d *cachedData) size() int64 {
+	return metadataSize(d.md) + int64(len(d.data))
+}
+
+// cachedResolution is a cached result of ResolveMutable.
+type cachedResolution struct {
+	p       path.ImmutablePath
+	lastMod time.Time
+}
+
+// streamedResponse is a response that was too large to be shared, which is
+// only returned to the request that made the backend call.
+type streamedResponse struct {
+	md  ContentPathMetadata
+	res any
+}
+
+// metadataSize estimates the memory used by md.
+func metadataSize(md ContentPathMetadata) int64 {
+	size := 64 + len(md.ContentType)
+	if md.LastSegment.RootCid().Defined() {
+		size += len(md.LastSegment.String())
+	}
+	size += len(md.PathSegmentRoots) * 64
+	for _, s := range md.LastSegmentRemainder {
+		size += len(s)
+	}
+	return int64(size)
+}
+
+// coalesce calls fn once for all the concurrent calls with the same key and
+// returns its result. leader is true for the call that ran fn.
+//
+// Calls that joined a flight whose context was canceled retry with their own
+// context, so that a canceled request does not fail the others.
+func (b *cachingBackend) coalesce(ctx context.Context, name, key string, fn func() (any, error)) (v any, leader bool, err error) {
+	v, err, _ = b.flights.Do(key, func() (any, error) {
+		leader = true
+		return fn()
+	})
+	if leader {
+		b.requestsMetric.WithLabelValues(name, "miss").Inc()
+		return v, true, err
+	}
+
+	if err != nil && ctx.Err() == nil && (errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)) {
+		return b.coalesce(ctx, name, key, fn)
+	}
+	b.requestsMetric.WithLabelValues(name, "coalesced").Inc()
+	return v, false, err
+}
+
+// cached returns the cached value of key, if any.
+func (b *cachingBackend) cached(name, key string) (any, time.Time, bool) {
+	v, eol, ok := b.cache.get(key)
+	if ok {
+		b.requestsMetric.WithLabelValues(name, "hit").Inc()
+	}
+	return v, eol, ok
+}
+
+// readSmall reads r if it is not larger than the maximum size of the cached
+// responses. Otherwise, or if reading fails, the returned reader yields the
+// full content of r, or the same error.
+func (b *cachingBackend) readSmall(r io.Reader) ([]byte, io.Reader) {
+	data, err := io.ReadAll(io.LimitReader(r, b.maxResponseSize+1))
+	if err != nil {
+		return nil, io.MultiReader(bytes.NewReader(data), &errReader{err})
+	}
+	if int64(len(data)) > b.maxResponseSize {
+		return nil, io.MultiReader(bytes.NewReader(data), r)
+	}
+	return data, nil
+}
+
+func (b *cachingBackend) Get(ctx context.Context, p path.ImmutablePath, ranges ...ByteRange) (ContentPathMetadata, *GetResponse, error) {
+	if len(ranges) > 0 {
+		return b.backend.Get(ctx, p, ranges...)
+	}
+
+	name, key := "IPFSBackend.Get", "Get:"+p.String()
+	if v, _, ok := b.cached(name, key); ok {
+		d := v.(*cachedData)
+		return d.md, NewGetResponseFromReader(files.NewBytesFile(d.data), int64(len(d.data))), nil
+	}
+
+	v, leader, err := b.coalesce(ctx, name, key, func() (any, error) {
+		md, res, err := b.backend.Get(ctx, p)
+		if err != nil {
+			return nil, err
+		}
+		// Only small files are shared: directories and symlinks are not.
+		if res.bytes == nil || res.bytesSize > b.maxResponseSize {
+			return &streamedResponse{md: md, res: res}, nil
+		}
+		defer res.Close()
+		data, err := io.ReadAll(io.LimitReader(res.bytes, b.maxResponseSize+1))
+		if err != nil {
+			return nil, err
+		}
+		d := &cachedData{md: md, data: data}
+		b.cache.add(key, d, d.size(), time.Time{})
+		return d, nil
+	})
+	if err != nil {
+		return ContentPathMetadata{}, nil, err
+	}
+
+	switch v := v.(type) {
+	case *cachedData:
+		return v.md, NewGetResponseFromReader(files.NewBytesFile(v.data), int64(len(v.data))), nil
+	case *streamedResponse:
+		if leader {
+			return v.md, v.res.(*GetResponse), nil
+		}
+	}
+	return b.backend.Get(ctx, p)
+}
+
+func (b *cachingBackend) GetAll(ctx context.Context, p path.ImmutablePath) (ContentPathMetadata, files.Node, error) {
+	return b.backend.GetAll(ctx, p)
+}
+
+func (b *cachingBackend) GetBlock(ctx context.Context, p path.ImmutablePath) (ContentPathMetadata, files.File, error) {
+	name, key := "IPFSBackend.GetBlock", "GetBlock:"+p.String()
+	if v, _, ok := b.cached(name, key); ok {
+		d := v.(*cachedData)
+		return d.md, files.NewBytesFile(d.data), nil
+	}
+
+	v, leader, err := b.coalesce(ctx, name, key, func() (any, error) {
+		md, f, err := b.backend.GetBlock(ctx, p)
+		if err != nil {
+			return nil, err
+		}
+		if size, err := f.Size(); err != nil || size > b.maxResponseSize {
+			return &streamedResponse{md: md, res: f}, nil
+		}
+		defer f.Close()
+		data, err := io.ReadAll(f)
+		if err != nil {
+			return nil, err
+		}
+		d := &cachedData{md: md, data: data}
+		b.cache.add(key, d, d.size(), time.Time{})
+		return d, nil
+	})
+	if err != nil {
+		return ContentPathMetadata{}, nil, err
+	}
+
+	switch v := v.(type) {
+	case *cachedData:
+		return v.md, files.NewBytesFile(v.data), nil
+	case *streamedResponse:
+		if leader {
+			return v.md, v.res.(files.File), nil
+		}
+	}
+	return b.backend.GetBlock(ctx, p)
+}
+
+func (b *cachingBackend) Head(ctx context.Context, p path.ImmutablePath) (ContentPathMetadata, *HeadResponse, error) {
+	return b.backend.Head(ctx, p)
+}
+
+func (b *cachingBackend) ResolvePath(ctx context.Context, p path.ImmutablePath) (ContentPathMetadata, error) {
+	name, key := "IPFSBackend.ResolvePath", "ResolvePath:"+p.String()
+	if v, _, ok := b.cached(name, key); ok {
+		return v.(ContentPathMetadata), nil
+	}
+
+	v, _, err := b.coalesce(ctx, name, key, func() (any, error) {
+		md, err := b.backend.ResolvePath(ctx, p)
+		if err != nil {
+			return nil, err
+		}
+		b.cache.add(key, md, metadataSize(md), time.Time{})
+		return md, nil
+	})
+	if err != nil {
+		return ContentPathMetadata{}, err
+	}
+	return v.(ContentPathMetadata), nil
+}
+
+// carParamsKey returns the part of the key of a GetCAR call given by params.
+func carParamsKey(params CarParams) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "scope=%s&order=%s&dups=%s", params.Scope, params.Order, params.Duplicates)
+	if params.Range != nil {
+		fmt.Fprintf(&sb, "&range=%d:", params.Range.From)
+		if params.Range.To != nil {
+			fmt.Fprintf(&sb, "%d", *params.Range.To)
+		}
+	}
+	return sb.String()
+}
+
+func (b *cachingBackend) GetCAR(ctx context.Context, p path.ImmutablePath, params CarParams) (ContentPathMetadata, io.ReadCloser, error) {
+	name, key := "IPFSBackend.GetCAR", "GetCAR:"+p.String()+"?"+carParamsKey(params)
+	if v, _, ok := b.cached(name, key); ok {
+		d := v.(*cachedData)
+		return d.md, io.NopCloser(bytes.NewReader(d.data)), nil
+	}
+
+	v, leader, err := b.coalesce(ctx, name, key, func() (any, error) {
+		md, rc, err := b.backend.GetCAR(ctx, p, params)
+		if err != nil {
+			return nil, err
+		}
+		// CARs are streamed, their size is only known once read.
+		data, rest := b.readSmall(rc)
+		if rest != nil {
+			return &streamedResponse{md: md, res: &carReadCloser{Reader: rest, Closer: rc}}, nil
+		}
+		rc.Close()
+		d := &cachedData{md: md, data: data}
+		b.cache.add(key, d, d.size(), time.Time{})
+		return d, nil
+	})
+	if err != nil {
+		return ContentPathMetadata{}, nil, err
+	}
+
+	switch v := v.(type) {
+	case *cachedData:
+		return v.md, io.NopCloser(bytes.NewReader(v.data)), nil
+	case *streamedResponse:
+		if leader {
+			return v.md, v.res.(io.ReadCloser), nil
+		}
+	}
+	return b.backend.GetCAR(ctx, p, params)
+}
+
+type carReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+type errReader struct {
+	err error
+}
+
+func (r *errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+func (b *cachingBackend) IsCached(ctx context.Context, p path.Path) bool {
+	return b.backend.IsCached(ctx, p)
+}
+
+func (b *cachingBackend) GetIPNSRecord(ctx context.Context, c cid.Cid) ([]byte, error) {
+	v, _, err := b.coalesce(ctx, "IPFSBackend.GetIPNSRecord", "GetIPNSRecord:"+c.String(), func() (any, error) {
+		return b.backend.GetIPNSRecord(ctx, c)
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Each caller gets its own copy of the record.
+	return bytes.Clone(v.([]byte)), nil
+}
+
+func (b *cachingBackend) ResolveMutable(ctx context.Context, p path.Path) (path.ImmutablePath, time.Duration, time.Time, error) {
+	name, key := "IPFSBackend.ResolveMutable", "ResolveMutable:"+p.String()
+	if v, eol, ok := b.cached(name, key); ok {
+		r := v.(*cachedResolution)
+		return r.p, time.Until(eol), r.lastMod, nil
+	}
+
+	type result struct {
+		cachedResolution
+		ttl time.Duration
+	}
+	v, _, err := b.coalesce(ctx, name, key, func() (any, error) {
+		resolved, ttl, lastMod, err := b.backend.ResolveMutable(ctx, p)
+		if err != nil {
+			return nil, err
+		}
+		r := &result{cachedResolution{p: resolved, lastMod: lastMod}, ttl}
+		// A TTL of zero means that it is unknown.
+		if ttl > 0 {
+			b.cache.add(key, &r.cachedResolution, int64(64+len(key)+len(resolved.String())), time.Now().Add(ttl))
+		}
+		return r, nil
+	})
+	if err != nil {
+		return path.ImmutablePath{}, 0, time.Time{}, err
+	}
+	r := v.(*result)
+	return r.p, r.ttl, r.lastMod, nil
+}
+
+func (b *cachingBackend) GetDNSLinkRecord(ctx context.Context, fqdn string) (path.Path, error) {
+	v, _, err := b.coalesce(ctx, "IPFSBackend.GetDNSLinkRecord", "GetDNSLinkRecord:"+fqdn, func() (any, error) {
+		return b.backend.GetDNSLinkRecord(ctx, fqdn)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(path.Path), nil
+}
+
+// responseCache is an LRU cache bounded by the total size of its entries.
+// Entries with a zero end of life never expire.
+type responseCache struct {
+	lk      sync.Mutex
+	lru     *simplelru.LRU[string, *responseCacheEntry]
+	size    int64
+	maxSize int64
+}
+
+type responseCacheEntry struct {
+	value any
+	size  int64
+	eol   time.Time
+}
+
+func newResponseCache(maxSize int64) (*responseCache, error) {
+	c := &responseCache{maxSize: maxSize}
+	// The number of entries is not bounded, only their size.
+	lru, err := simplelru.NewLRU[string, *responseCacheEntry](math.MaxInt, func(_ string, e *responseCacheEntry) {
+		c.size -= e.size
+	})
+	if err != nil {
+		return nil, err
+	}
+	c.lru = lru
+	return c, nil
+}
+
+func (c *responseCache) get(key string) (any, time.Time, bool) {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+
+	e, ok := c.lru.Get(key)
+	if !ok {
+		return nil, time.Time{}, false
+	}
+	if !e.eol.IsZero() && !time.Now().Before(e.eol) {
+		c.lru.Remove(key)
+		return nil, time.Time{}, false
+	}
+	return e.value, e.eol, true
+}
+
+func (c *responseCache) add(key string, value any, size int64, eol time.Time) {
+	if size > c.maxSize {
+		return
+	}
+
+	c.lk.Lock()
+	defer c.lk.Unlock()
+
+	c.lru.Remove(key)
+	c.lru.Add(key, &responseCacheEntry{value: value, size: size, eol: eol})
+	c.size += size
+	for c.size > c.maxSize {
+		c.lru.RemoveOldest()
+	}
+}
@@ -0,0 +1,268 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ipfs/boxo/blockservice"
+	"github.com/ipfs/boxo/blockstore"
+	offline "github.com/ipfs/boxo/exchange/offline"
+	"github.com/ipfs/boxo/files"
+	"github.com/ipfs/boxo/ipld/merkledag"
+	ft "github.com/ipfs/boxo/ipld/unixfs"
+	"github.com/ipfs/boxo/path"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	format "github.com/ipfs/go-ipld-format"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingBackend counts the calls made to an IPFSBackend.
+type countingBackend struct {
+	IPFSBackend
+
+	lk    sync.Mutex
+	calls map[string]int
+
+	// release, if set, blocks ResolvePath until it is closed.
+	release chan struct{}
+	// ttl and err are returned by ResolveMutable.
+	ttl time.Duration
+	err error
+}
+
+func (b *countingBackend) count(name string) {
+	b.lk.Lock()
+	defer b.lk.Unlock()
+	b.calls[name]++
+}
+
+func (b *countingBackend) callsOf(name string) int {
+	b.lk.Lock()
+	defer b.lk.Unlock()
+	return b.calls[name]
+}
+
+func (b *countingBackend) Get(ctx context.Context, p path.ImmutablePath, ranges ...ByteRange) (ContentPathMetadata, *GetResponse, error) {
+	b.count("Get")
+	return b.IPFSBackend.Get(ctx, p, ranges...)
+}
+
+func (b *countingBackend) GetBlock(ctx context.Context, p path.ImmutablePath) (ContentPathMetadata, files.File, error) {
+	b.count("GetBlock")
+	return b.IPFSBackend.GetBlock(ctx, p)
+}
+
+func (b *countingBackend) GetCAR(ctx context.Context, p path.ImmutablePath, params CarParams) (ContentPathMetadata, io.ReadCloser, error) {
+	b.count("GetCAR")
+	return b.IPFSBackend.GetCAR(ctx, p, params)
+}
+
+func (b *countingBackend) ResolvePath(ctx context.Context, p path.ImmutablePath) (ContentPathMetadata, error) {
+	b.count("ResolvePath")
+	if b.release != nil {
+		<-b.release
+	}
+	return b.IPFSBackend.ResolvePath(ctx, p)
+}
+
+func (b *countingBackend) ResolveMutable(ctx context.Context, p path.Path) (path.ImmutablePath, time.Duration, time.Time, error) {
+	b.count("ResolveMutable")
+	b.lk.Lock()
+	ttl, err := b.ttl, b.err
+	b.lk.Unlock()
+	if err != nil {
+		return path.ImmutablePath{}, 0, time.Time{}, err
+	}
+	imPath, err := path.NewImmutablePath(path.FromCid(ft.EmptyDirNode().Cid()))
+	return imPath, ttl, time.Time{}, err
+}
+
+func newCachingTestBackend(t *testing.T, opts ...CachingBackendOption) (IPFSBackend, *countingBackend, path.ImmutablePath) {
+	ctx := context.Background()
+	bs := blockservice.New(blockstore.NewBlockstore(dssync.MutexWrap(datastore.NewMapDatastore())), offline.Exchange(nil))
+	dagService := merkledag.NewDAGService(bs)
+
+	small := merkledag.NewRawNode([]byte("small file"))
+	large := merkledag.NewRawNode(bytes.Repeat([]byte("large file "), 100))
+	dir := ft.EmptyDirNode()
+	require.NoError(t, dir.AddNodeLink("small.txt", small))
+	require.NoError(t, dir.AddNodeLink("large.txt", large))
+	require.NoError(t, dagService.AddMany(ctx, []format.Node{small, large, dir}))
+
+	blocksBackend, err := NewBlocksBackend(bs)
+	require.NoError(t, err)
+	counting := &countingBackend{IPFSBackend: blocksBackend, calls: map[string]int{}}
+
+	backend, err := NewCachingBackend(counting, opts...)
+	require.NoError(t, err)
+
+	root, err := path.NewImmutablePath(path.FromCid(dir.Cid()))
+	require.NoError(t, err)
+	return backend, counting, root
+}
+
+func mustJoinImmutable(t *testing.T, p path.ImmutablePath, segments ...string) path.ImmutablePath {
+	joined, err := path.Join(p, segments...)
+	require.NoError(t, err)
+	imPath, err := path.NewImmutablePath(joined)
+	require.NoError(t, err)
+	return imPath
+}
+
+func TestCachingBackend(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	t.Run("Coalesces and caches ResolvePath", func(t *testing.T) {
+		t.Parallel()
+		backend, counting, root := newCachingTestBackend(t)
+		counting.release = make(chan struct{})
+		p := mustJoinImmutable(t, root, "small.txt")
+
+		var wg sync.WaitGroup
+		results := make([]ContentPathMetadata, 10)
+		for i := range results {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				md, err := backend.ResolvePath(ctx, p)
+				assert.NoError(t, err)
+				results[i] = md
+			}(i)
+		}
+		require.Eventually(t, func() bool { return counting.callsOf("ResolvePath") == 1 }, 5*time.Second, time.Millisecond)
+		close(counting.release)
+		wg.Wait()
+
+		// Calls that arrived after the first one finished were cache hits.
+		assert.Equal(t, 1, counting.callsOf("ResolvePath"))
+		for _, md := range results {
+			assert.Equal(t, results[0].LastSegment.RootCid(), md.LastSegment.RootCid())
+		}
+	})
+
+	t.Run("Caches small files, blocks and CARs", func(t *testing.T) {
+		t.Parallel()
+		backend, counting, root := newCachingTestBackend(t, WithMaxCachedResponseSize(512))
+		small := mustJoinImmutable(t, root, "small.txt")
+		large := mustJoinImmutable(t, root, "large.txt")
+
+		readGet := func(p path.ImmutablePath) string {
+			_, res, err := backend.Get(ctx, p)
+			require.NoError(t, err)
+			defer res.Close()
+			data, err := io.ReadAll(res.bytes)
+			require.NoError(t, err)
+			return string(data)
+		}
+		for i := 0; i < 3; i++ {
+			assert.Equal(t, "small file", readGet(small))
+			assert.Len(t, readGet(large), 1100)
+		}
+		// Only the large file, which is over the size limit, reached the
+		// backend every time.
+		assert.Equal(t, 4, counting.callsOf("Get"))
+
+		// Range requests are not cached.
+		_, res, err := backend.Get(ctx, small, ByteRange{From: 1})
+		require.NoError(t, err)
+		res.Close()
+		assert.Equal(t, 5, counting.callsOf("Get"))
+
+		for i := 0; i < 3; i++ {
+			_, f, err := backend.GetBlock(ctx, small)
+			require.NoError(t, err)
+			data, err := io.ReadAll(f)
+			require.NoError(t, err)
+			f.Close()
+			assert.Equal(t, "small file", string(data))
+		}
+		assert.Equal(t, 1, counting.callsOf("GetBlock"))
+
+		readCAR := func(p path.ImmutablePath, params CarParams) []byte {
+			_, rc, err := backend.GetCAR(ctx, p, params)
+			require.NoError(t, err)
+			defer rc.Close()
+			data, err := io.ReadAll(rc)
+			require.NoError(t, err)
+			return data
+		}
+		_, rc, err := counting.IPFSBackend.GetCAR(ctx, small, CarParams{Scope: DagScopeAll})
+		require.NoError(t, err)
+		expected, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		rc.Close()
+
+		assert.Equal(t, expected, readCAR(small, CarParams{Scope: DagScopeAll}))
+		assert.Equal(t, expected, readCAR(small, CarParams{Scope: DagScopeAll}))
+		assert.Equal(t, 1, counting.callsOf("GetCAR"))
+		// Different parameters are different requests.
+		readCAR(small, CarParams{Scope: DagScopeBlock})
+		assert.Equal(t, 2, counting.callsOf("GetCAR"))
+		// Large CARs are streamed.
+		assert.Equal(t, readCAR(root, CarParams{Scope: DagScopeAll}), readCAR(root, CarParams{Scope: DagScopeAll}))
+		assert.Equal(t, 4, counting.callsOf("GetCAR"))
+	})
+
+	t.Run("Caches ResolveMutable until the TTL expires", func(t *testing.T) {
+		t.Parallel()
+		backend, counting, _ := newCachingTestBackend(t)
+		p, err := path.NewPath("/ipns/example.net")
+		require.NoError(t, err)
+
+		counting.err = errors.New("resolution failed")
+		_, _, _, err = backend.ResolveMutable(ctx, p)
+		require.Error(t, err)
+		counting.err = nil
+
+		counting.ttl = 100 * time.Millisecond
+		_, ttl, _, err := backend.ResolveMutable(ctx, p)
+		require.NoError(t, err)
+		assert.Equal(t, 100*time.Millisecond, ttl)
+		_, ttl, _, err = backend.ResolveMutable(ctx, p)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, ttl, 100*time.Millisecond)
+		// Errors are not cached.
+		assert.Equal(t, 2, counting.callsOf("ResolveMutable"))
+
+		time.Sleep(150 * time.Millisecond)
+		counting.ttl = 0
+		_, _, _, err = backend.ResolveMutable(ctx, p)
+		require.NoError(t, err)
+		_, _, _, err = backend.ResolveMutable(ctx, p)
+		require.NoError(t, err)
+		// Resolutions with an unknown TTL are not cached.
+		assert.Equal(t, 4, counting.callsOf("ResolveMutable"))
+	})
+
+	t.Run("Bounds the cache size", func(t *testing.T) {
+		t.Parallel()
+		cache, err := newResponseCache(100)
+		require.NoError(t, err)
+
+		cache.add("a", "a", 40, time.Time{})
+		cache.add("b", "b", 40, time.Time{})
+		cache.add("too large", "", 101, time.Time{})
+		_, _, ok := cache.get("a")
+		require.True(t, ok)
+		cache.add("c", "c", 40, time.Time{})
+		// b was the least recently used entry.
+		_, _, ok = cache.get("b")
+		require.False(t, ok)
+		_, _, ok = cache.get("a")
+		require.True(t, ok)
+		cache.add("a", "a", 20, time.Time{})
+		assert.Equal(t, int64(60), cache.size)
+
+		cache.add("expired", "", 1, time.Now().Add(-time.Second))
+		_, _, ok = cache.get("expired")
+		require.False(t, ok)
+	})
+}
@@ -208,8 +208,24 @@ func isErrNotFound(err error) bool {
 	}
 }
 
+// BlockedContentError is implemented by the errors of content filtering
+// systems reporting blocked content, such as [denylist.ErrContentBlocked],
+// for which the gateway responds with 410 Gone.
+//
+// [denylist.ErrContentBlocked]: https://pkg.go.dev/github.com/ipfs/boxo/denylist#ErrContentBlocked
+type BlockedContentError interface {
+	error
+	// ContentBlocked is a marker method.
+	ContentBlocked()
+}
+
 // isErrContentBlocked returns true for content filtering system errors
 func isErrContentBlocked(err error) bool {
+	var blocked BlockedContentError
+	if errors.As(err, &blocked) {
+		return true
+	}
+
 	// TODO: we match error message to avoid pulling nopfs as a dependency
 	// Ref. https://github.com/ipfs-shipyard/nopfs/blob/cde3b5ba964c13e977f4a95f3bd8ca7d7710fbda/status.go#L87-L89
 	return strings.Contains(err.Error(), "blocked and cannot be provided")
@@ -39,12 +39,27 @@ func TestErrRetryAfterAs(t *testing.T) {
 	require.EqualValues(t, errRA.RetryAfter, 25*time.Second)
 }
 
+type testBlockedError struct{}
+
+func (testBlockedError) Error() string   { return "denied" }
+func (testBlockedError) ContentBlocked() {}
+
 func TestWebError(t *testing.T) {
 	t.Parallel()
 
 	// Create a handler to be able to test `webError`.
 	config := &Config{Headers: map[string][]string{}}
 
+	t.Run("410 Gone for blocked content", func(t *testing.T) {
+		t.Parallel()
+
+		err := fmt.Errorf("wrapped for testing: %w", testBlockedError{})
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/blah", nil)
+		webError(w, r, config, err, http.StatusInternalServerError)
+		require.Equal(t, http.StatusGone, w.Result().StatusCode)
+	})
+
 	t.Run("429 Too Many Requests", func(t *testing.T) {
 		t.Parallel()
 
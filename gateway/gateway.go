@@ -11,6 +11,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/ipfs/boxo/blockservice"
 	"github.com/ipfs/boxo/files"
 	"github.com/ipfs/boxo/gateway/assets"
 	"github.com/ipfs/boxo/ipld/unixfs"
@@ -55,6 +56,24 @@ type Config struct {
 	// directory listings, DAG previews and errors. These will be displayed to the
 	// right of "About IPFS" and "Install IPFS".
 	Menu []assets.MenuItem
+
+	// Writable enables the writable gateway, which accepts POST, PUT, DELETE
+	// and PATCH requests on /ipfs/ paths to create new content. Every write
+	// returns the CID of the new content in the IPFS-Hash header. The backend
+	// must implement [WritableBackend].
+	Writable bool
+
+	// AuthorizeWrite is called before every write request when Writable is
+	// set. Returning an error rejects the request with 403 Forbidden, or with
+	// the status code of an [ErrorStatusCode]. If nil, all write requests are
+	// rejected: a function always returning nil accepts them all, which
+	// should only be done on gateways that are not exposed publicly.
+	AuthorizeWrite func(*http.Request) error
+
+	// MaxWriteSize is the maximum size of the body of PUT requests and of
+	// the CARs of POST requests, above which they are rejected with 413
+	// Request Entity Too Large. Default is [DefaultMaxWriteSize].
+	MaxWriteSize int64
 }
 
 // PublicGateway is the specification of an IPFS Public Gateway.
@@ -216,7 +235,8 @@ type ContentPathMetadata struct {
 	PathSegmentRoots     []cid.Cid
 	LastSegment          path.ImmutablePath
 	LastSegmentRemainder []string
-	ContentType          string // Only used for UnixFS requests
+	ContentType          string    // Only used for UnixFS requests
+	ModTime              time.Time // Optional, non-zero values may be present in UnixFS 1.5 DAGs
 }
 
 // ByteRange describes a range request within a UnixFS file. "From" and "To" mostly
@@ -386,6 +406,16 @@ type IPFSBackend interface {
 	GetDNSLinkRecord(context.Context, string) (path.Path, error)
 }
 
+// WritableBackend is an [IPFSBackend] that can also store new content, which
+// is required by the writable gateway (see [Config.Writable]).
+type WritableBackend interface {
+	IPFSBackend
+
+	// BlockService returns the block service where the content created by
+	// write requests is read from and added to.
+	BlockService() blockservice.BlockService
+}
+
 // cleanHeaderSet is an helper function that cleans a set of headers by
 // (1) canonicalizing, (2) de-duplicating and (3) sorting.
 func cleanHeaderSet(headers []string) []string {
@@ -6,14 +6,24 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/ipfs/boxo/blockservice"
+	blockstore "github.com/ipfs/boxo/blockstore"
+	chunker "github.com/ipfs/boxo/chunker"
+	offline "github.com/ipfs/boxo/exchange/offline"
 	"github.com/ipfs/boxo/files"
+	"github.com/ipfs/boxo/ipld/merkledag"
+	"github.com/ipfs/boxo/ipld/unixfs/importer/balanced"
+	uih "github.com/ipfs/boxo/ipld/unixfs/importer/helpers"
 	"github.com/ipfs/boxo/namesys"
 	"github.com/ipfs/boxo/path"
 	"github.com/ipfs/boxo/path/resolver"
 	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
 	ipld "github.com/ipfs/go-ipld-format"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -420,6 +430,45 @@ func TestHeaders(t *testing.T) {
 	})
 }
 
+func TestLastModifiedUnixFS(t *testing.T) {
+	t.Parallel()
+
+	bs := blockstore.NewBlockstore(dssync.MutexWrap(datastore.NewMapDatastore()))
+	bsrv := blockservice.New(bs, offline.Exchange(bs))
+	dsrv := merkledag.NewDAGService(bsrv)
+
+	mtime := time.Date(2021, time.November, 28, 14, 0, 0, 0, time.UTC)
+	dbp := uih.DagBuilderParams{
+		Dagserv:     dsrv,
+		Maxlinks:    uih.DefaultLinksPerBlock,
+		RawLeaves:   true,
+		CidBuilder:  merkledag.V1CidPrefix(),
+		FileModTime: mtime,
+	}
+	db, err := dbp.New(chunker.DefaultSplitter(strings.NewReader("hello world")))
+	require.NoError(t, err)
+	nd, err := balanced.Layout(db)
+	require.NoError(t, err)
+
+	backend, err := NewBlocksBackend(bsrv)
+	require.NoError(t, err)
+	ts := newTestServer(t, backend)
+
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		req := mustNewRequest(t, method, ts.URL+"/ipfs/"+nd.Cid().String(), nil)
+		res := mustDoWithoutRedirect(t, req)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, mtime.Format(http.TimeFormat), res.Header.Get("Last-Modified"), method)
+		assert.Equal(t, immutableCacheControl, res.Header.Get("Cache-Control"), method)
+
+		// Conditional requests are answered from the modification time.
+		req = mustNewRequest(t, method, ts.URL+"/ipfs/"+nd.Cid().String(), nil)
+		req.Header.Set("If-Modified-Since", mtime.Format(http.TimeFormat))
+		res = mustDoWithoutRedirect(t, req)
+		assert.Equal(t, http.StatusNotModified, res.StatusCode, method)
+	}
+}
+
 func TestGoGetSupport(t *testing.T) {
 	ts, _, root := newTestServerAndNode(t, nil, "fixtures.car")
 
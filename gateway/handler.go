@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"html/template"
 	"io"
-	"mime"
 	"net/http"
 	"net/textproto"
 	"net/url"
@@ -69,6 +68,9 @@ type handler struct {
 	config  *Config
 	backend IPFSBackend
 
+	// writableBackend is the backend, if it supports write requests.
+	writableBackend WritableBackend
+
 	// response type metrics
 	requestTypeMetric            *prometheus.CounterVec
 	getMetric                    *prometheus.HistogramVec
@@ -161,16 +163,24 @@ func (i *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	case http.MethodOptions:
 		i.optionsHandler(w, r)
 		return
+	case http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodPatch:
+		if i.config.Writable {
+			i.writeHandler(w, r)
+			return
+		}
 	}
 
-	addAllowHeader(w)
+	addAllowHeader(w, i.config.Writable)
 
-	errmsg := "Method " + r.Method + " not allowed: read only access"
+	errmsg := "Method " + r.Method + " not allowed"
+	if !i.config.Writable {
+		errmsg += ": read only access"
+	}
 	http.Error(w, errmsg, http.StatusMethodNotAllowed)
 }
 
 func (i *handler) optionsHandler(w http.ResponseWriter, r *http.Request) {
-	addAllowHeader(w)
+	addAllowHeader(w, i.config.Writable)
 	// OPTIONS is a noop request that is used by the browsers to check if server accepts
 	// cross-site XMLHttpRequest, which is indicated by the presence of CORS headers:
 	// https://developer.mozilla.org/en-US/docs/Web/HTTP/Access_control_CORS#Preflighted_requests
@@ -178,10 +188,16 @@ func (i *handler) optionsHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // addAllowHeader sets Allow header with supported HTTP methods
-func addAllowHeader(w http.ResponseWriter) {
+func addAllowHeader(w http.ResponseWriter, writable bool) {
 	w.Header().Add("Allow", http.MethodGet)
 	w.Header().Add("Allow", http.MethodHead)
 	w.Header().Add("Allow", http.MethodOptions)
+	if writable {
+		w.Header().Add("Allow", http.MethodPost)
+		w.Header().Add("Allow", http.MethodPut)
+		w.Header().Add("Allow", http.MethodDelete)
+		w.Header().Add("Allow", http.MethodPatch)
+	}
 }
 
 type requestData struct {
@@ -253,7 +269,11 @@ func (i *handler) getOrHeadHandler(w http.ResponseWriter, r *http.Request) {
 	// Detect when explicit Accept header or ?format parameter are present
 	responseFormat, formatParams, err := customResponseFormat(r)
 	if err != nil {
-		i.webError(w, r, fmt.Errorf("error while processing the Accept header: %w", err), http.StatusBadRequest)
+		if errors.Is(err, errNotAcceptable) {
+			i.webError(w, r, err, http.StatusNotAcceptable)
+		} else {
+			i.webError(w, r, fmt.Errorf("error while processing the Accept header: %w", err), http.StatusBadRequest)
+		}
 		return
 	}
 	trace.SpanFromContext(r.Context()).SetAttributes(attribute.String("ResponseFormat", responseFormat))
@@ -440,10 +460,13 @@ func addCacheControlHeaders(w http.ResponseWriter, r *http.Request, contentPath
 		}
 	} else {
 		w.Header().Set("Cache-Control", immutableCacheControl)
-		modtime = noModtime // disable Last-Modified
 
-		// TODO: consider setting Last-Modified if UnixFS V1.5 ever gets released
-		// with metadata: https://github.com/ipfs/kubo/issues/6920
+		if lastMod.IsZero() {
+			modtime = noModtime // disable Last-Modified
+		} else {
+			// Set Last-Modified from the optional mtime of UnixFS 1.5 nodes.
+			modtime = lastMod
+		}
 	}
 
 	return modtime
@@ -617,27 +640,23 @@ func customResponseFormat(r *http.Request) (mediaType string, params map[string]
 	//
 	// Browsers and other user agents will send Accept header with generic types like:
 	// Accept:text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,*/*;q=0.8
-	// We only care about explicit, vendor-specific content-types and respond to the first match (in order).
-	// TODO: make this RFC compliant and respect weights (eg. return CAR for Accept:application/vnd.ipld.dag-json;q=0.1,application/vnd.ipld.car;q=0.2)
-	for _, header := range r.Header.Values("Accept") {
-		for _, value := range strings.Split(header, ",") {
-			accept := strings.TrimSpace(value)
-			// respond to the very first matching content type
-			if strings.HasPrefix(accept, "application/vnd.ipld") ||
-				strings.HasPrefix(accept, "application/vnd.ipfs") ||
-				strings.HasPrefix(accept, tarResponseFormat) ||
-				strings.HasPrefix(accept, jsonResponseFormat) ||
-				strings.HasPrefix(accept, cborResponseFormat) {
-				mediatype, params, err := mime.ParseMediaType(accept)
-				if err != nil {
-					return "", nil, err
-				}
-				return mediatype, params, nil
+	// Such media ranges select the implicit response, while explicit, vendor-specific content-types
+	// are negotiated respecting their weights (see negotiateResponseFormat).
+	var negotiationErr error
+	if len(r.Header.Values("Accept")) != 0 {
+		ranges, err := parseAccept(r)
+		if err != nil {
+			return "", nil, err
+		}
+		if len(ranges) != 0 {
+			mediaType, params, negotiationErr = negotiateResponseFormat(ranges)
+			if mediaType != "" {
+				return mediaType, params, nil
 			}
 		}
 	}
 
-	// If no Accept header, translate query param to a content type, if present.
+	// If no explicit format was negotiated, translate query param to a content type, if present.
 	if formatParam := r.URL.Query().Get("format"); formatParam != "" {
 		switch formatParam {
 		case "raw":
@@ -660,8 +679,9 @@ func customResponseFormat(r *http.Request) (mediaType string, params map[string]
 	}
 
 	// If none of special-cased content types is found, return empty string
-	// to indicate default, implicit UnixFS response should be prepared
-	return "", nil, nil
+	// to indicate default, implicit UnixFS response should be prepared, unless
+	// the Accept header does not allow it.
+	return "", nil, negotiationErr
 }
 
 // returns unquoted path with all special characters revealed as \u codes
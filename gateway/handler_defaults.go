@@ -94,6 +94,12 @@ func (i *handler) serveDefaults(ctx context.Context, w http.ResponseWriter, r *h
 
 	setIpfsRootsHeader(w, rq, &pathMetadata)
 
+	// Prefer the optional mtime of UnixFS 1.5 nodes over the IPNS record
+	// time for the Last-Modified header.
+	if !pathMetadata.ModTime.IsZero() {
+		rq.lastMod = pathMetadata.ModTime
+	}
+
 	resolvedPath := pathMetadata.LastSegment
 	switch mc.Code(resolvedPath.RootCid().Prefix().Codec) {
 	case mc.Json, mc.DagJson, mc.Cbor, mc.DagCbor:
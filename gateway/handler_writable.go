@@ -0,0 +1,446 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	gopath "path"
+	"strings"
+
+	"github.com/ipfs/boxo/car"
+	chunk "github.com/ipfs/boxo/chunker"
+	"github.com/ipfs/boxo/ipld/merkledag"
+	"github.com/ipfs/boxo/ipld/unixfs/importer/balanced"
+	"github.com/ipfs/boxo/ipld/unixfs/importer/helpers"
+	"github.com/ipfs/boxo/mfs"
+	"github.com/ipfs/boxo/path"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	format "github.com/ipfs/go-ipld-format"
+	"github.com/ipld/go-ipld-prime/codec/json"
+	"github.com/ipld/go-ipld-prime/multicodec"
+	basicnode "github.com/ipld/go-ipld-prime/node/basic"
+	"github.com/ipld/go-ipld-prime/traversal/patch"
+	mc "github.com/multiformats/go-multicodec"
+	mh "github.com/multiformats/go-multihash"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// DefaultMaxWriteSize is the default maximum size of the body of PUT
+	// requests and of the CARs of POST requests, see [Config.MaxWriteSize].
+	DefaultMaxWriteSize = 100 << 20
+
+	// maxWriteBlockSize is the maximum size of the blocks created by POST and
+	// PATCH requests, which is the largest block that can be exchanged with
+	// Bitswap.
+	maxWriteBlockSize = 2 << 20
+)
+
+// writeHandler handles the requests of the writable gateway:
+//
+//   - POST /ipfs/ stores a raw, DAG-JSON or DAG-CBOR block, or the blocks of a
+//     CAR with a single root, depending on the Content-Type of the request.
+//   - PUT /ipfs/{cid}/path/to/file adds the request body as a UnixFS file at
+//     the given path of the {cid} directory, replacing any existing file.
+//   - DELETE /ipfs/{cid}/path/to/file removes the given path from the {cid}
+//     directory.
+//   - PATCH /ipfs/{cid} applies the [IPLD Patch] document of the request body
+//     to a DAG-JSON or DAG-CBOR block.
+//
+// Every successful write returns the CID of the new content in the IPFS-Hash
+// header. POST, PUT and PATCH return 201 Created, with the path of the new
+// content in the Location header, and DELETE returns 200 OK.
+//
+// [IPLD Patch]: https://ipld.io/specs/patch/
+func (i *handler) writeHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, span := spanTrace(r.Context(), "Handler.Write", trace.WithAttributes(attribute.String("method", r.Method), attribute.String("path", r.URL.Path)))
+	defer span.End()
+
+	logger := log.With("from", r.RequestURI)
+	logger.Debugf("http %s request received", r.Method)
+
+	if i.config.AuthorizeWrite == nil {
+		i.webError(w, r, errors.New("writes are not authorized on this gateway"), http.StatusForbidden)
+		return
+	}
+	if err := i.config.AuthorizeWrite(r); err != nil {
+		i.webError(w, r, err, http.StatusForbidden)
+		return
+	}
+
+	if i.writableBackend == nil {
+		i.webError(w, r, errors.New("the backend of this gateway does not support writes"), http.StatusNotImplemented)
+		return
+	}
+
+	addCustomHeaders(w, i.config.Headers)
+
+	if r.Method == http.MethodPost {
+		if strings.TrimSuffix(r.URL.Path, "/") != strings.TrimSuffix(ipfsPathPrefix, "/") {
+			i.webError(w, r, fmt.Errorf("new content must be posted to %s", ipfsPathPrefix), http.StatusBadRequest)
+			return
+		}
+		i.postHandler(ctx, w, r)
+		return
+	}
+
+	contentPath, err := path.NewPath(r.URL.Path)
+	if err != nil {
+		i.webError(w, r, err, http.StatusBadRequest)
+		return
+	}
+	immutablePath, err := path.NewImmutablePath(contentPath)
+	if err != nil {
+		i.webError(w, r, fmt.Errorf("writes are only supported on %s paths: %w", ipfsPathPrefix, err), http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		i.putHandler(ctx, w, r, immutablePath)
+	case http.MethodDelete:
+		i.deleteHandler(ctx, w, r, immutablePath)
+	case http.MethodPatch:
+		i.patchHandler(ctx, w, r, immutablePath)
+	}
+}
+
+// writeCreated replies to a successful write of c, whose content is at the
+// given path under c.
+func writeCreated(w http.ResponseWriter, c cid.Cid, subPath string) {
+	w.Header().Set("IPFS-Hash", c.String())
+	w.Header().Set("Location", gopath.Join(ipfsPathPrefix, c.String(), subPath))
+	w.WriteHeader(http.StatusCreated)
+}
+
+// writeDeleted replies to a successful DELETE, which resulted in c.
+func writeDeleted(w http.ResponseWriter, c cid.Cid) {
+	w.Header().Set("IPFS-Hash", c.String())
+	w.WriteHeader(http.StatusOK)
+}
+
+// limitBody limits the size of the body of r to the MaxWriteSize of the
+// configuration. Reading past it fails with an [http.MaxBytesError], see
+// bodyErrorStatus.
+func (i *handler) limitBody(w http.ResponseWriter, r *http.Request) io.Reader {
+	limit := i.config.MaxWriteSize
+	if limit <= 0 {
+		limit = DefaultMaxWriteSize
+	}
+	return http.MaxBytesReader(w, r.Body, limit)
+}
+
+// bodyErrorStatus returns 413 Request Entity Too Large if err is due to a
+// body read through limitBody being too large, or status otherwise.
+func bodyErrorStatus(err error, status int) int {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return status
+}
+
+func (i *handler) postHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	bs := i.writableBackend.BlockService()
+
+	contentType := r.Header.Get("Content-Type")
+	if contentType != "" {
+		var err error
+		contentType, _, err = mime.ParseMediaType(contentType)
+		if err != nil {
+			i.webError(w, r, err, http.StatusBadRequest)
+			return
+		}
+	}
+
+	if contentType == carResponseFormat {
+		// The roots are checked before any block is imported, and verified
+		// after, so that only complete DAGs are created.
+		checkRoots := func(roots []cid.Cid) error {
+			if len(roots) != 1 {
+				return fmt.Errorf("CAR must have a single root, found %d", len(roots))
+			}
+			return nil
+		}
+		res, err := car.Import(ctx, i.limitBody(w, r), bs.Blockstore(), car.WithCheckRoots(checkRoots), car.WithVerifyRoots())
+		if err != nil {
+			i.webError(w, r, err, bodyErrorStatus(err, http.StatusBadRequest))
+			return
+		}
+		writeCreated(w, res.Roots[0].Root, "")
+		return
+	}
+
+	var codec mc.Code
+	switch contentType {
+	case "", rawResponseFormat, "application/octet-stream":
+		codec = mc.Raw
+	case dagJsonResponseFormat, dagCborResponseFormat:
+		codec = contentTypeToCodec[contentType]
+	default:
+		i.webError(w, r, fmt.Errorf("unsupported content type %q: expected %s, %s, %s or %s", contentType, rawResponseFormat, dagJsonResponseFormat, dagCborResponseFormat, carResponseFormat), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	data, err := readBlockData(r.Body)
+	if err != nil {
+		i.webError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	if codec != mc.Raw {
+		// Reject documents that are not valid in the codec of their CID.
+		decoder, err := multicodec.LookupDecoder(uint64(codec))
+		if err != nil {
+			i.webError(w, r, err, http.StatusInternalServerError)
+			return
+		}
+		if err := decoder(basicnode.Prototype.Any.NewBuilder(), bytes.NewReader(data)); err != nil {
+			i.webError(w, r, fmt.Errorf("invalid %s document: %w", contentType, err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	c, err := cid.Prefix{Version: 1, Codec: uint64(codec), MhType: mh.SHA2_256, MhLength: -1}.Sum(data)
+	if err != nil {
+		i.webError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	if err := i.addBlock(ctx, c, data); err != nil {
+		i.webError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	writeCreated(w, c, "")
+}
+
+func (i *handler) putHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, p path.ImmutablePath) {
+	filePath := path.SegmentsToString(p.Segments()[2:]...)
+	dirPath, fileName := gopath.Split(filePath)
+	if dirPath == "" || fileName == "" {
+		i.webError(w, r, errors.New("the path of the file to put is missing"), http.StatusBadRequest)
+		return
+	}
+
+	dagService := merkledag.NewDAGService(i.writableBackend.BlockService())
+	root, ok := i.getUnixFSRoot(ctx, w, r, dagService, p.RootCid())
+	if !ok {
+		return
+	}
+
+	// Files are added with the CID version of the root directory, and raw
+	// leaves with CIDv1, as done by 'ipfs add'.
+	params := helpers.DagBuilderParams{
+		Dagserv:    dagService,
+		Maxlinks:   helpers.DefaultLinksPerBlock,
+		CidBuilder: root.CidBuilder(),
+		RawLeaves:  p.RootCid().Version() == 1,
+	}
+	db, err := params.New(chunk.DefaultSplitter(i.limitBody(w, r)))
+	if err != nil {
+		i.webError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	file, err := balanced.Layout(db)
+	if err != nil {
+		i.webError(w, r, fmt.Errorf("cannot add file: %w", err), bodyErrorStatus(err, http.StatusInternalServerError))
+		return
+	}
+
+	mroot, err := mfs.NewRoot(ctx, dagService, root, nil)
+	if err != nil {
+		i.webError(w, r, err, http.StatusBadRequest)
+		return
+	}
+	if err := mfs.Mkdir(mroot, dirPath, mfs.MkdirOpts{Mkparents: true}); err != nil {
+		i.webError(w, r, fmt.Errorf("cannot create directory %s: %w", dirPath, err), http.StatusBadRequest)
+		return
+	}
+	dir, err := mfs.Lookup(mroot, dirPath)
+	if err != nil {
+		i.webError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	// Replace the existing file, if any. Directories are not replaced.
+	if child, err := dir.(*mfs.Directory).Child(fileName); err == nil {
+		if child.Type() == mfs.TDir {
+			i.webError(w, r, fmt.Errorf("cannot replace directory %s", filePath), http.StatusConflict)
+			return
+		}
+		if err := dir.(*mfs.Directory).Unlink(fileName); err != nil {
+			i.webError(w, r, err, http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := mfs.PutNode(mroot, filePath, file); err != nil {
+		i.webError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	newRoot, err := mroot.GetDirectory().GetNode()
+	if err != nil {
+		i.webError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	writeCreated(w, newRoot.Cid(), filePath)
+}
+
+func (i *handler) deleteHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, p path.ImmutablePath) {
+	segments := p.Segments()[2:]
+	if len(segments) == 0 {
+		i.webError(w, r, errors.New("the path to delete is missing"), http.StatusBadRequest)
+		return
+	}
+
+	dagService := merkledag.NewDAGService(i.writableBackend.BlockService())
+	root, ok := i.getUnixFSRoot(ctx, w, r, dagService, p.RootCid())
+	if !ok {
+		return
+	}
+
+	// Directories are edited through MFS, which supports sharded
+	// directories.
+	mroot, err := mfs.NewRoot(ctx, dagService, root, nil)
+	if err != nil {
+		i.webError(w, r, err, http.StatusBadRequest)
+		return
+	}
+	dirPath := path.SegmentsToString(segments[:len(segments)-1]...)
+	dir, err := mfs.Lookup(mroot, dirPath)
+	if err == nil {
+		if d, ok := dir.(*mfs.Directory); ok {
+			err = d.Unlink(segments[len(segments)-1])
+		} else {
+			err = fmt.Errorf("%s is not a directory: %w", dirPath, os.ErrNotExist)
+		}
+	}
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, os.ErrNotExist) {
+			status = http.StatusNotFound
+		}
+		i.webError(w, r, fmt.Errorf("cannot delete %s: %w", p, err), status)
+		return
+	}
+
+	newRoot, err := mroot.GetDirectory().GetNode()
+	if err != nil {
+		i.webError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	writeDeleted(w, newRoot.Cid())
+}
+
+func (i *handler) patchHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, p path.ImmutablePath) {
+	if len(p.Segments()) > 2 {
+		i.webError(w, r, errors.New("IPLD Patch must be applied to the root of a block: paths are part of the patch operations"), http.StatusBadRequest)
+		return
+	}
+
+	c := p.RootCid()
+	codec := mc.Code(c.Prefix().Codec)
+	if codec != mc.DagJson && codec != mc.DagCbor {
+		i.webError(w, r, fmt.Errorf("IPLD Patch is only supported on %s and %s blocks, not %s", mc.DagJson, mc.DagCbor, codec), http.StatusBadRequest)
+		return
+	}
+
+	ops, err := patch.Parse(r.Body, json.Decode)
+	if err != nil {
+		i.webError(w, r, NewErrorStatusCode(fmt.Errorf("invalid IPLD Patch document: %w", err), http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	blk, err := i.writableBackend.BlockService().GetBlock(ctx, c)
+	if err != nil {
+		i.webError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	decoder, err := multicodec.LookupDecoder(uint64(codec))
+	if err != nil {
+		i.webError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	nb := basicnode.Prototype.Any.NewBuilder()
+	if err := decoder(nb, bytes.NewReader(blk.RawData())); err != nil {
+		i.webError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	patched, err := patch.Eval(nb.Build(), ops)
+	if err != nil {
+		i.webError(w, r, NewErrorStatusCode(fmt.Errorf("cannot apply IPLD Patch: %w", err), http.StatusConflict), http.StatusConflict)
+		return
+	}
+
+	encoder, err := multicodec.LookupEncoder(uint64(codec))
+	if err != nil {
+		i.webError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	var buf bytes.Buffer
+	if err := encoder(patched, &buf); err != nil {
+		i.webError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	if buf.Len() > maxWriteBlockSize {
+		i.webError(w, r, fmt.Errorf("patched block is larger than %d bytes", maxWriteBlockSize), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	newCid, err := c.Prefix().Sum(buf.Bytes())
+	if err != nil {
+		i.webError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	if err := i.addBlock(ctx, newCid, buf.Bytes()); err != nil {
+		i.webError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	writeCreated(w, newCid, "")
+}
+
+// getUnixFSRoot returns the dag-pb node of c, which the PUT and DELETE
+// requests modify.
+func (i *handler) getUnixFSRoot(ctx context.Context, w http.ResponseWriter, r *http.Request, dagService format.DAGService, c cid.Cid) (*merkledag.ProtoNode, bool) {
+	nd, err := dagService.Get(ctx, c)
+	if err != nil {
+		i.webError(w, r, err, http.StatusInternalServerError)
+		return nil, false
+	}
+	root, ok := nd.(*merkledag.ProtoNode)
+	if !ok {
+		i.webError(w, r, fmt.Errorf("%s is not a UnixFS directory", c), http.StatusBadRequest)
+		return nil, false
+	}
+	return root, true
+}
+
+// addBlock adds the block with the given CID and data to the block service of
+// the backend.
+func (i *handler) addBlock(ctx context.Context, c cid.Cid, data []byte) error {
+	blk, err := blocks.NewBlockWithCid(data, c)
+	if err != nil {
+		return err
+	}
+	return i.writableBackend.BlockService().AddBlock(ctx, blk)
+}
+
+// readBlockData reads the data of a block from r, which must not be larger
+// than maxWriteBlockSize.
+func readBlockData(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxWriteBlockSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxWriteBlockSize {
+		return nil, NewErrorStatusCode(fmt.Errorf("blocks cannot be larger than %d bytes", maxWriteBlockSize), http.StatusRequestEntityTooLarge)
+	}
+	return data, nil
+}
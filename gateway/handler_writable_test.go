@@ -0,0 +1,340 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/boxo/blockservice"
+	"github.com/ipfs/boxo/blockstore"
+	offline "github.com/ipfs/boxo/exchange/offline"
+	"github.com/ipfs/boxo/ipld/merkledag"
+	ft "github.com/ipfs/boxo/ipld/unixfs"
+	"github.com/ipfs/boxo/ipld/unixfs/hamt"
+	"github.com/ipfs/boxo/path"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	format "github.com/ipfs/go-ipld-format"
+	carv2 "github.com/ipld/go-car/v2"
+	"github.com/ipld/go-car/v2/storage"
+	mc "github.com/multiformats/go-multicodec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newWritableTestServer(t *testing.T, config Config) (string, blockservice.BlockService) {
+	bs := blockservice.New(blockstore.NewBlockstore(dssync.MutexWrap(datastore.NewMapDatastore())), offline.Exchange(nil))
+	backend, err := NewBlocksBackend(bs)
+	require.NoError(t, err)
+
+	config.Headers = map[string][]string{}
+	config.DeserializedResponses = true
+	return newTestServerWithConfig(t, backend, config).URL, bs
+}
+
+func allowWrites(*http.Request) error {
+	return nil
+}
+
+func mustNewWriteRequest(t *testing.T, method, url string, body io.Reader) *http.Request {
+	req, err := http.NewRequest(method, url, body)
+	require.NoError(t, err)
+	return req
+}
+
+func mustWrite(t *testing.T, method, url, contentType string, body io.Reader) (*http.Response, cid.Cid) {
+	req := mustNewWriteRequest(t, method, url, body)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	res := mustDoWithoutRedirect(t, req)
+	t.Cleanup(func() { res.Body.Close() })
+	if res.StatusCode != http.StatusCreated && res.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(res.Body)
+		t.Logf("%s %s: %d %s", method, url, res.StatusCode, body)
+		return res, cid.Undef
+	}
+
+	c, err := cid.Decode(res.Header.Get("IPFS-Hash"))
+	require.NoError(t, err)
+	return res, c
+}
+
+// writeTestCAR returns a CARv1 with the given root and blocks.
+func writeTestCAR(t *testing.T, root cid.Cid, blks ...blocks.Block) []byte {
+	return writeTestCARWithRoots(t, []cid.Cid{root}, blks...)
+}
+
+// writeTestCARWithRoots returns a CARv1 with the given roots and blocks.
+func writeTestCARWithRoots(t *testing.T, roots []cid.Cid, blks ...blocks.Block) []byte {
+	var buf bytes.Buffer
+	w, err := storage.NewWritable(&buf, roots, carv2.WriteAsCarV1(true))
+	require.NoError(t, err)
+	for _, blk := range blks {
+		require.NoError(t, w.Put(context.Background(), blk.Cid().KeyString(), blk.RawData()))
+	}
+	require.NoError(t, w.Finalize())
+	return buf.Bytes()
+}
+
+func mustGetBody(t *testing.T, url string) string {
+	res := mustDo(t, mustNewRequest(t, http.MethodGet, url, nil))
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode, url)
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func TestWritableGateway(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Read only by default", func(t *testing.T) {
+		t.Parallel()
+		url, _ := newWritableTestServer(t, Config{})
+
+		res, _ := mustWrite(t, http.MethodPost, url+"/ipfs/", "", strings.NewReader("hello"))
+		assert.Equal(t, http.StatusMethodNotAllowed, res.StatusCode)
+		assert.NotContains(t, res.Header.Values("Allow"), http.MethodPost)
+	})
+
+	t.Run("Writes are denied without AuthorizeWrite", func(t *testing.T) {
+		t.Parallel()
+		url, _ := newWritableTestServer(t, Config{Writable: true})
+
+		for _, method := range []string{http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodPatch} {
+			res, _ := mustWrite(t, method, url+"/ipfs/", "", strings.NewReader("hello"))
+			assert.Equal(t, http.StatusForbidden, res.StatusCode, method)
+		}
+	})
+
+	t.Run("Write authorization", func(t *testing.T) {
+		t.Parallel()
+		url, _ := newWritableTestServer(t, Config{
+			Writable: true,
+			AuthorizeWrite: func(r *http.Request) error {
+				if r.Header.Get("Authorization") != "Bearer secret" {
+					return NewErrorStatusCode(errors.New("invalid token"), http.StatusUnauthorized)
+				}
+				return nil
+			},
+		})
+
+		res, _ := mustWrite(t, http.MethodPost, url+"/ipfs/", "", strings.NewReader("hello"))
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+		req := mustNewWriteRequest(t, http.MethodPost, url+"/ipfs/", strings.NewReader("hello"))
+		req.Header.Set("Authorization", "Bearer secret")
+		res = mustDoWithoutRedirect(t, req)
+		defer res.Body.Close()
+		assert.Equal(t, http.StatusCreated, res.StatusCode)
+
+		res = mustDoWithoutRedirect(t, mustNewWriteRequest(t, http.MethodOptions, url+"/ipfs/", nil))
+		defer res.Body.Close()
+		assert.Contains(t, res.Header.Values("Allow"), http.MethodPut)
+	})
+
+	t.Run("POST raw block, DAG-JSON and CAR", func(t *testing.T) {
+		t.Parallel()
+		url, _ := newWritableTestServer(t, Config{Writable: true, AuthorizeWrite: allowWrites})
+
+		res, c := mustWrite(t, http.MethodPost, url+"/ipfs/", rawResponseFormat, strings.NewReader("hello"))
+		require.Equal(t, http.StatusCreated, res.StatusCode)
+		assert.Equal(t, blocks.NewBlock([]byte("hello")).Cid().Hash(), c.Hash())
+		assert.Equal(t, uint64(mc.Raw), c.Prefix().Codec)
+		assert.Equal(t, "/ipfs/"+c.String(), res.Header.Get("Location"))
+		assert.Equal(t, "hello", mustGetBody(t, url+"/ipfs/"+c.String()))
+
+		res, c = mustWrite(t, http.MethodPost, url+"/ipfs/", dagJsonResponseFormat, strings.NewReader(`{"hello":"world"}`))
+		require.Equal(t, http.StatusCreated, res.StatusCode)
+		assert.Equal(t, uint64(mc.DagJson), c.Prefix().Codec)
+
+		res, _ = mustWrite(t, http.MethodPost, url+"/ipfs/", dagJsonResponseFormat, strings.NewReader(`{"hello"`))
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+		res, _ = mustWrite(t, http.MethodPost, url+"/ipfs/", "text/plain", strings.NewReader("hello"))
+		assert.Equal(t, http.StatusUnsupportedMediaType, res.StatusCode)
+
+		res, _ = mustWrite(t, http.MethodPost, url+"/ipfs/", rawResponseFormat, bytes.NewReader(make([]byte, maxWriteBlockSize+1)))
+		assert.Equal(t, http.StatusRequestEntityTooLarge, res.StatusCode)
+
+		file := merkledag.NewRawNode([]byte("file in a CAR"))
+		dir := ft.EmptyDirNode()
+		require.NoError(t, dir.AddNodeLink("file.txt", file))
+		// The CAR must contain the complete DAG of its root.
+		incomplete := writeTestCAR(t, dir.Cid(), dir)
+		res, _ = mustWrite(t, http.MethodPost, url+"/ipfs/", carResponseFormat, bytes.NewReader(incomplete))
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+		res, c = mustWrite(t, http.MethodPost, url+"/ipfs/", carResponseFormat, bytes.NewReader(writeTestCAR(t, dir.Cid(), dir, file)))
+		require.Equal(t, http.StatusCreated, res.StatusCode)
+		assert.Equal(t, dir.Cid(), c)
+		assert.Equal(t, "file in a CAR", mustGetBody(t, url+"/ipfs/"+c.String()+"/file.txt"))
+	})
+
+	t.Run("POST CAR with several roots", func(t *testing.T) {
+		t.Parallel()
+		url, bs := newWritableTestServer(t, Config{Writable: true, AuthorizeWrite: allowWrites})
+
+		a := merkledag.NewRawNode([]byte("a"))
+		b := merkledag.NewRawNode([]byte("b"))
+		res, _ := mustWrite(t, http.MethodPost, url+"/ipfs/", carResponseFormat, bytes.NewReader(writeTestCARWithRoots(t, []cid.Cid{a.Cid(), b.Cid()}, a, b)))
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+		// Nothing is imported.
+		for _, c := range []cid.Cid{a.Cid(), b.Cid()} {
+			has, err := bs.Blockstore().Has(context.Background(), c)
+			require.NoError(t, err)
+			assert.False(t, has)
+		}
+	})
+
+	t.Run("Size of written bodies is limited", func(t *testing.T) {
+		t.Parallel()
+		url, bs := newWritableTestServer(t, Config{Writable: true, AuthorizeWrite: allowWrites, MaxWriteSize: 1024})
+		root := ft.EmptyDirNode()
+		require.NoError(t, bs.AddBlock(context.Background(), root))
+
+		res, _ := mustWrite(t, http.MethodPut, url+"/ipfs/"+root.Cid().String()+"/small.txt", "", bytes.NewReader(make([]byte, 1024)))
+		assert.Equal(t, http.StatusCreated, res.StatusCode)
+
+		res, _ = mustWrite(t, http.MethodPut, url+"/ipfs/"+root.Cid().String()+"/large.txt", "", bytes.NewReader(make([]byte, 1025)))
+		assert.Equal(t, http.StatusRequestEntityTooLarge, res.StatusCode)
+
+		large := merkledag.NewRawNode(make([]byte, 1025))
+		res, _ = mustWrite(t, http.MethodPost, url+"/ipfs/", carResponseFormat, bytes.NewReader(writeTestCAR(t, large.Cid(), large)))
+		assert.Equal(t, http.StatusRequestEntityTooLarge, res.StatusCode)
+	})
+
+	t.Run("PUT and DELETE UnixFS files", func(t *testing.T) {
+		t.Parallel()
+		url, bs := newWritableTestServer(t, Config{Writable: true, AuthorizeWrite: allowWrites})
+		ctx := context.Background()
+
+		dagService := merkledag.NewDAGService(bs)
+		existing := merkledag.NewRawNode([]byte("existing"))
+		subdir := ft.EmptyDirNode()
+		require.NoError(t, subdir.AddNodeLink("existing.txt", existing))
+		root := ft.EmptyDirNode()
+		root.SetCidBuilder(cid.V1Builder{Codec: cid.DagProtobuf, MhType: uint64(mc.Sha2_256)})
+		require.NoError(t, root.AddNodeLink("subdir", subdir))
+		require.NoError(t, dagService.AddMany(ctx, []format.Node{existing, subdir, root}))
+		rootPath := url + "/ipfs/" + root.Cid().String()
+
+		// Add a file in new directories.
+		res, c1 := mustWrite(t, http.MethodPut, rootPath+"/a/b/new.txt", "", strings.NewReader("new file"))
+		require.Equal(t, http.StatusCreated, res.StatusCode)
+		assert.Equal(t, "/ipfs/"+c1.String()+"/a/b/new.txt", res.Header.Get("Location"))
+		assert.Equal(t, 1, int(c1.Version()))
+		assert.Equal(t, "new file", mustGetBody(t, url+res.Header.Get("Location")))
+		assert.Equal(t, "existing", mustGetBody(t, url+"/ipfs/"+c1.String()+"/subdir/existing.txt"))
+
+		// Replace an existing file.
+		res, c2 := mustWrite(t, http.MethodPut, url+"/ipfs/"+c1.String()+"/subdir/existing.txt", "", strings.NewReader("replaced"))
+		require.Equal(t, http.StatusCreated, res.StatusCode)
+		assert.Equal(t, "replaced", mustGetBody(t, url+"/ipfs/"+c2.String()+"/subdir/existing.txt"))
+		assert.Equal(t, "new file", mustGetBody(t, url+"/ipfs/"+c2.String()+"/a/b/new.txt"))
+
+		// Directories are not replaced.
+		res, _ = mustWrite(t, http.MethodPut, url+"/ipfs/"+c2.String()+"/subdir", "", strings.NewReader("file"))
+		assert.Equal(t, http.StatusConflict, res.StatusCode)
+
+		// Delete a file.
+		res, c3 := mustWrite(t, http.MethodDelete, url+"/ipfs/"+c2.String()+"/a/b/new.txt", "", nil)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Empty(t, res.Header.Get("Location"))
+		res = mustDo(t, mustNewRequest(t, http.MethodGet, url+"/ipfs/"+c3.String()+"/a/b/new.txt", nil))
+		res.Body.Close()
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+		assert.Equal(t, "replaced", mustGetBody(t, url+"/ipfs/"+c3.String()+"/subdir/existing.txt"))
+
+		res, _ = mustWrite(t, http.MethodDelete, url+"/ipfs/"+c3.String()+"/missing.txt", "", nil)
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+
+		res, _ = mustWrite(t, http.MethodDelete, url+"/ipfs/"+c3.String(), "", nil)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+		// Only UnixFS directories can be modified.
+		res, _ = mustWrite(t, http.MethodPut, url+"/ipfs/"+existing.Cid().String()+"/file.txt", "", strings.NewReader("file"))
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+		p, err := path.NewPath("/ipns/example.com/file.txt")
+		require.NoError(t, err)
+		res, _ = mustWrite(t, http.MethodPut, url+p.String(), "", strings.NewReader("file"))
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	})
+
+	t.Run("DELETE in a sharded directory", func(t *testing.T) {
+		t.Parallel()
+		url, bs := newWritableTestServer(t, Config{Writable: true, AuthorizeWrite: allowWrites})
+		ctx := context.Background()
+
+		dagService := merkledag.NewDAGService(bs)
+		shard, err := hamt.NewShard(dagService, 256)
+		require.NoError(t, err)
+		for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+			nd := merkledag.NewRawNode([]byte(name))
+			require.NoError(t, dagService.Add(ctx, nd))
+			require.NoError(t, shard.Set(ctx, name, nd))
+		}
+		root, err := shard.Node()
+		require.NoError(t, err)
+		require.NoError(t, dagService.Add(ctx, root))
+
+		res, c := mustWrite(t, http.MethodDelete, url+"/ipfs/"+root.Cid().String()+"/b.txt", "", nil)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		res = mustDo(t, mustNewRequest(t, http.MethodGet, url+"/ipfs/"+c.String()+"/b.txt", nil))
+		res.Body.Close()
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+		assert.Equal(t, "a.txt", mustGetBody(t, url+"/ipfs/"+c.String()+"/a.txt"))
+		assert.Equal(t, "c.txt", mustGetBody(t, url+"/ipfs/"+c.String()+"/c.txt"))
+
+		res, _ = mustWrite(t, http.MethodDelete, url+"/ipfs/"+c.String()+"/b.txt", "", nil)
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+
+	t.Run("PATCH DAG-JSON and DAG-CBOR", func(t *testing.T) {
+		t.Parallel()
+		url, _ := newWritableTestServer(t, Config{Writable: true, AuthorizeWrite: allowWrites})
+
+		res, c := mustWrite(t, http.MethodPost, url+"/ipfs/", dagJsonResponseFormat, strings.NewReader(`{"a":1,"b":{"c":"d"}}`))
+		require.Equal(t, http.StatusCreated, res.StatusCode)
+
+		res, patched := mustWrite(t, http.MethodPatch, url+"/ipfs/"+c.String(), "application/json", strings.NewReader(`[
+			{"op": "replace", "path": "/a", "value": 2},
+			{"op": "add", "path": "/b/e", "value": "f"},
+			{"op": "remove", "path": "/b/c"}
+		]`))
+		require.Equal(t, http.StatusCreated, res.StatusCode)
+		assert.Equal(t, uint64(mc.DagJson), patched.Prefix().Codec)
+		assert.JSONEq(t, `{"a":2,"b":{"e":"f"}}`, mustGetBody(t, url+"/ipfs/"+patched.String()+"?format=dag-json"))
+
+		// Failing operations don't create anything.
+		res, _ = mustWrite(t, http.MethodPatch, url+"/ipfs/"+c.String(), "application/json", strings.NewReader(`[{"op": "test", "path": "/a", "value": 3}]`))
+		assert.Equal(t, http.StatusConflict, res.StatusCode)
+
+		res, _ = mustWrite(t, http.MethodPatch, url+"/ipfs/"+c.String(), "application/json", strings.NewReader(`{`))
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+		res, _ = mustWrite(t, http.MethodPatch, url+"/ipfs/"+c.String()+"/b", "application/json", strings.NewReader(`[]`))
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+		res, raw := mustWrite(t, http.MethodPost, url+"/ipfs/", rawResponseFormat, strings.NewReader("raw"))
+		require.Equal(t, http.StatusCreated, res.StatusCode)
+		res, _ = mustWrite(t, http.MethodPatch, url+"/ipfs/"+raw.String(), "application/json", strings.NewReader(`[]`))
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+		res, c = mustWrite(t, http.MethodPost, url+"/ipfs/", dagCborResponseFormat, bytes.NewReader([]byte{0xa1, 0x61, 0x61, 0x01})) // {"a": 1}
+		require.Equal(t, http.StatusCreated, res.StatusCode)
+		res, patched = mustWrite(t, http.MethodPatch, url+"/ipfs/"+c.String(), "application/json", strings.NewReader(`[{"op": "add", "path": "/b", "value": true}]`))
+		require.Equal(t, http.StatusCreated, res.StatusCode)
+		assert.Equal(t, uint64(mc.DagCbor), patched.Prefix().Codec)
+		assert.JSONEq(t, `{"a":1,"b":true}`, mustGetBody(t, url+"/ipfs/"+patched.String()+"?format=dag-json"))
+	})
+}
@@ -181,9 +181,11 @@ func (b *ipfsBackendWithMetrics) GetDNSLinkRecord(ctx context.Context, fqdn stri
 var _ IPFSBackend = (*ipfsBackendWithMetrics)(nil)
 
 func newHandlerWithMetrics(c *Config, backend IPFSBackend) *handler {
+	writableBackend, _ := backend.(WritableBackend)
 	i := &handler{
-		config:  c,
-		backend: newIPFSBackendWithMetrics(backend),
+		config:          c,
+		backend:         newIPFSBackendWithMetrics(backend),
+		writableBackend: writableBackend,
 
 		// Response-type specific metrics
 		// ----------------------------
@@ -0,0 +1,325 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/ipfs/boxo/blockservice"
+	blockstore "github.com/ipfs/boxo/blockstore"
+	"github.com/ipfs/boxo/exchange"
+	"github.com/ipfs/boxo/files"
+	"github.com/ipfs/boxo/path"
+	"github.com/ipfs/boxo/verifcid"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/ipld/go-car/v2"
+)
+
+// maxRemoteBlockSize is the maximum size of a block accepted from a remote
+// gateway. It matches the largest block that is transferred over Bitswap.
+const maxRemoteBlockSize = 2 << 20
+
+// remoteBlockTimeout is the timeout of the request of a single block.
+const remoteBlockTimeout = 30 * time.Second
+
+// defaultRemoteResponseHeaderTimeout is how long the [http.Client] used by
+// [NewRemoteBackend], when none is provided, waits for the response headers of
+// a remote gateway. It has no overall timeout, so that CAR streams of large
+// DAGs are only bound by the context of the request.
+const defaultRemoteResponseHeaderTimeout = 30 * time.Second
+
+// RemoteBackend is an [IPFSBackend] implementation that retrieves data from a
+// pool of [trustless gateways] instead of a libp2p stack. Blocks are fetched as
+// application/vnd.ipld.raw and DAGs as application/vnd.ipld.car, every block is
+// verified against its CID and cached in a local blockstore. Requests fail over
+// to the next gateway in the pool when one is unavailable or misbehaves.
+//
+// [trustless gateways]: https://specs.ipfs.tech/http-gateways/trustless-gateway/
+type RemoteBackend struct {
+	*BlocksBackend
+
+	// local only uses the cached blocks.
+	local   *BlocksBackend
+	fetcher *remoteFetcher
+}
+
+var _ IPFSBackend = (*RemoteBackend)(nil)
+
+// NewRemoteBackend creates a [RemoteBackend] that fetches data from the given
+// gateway URLs, e.g. "https://trustless-gateway.link". Fetched blocks are
+// cached in bs. If bs is nil, an in-memory blockstore without any eviction is
+// used. If httpClient is nil, a client with a default response header timeout
+// is used. Block requests time out after 30 seconds, while CAR requests last
+// as long as their context, so httpClient should not set [http.Client.Timeout].
+//
+// The options are the same as the ones accepted by [NewBlocksBackend].
+func NewRemoteBackend(gatewayURLs []string, bs blockstore.Blockstore, httpClient *http.Client, opts ...BlocksBackendOption) (*RemoteBackend, error) {
+	if len(gatewayURLs) == 0 {
+		return nil, errors.New("at least one gateway URL is required")
+	}
+
+	gateways := make([]string, len(gatewayURLs))
+	for i, gw := range gatewayURLs {
+		u, err := url.Parse(gw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gateway URL %q: %w", gw, err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return nil, fmt.Errorf("invalid gateway URL %q: scheme must be http or https", gw)
+		}
+		gateways[i] = strings.TrimRight(u.String(), "/")
+	}
+
+	if bs == nil {
+		bs = blockstore.NewBlockstore(dssync.MutexWrap(datastore.NewMapDatastore()))
+	}
+
+	if httpClient == nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.ResponseHeaderTimeout = defaultRemoteResponseHeaderTimeout
+		httpClient = &http.Client{Transport: transport}
+	}
+
+	fetcher := &remoteFetcher{
+		gateways:     gateways,
+		httpClient:   httpClient,
+		blockTimeout: remoteBlockTimeout,
+	}
+
+	backend, err := NewBlocksBackend(blockservice.New(bs, fetcher), opts...)
+	if err != nil {
+		return nil, err
+	}
+	local, err := NewBlocksBackend(blockservice.New(bs, nil), opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RemoteBackend{
+		BlocksBackend: backend,
+		local:         local,
+		fetcher:       fetcher,
+	}, nil
+}
+
+func (rb *RemoteBackend) Get(ctx context.Context, path path.ImmutablePath, ranges ...ByteRange) (ContentPathMetadata, *GetResponse, error) {
+	params := CarParams{Scope: DagScopeEntity}
+	if len(ranges) == 1 {
+		params.Range = &DagByteRange{From: int64(ranges[0].From), To: ranges[0].To}
+	}
+	rb.prefetch(ctx, path, params)
+	return rb.BlocksBackend.Get(ctx, path, ranges...)
+}
+
+func (rb *RemoteBackend) GetAll(ctx context.Context, path path.ImmutablePath) (ContentPathMetadata, files.Node, error) {
+	rb.prefetch(ctx, path, CarParams{Scope: DagScopeAll})
+	return rb.BlocksBackend.GetAll(ctx, path)
+}
+
+func (rb *RemoteBackend) GetCAR(ctx context.Context, path path.ImmutablePath, params CarParams) (ContentPathMetadata, io.ReadCloser, error) {
+	rb.prefetch(ctx, path, params)
+	return rb.BlocksBackend.GetCAR(ctx, path, params)
+}
+
+// IsCached returns whether the blocks of the path p, up to the block it
+// resolves to, are in the local blockstore. Nothing is fetched from the remote
+// gateways.
+func (rb *RemoteBackend) IsCached(ctx context.Context, p path.Path) bool {
+	return rb.local.IsCached(ctx, p)
+}
+
+// prefetch retrieves the blocks needed to serve the given path and parameters
+// with a single CAR request, so that they do not have to be fetched one by one.
+// The prefetch is skipped if the path is already cached. Errors are not
+// fatal: any block that is still missing is fetched individually later on.
+func (rb *RemoteBackend) prefetch(ctx context.Context, p path.ImmutablePath, params CarParams) {
+	if rb.IsCached(ctx, p) {
+		return
+	}
+
+	if err := rb.fetcher.fetchCAR(ctx, p, params, rb.blockStore); err != nil {
+		log.Debugw("failed to prefetch CAR from remote gateways", "path", p, "error", err)
+	}
+}
+
+// remoteFetcher is an [exchange.Interface] that retrieves verified blocks from
+// a pool of trustless gateways. Requests are distributed in a round-robin
+// fashion and fail over to the next gateway on error.
+type remoteFetcher struct {
+	gateways     []string
+	httpClient   *http.Client
+	blockTimeout time.Duration // is the timeout of block requests, if set
+	next         atomic.Uint32
+}
+
+var _ exchange.Interface = (*remoteFetcher)(nil)
+
+func (f *remoteFetcher) GetBlock(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	if f.blockTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.blockTimeout)
+		defer cancel()
+	}
+
+	var blk blocks.Block
+	err := f.fetch(ctx, "/ipfs/"+c.String(), url.Values{"format": {"raw"}}, rawResponseFormat, func(r io.Reader) error {
+		data, err := io.ReadAll(io.LimitReader(r, maxRemoteBlockSize+1))
+		if err != nil {
+			return err
+		}
+		if len(data) > maxRemoteBlockSize {
+			return fmt.Errorf("block %s exceeds the maximum size of %d bytes", c, maxRemoteBlockSize)
+		}
+		blk, err = verifiedBlock(c, data)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return blk, nil
+}
+
+func (f *remoteFetcher) GetBlocks(ctx context.Context, cids []cid.Cid) (<-chan blocks.Block, error) {
+	out := make(chan blocks.Block)
+	go func() {
+		defer close(out)
+		for _, c := range cids {
+			blk, err := f.GetBlock(ctx, c)
+			if err != nil {
+				log.Debugw("failed to fetch block from remote gateways", "cid", c, "error", err)
+				continue
+			}
+			select {
+			case out <- blk:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (f *remoteFetcher) NotifyNewBlocks(ctx context.Context, blocks ...blocks.Block) error {
+	return nil
+}
+
+func (f *remoteFetcher) Close() error {
+	return nil
+}
+
+// fetchCAR retrieves the CAR for the given path and parameters and stores every
+// block it contains in bs. Blocks are verified against their CID, whose hash
+// function must be in the default [verifcid.Allowlist] of the block service,
+// before being stored. If a gateway fails midway, the CAR is requested from
+// the next one.
+func (f *remoteFetcher) fetchCAR(ctx context.Context, p path.ImmutablePath, params CarParams, bs blockstore.Blockstore) error {
+	query := url.Values{"format": {"car"}}
+	if params.Scope != "" {
+		query.Set("dag-scope", string(params.Scope))
+	}
+	if params.Range != nil {
+		to := "*"
+		if params.Range.To != nil {
+			to = strconv.FormatInt(*params.Range.To, 10)
+		}
+		query.Set("entity-bytes", strconv.FormatInt(params.Range.From, 10)+":"+to)
+	}
+
+	return f.fetch(ctx, p.String(), query, carResponseFormat, func(r io.Reader) error {
+		br, err := car.NewBlockReader(r)
+		if err != nil {
+			return err
+		}
+
+		for {
+			blk, err := br.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			if err := verifcid.ValidateCid(verifcid.DefaultAllowlist, blk.Cid()); err != nil {
+				return fmt.Errorf("block %s: %w", blk.Cid(), err)
+			}
+			blk, err = verifiedBlock(blk.Cid(), blk.RawData())
+			if err != nil {
+				return err
+			}
+
+			if err := bs.Put(ctx, blk); err != nil {
+				return err
+			}
+		}
+	})
+}
+
+// fetch requests the given content path from the gateways, starting with the
+// next one in the round-robin order, until one of them responds successfully
+// and its response body is accepted by handle.
+func (f *remoteFetcher) fetch(ctx context.Context, contentPath string, query url.Values, accept string, handle func(io.Reader) error) error {
+	start := int(f.next.Add(1) - 1)
+
+	var errs []error
+	for i := range f.gateways {
+		gw := f.gateways[(start+i)%len(f.gateways)]
+
+		err := f.fetchFrom(ctx, gw, contentPath, query, accept, handle)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		errs = append(errs, fmt.Errorf("%s: %w", gw, err))
+	}
+
+	return NewErrorStatusCode(fmt.Errorf("failed to fetch %s from remote gateways: %w", contentPath, errors.Join(errs...)), http.StatusBadGateway)
+}
+
+func (f *remoteFetcher) fetchFrom(ctx context.Context, gateway, contentPath string, query url.Values, accept string, handle func(io.Reader) error) error {
+	u := gateway + (&url.URL{Path: contentPath}).EscapedPath() + "?" + query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", accept)
+
+	res, err := f.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", res.Status)
+	}
+
+	return handle(res.Body)
+}
+
+// verifiedBlock returns a block for the given data, after verifying that it
+// matches the multihash of c.
+func verifiedBlock(c cid.Cid, data []byte) (blocks.Block, error) {
+	expected, err := c.Prefix().Sum(data)
+	if err != nil {
+		return nil, err
+	}
+	if !expected.Equals(c) {
+		return nil, fmt.Errorf("block data does not match %s", c)
+	}
+	return blocks.NewBlockWithCid(data, c)
+}
@@ -0,0 +1,216 @@
+package gateway
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ipfs/boxo/blockstore"
+	"github.com/ipfs/boxo/path"
+	"github.com/ipfs/boxo/verifcid"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/ipld/go-car/v2"
+	mh "github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRemoteTestServer(t *testing.T, handler http.HandlerFunc) (*httptest.Server, *atomic.Int32) {
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		handler(w, r)
+	}))
+	t.Cleanup(func() { ts.Close() })
+	return ts, &calls
+}
+
+func TestRemoteBackend(t *testing.T) {
+	t.Parallel()
+
+	upstream, _, root := newTestServerAndNode(t, nil, "fixtures.car")
+
+	// Counts the requests proxied to the working upstream gateway.
+	proxy, proxyCalls := newRemoteTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, upstream.URL+r.URL.RequestURI(), http.StatusTemporaryRedirect)
+	})
+
+	// Returns data that does not match the requested CIDs.
+	corrupt, corruptCalls := newRemoteTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("not the block you are looking for"))
+	})
+
+	// Always fails.
+	broken, brokenCalls := newRemoteTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "broken", http.StatusInternalServerError)
+	})
+
+	t.Run("UnixFS file is fetched, verified and cached", func(t *testing.T) {
+		t.Parallel()
+
+		backend, err := NewRemoteBackend([]string{corrupt.URL, broken.URL, proxy.URL + "/"}, nil, nil)
+		require.NoError(t, err)
+		ts := newTestServer(t, backend)
+
+		req := mustNewRequest(t, http.MethodGet, ts.URL+"/ipfs/"+root.String()+"/subdir/fnord", nil)
+		res := mustDoWithoutRedirect(t, req)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "fnord", string(body))
+		assert.NotZero(t, corruptCalls.Load())
+		assert.NotZero(t, brokenCalls.Load())
+
+		// The second request is served from the local blockstore.
+		calls := proxyCalls.Load() + corruptCalls.Load() + brokenCalls.Load()
+		req = mustNewRequest(t, http.MethodGet, ts.URL+"/ipfs/"+root.String()+"/subdir/fnord", nil)
+		res = mustDoWithoutRedirect(t, req)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, calls, proxyCalls.Load()+corruptCalls.Load()+brokenCalls.Load())
+	})
+
+	t.Run("CAR is fetched and verified", func(t *testing.T) {
+		t.Parallel()
+
+		backend, err := NewRemoteBackend([]string{corrupt.URL, proxy.URL}, nil, nil)
+		require.NoError(t, err)
+		ts := newTestServer(t, backend)
+
+		req := mustNewRequest(t, http.MethodGet, ts.URL+"/ipfs/"+root.String()+"?format=car", nil)
+		res := mustDoWithoutRedirect(t, req)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusOK, res.StatusCode)
+
+		br, err := car.NewBlockReader(res.Body)
+		require.NoError(t, err)
+		assert.Equal(t, []cid.Cid{root}, br.Roots)
+
+		var n int
+		for {
+			blk, err := br.Next()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			_, err = verifiedBlock(blk.Cid(), blk.RawData())
+			require.NoError(t, err)
+			n++
+		}
+		assert.NotZero(t, n)
+	})
+
+	t.Run("Paths with a cached root are prefetched", func(t *testing.T) {
+		t.Parallel()
+
+		var carCalls atomic.Int32
+		carProxy, _ := newRemoteTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("format") == "car" {
+				carCalls.Add(1)
+			}
+			http.Redirect(w, r, upstream.URL+r.URL.RequestURI(), http.StatusTemporaryRedirect)
+		})
+
+		bs := blockstore.NewBlockstore(dssync.MutexWrap(datastore.NewMapDatastore()))
+		f := &remoteFetcher{gateways: []string{proxy.URL}, httpClient: http.DefaultClient}
+		blk, err := f.GetBlock(context.Background(), root)
+		require.NoError(t, err)
+		require.NoError(t, bs.Put(context.Background(), blk))
+
+		backend, err := NewRemoteBackend([]string{carProxy.URL}, bs, nil)
+		require.NoError(t, err)
+		p, err := path.Join(path.FromCid(root), "subdir", "fnord")
+		require.NoError(t, err)
+		assert.False(t, backend.IsCached(context.Background(), p))
+
+		ts := newTestServer(t, backend)
+		req := mustNewRequest(t, http.MethodGet, ts.URL+p.String(), nil)
+		res := mustDoWithoutRedirect(t, req)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, int32(1), carCalls.Load())
+		assert.True(t, backend.IsCached(context.Background(), p))
+	})
+
+	t.Run("CAR blocks must use an allowed hash function", func(t *testing.T) {
+		t.Parallel()
+
+		blk, err := blocks.NewBlockWithCid([]byte("md5"), mustSumCid(t, cid.Prefix{Version: 1, Codec: cid.Raw, MhType: mh.MD5, MhLength: -1}, []byte("md5")))
+		require.NoError(t, err)
+		data := writeTestCAR(t, blk.Cid(), blk)
+		insecure, _ := newRemoteTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write(data)
+		})
+
+		bs := blockstore.NewBlockstore(dssync.MutexWrap(datastore.NewMapDatastore()))
+		f := &remoteFetcher{gateways: []string{insecure.URL}, httpClient: http.DefaultClient}
+		err = f.fetchCAR(context.Background(), path.FromCid(blk.Cid()), CarParams{}, bs)
+		require.ErrorContains(t, err, verifcid.ErrPossiblyInsecureHashFunction.Error())
+		has, err := bs.Has(context.Background(), blk.Cid())
+		require.NoError(t, err)
+		assert.False(t, has)
+	})
+
+	t.Run("All gateways failing is a bad gateway error", func(t *testing.T) {
+		t.Parallel()
+
+		backend, err := NewRemoteBackend([]string{corrupt.URL, broken.URL}, nil, nil)
+		require.NoError(t, err)
+		ts := newTestServer(t, backend)
+
+		req := mustNewRequest(t, http.MethodGet, ts.URL+"/ipfs/"+root.String(), nil)
+		res := mustDoWithoutRedirect(t, req)
+		defer res.Body.Close()
+		assert.Equal(t, http.StatusBadGateway, res.StatusCode)
+	})
+
+	t.Run("Remote blocks are verified", func(t *testing.T) {
+		t.Parallel()
+
+		f := &remoteFetcher{gateways: []string{corrupt.URL}, httpClient: http.DefaultClient}
+		_, err := f.GetBlock(context.Background(), root)
+		require.ErrorContains(t, err, "block data does not match")
+	})
+
+	t.Run("Block requests time out", func(t *testing.T) {
+		t.Parallel()
+
+		hanging, _ := newRemoteTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+		})
+		f := &remoteFetcher{gateways: []string{hanging.URL}, httpClient: http.DefaultClient, blockTimeout: 50 * time.Millisecond}
+		_, err := f.GetBlock(context.Background(), root)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
+
+func mustSumCid(t *testing.T, prefix cid.Prefix, data []byte) cid.Cid {
+	c, err := prefix.Sum(data)
+	require.NoError(t, err)
+	return c
+}
+
+func TestNewRemoteBackend(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewRemoteBackend(nil, nil, nil)
+	assert.Error(t, err)
+
+	_, err = NewRemoteBackend([]string{"ftp://example.com"}, nil, nil)
+	assert.Error(t, err)
+
+	backend, err := NewRemoteBackend([]string{"https://example.com"}, nil, nil)
+	assert.NoError(t, err)
+
+	// The default client does not cut CAR streams off
+	assert.Zero(t, backend.fetcher.httpClient.Timeout)
+	assert.Equal(t, defaultRemoteResponseHeaderTimeout, backend.fetcher.httpClient.Transport.(*http.Transport).ResponseHeaderTimeout)
+}
@@ -0,0 +1,535 @@
+// Package gc implements a mark-and-sweep garbage collector for blockstores.
+//
+// The collector keeps every block that is reachable from the pins of a
+// [pin.Pinner] and from any additional roots, such as the MFS root, and removes
+// everything else from a [blockstore.GCBlockstore].
+package gc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ipfs/boxo/blockstore"
+	"github.com/ipfs/boxo/fetcher"
+	"github.com/ipfs/boxo/fetcher/helpers"
+	pin "github.com/ipfs/boxo/pinning/pinner"
+	"github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	logging "github.com/ipfs/go-log/v2"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipld/go-ipld-prime/traversal"
+)
+
+var log = logging.Logger("gc")
+
+// ErrCannotFetchAllLinks is returned as the last Result in the GC output
+// channel when there was an error creating the marked set because of a
+// problem when finding descendants.
+var ErrCannotFetchAllLinks = errors.New("garbage collection aborted: could not retrieve some links")
+
+// ErrCannotDeleteSomeBlocks is returned as the last Result in the GC output
+// channel when removing some of the blocks marked for deletion failed.
+var ErrCannotDeleteSomeBlocks = errors.New("garbage collection incomplete: could not delete some blocks")
+
+// CannotFetchLinksError provides detailed information about which links
+// could not be fetched and can appear as a Result in the GC output channel.
+type CannotFetchLinksError struct {
+	Key cid.Cid
+	Err error
+}
+
+func (e *CannotFetchLinksError) Error() string {
+	return fmt.Sprintf("could not retrieve links for %s: %s", e.Key, e.Err)
+}
+
+func (e *CannotFetchLinksError) Unwrap() error {
+	return e.Err
+}
+
+// CannotDeleteBlockError provides detailed information about which blocks
+// could not be deleted and can appear as a Result in the GC output channel.
+type CannotDeleteBlockError struct {
+	Key cid.Cid
+	Err error
+}
+
+func (e *CannotDeleteBlockError) Error() string {
+	return fmt.Sprintf("could not remove %s: %s", e.Key, e.Err)
+}
+
+func (e *CannotDeleteBlockError) Unwrap() error {
+	return e.Err
+}
+
+// Result represents an incremental output from a garbage collection run. It
+// contains either an error, or the CID of a removed block.
+type Result struct {
+	// KeyRemoved is the CID of a removed block, or of a block that would
+	// have been removed in dry-run mode. Blockstores identify blocks by their
+	// multihash, so it is always a CIDv1 with the raw codec.
+	KeyRemoved cid.Cid
+	Error      error
+}
+
+// Progress is a snapshot of the state of a garbage collection run.
+type Progress struct {
+	// Marked is the number of blocks that are kept.
+	Marked int
+	// Candidates is the number of blocks that were not marked when the
+	// blockstore was enumerated.
+	Candidates int
+	// Swept is the number of candidates that have been processed.
+	Swept int
+	// Removed is the number of removed blocks, or of blocks that would have
+	// been removed in dry-run mode.
+	Removed int
+	// RemovedBytes is the total size of the removed blocks.
+	RemovedBytes uint64
+}
+
+// RootsFunc returns CIDs that must be kept, along with their descendants, in
+// addition to the pinned ones. It is called every time the collector marks the
+// blocks to keep, so it can return roots that change over time.
+type RootsFunc func(ctx context.Context) ([]cid.Cid, error)
+
+// StaticRoots returns a [RootsFunc] that always returns the given CIDs.
+func StaticRoots(roots ...cid.Cid) RootsFunc {
+	return func(context.Context) ([]cid.Cid, error) {
+		return roots, nil
+	}
+}
+
+type options struct {
+	roots           RootsFunc
+	bestEffortRoots RootsFunc
+	dryRun          bool
+	maxBytes        uint64
+	maxDuration     time.Duration
+	batchSize       int
+	progress        chan<- Progress
+}
+
+// Option configures a garbage collection run.
+type Option func(*options)
+
+// WithRoots sets additional roots that are kept along with all their
+// descendants, e.g. the MFS root. Failing to retrieve any of their blocks
+// aborts the garbage collection.
+func WithRoots(roots RootsFunc) Option {
+	return func(o *options) {
+		o.roots = roots
+	}
+}
+
+// WithBestEffortRoots sets additional roots whose descendants are kept if they
+// are present in the blockstore. Missing blocks are ignored.
+func WithBestEffortRoots(roots RootsFunc) Option {
+	return func(o *options) {
+		o.bestEffortRoots = roots
+	}
+}
+
+// WithDryRun reports the blocks that would be removed without removing them.
+func WithDryRun() Option {
+	return func(o *options) {
+		o.dryRun = true
+	}
+}
+
+// WithSizeBudget stops the garbage collection once at least the given number
+// of bytes have been removed. Zero means no limit.
+func WithSizeBudget(bytes uint64) Option {
+	return func(o *options) {
+		o.maxBytes = bytes
+	}
+}
+
+// WithTimeBudget stops removing blocks once the garbage collection has been
+// running for the given duration. Marking always runs to completion, so that
+// no needed block is ever removed. Zero means no limit.
+func WithTimeBudget(d time.Duration) Option {
+	return func(o *options) {
+		o.maxDuration = d
+	}
+}
+
+// WithBatchSize sets the number of candidate blocks that are swept while the
+// GC lock is held. The lock is released between batches, so that pins can make
+// progress during long collections. Before every batch, pins and roots added
+// in between are marked again. Zero, the default, sweeps all the blocks under
+// a single lock.
+func WithBatchSize(n int) Option {
+	return func(o *options) {
+		o.batchSize = n
+	}
+}
+
+// WithProgress sets a channel that receives a [Progress] update after marking
+// and after every batch. Updates are dropped if the channel is not ready to
+// receive them. The channel is not closed by the collector.
+func WithProgress(ch chan<- Progress) Option {
+	return func(o *options) {
+		o.progress = ch
+	}
+}
+
+// GC performs a mark and sweep garbage collection of the blocks in the
+// blockstore. First, it builds a marked set with:
+//
+//   - all recursively pinned blocks, plus all of their descendants
+//   - the roots set with [WithRoots], plus all of their descendants
+//   - the roots set with [WithBestEffortRoots], plus their available descendants
+//   - all directly pinned blocks
+//   - all blocks used internally by the pinner, plus their descendants
+//
+// Then, it iterates over every block in the blockstore and removes those that
+// are not in the marked set.
+//
+// Blocks are read and decoded with fetcherFactory, which must only read from
+// the local blockstore, e.g. a fetcher over a blockservice with an offline
+// exchange.
+//
+// The returned channel receives the removed blocks and any error, and is closed
+// once the garbage collection is done.
+func GC(ctx context.Context, bs blockstore.GCBlockstore, pn pin.Pinner, fetcherFactory fetcher.Factory, opts ...Option) <-chan Result {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	output := make(chan Result, 128)
+
+	c := &collector{
+		bs:      bs,
+		pn:      pn,
+		fetcher: fetcherFactory.NewSession(ctx),
+		opts:    o,
+		output:  output,
+		visited: cid.NewSet(),
+		marked:  cid.NewSet(),
+		start:   time.Now(),
+	}
+
+	go func() {
+		defer cancel()
+		defer close(output)
+		c.run(ctx)
+	}()
+
+	return output
+}
+
+type collector struct {
+	bs      blockstore.GCBlockstore
+	pn      pin.Pinner
+	fetcher fetcher.Fetcher
+	opts    options
+	output  chan<- Result
+
+	// visited contains the CIDv1 of the walked blocks, preserving their
+	// codec. marked contains the raw CIDv1 of the same blocks, which is how
+	// the blockstore identifies them.
+	visited *cid.Set
+	marked  *cid.Set
+
+	start    time.Time
+	progress Progress
+}
+
+func (c *collector) run(ctx context.Context) {
+	unlocker := c.bs.GCLock(ctx)
+
+	if err := c.mark(ctx); err != nil {
+		unlocker.Unlock(ctx)
+		c.emit(ctx, Result{Error: err})
+		return
+	}
+
+	candidates, err := c.candidates(ctx)
+	if err != nil {
+		unlocker.Unlock(ctx)
+		c.emit(ctx, Result{Error: err})
+		return
+	}
+	c.sendProgress()
+
+	batchSize := c.opts.batchSize
+	if batchSize <= 0 {
+		batchSize = len(candidates)
+	}
+
+	var deleteErrors bool
+	for start := 0; ; start += batchSize {
+		end := start + batchSize
+		if end > len(candidates) {
+			end = len(candidates)
+		}
+
+		stop := c.sweep(ctx, candidates[start:end], &deleteErrors)
+		unlocker.Unlock(ctx)
+		c.sendProgress()
+
+		if stop || end == len(candidates) {
+			break
+		}
+
+		// Pins and roots may have changed while the lock was released.
+		unlocker = c.bs.GCLock(ctx)
+		if err := c.mark(ctx); err != nil {
+			unlocker.Unlock(ctx)
+			c.emit(ctx, Result{Error: err})
+			return
+		}
+	}
+
+	if deleteErrors {
+		c.emit(ctx, Result{Error: ErrCannotDeleteSomeBlocks})
+	}
+}
+
+// mark adds the pinned blocks, the roots and their descendants to the marked
+// set. Blocks that are already marked are not walked again, so calling it again
+// only walks what was pinned or added to the roots since the previous call.
+func (c *collector) mark(ctx context.Context) error {
+	var failed bool
+	walkAll := func(roots []cid.Cid, bestEffort bool) error {
+		for _, root := range roots {
+			ok, err := c.walk(ctx, root, bestEffort)
+			if err != nil {
+				return err
+			}
+			failed = failed || !ok
+		}
+		return nil
+	}
+
+	recursive, err := collectKeys(c.pn.RecursiveKeys(ctx))
+	if err != nil {
+		return err
+	}
+	if err := walkAll(recursive, false); err != nil {
+		return err
+	}
+
+	if c.opts.roots != nil {
+		roots, err := c.opts.roots(ctx)
+		if err != nil {
+			return err
+		}
+		if err := walkAll(roots, false); err != nil {
+			return err
+		}
+	}
+
+	if c.opts.bestEffortRoots != nil {
+		roots, err := c.opts.bestEffortRoots(ctx)
+		if err != nil {
+			return err
+		}
+		if err := walkAll(roots, true); err != nil {
+			return err
+		}
+	}
+
+	direct, err := collectKeys(c.pn.DirectKeys(ctx))
+	if err != nil {
+		return err
+	}
+	for _, k := range direct {
+		c.visited.Add(toCidV1(k))
+		c.marked.Add(toRawCid(k))
+	}
+
+	internal, err := collectKeys(c.pn.InternalPins(ctx))
+	if err != nil {
+		return err
+	}
+	if err := walkAll(internal, false); err != nil {
+		return err
+	}
+
+	if failed {
+		return ErrCannotFetchAllLinks
+	}
+
+	c.progress.Marked = c.marked.Len()
+	return nil
+}
+
+// walk marks the given root and its descendants. It returns false if the
+// links of some blocks could not be retrieved, which is reported in the output
+// channel. If bestEffort is true, missing blocks are ignored.
+func (c *collector) walk(ctx context.Context, root cid.Cid, bestEffort bool) (bool, error) {
+	ok := true
+	stack := []cid.Cid{root}
+	for len(stack) > 0 {
+		k := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if !c.visited.Visit(toCidV1(k)) {
+			continue
+		}
+		c.marked.Add(toRawCid(k))
+
+		links, err := c.links(ctx, k)
+		if err != nil {
+			if ctx.Err() != nil {
+				return false, ctx.Err()
+			}
+			if bestEffort && ipld.IsNotFound(err) {
+				continue
+			}
+
+			log.Debugw("could not retrieve links", "cid", k, "error", err)
+			ok = false
+			if !c.emit(ctx, Result{Error: &CannotFetchLinksError{Key: k, Err: err}}) {
+				return false, ctx.Err()
+			}
+			continue
+		}
+		stack = append(stack, links...)
+	}
+	return ok, nil
+}
+
+// links returns the CIDs linked from the given block.
+func (c *collector) links(ctx context.Context, k cid.Cid) ([]cid.Cid, error) {
+	nd, err := helpers.Block(ctx, c.fetcher, cidlink.Link{Cid: k})
+	if err != nil {
+		return nil, err
+	}
+
+	links, err := traversal.SelectLinks(nd)
+	if err != nil {
+		return nil, err
+	}
+
+	cids := make([]cid.Cid, 0, len(links))
+	for _, l := range links {
+		cl, ok := l.(cidlink.Link)
+		if !ok {
+			return nil, fmt.Errorf("unsupported link type %T", l)
+		}
+		cids = append(cids, cl.Cid)
+	}
+	return cids, nil
+}
+
+// candidates returns the keys of the blocks in the blockstore that are not
+// marked.
+func (c *collector) candidates(ctx context.Context) ([]cid.Cid, error) {
+	keys, err := c.bs.AllKeysChan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []cid.Cid
+	for k := range keys {
+		if !c.marked.Has(toRawCid(k)) {
+			candidates = append(candidates, k)
+		}
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	c.progress.Candidates = len(candidates)
+	return candidates, nil
+}
+
+// sweep removes the given candidates that are still not marked. It returns
+// true if the garbage collection must stop because it was cancelled or one
+// of the budgets was exhausted.
+func (c *collector) sweep(ctx context.Context, candidates []cid.Cid, deleteErrors *bool) bool {
+	for _, k := range candidates {
+		if ctx.Err() != nil || c.budgetExhausted() {
+			return true
+		}
+		c.progress.Swept++
+
+		if c.marked.Has(toRawCid(k)) {
+			continue
+		}
+
+		size, err := c.bs.GetSize(ctx, k)
+		if ipld.IsNotFound(err) {
+			// Already removed by someone else.
+			continue
+		}
+
+		if !c.opts.dryRun {
+			if err := c.bs.DeleteBlock(ctx, k); err != nil {
+				*deleteErrors = true
+				if !c.emit(ctx, Result{Error: &CannotDeleteBlockError{Key: k, Err: err}}) {
+					return true
+				}
+				// continue as error is non-fatal
+				continue
+			}
+		}
+
+		c.progress.Removed++
+		if size > 0 {
+			c.progress.RemovedBytes += uint64(size)
+		}
+		if !c.emit(ctx, Result{KeyRemoved: k}) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *collector) budgetExhausted() bool {
+	if c.opts.maxBytes > 0 && c.progress.RemovedBytes >= c.opts.maxBytes {
+		return true
+	}
+	if c.opts.maxDuration > 0 && time.Since(c.start) >= c.opts.maxDuration {
+		return true
+	}
+	return false
+}
+
+func (c *collector) emit(ctx context.Context, res Result) bool {
+	select {
+	case c.output <- res:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (c *collector) sendProgress() {
+	if c.opts.progress == nil {
+		return
+	}
+	select {
+	case c.opts.progress <- c.progress:
+	default:
+	}
+}
+
+// collectKeys reads all the CIDs from a pinner channel.
+func collectKeys(ch <-chan pin.StreamedCid) ([]cid.Cid, error) {
+	var keys []cid.Cid
+	for sc := range ch {
+		if sc.Err != nil {
+			return nil, sc.Err
+		}
+		keys = append(keys, sc.C)
+	}
+	return keys, nil
+}
+
+func toCidV1(c cid.Cid) cid.Cid {
+	if c.Version() == 0 {
+		return cid.NewCidV1(c.Type(), c.Hash())
+	}
+	return c
+}
+
+func toRawCid(c cid.Cid) cid.Cid {
+	return cid.NewCidV1(cid.Raw, c.Hash())
+}
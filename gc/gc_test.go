@@ -0,0 +1,195 @@
+package gc
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ipfs/boxo/blockservice"
+	"github.com/ipfs/boxo/blockstore"
+	offline "github.com/ipfs/boxo/exchange/offline"
+	bsfetcher "github.com/ipfs/boxo/fetcher/impl/blockservice"
+	"github.com/ipfs/boxo/ipld/merkledag"
+	"github.com/ipfs/boxo/pinning/pinner/dspinner"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testEnv struct {
+	bs      blockstore.GCBlockstore
+	gc      func(ctx context.Context, opts ...Option) <-chan Result
+	pinned  []cid.Cid
+	garbage []cid.Cid
+	// root of an unpinned DAG
+	unpinned cid.Cid
+}
+
+// newTestEnv creates a blockstore with:
+//
+//   - a recursively pinned DAG: root -> child -> raw leaf
+//   - a directly pinned node with a child, which is garbage
+//   - an unpinned DAG: root -> child, which is garbage
+func newTestEnv(t *testing.T) *testEnv {
+	ctx := context.Background()
+
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	bs := blockstore.NewGCBlockstore(blockstore.NewBlockstore(ds), blockstore.NewGCLocker())
+	bsrv := blockservice.New(bs, offline.Exchange(bs))
+	dserv := merkledag.NewDAGService(bsrv)
+
+	pinner, err := dspinner.New(ctx, ds, dserv)
+	require.NoError(t, err)
+
+	add := func(nd ipld.Node) ipld.Node {
+		require.NoError(t, dserv.Add(ctx, nd))
+		return nd
+	}
+	link := func(parent *merkledag.ProtoNode, child ipld.Node) *merkledag.ProtoNode {
+		require.NoError(t, parent.AddNodeLink("child", child))
+		return parent
+	}
+
+	leaf := add(merkledag.NewRawNode([]byte("pinned leaf")))
+	child := add(link(merkledag.NodeWithData([]byte("pinned child")), leaf))
+	root := add(link(merkledag.NodeWithData([]byte("pinned root")), child))
+	_, err = pinner.Pin(ctx, root, true, "", nil)
+	require.NoError(t, err)
+
+	directChild := add(merkledag.NodeWithData([]byte("direct child")))
+	direct := add(link(merkledag.NodeWithData([]byte("direct")), directChild))
+	_, err = pinner.Pin(ctx, direct, false, "", nil)
+	require.NoError(t, err)
+
+	unpinnedChild := add(merkledag.NodeWithData([]byte("unpinned child")))
+	unpinned := add(link(merkledag.NodeWithData([]byte("unpinned root")), unpinnedChild))
+
+	require.NoError(t, pinner.Flush(ctx))
+
+	fetcherFactory := bsfetcher.NewFetcherConfig(bsrv)
+
+	return &testEnv{
+		bs: bs,
+		gc: func(ctx context.Context, opts ...Option) <-chan Result {
+			return GC(ctx, bs, pinner, fetcherFactory, opts...)
+		},
+		pinned:   []cid.Cid{leaf.Cid(), child.Cid(), root.Cid(), direct.Cid()},
+		garbage:  []cid.Cid{directChild.Cid(), unpinnedChild.Cid(), unpinned.Cid()},
+		unpinned: unpinned.Cid(),
+	}
+}
+
+func collect(results <-chan Result) ([]cid.Cid, []error) {
+	var (
+		removed []cid.Cid
+		errs    []error
+	)
+	for res := range results {
+		if res.Error != nil {
+			errs = append(errs, res.Error)
+			continue
+		}
+		removed = append(removed, res.KeyRemoved)
+	}
+	return removed, errs
+}
+
+func (env *testEnv) requireHas(t *testing.T, keys []cid.Cid, expected bool) {
+	for _, k := range keys {
+		has, err := env.bs.Has(context.Background(), k)
+		require.NoError(t, err)
+		require.Equal(t, expected, has, "block %s", k)
+	}
+}
+
+func rawCids(keys []cid.Cid) []cid.Cid {
+	out := make([]cid.Cid, len(keys))
+	for i, k := range keys {
+		out[i] = toRawCid(k)
+	}
+	return out
+}
+
+func TestGC(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("removes unpinned blocks", func(t *testing.T) {
+		env := newTestEnv(t)
+		removed, errs := collect(env.gc(ctx))
+		require.Empty(t, errs)
+		assert.ElementsMatch(t, rawCids(env.garbage), removed)
+		env.requireHas(t, env.pinned, true)
+		env.requireHas(t, env.garbage, false)
+	})
+
+	t.Run("dry run does not remove anything", func(t *testing.T) {
+		env := newTestEnv(t)
+		removed, errs := collect(env.gc(ctx, WithDryRun()))
+		require.Empty(t, errs)
+		assert.ElementsMatch(t, rawCids(env.garbage), removed)
+		env.requireHas(t, env.pinned, true)
+		env.requireHas(t, env.garbage, true)
+	})
+
+	t.Run("batches give the same result", func(t *testing.T) {
+		env := newTestEnv(t)
+		progress := make(chan Progress, 16)
+		removed, errs := collect(env.gc(ctx, WithBatchSize(1), WithProgress(progress)))
+		require.Empty(t, errs)
+		assert.ElementsMatch(t, rawCids(env.garbage), removed)
+		env.requireHas(t, env.garbage, false)
+
+		// One update after marking, and one after each batch.
+		close(progress)
+		var last Progress
+		var updates int
+		for p := range progress {
+			last = p
+			updates++
+		}
+		assert.Equal(t, 1+len(env.garbage), updates)
+		assert.Equal(t, len(env.garbage), last.Candidates)
+		assert.Equal(t, len(env.garbage), last.Removed)
+		assert.NotZero(t, last.RemovedBytes)
+		assert.Equal(t, len(env.pinned), last.Marked)
+	})
+
+	t.Run("size budget stops the collection", func(t *testing.T) {
+		env := newTestEnv(t)
+		removed, errs := collect(env.gc(ctx, WithSizeBudget(1)))
+		require.Empty(t, errs)
+		assert.Len(t, removed, 1)
+	})
+
+	t.Run("roots are kept", func(t *testing.T) {
+		env := newTestEnv(t)
+		removed, errs := collect(env.gc(ctx, WithRoots(StaticRoots(env.unpinned))))
+		require.Empty(t, errs)
+		assert.Equal(t, rawCids(env.garbage[:1]), removed)
+	})
+
+	t.Run("missing best-effort roots are ignored", func(t *testing.T) {
+		env := newTestEnv(t)
+		missing := merkledag.NodeWithData([]byte("missing")).Cid()
+		removed, errs := collect(env.gc(ctx, WithBestEffortRoots(StaticRoots(missing))))
+		require.Empty(t, errs)
+		assert.ElementsMatch(t, rawCids(env.garbage), removed)
+	})
+
+	t.Run("missing roots abort the collection", func(t *testing.T) {
+		env := newTestEnv(t)
+		missing := merkledag.NodeWithData([]byte("missing")).Cid()
+		removed, errs := collect(env.gc(ctx, WithRoots(StaticRoots(missing))))
+		assert.Empty(t, removed)
+		require.Len(t, errs, 2)
+
+		var linksErr *CannotFetchLinksError
+		require.True(t, errors.As(errs[0], &linksErr))
+		assert.Equal(t, missing, linksErr.Key)
+		assert.ErrorIs(t, errs[1], ErrCannotFetchAllLinks)
+		env.requireHas(t, env.garbage, true)
+	})
+}
@@ -73,6 +73,7 @@ require (
 	golang.org/x/oauth2 v0.8.0
 	golang.org/x/sync v0.4.0
 	golang.org/x/sys v0.13.0
+	golang.org/x/time v0.3.0
 	google.golang.org/protobuf v1.31.0
 )
 
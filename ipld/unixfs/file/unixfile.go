@@ -3,6 +3,8 @@ package unixfile
 import (
 	"context"
 	"errors"
+	"os"
+	"time"
 
 	ft "github.com/ipfs/boxo/ipld/unixfs"
 	uio "github.com/ipfs/boxo/ipld/unixfs/io"
@@ -21,6 +23,8 @@ type ufsDirectory struct {
 	dserv ipld.DAGService
 	dir   uio.Directory
 	size  int64
+	mode  os.FileMode
+	mtime time.Time
 }
 
 type ufsIterator struct {
@@ -122,15 +126,33 @@ func (d *ufsDirectory) Size() (int64, error) {
 	return d.size, nil
 }
 
+func (d *ufsDirectory) Mode() os.FileMode {
+	return d.mode
+}
+
+func (d *ufsDirectory) ModTime() time.Time {
+	return d.mtime
+}
+
 type ufsFile struct {
 	uio.DagReader
+	mode  os.FileMode
+	mtime time.Time
 }
 
 func (f *ufsFile) Size() (int64, error) {
 	return int64(f.DagReader.Size()), nil
 }
 
-func newUnixfsDir(ctx context.Context, dserv ipld.DAGService, nd *dag.ProtoNode) (files.Directory, error) {
+func (f *ufsFile) Mode() os.FileMode {
+	return f.mode
+}
+
+func (f *ufsFile) ModTime() time.Time {
+	return f.mtime
+}
+
+func newUnixfsDir(ctx context.Context, dserv ipld.DAGService, nd *dag.ProtoNode, fsn *ft.FSNode) (files.Directory, error) {
 	dir, err := uio.NewDirectoryFromNode(dserv, nd)
 	if err != nil {
 		return nil, err
@@ -145,12 +167,19 @@ func newUnixfsDir(ctx context.Context, dserv ipld.DAGService, nd *dag.ProtoNode)
 		ctx:   ctx,
 		dserv: dserv,
 
-		dir:  dir,
-		size: int64(size),
+		dir:   dir,
+		size:  int64(size),
+		mode:  fsn.Mode(),
+		mtime: fsn.ModTime(),
 	}, nil
 }
 
 func NewUnixfsFile(ctx context.Context, dserv ipld.DAGService, nd ipld.Node) (files.Node, error) {
+	var (
+		mode  os.FileMode
+		mtime time.Time
+	)
+
 	switch dn := nd.(type) {
 	case *dag.ProtoNode:
 		fsn, err := ft.FSNodeFromBytes(dn.Data())
@@ -158,11 +187,12 @@ func NewUnixfsFile(ctx context.Context, dserv ipld.DAGService, nd ipld.Node) (fi
 			return nil, err
 		}
 		if fsn.IsDir() {
-			return newUnixfsDir(ctx, dserv, dn)
+			return newUnixfsDir(ctx, dserv, dn, fsn)
 		}
 		if fsn.Type() == ft.TSymlink {
-			return files.NewLinkFile(string(fsn.Data()), nil), nil
+			return files.NewSymlinkFile(string(fsn.Data()), fsn.ModTime()), nil
 		}
+		mode, mtime = fsn.Mode(), fsn.ModTime()
 
 	case *dag.RawNode:
 	default:
@@ -176,6 +206,8 @@ func NewUnixfsFile(ctx context.Context, dserv ipld.DAGService, nd ipld.Node) (fi
 
 	return &ufsFile{
 		DagReader: dr,
+		mode:      mode,
+		mtime:     mtime,
 	}, nil
 }
 
@@ -0,0 +1,365 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	gopath "path"
+	"strings"
+	"time"
+
+	chunker "github.com/ipfs/boxo/chunker"
+	"github.com/ipfs/boxo/files"
+	dag "github.com/ipfs/boxo/ipld/merkledag"
+	ft "github.com/ipfs/boxo/ipld/unixfs"
+	bal "github.com/ipfs/boxo/ipld/unixfs/importer/balanced"
+	h "github.com/ipfs/boxo/ipld/unixfs/importer/helpers"
+	trickle "github.com/ipfs/boxo/ipld/unixfs/importer/trickle"
+	uio "github.com/ipfs/boxo/ipld/unixfs/io"
+	cid "github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	mh "github.com/multiformats/go-multihash"
+)
+
+// ErrWrapWithoutName is returned by Add when asked to wrap a node without a
+// name to give to its link.
+var ErrWrapWithoutName = errors.New("cannot wrap a node without a name")
+
+// AddParams configures how Add imports files, directories and symlinks.
+type AddParams struct {
+	// DAGService to write the nodes to (required)
+	Dagserv ipld.DAGService
+
+	// Chunker is the splitter of the files, in the format accepted by
+	// chunker.FromString. The default splitter is used if empty.
+	Chunker string
+
+	// Trickle selects the trickle layout for the files instead of the
+	// balanced one.
+	Trickle bool
+
+	// RawLeaves signifies that the files data should be stored in raw
+	// leaves instead of UnixFS nodes.
+	RawLeaves bool
+
+	// CidVersion is the version of the CIDs, 0 or 1. Version 1 is used
+	// anyway when HashFunction is not sha2-256.
+	CidVersion int
+
+	// HashFunction is the multihash code of the hash function, sha2-256
+	// if zero.
+	HashFunction uint64
+
+	// Maxlinks is the maximum number of links of the intermediate nodes
+	// of the files, DefaultLinksPerBlock if zero.
+	Maxlinks int
+
+	// Concurrency is passed to DagBuilderParams.Concurrency.
+	Concurrency int
+
+	// PreserveMode stores the permissions of the files, directories and
+	// symlinks, as returned by their Mode, in their root nodes.
+	PreserveMode bool
+
+	// PreserveMtime stores the modification time of the files, directories
+	// and symlinks, as returned by their ModTime, in their root nodes.
+	PreserveMtime bool
+
+	// Filter, if set, excludes the hidden entries and the entries whose path
+	// matches its rules. The paths are relative to the added node, and end
+	// with a slash for directories.
+	Filter *files.Filter
+
+	// Wrap adds the node to a directory, under its name, and returns the
+	// directory.
+	Wrap bool
+
+	// Progress, if set, is called as the files are read with their path and
+	// how many bytes were read from them so far.
+	Progress func(path string, bytes int64)
+
+	// Added, if set, is called with the path and the root node of every
+	// file, symlink and directory once it is stored.
+	Added func(path string, nd ipld.Node)
+}
+
+// Add imports the file, directory or symlink nd, and all the entries of
+// directories, in params.Dagserv, and returns the root node of the DAG.
+// Directories are sharded with a HAMT as needed, see uio.NewDirectory.
+//
+// name is the name of nd, the root of the paths given to the callbacks and
+// the name of the link to nd when wrapping it. It may only be empty when not
+// wrapping.
+func Add(ctx context.Context, name string, nd files.Node, params AddParams) (ipld.Node, error) {
+	if params.Wrap && name == "" {
+		return nil, ErrWrapWithoutName
+	}
+
+	hashFunction := params.HashFunction
+	if hashFunction == 0 {
+		hashFunction = mh.SHA2_256
+	}
+	cidVersion := params.CidVersion
+	if hashFunction != mh.SHA2_256 {
+		cidVersion = 1
+	}
+	prefix, err := dag.PrefixForCidVersion(cidVersion)
+	if err != nil {
+		return nil, err
+	}
+	prefix.MhType = hashFunction
+	prefix.MhLength = -1
+
+	maxlinks := params.Maxlinks
+	if maxlinks == 0 {
+		maxlinks = h.DefaultLinksPerBlock
+	}
+
+	a := &adder{
+		ctx:      ctx,
+		params:   &params,
+		name:     name,
+		prefix:   prefix,
+		maxlinks: maxlinks,
+	}
+
+	root, err := a.add("", nd)
+	if err != nil {
+		return nil, err
+	}
+	if !params.Wrap {
+		return root, nil
+	}
+
+	dir := a.newDirectory()
+	if err := dir.AddChild(ctx, name, root); err != nil {
+		return nil, err
+	}
+	return a.storeDirectory("", dir, nil)
+}
+
+type adder struct {
+	ctx      context.Context
+	params   *AddParams
+	name     string
+	prefix   cid.Prefix
+	maxlinks int
+}
+
+// add imports nd, at rel relative to the added node.
+func (a *adder) add(rel string, nd files.Node) (ipld.Node, error) {
+	if err := a.ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	switch nd := nd.(type) {
+	case *files.Symlink:
+		return a.addSymlink(rel, nd)
+	case files.File:
+		return a.addFile(rel, nd)
+	case files.Directory:
+		return a.addDirectory(rel, nd)
+	default:
+		return nil, fmt.Errorf("unsupported file type %T at %q", nd, a.path(rel))
+	}
+}
+
+func (a *adder) addFile(rel string, f files.File) (ipld.Node, error) {
+	defer f.Close()
+
+	var r io.Reader = f
+	if a.params.Progress != nil {
+		r = &progressReader{r: f, path: a.path(rel), progress: a.params.Progress}
+	}
+	spl, err := chunker.FromString(r, a.params.Chunker)
+	if err != nil {
+		return nil, err
+	}
+
+	dbp := h.DagBuilderParams{
+		Dagserv:     a.params.Dagserv,
+		Maxlinks:    a.maxlinks,
+		RawLeaves:   a.params.RawLeaves,
+		CidBuilder:  a.prefix,
+		Concurrency: a.params.Concurrency,
+	}
+	dbp.FileMode, dbp.FileModTime = a.stat(f)
+	db, err := dbp.New(spl)
+	if err != nil {
+		return nil, err
+	}
+
+	var nd ipld.Node
+	if a.params.Trickle {
+		nd, err = trickle.Layout(db)
+	} else {
+		nd, err = bal.Layout(db)
+	}
+	if err != nil {
+		return nil, err
+	}
+	a.added(rel, nd)
+	return nd, nil
+}
+
+func (a *adder) addSymlink(rel string, l *files.Symlink) (ipld.Node, error) {
+	data, err := ft.SymlinkData(l.Target)
+	if err != nil {
+		return nil, err
+	}
+	nd := dag.NodeWithData(data)
+	if err := nd.SetCidBuilder(a.prefix); err != nil {
+		return nil, err
+	}
+	if err := a.setStat(nd, l); err != nil {
+		return nil, err
+	}
+	if err := a.params.Dagserv.Add(a.ctx, nd); err != nil {
+		return nil, err
+	}
+	a.added(rel, nd)
+	return nd, nil
+}
+
+func (a *adder) addDirectory(rel string, d files.Directory) (ipld.Node, error) {
+	defer d.Close()
+
+	dir := a.newDirectory()
+	it := d.Entries()
+	for it.Next() {
+		childRel := gopath.Join(rel, it.Name())
+		if a.excluded(childRel, it.Node()) {
+			it.Node().Close()
+			continue
+		}
+
+		child, err := a.add(childRel, it.Node())
+		if err != nil {
+			return nil, err
+		}
+		if err := dir.AddChild(a.ctx, it.Name(), child); err != nil {
+			return nil, err
+		}
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+
+	return a.storeDirectory(rel, dir, d)
+}
+
+func (a *adder) newDirectory() uio.Directory {
+	dir := uio.NewDirectory(a.params.Dagserv)
+	dir.SetCidBuilder(a.prefix)
+	return dir
+}
+
+// storeDirectory adds the root node of dir, with the mode and modification
+// time of d if set, to the DAGService. The shards of HAMT directories are
+// stored by GetNode.
+func (a *adder) storeDirectory(rel string, dir uio.Directory, d files.Directory) (ipld.Node, error) {
+	nd, err := dir.GetNode()
+	if err != nil {
+		return nil, err
+	}
+	if d != nil {
+		pbnd, ok := nd.(*dag.ProtoNode)
+		if !ok {
+			return nil, dag.ErrNotProtobuf
+		}
+		pbnd = pbnd.Copy().(*dag.ProtoNode)
+		if err := a.setStat(pbnd, d); err != nil {
+			return nil, err
+		}
+		nd = pbnd
+	}
+	if err := a.params.Dagserv.Add(a.ctx, nd); err != nil {
+		return nil, err
+	}
+	a.added(rel, nd)
+	return nd, nil
+}
+
+// stat returns the mode and modification time of n to store, depending on
+// PreserveMode and PreserveMtime.
+func (a *adder) stat(n files.Node) (mode os.FileMode, mtime time.Time) {
+	if a.params.PreserveMode {
+		mode = n.Mode()
+	}
+	if a.params.PreserveMtime {
+		mtime = n.ModTime()
+	}
+	return mode, mtime
+}
+
+// setStat stores the mode and modification time of n in the UnixFS data of
+// nd, depending on PreserveMode and PreserveMtime.
+func (a *adder) setStat(nd *dag.ProtoNode, n files.Node) error {
+	mode, mtime := a.stat(n)
+	if mode == 0 && mtime.IsZero() {
+		return nil
+	}
+	fsn, err := ft.FSNodeFromBytes(nd.Data())
+	if err != nil {
+		return err
+	}
+	if mode != 0 {
+		fsn.SetMode(mode)
+	}
+	if !mtime.IsZero() {
+		fsn.SetModTime(mtime)
+	}
+	data, err := fsn.GetBytes()
+	if err != nil {
+		return err
+	}
+	nd.SetData(data)
+	return nil
+}
+
+// excluded returns whether the entry nd at rel is filtered out.
+func (a *adder) excluded(rel string, nd files.Node) bool {
+	filter := a.params.Filter
+	if filter == nil {
+		return false
+	}
+	if !filter.IncludeHidden && strings.HasPrefix(gopath.Base(rel), ".") {
+		return true
+	}
+	if filter.Rules == nil {
+		return false
+	}
+	if _, ok := nd.(files.Directory); ok {
+		rel += "/"
+	}
+	return filter.Rules.MatchesPath(rel)
+}
+
+func (a *adder) path(rel string) string {
+	return gopath.Join(a.name, rel)
+}
+
+func (a *adder) added(rel string, nd ipld.Node) {
+	if a.params.Added != nil {
+		a.params.Added(a.path(rel), nd)
+	}
+}
+
+// progressReader reports the bytes read from a file.
+type progressReader struct {
+	r        io.Reader
+	path     string
+	progress func(path string, bytes int64)
+	read     int64
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.read += int64(n)
+		r.progress(r.path, r.read)
+	}
+	return n, err
+}
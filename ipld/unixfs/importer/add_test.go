@@ -0,0 +1,300 @@
+package importer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	chunker "github.com/ipfs/boxo/chunker"
+	"github.com/ipfs/boxo/files"
+	dag "github.com/ipfs/boxo/ipld/merkledag"
+	mdtest "github.com/ipfs/boxo/ipld/merkledag/test"
+	ft "github.com/ipfs/boxo/ipld/unixfs"
+	uio "github.com/ipfs/boxo/ipld/unixfs/io"
+	u "github.com/ipfs/boxo/util"
+	cid "github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	mh "github.com/multiformats/go-multihash"
+)
+
+func randomData(size int) []byte {
+	buf := make([]byte, size)
+	u.NewTimeSeededRand().Read(buf)
+	return buf
+}
+
+func readFile(t *testing.T, ds ipld.DAGService, nd ipld.Node) []byte {
+	dr, err := uio.NewDagReader(context.Background(), nd, ds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := io.ReadAll(dr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func findChild(t *testing.T, ds ipld.DAGService, dirNode ipld.Node, name string) ipld.Node {
+	dir, err := uio.NewDirectoryFromNode(ds, dirNode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nd, err := dir.Find(context.Background(), name)
+	if err != nil {
+		t.Fatalf("finding %q: %s", name, err)
+	}
+	return nd
+}
+
+func TestAdd(t *testing.T) {
+	ds := mdtest.Mock()
+	a := randomData(300 * 1024)
+	b := randomData(1000)
+
+	tree := files.NewMapDirectory(map[string]files.Node{
+		"a.txt": files.NewBytesFile(a),
+		"sub": files.NewMapDirectory(map[string]files.Node{
+			"b.txt":   files.NewBytesFile(b),
+			".hidden": files.NewBytesFile([]byte("hidden")),
+		}),
+		"link":        files.NewSymlinkFile("sub/b.txt", time.Time{}),
+		"ignored.log": files.NewBytesFile([]byte("ignored")),
+		"logs":        files.NewMapDirectory(map[string]files.Node{}),
+	})
+	filter, err := files.NewFilter("", []string{"*.log", "logs/"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	added := make(map[string]cid.Cid)
+	progress := make(map[string]int64)
+	root, err := Add(context.Background(), "root", tree, AddParams{
+		Dagserv: ds,
+		Filter:  filter,
+		Progress: func(path string, bytes int64) {
+			progress[path] = bytes
+		},
+		Added: func(path string, nd ipld.Node) {
+			added[path] = nd.Cid()
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	links := root.Links()
+	if len(links) != 3 {
+		t.Fatalf("expected 3 links, got %d", len(links))
+	}
+	for _, name := range []string{"ignored.log", "logs"} {
+		if _, _, err := root.ResolveLink([]string{name}); err == nil {
+			t.Fatalf("%q should have been filtered out", name)
+		}
+	}
+
+	// Files are imported like with BuildDagFromReader
+	expected, err := BuildDagFromReader(mdtest.Mock(), chunker.DefaultSplitter(bytes.NewReader(a)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	aNode := findChild(t, ds, root, "a.txt")
+	if !aNode.Cid().Equals(expected.Cid()) {
+		t.Fatalf("expected a.txt CID %s, got %s", expected.Cid(), aNode.Cid())
+	}
+	if !bytes.Equal(readFile(t, ds, aNode), a) {
+		t.Fatal("bad a.txt content")
+	}
+
+	sub := findChild(t, ds, root, "sub")
+	if len(sub.Links()) != 1 {
+		t.Fatalf("expected hidden file to be filtered out, got %d links", len(sub.Links()))
+	}
+	if !bytes.Equal(readFile(t, ds, findChild(t, ds, sub, "b.txt")), b) {
+		t.Fatal("bad sub/b.txt content")
+	}
+
+	fsn, err := ft.ExtractFSNode(findChild(t, ds, root, "link"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fsn.Type() != ft.TSymlink || string(fsn.Data()) != "sub/b.txt" {
+		t.Fatalf("bad symlink: type %s, target %q", fsn.Type(), fsn.Data())
+	}
+
+	for _, path := range []string{"root", "root/a.txt", "root/sub", "root/sub/b.txt", "root/link"} {
+		if _, ok := added[path]; !ok {
+			t.Fatalf("no Added call for %q", path)
+		}
+	}
+	if len(added) != 5 {
+		t.Fatalf("expected 5 Added calls, got %v", added)
+	}
+	if !added["root"].Equals(root.Cid()) {
+		t.Fatal("bad Added call for the root")
+	}
+	if progress["root/a.txt"] != int64(len(a)) || progress["root/sub/b.txt"] != int64(len(b)) {
+		t.Fatalf("bad progress: %v", progress)
+	}
+}
+
+func TestAddWrap(t *testing.T) {
+	ds := mdtest.Mock()
+	data := randomData(1000)
+
+	_, err := Add(context.Background(), "", files.NewBytesFile(data), AddParams{Dagserv: ds, Wrap: true})
+	if err != ErrWrapWithoutName {
+		t.Fatalf("expected ErrWrapWithoutName, got %v", err)
+	}
+
+	root, err := Add(context.Background(), "file.bin", files.NewBytesFile(data), AddParams{Dagserv: ds, Wrap: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(readFile(t, ds, findChild(t, ds, root, "file.bin")), data) {
+		t.Fatal("bad wrapped file content")
+	}
+}
+
+func TestAddHAMT(t *testing.T) {
+	oldShardingSize := uio.HAMTShardingSize
+	uio.HAMTShardingSize = 1024
+	defer func() { uio.HAMTShardingSize = oldShardingSize }()
+
+	ds := mdtest.Mock()
+	entries := make(map[string]files.Node)
+	for i := 0; i < 200; i++ {
+		entries[fmt.Sprintf("file-%d", i)] = files.NewBytesFile([]byte(fmt.Sprint(i)))
+	}
+
+	root, err := Add(context.Background(), "dir", files.NewMapDirectory(entries), AddParams{Dagserv: ds})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsn, err := ft.ExtractFSNode(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fsn.Type() != ft.THAMTShard {
+		t.Fatalf("expected a HAMT shard, got %s", fsn.Type())
+	}
+
+	// Reading the shards back from the DAGService
+	if !bytes.Equal(readFile(t, ds, findChild(t, ds, root, "file-123")), []byte("123")) {
+		t.Fatal("bad file content")
+	}
+	dir, err := uio.NewDirectoryFromNode(ds, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	links, err := dir.Links(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(links) != len(entries) {
+		t.Fatalf("expected %d links, got %d", len(entries), len(links))
+	}
+}
+
+func TestAddCidOptions(t *testing.T) {
+	tree := func() files.Node {
+		return files.NewMapDirectory(map[string]files.Node{
+			"file": files.NewBytesFile(randomData(600 * 1024)),
+		})
+	}
+
+	for _, tc := range []struct {
+		params      AddParams
+		version     uint64
+		mhType      uint64
+		leafCodec   uint64
+		description string
+	}{
+		{AddParams{}, 0, mh.SHA2_256, cid.DagProtobuf, "defaults"},
+		{AddParams{RawLeaves: true, CidVersion: 1}, 1, mh.SHA2_256, cid.Raw, "CIDv1 with raw leaves"},
+		{AddParams{HashFunction: mh.BLAKE2B_MIN + 31}, 1, mh.BLAKE2B_MIN + 31, cid.DagProtobuf, "blake2b-256"},
+		{AddParams{Trickle: true, RawLeaves: true}, 0, mh.SHA2_256, cid.Raw, "trickle with raw leaves"},
+	} {
+		ds := mdtest.Mock()
+		tc.params.Dagserv = ds
+		root, err := Add(context.Background(), "dir", tree(), tc.params)
+		if err != nil {
+			t.Fatalf("%s: %s", tc.description, err)
+		}
+
+		prefix := root.Cid().Prefix()
+		if prefix.Version != tc.version || prefix.MhType != tc.mhType {
+			t.Fatalf("%s: bad root CID %s", tc.description, root.Cid())
+		}
+		file := findChild(t, ds, root, "file")
+		leaf, err := file.Links()[0].GetNode(context.Background(), ds)
+		if err != nil {
+			t.Fatal(err)
+		}
+		leafPrefix := leaf.Cid().Prefix()
+		if leafPrefix.Codec != tc.leafCodec || leafPrefix.MhType != tc.mhType {
+			t.Fatalf("%s: bad leaf CID %s", tc.description, leaf.Cid())
+		}
+		if _, ok := file.(*dag.ProtoNode); !ok {
+			t.Fatalf("%s: expected a dag-pb file root", tc.description)
+		}
+	}
+}
+
+func TestAddPreserveModeAndMtime(t *testing.T) {
+	mtime := time.Unix(1700000000, 42)
+	tree := func() files.Node {
+		return files.NewSliceDirectoryWithMeta([]files.DirEntry{
+			files.FileEntry("file", files.NewBytesFileWithMeta([]byte("data"), 0o640, mtime)),
+			files.FileEntry("link", files.NewSymlinkFile("file", mtime)),
+		}, os.ModeDir|0o750, mtime)
+	}
+	stat := func(t *testing.T, nd ipld.Node) *ft.FSNode {
+		fsn, err := ft.ExtractFSNode(nd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fsn
+	}
+
+	ds := mdtest.Mock()
+	root, err := Add(context.Background(), "dir", tree(), AddParams{Dagserv: ds, PreserveMode: true, PreserveMtime: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		nd   ipld.Node
+		mode os.FileMode
+	}{
+		{root, os.ModeDir | 0o750},
+		{findChild(t, ds, root, "file"), 0o640},
+		{findChild(t, ds, root, "link"), 0},
+	} {
+		fsn := stat(t, tc.nd)
+		if fsn.Mode() != tc.mode {
+			t.Fatalf("expected mode %s, got %s", tc.mode, fsn.Mode())
+		}
+		if !fsn.ModTime().Equal(mtime) {
+			t.Fatalf("expected mtime %s, got %s", mtime, fsn.ModTime())
+		}
+	}
+	if !bytes.Equal(readFile(t, ds, findChild(t, ds, root, "file")), []byte("data")) {
+		t.Fatal("bad file content")
+	}
+
+	// Mode and modification times are not stored by default.
+	root, err = Add(context.Background(), "dir", tree(), AddParams{Dagserv: ds})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, nd := range []ipld.Node{root, findChild(t, ds, root, "file")} {
+		fsn := stat(t, nd)
+		if fsn.Mode() != 0 || !fsn.ModTime().IsZero() {
+			t.Fatal("expected no mode nor mtime")
+		}
+	}
+}
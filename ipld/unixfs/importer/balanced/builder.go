@@ -129,7 +129,13 @@ import (
 //	  +=========+   +=========+   + - - - - +
 //	  | Chunk 1 |   | Chunk 2 |   | Chunk 3 |
 //	  +=========+   +=========+   + - - - - +
-func Layout(db *h.DagBuilderHelper) (ipld.Node, error) {
+func Layout(db *h.DagBuilderHelper) (root ipld.Node, err error) {
+	defer func() {
+		if cerr := db.Close(); err == nil && cerr != nil {
+			root, err = nil, cerr
+		}
+	}()
+
 	if db.Done() {
 		// No data, return just an empty node.
 		root, err := db.NewLeafNode(nil, ft.TFile)
@@ -139,6 +145,11 @@ func Layout(db *h.DagBuilderHelper) (ipld.Node, error) {
 		// This works without Filestore support (`ProcessFileStore`).
 		// TODO: Why? Is there a test case missing?
 
+		root, err = db.ProcessFileAttributes(root, 0)
+		if err != nil {
+			return nil, err
+		}
+
 		return root, db.Add(root)
 	}
 
@@ -172,6 +183,12 @@ func Layout(db *h.DagBuilderHelper) (ipld.Node, error) {
 		}
 	}
 
+	// Store the file attributes (if any) in the final `root`.
+	root, err = db.ProcessFileAttributes(root, fileSize)
+	if err != nil {
+		return nil, err
+	}
+
 	return root, db.Add(root)
 }
 
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"io"
 	"os"
+	"time"
 
 	dag "github.com/ipfs/boxo/ipld/merkledag"
 
@@ -28,6 +29,7 @@ type DagBuilderHelper struct {
 	recvdErr   error
 	rawLeaves  bool
 	nextData   []byte // the next item to return.
+	nextLeaf   *pendingLeaf
 	maxlinks   int
 	cidBuilder cid.Builder
 
@@ -44,6 +46,13 @@ type DagBuilderHelper struct {
 	// is not reused to construct another DAG, but a new one (with a
 	// zero `offset`) is created.
 	offset uint64
+
+	// Optional file attributes (UnixFS 1.5) stored in the root node.
+	fileMode    os.FileMode
+	fileModTime time.Time
+
+	// Set when the leaves are built concurrently.
+	pipe *pipeline
 }
 
 // DagBuilderParams wraps configuration options to create a DagBuilderHelper
@@ -65,17 +74,35 @@ type DagBuilderParams struct {
 	// NoCopy signals to the chunker that it should track fileinfo for
 	// filestore adds
 	NoCopy bool
+
+	// FileMode, if set, is stored as the permissions of the file in the
+	// root node of the DAG.
+	FileMode os.FileMode
+
+	// FileModTime, if set, is stored as the modification time of the file
+	// in the root node of the DAG.
+	FileModTime time.Time
+
+	// Concurrency, if greater than 1, is the number of goroutines that
+	// build and hash the leaves ahead of the layout, while the chunks are
+	// read on another one and the nodes are written to the DAGService in
+	// batches. The resulting DAG is the same as without it. At most
+	// 2*Concurrency chunks are read ahead. The layout must call Close
+	// once the DAG is built.
+	Concurrency int
 }
 
 // New generates a new DagBuilderHelper from the given params and a given
 // chunker.Splitter as data source.
 func (dbp *DagBuilderParams) New(spl chunker.Splitter) (*DagBuilderHelper, error) {
 	db := &DagBuilderHelper{
-		dserv:      dbp.Dagserv,
-		spl:        spl,
-		rawLeaves:  dbp.RawLeaves,
-		cidBuilder: dbp.CidBuilder,
-		maxlinks:   dbp.Maxlinks,
+		dserv:       dbp.Dagserv,
+		spl:         spl,
+		rawLeaves:   dbp.RawLeaves,
+		cidBuilder:  dbp.CidBuilder,
+		maxlinks:    dbp.Maxlinks,
+		fileMode:    dbp.FileMode,
+		fileModTime: dbp.FileModTime,
 	}
 	if fi, ok := spl.Reader().(files.FileInfo); dbp.NoCopy && ok {
 		db.fullPath = fi.AbsPath()
@@ -86,6 +113,10 @@ func (dbp *DagBuilderParams) New(spl chunker.Splitter) (*DagBuilderHelper, error
 		return nil, ErrMissingFsRef
 	}
 
+	if dbp.Concurrency > 1 {
+		db.pipe = newPipeline(db, spl, dbp.Concurrency)
+	}
+
 	return db, nil
 }
 
@@ -98,6 +129,20 @@ func (db *DagBuilderHelper) prepareNext() {
 		return
 	}
 
+	if db.pipe != nil {
+		l, ok := <-db.pipe.leaves
+		if !ok {
+			return
+		}
+		if l.err != nil {
+			db.recvdErr = l.err
+			return
+		}
+		db.nextLeaf = l
+		db.nextData = l.data
+		return
+	}
+
 	db.nextData, db.recvdErr = db.spl.NextBytes()
 	if db.recvdErr == io.EOF {
 		db.recvdErr = nil
@@ -122,6 +167,7 @@ func (db *DagBuilderHelper) Next() ([]byte, error) {
 	db.prepareNext() // idempotent
 	d := db.nextData
 	db.nextData = nil // signal we've consumed it
+	db.nextLeaf = nil
 	if db.recvdErr != nil {
 		return nil, db.recvdErr
 	}
@@ -202,14 +248,30 @@ func (db *DagBuilderHelper) FillNodeLayer(node *FSNodeOverDag) error {
 // after that it will be hidden by `NewLeafNode` inside a generic
 // `ipld.Node` representation.
 func (db *DagBuilderHelper) NewLeafDataNode(fsNodeType pb.Data_DataType) (node ipld.Node, dataSize uint64, err error) {
+	var leaf *pendingLeaf
+	if db.pipe != nil {
+		db.pipe.setLeafType(fsNodeType)
+		db.prepareNext()
+		leaf = db.nextLeaf
+	}
+
 	fileData, err := db.Next()
 	if err != nil {
 		return nil, 0, err
 	}
 	dataSize = uint64(len(fileData))
 
-	// Create a new leaf node containing the file chunk data.
-	node, err = db.NewLeafNode(fileData, fsNodeType)
+	if leaf != nil {
+		// The leaf was built by the pipeline.
+		<-leaf.done
+		node, err = leaf.node, leaf.nodeErr
+		if err == nil && leaf.fsNodeType != fsNodeType {
+			node, err = db.NewLeafNode(fileData, fsNodeType)
+		}
+	} else {
+		// Create a new leaf node containing the file chunk data.
+		node, err = db.NewLeafNode(fileData, fsNodeType)
+	}
 	if err != nil {
 		return nil, 0, err
 	}
@@ -254,11 +316,75 @@ func (db *DagBuilderHelper) ProcessFileStore(node ipld.Node, dataSize uint64) ip
 	return node
 }
 
-// Add inserts the given node in the DAGService.
+// HasFileAttributes returns whether a file mode or modification time
+// should be stored in the root node of the DAG.
+func (db *DagBuilderHelper) HasFileAttributes() bool {
+	return db.fileMode != 0 || !db.fileModTime.IsZero()
+}
+
+// SetFileAttributes stores the configured file mode and modification
+// time (if any) in the `FSNodeOverDag`. It should be called on the root
+// node before it is committed.
+func (db *DagBuilderHelper) SetFileAttributes(n *FSNodeOverDag) {
+	if db.fileMode != 0 {
+		n.file.SetMode(db.fileMode)
+	}
+	if !db.fileModTime.IsZero() {
+		n.file.SetModTime(db.fileModTime)
+	}
+}
+
+// ProcessFileAttributes returns the root `node` of a file DAG with the
+// configured file attributes (if any) stored in it. UnixFS nodes are
+// updated in place, while other nodes (raw leaves and Filestore nodes),
+// that can't hold attributes, are wrapped in a new UnixFS file node
+// (of `fileSize`) with `node` as its only child.
+func (db *DagBuilderHelper) ProcessFileAttributes(node ipld.Node, fileSize uint64) (ipld.Node, error) {
+	if !db.HasFileAttributes() {
+		return node, nil
+	}
+
+	if pbn, ok := node.(*dag.ProtoNode); ok {
+		fsn, err := NewFSNFromDag(pbn)
+		if err != nil {
+			return nil, err
+		}
+		db.SetFileAttributes(fsn)
+		return fsn.Commit()
+	}
+
+	root := db.NewFSNodeOverDag(ft.TFile)
+	if err := root.AddChild(node, fileSize, db); err != nil {
+		return nil, err
+	}
+	db.SetFileAttributes(root)
+	return root.Commit()
+}
+
+// Add inserts the given node in the DAGService. When the leaves are built
+// concurrently the node is batched, and only written for sure once Close
+// returns.
 func (db *DagBuilderHelper) Add(node ipld.Node) error {
+	if db.pipe != nil {
+		return db.pipe.batch.Add(context.TODO(), node)
+	}
 	return db.dserv.Add(context.TODO(), node)
 }
 
+// Close stops the goroutines building the leaves and writes the batched
+// nodes to the DAGService, when DagBuilderParams.Concurrency is set. It does
+// nothing otherwise. The layouts call it once the DAG is built, after which
+// the nodes are added directly to the DAGService.
+func (db *DagBuilderHelper) Close() error {
+	if db.pipe == nil {
+		return nil
+	}
+	err := db.pipe.close()
+	db.pipe = nil
+	db.nextLeaf = nil
+	return err
+}
+
 // Maxlinks returns the configured maximum number for links
 // for nodes built with this helper.
 func (db *DagBuilderHelper) Maxlinks() int {
@@ -0,0 +1,129 @@
+package helpers
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	chunker "github.com/ipfs/boxo/chunker"
+	pb "github.com/ipfs/boxo/ipld/unixfs/pb"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// pipeline reads the chunks of a DagBuilderHelper and builds their leaf
+// nodes ahead of the layout, on several goroutines. The leaves are handed
+// to the layout in the order of the chunks, so the DAG is the same as the
+// one built sequentially.
+type pipeline struct {
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	// leaves holds the chunks read ahead, in order. Its capacity bounds
+	// the memory used by the pipeline.
+	leaves chan *pendingLeaf
+	work   chan *pendingLeaf
+
+	// The leaf type is only known once the layout asks for the first
+	// leaf, until then the workers wait on typeSet.
+	typeOnce sync.Once
+	typeSet  chan struct{}
+	leafType pb.Data_DataType
+
+	// batch buffers the nodes added to the DAGService.
+	batch *ipld.Batch
+}
+
+// pendingLeaf is a chunk whose leaf node may still be being built.
+type pendingLeaf struct {
+	data []byte
+	// err is the error returned by the splitter instead of a chunk.
+	err error
+
+	// done is closed once node and nodeErr are set.
+	done       chan struct{}
+	node       ipld.Node
+	nodeErr    error
+	fsNodeType pb.Data_DataType
+}
+
+func newPipeline(db *DagBuilderHelper, spl chunker.Splitter, workers int) *pipeline {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &pipeline{
+		cancel:  cancel,
+		leaves:  make(chan *pendingLeaf, 2*workers),
+		work:    make(chan *pendingLeaf, workers),
+		typeSet: make(chan struct{}),
+		batch:   ipld.NewBatch(context.Background(), db.dserv),
+	}
+
+	p.wg.Add(1 + workers)
+	go p.chunk(ctx, spl)
+	for i := 0; i < workers; i++ {
+		go p.build(ctx, db)
+	}
+	return p
+}
+
+// chunk reads the splitter until its end or its first error.
+func (p *pipeline) chunk(ctx context.Context, spl chunker.Splitter) {
+	defer p.wg.Done()
+	defer close(p.work)
+	defer close(p.leaves)
+
+	for {
+		data, err := spl.NextBytes()
+		if err == io.EOF {
+			return
+		}
+		l := &pendingLeaf{data: data, err: err, done: make(chan struct{})}
+		select {
+		case p.leaves <- l:
+		case <-ctx.Done():
+			return
+		}
+		if err != nil {
+			return
+		}
+		select {
+		case p.work <- l:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// build creates and hashes the leaf nodes of the chunks.
+func (p *pipeline) build(ctx context.Context, db *DagBuilderHelper) {
+	defer p.wg.Done()
+
+	select {
+	case <-p.typeSet:
+	case <-ctx.Done():
+		return
+	}
+	for l := range p.work {
+		l.fsNodeType = p.leafType
+		l.node, l.nodeErr = db.NewLeafNode(l.data, l.fsNodeType)
+		if l.nodeErr == nil {
+			// Encode and hash the node here rather than in the layout
+			l.node.Cid()
+		}
+		close(l.done)
+	}
+}
+
+// setLeafType starts building the leaves as fsNodeType nodes. Later calls
+// have no effect.
+func (p *pipeline) setLeafType(fsNodeType pb.Data_DataType) {
+	p.typeOnce.Do(func() {
+		p.leafType = fsNodeType
+		close(p.typeSet)
+	})
+}
+
+// close stops the pipeline and writes the nodes left in the batch.
+func (p *pipeline) close() error {
+	p.cancel()
+	p.wg.Wait()
+	return p.batch.Commit()
+}
@@ -3,9 +3,18 @@ package importer
 import (
 	"bytes"
 	"context"
+	"errors"
+	"fmt"
 	"io"
+	"os"
 	"testing"
+	"testing/iotest"
+	"time"
 
+	ft "github.com/ipfs/boxo/ipld/unixfs"
+	bal "github.com/ipfs/boxo/ipld/unixfs/importer/balanced"
+	h "github.com/ipfs/boxo/ipld/unixfs/importer/helpers"
+	trickle "github.com/ipfs/boxo/ipld/unixfs/importer/trickle"
 	uio "github.com/ipfs/boxo/ipld/unixfs/io"
 
 	chunker "github.com/ipfs/boxo/chunker"
@@ -13,6 +22,7 @@ import (
 	u "github.com/ipfs/boxo/util"
 	cid "github.com/ipfs/go-cid"
 	ipld "github.com/ipfs/go-ipld-format"
+	mh "github.com/multiformats/go-multihash"
 )
 
 func getBalancedDag(t testing.TB, size int64, blksize int64) (ipld.Node, ipld.DAGService) {
@@ -150,3 +160,169 @@ func runReadBench(b *testing.B, nd ipld.Node, ds ipld.DAGService) {
 		cancel()
 	}
 }
+
+func TestFileAttributes(t *testing.T) {
+	mode := os.FileMode(0o640)
+	mtime := time.Unix(1638111600, 76552)
+
+	layouts := map[string]func(*h.DagBuilderHelper) (ipld.Node, error){
+		"balanced": bal.Layout,
+		"trickle":  trickle.Layout,
+	}
+	for name, layout := range layouts {
+		for _, size := range []int{0, 100, 10000} {
+			for _, rawLeaves := range []bool{false, true} {
+				ds := mdtest.Mock()
+				buf := make([]byte, size)
+				u.NewTimeSeededRand().Read(buf)
+
+				dbp := h.DagBuilderParams{
+					Dagserv:     ds,
+					Maxlinks:    h.DefaultLinksPerBlock,
+					RawLeaves:   rawLeaves,
+					FileMode:    mode,
+					FileModTime: mtime,
+				}
+				db, err := dbp.New(chunker.NewSizeSplitter(bytes.NewReader(buf), 512))
+				if err != nil {
+					t.Fatal(err)
+				}
+				nd, err := layout(db)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				fsn, err := ft.ExtractFSNode(nd)
+				if err != nil {
+					t.Fatalf("%s (size %d, raw leaves %t): %s", name, size, rawLeaves, err)
+				}
+				if fsn.Mode() != mode {
+					t.Fatalf("%s (size %d, raw leaves %t): expected mode %s, got %s", name, size, rawLeaves, mode, fsn.Mode())
+				}
+				if !fsn.ModTime().Equal(mtime) {
+					t.Fatalf("%s (size %d, raw leaves %t): expected mtime %s, got %s", name, size, rawLeaves, mtime, fsn.ModTime())
+				}
+
+				dr, err := uio.NewDagReader(context.Background(), nd, ds)
+				if err != nil {
+					t.Fatal(err)
+				}
+				out, err := io.ReadAll(dr)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(out, buf) {
+					t.Fatalf("%s (size %d, raw leaves %t): bad read", name, size, rawLeaves)
+				}
+			}
+		}
+	}
+}
+
+func TestConcurrentImport(t *testing.T) {
+	layouts := map[string]func(*h.DagBuilderHelper) (ipld.Node, error){
+		"balanced": bal.Layout,
+		"trickle":  trickle.Layout,
+	}
+	build := func(layout func(*h.DagBuilderHelper) (ipld.Node, error), ds ipld.DAGService, buf []byte, rawLeaves bool, cidBuilder cid.Builder, concurrency int) ipld.Node {
+		dbp := h.DagBuilderParams{
+			Dagserv:     ds,
+			Maxlinks:    h.DefaultLinksPerBlock,
+			RawLeaves:   rawLeaves,
+			CidBuilder:  cidBuilder,
+			Concurrency: concurrency,
+		}
+		db, err := dbp.New(chunker.NewSizeSplitter(bytes.NewReader(buf), 1024))
+		if err != nil {
+			t.Fatal(err)
+		}
+		nd, err := layout(db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return nd
+	}
+
+	for name, layout := range layouts {
+		for _, size := range []int{0, 100, 2 * 1024 * 1024} {
+			for _, rawLeaves := range []bool{false, true} {
+				for _, cidBuilder := range []cid.Builder{nil, cid.V1Builder{Codec: cid.DagProtobuf, MhType: mh.SHA2_256}} {
+					buf := make([]byte, size)
+					u.NewTimeSeededRand().Read(buf)
+
+					expected := build(layout, mdtest.Mock(), buf, rawLeaves, cidBuilder, 0)
+					ds := mdtest.Mock()
+					nd := build(layout, ds, buf, rawLeaves, cidBuilder, 4)
+					if !nd.Cid().Equals(expected.Cid()) {
+						t.Fatalf("%s (size %d, raw leaves %t, builder %v): expected CID %s, got %s", name, size, rawLeaves, cidBuilder, expected.Cid(), nd.Cid())
+					}
+
+					// All the nodes must have been written
+					dr, err := uio.NewDagReader(context.Background(), nd, ds)
+					if err != nil {
+						t.Fatal(err)
+					}
+					out, err := io.ReadAll(dr)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if !bytes.Equal(out, buf) {
+						t.Fatalf("%s (size %d, raw leaves %t, builder %v): bad read", name, size, rawLeaves, cidBuilder)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestConcurrentImportReadError(t *testing.T) {
+	errRead := errors.New("read failed")
+	for name, layout := range map[string]func(*h.DagBuilderHelper) (ipld.Node, error){
+		"balanced": bal.Layout,
+		"trickle":  trickle.Layout,
+	} {
+		buf := make([]byte, 100*1024)
+		u.NewTimeSeededRand().Read(buf)
+		r := io.MultiReader(bytes.NewReader(buf), iotest.ErrReader(errRead))
+
+		dbp := h.DagBuilderParams{
+			Dagserv:     mdtest.Mock(),
+			Maxlinks:    h.DefaultLinksPerBlock,
+			Concurrency: 4,
+		}
+		db, err := dbp.New(chunker.NewSizeSplitter(r, 1000))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := layout(db); !errors.Is(err, errRead) {
+			t.Fatalf("%s: expected read error, got %v", name, err)
+		}
+	}
+}
+
+func BenchmarkBalancedImport(b *testing.B) {
+	nbytes := int64(64 * 1024 * 1024)
+	buf := make([]byte, nbytes)
+	u.NewSeededRand(0xdeadbeef).Read(buf)
+
+	for _, concurrency := range []int{0, 2, 8} {
+		b.Run(fmt.Sprintf("concurrency=%d", concurrency), func(b *testing.B) {
+			b.SetBytes(nbytes)
+			for i := 0; i < b.N; i++ {
+				dbp := h.DagBuilderParams{
+					Dagserv:     mdtest.Mock(),
+					Maxlinks:    h.DefaultLinksPerBlock,
+					RawLeaves:   true,
+					Concurrency: concurrency,
+				}
+				db, err := dbp.New(chunker.DefaultSplitter(bytes.NewReader(buf)))
+				if err != nil {
+					b.Fatal(err)
+				}
+				if _, err := bal.Layout(db); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
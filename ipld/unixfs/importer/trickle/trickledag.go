@@ -36,9 +36,16 @@ const depthRepeat = 4
 // Layout builds a new DAG with the trickle format using the provided
 // DagBuilderHelper. See the module's description for a more detailed
 // explanation.
-func Layout(db *h.DagBuilderHelper) (ipld.Node, error) {
+func Layout(db *h.DagBuilderHelper) (root ipld.Node, err error) {
+	defer func() {
+		if cerr := db.Close(); err == nil && cerr != nil {
+			root, err = nil, cerr
+		}
+	}()
+
 	newRoot := db.NewFSNodeOverDag(ft.TFile)
-	root, _, err := fillTrickleRec(db, newRoot, -1)
+	db.SetFileAttributes(newRoot)
+	root, _, err = fillTrickleRec(db, newRoot, -1)
 	if err != nil {
 		return nil, err
 	}
@@ -88,6 +95,12 @@ func fillTrickleRec(db *h.DagBuilderHelper, node *h.FSNodeOverDag, maxDepth int)
 
 // Append appends the data in `db` to the dag, using the Trickledag format
 func Append(ctx context.Context, basen ipld.Node, db *h.DagBuilderHelper) (out ipld.Node, errOut error) {
+	defer func() {
+		if err := db.Close(); errOut == nil && err != nil {
+			out, errOut = nil, err
+		}
+	}()
+
 	base, ok := basen.(*dag.ProtoNode)
 	if !ok {
 		return nil, dag.ErrNotProtobuf
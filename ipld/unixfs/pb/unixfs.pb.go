@@ -82,6 +82,8 @@ type Data struct {
 	Blocksizes           []uint64       `protobuf:"varint,4,rep,name=blocksizes" json:"blocksizes,omitempty"`
 	HashType             *uint64        `protobuf:"varint,5,opt,name=hashType" json:"hashType,omitempty"`
 	Fanout               *uint64        `protobuf:"varint,6,opt,name=fanout" json:"fanout,omitempty"`
+	Mode                 *uint32        `protobuf:"varint,7,opt,name=mode" json:"mode,omitempty"`
+	Mtime                *UnixTime      `protobuf:"bytes,8,opt,name=mtime" json:"mtime,omitempty"`
 	XXX_NoUnkeyedLiteral struct{}       `json:"-"`
 	XXX_unrecognized     []byte         `json:"-"`
 	XXX_sizecache        int32          `json:"-"`
@@ -158,6 +160,71 @@ func (m *Data) GetFanout() uint64 {
 	return 0
 }
 
+func (m *Data) GetMode() uint32 {
+	if m != nil && m.Mode != nil {
+		return *m.Mode
+	}
+	return 0
+}
+
+func (m *Data) GetMtime() *UnixTime {
+	if m != nil {
+		return m.Mtime
+	}
+	return nil
+}
+
+type UnixTime struct {
+	Seconds               *int64   `protobuf:"varint,1,req,name=Seconds" json:"Seconds,omitempty"`
+	FractionalNanoseconds *uint32  `protobuf:"fixed32,2,opt,name=FractionalNanoseconds" json:"FractionalNanoseconds,omitempty"`
+	XXX_NoUnkeyedLiteral  struct{} `json:"-"`
+	XXX_unrecognized      []byte   `json:"-"`
+	XXX_sizecache         int32    `json:"-"`
+}
+
+func (m *UnixTime) Reset()         { *m = UnixTime{} }
+func (m *UnixTime) String() string { return proto.CompactTextString(m) }
+func (*UnixTime) ProtoMessage()    {}
+func (*UnixTime) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e2fd76cc44dfc7c3, []int{1}
+}
+
+func (m *UnixTime) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_UnixTime.Unmarshal(m, b)
+}
+
+func (m *UnixTime) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_UnixTime.Marshal(b, m, deterministic)
+}
+
+func (m *UnixTime) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_UnixTime.Merge(m, src)
+}
+
+func (m *UnixTime) XXX_Size() int {
+	return xxx_messageInfo_UnixTime.Size(m)
+}
+
+func (m *UnixTime) XXX_DiscardUnknown() {
+	xxx_messageInfo_UnixTime.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_UnixTime proto.InternalMessageInfo
+
+func (m *UnixTime) GetSeconds() int64 {
+	if m != nil && m.Seconds != nil {
+		return *m.Seconds
+	}
+	return 0
+}
+
+func (m *UnixTime) GetFractionalNanoseconds() uint32 {
+	if m != nil && m.FractionalNanoseconds != nil {
+		return *m.FractionalNanoseconds
+	}
+	return 0
+}
+
 type Metadata struct {
 	MimeType             *string  `protobuf:"bytes,1,opt,name=MimeType" json:"MimeType,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
@@ -169,7 +236,7 @@ func (m *Metadata) Reset()         { *m = Metadata{} }
 func (m *Metadata) String() string { return proto.CompactTextString(m) }
 func (*Metadata) ProtoMessage()    {}
 func (*Metadata) Descriptor() ([]byte, []int) {
-	return fileDescriptor_e2fd76cc44dfc7c3, []int{1}
+	return fileDescriptor_e2fd76cc44dfc7c3, []int{2}
 }
 
 func (m *Metadata) XXX_Unmarshal(b []byte) error {
@@ -204,28 +271,34 @@ func (m *Metadata) GetMimeType() string {
 func init() {
 	proto.RegisterEnum("unixfs.v1.pb.Data_DataType", Data_DataType_name, Data_DataType_value)
 	proto.RegisterType((*Data)(nil), "unixfs.v1.pb.Data")
+	proto.RegisterType((*UnixTime)(nil), "unixfs.v1.pb.UnixTime")
 	proto.RegisterType((*Metadata)(nil), "unixfs.v1.pb.Metadata")
 }
 
 func init() { proto.RegisterFile("unixfs.proto", fileDescriptor_e2fd76cc44dfc7c3) }
 
 var fileDescriptor_e2fd76cc44dfc7c3 = []byte{
-	// 267 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x4c, 0x90, 0x41, 0x4f, 0x83, 0x30,
-	0x18, 0x86, 0x05, 0xba, 0x0d, 0xbe, 0xa1, 0x69, 0xbe, 0x83, 0x21, 0x9a, 0x18, 0xc2, 0xc1, 0x70,
-	0xc2, 0xe8, 0x3f, 0xd0, 0x2c, 0xc6, 0x0b, 0x97, 0x6e, 0xf1, 0xe0, 0xc5, 0x94, 0xad, 0x84, 0x66,
-	0x8c, 0x12, 0xe8, 0x54, 0xfc, 0x1b, 0xfe, 0x61, 0x53, 0x18, 0xdb, 0x2e, 0x4d, 0x9e, 0xf6, 0x79,
-	0x9b, 0x37, 0x2f, 0xf8, 0xfb, 0x4a, 0xfe, 0xe4, 0x6d, 0x52, 0x37, 0x4a, 0x2b, 0x1c, 0xe9, 0xeb,
-	0x31, 0xa9, 0xb3, 0xe8, 0xcf, 0x06, 0xb2, 0xe0, 0x9a, 0xe3, 0x03, 0x90, 0x55, 0x57, 0x8b, 0xc0,
-	0x0a, 0xed, 0xf8, 0xea, 0xe9, 0x36, 0x39, 0xb7, 0x12, 0x63, 0xf4, 0x87, 0x51, 0x58, 0x2f, 0x22,
-	0x0e, 0xc1, 0xc0, 0x0e, 0xad, 0xd8, 0x67, 0xc3, 0x27, 0x37, 0xe0, 0xe6, 0xb2, 0x14, 0xad, 0xfc,
-	0x15, 0x81, 0x13, 0x5a, 0x31, 0x61, 0x47, 0xc6, 0x3b, 0x80, 0xac, 0x54, 0xeb, 0xad, 0x81, 0x36,
-	0x20, 0xa1, 0x13, 0x13, 0x76, 0x76, 0x63, 0xb2, 0x05, 0x6f, 0x8b, 0xbe, 0xc4, 0x64, 0xc8, 0x8e,
-	0x8c, 0xd7, 0x30, 0xcd, 0x79, 0xa5, 0xf6, 0x3a, 0x98, 0xf6, 0x2f, 0x07, 0x8a, 0xde, 0xc1, 0x1d,
-	0x5b, 0xe1, 0x0c, 0x1c, 0xc6, 0xbf, 0xe9, 0x05, 0x5e, 0x82, 0xb7, 0x90, 0x8d, 0x58, 0x6b, 0xd5,
-	0x74, 0xd4, 0x42, 0x17, 0xc8, 0xab, 0x2c, 0x05, 0xb5, 0xd1, 0x07, 0x37, 0x15, 0x9a, 0x6f, 0xb8,
-	0xe6, 0xd4, 0xc1, 0x39, 0xcc, 0x96, 0xdd, 0xae, 0x94, 0xd5, 0x96, 0x12, 0x93, 0x79, 0x7b, 0x4e,
-	0x57, 0xcb, 0x82, 0x37, 0x1b, 0x3a, 0x89, 0xee, 0x4f, 0xa6, 0xe9, 0x95, 0xca, 0x9d, 0x38, 0x8c,
-	0x63, 0xc5, 0x1e, 0x3b, 0xf2, 0xcb, 0xfc, 0xc3, 0x1b, 0x76, 0xfa, 0xac, 0xb3, 0xff, 0x00, 0x00,
-	0x00, 0xff, 0xff, 0xbd, 0x16, 0xf8, 0x45, 0x67, 0x01, 0x00, 0x00,
+	// 347 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x6c, 0x91, 0x5f, 0x6b, 0xf2, 0x30,
+	0x14, 0xc6, 0xdf, 0xfe, 0xd1, 0xd6, 0xa3, 0xbe, 0x94, 0x03, 0xaf, 0x84, 0x77, 0x30, 0x4a, 0x2f,
+	0x46, 0x2f, 0x46, 0xc7, 0x64, 0x5f, 0x60, 0x43, 0x64, 0x37, 0xee, 0x22, 0xba, 0x5d, 0x78, 0x33,
+	0x62, 0x1b, 0x31, 0xd8, 0x36, 0xa5, 0x8d, 0x9b, 0xee, 0x7b, 0xee, 0xfb, 0x8c, 0xb4, 0xd6, 0x39,
+	0xd8, 0x4d, 0xc8, 0x2f, 0xe7, 0x79, 0x92, 0x73, 0x9e, 0xc0, 0x60, 0x97, 0x8b, 0xfd, 0xba, 0x8a,
+	0x8a, 0x52, 0x2a, 0x89, 0x2d, 0xbd, 0xdd, 0x46, 0xc5, 0x2a, 0xf8, 0x34, 0xc1, 0x9e, 0x30, 0xc5,
+	0xf0, 0x06, 0xec, 0xc5, 0xa1, 0xe0, 0xc4, 0xf0, 0xcd, 0xf0, 0xef, 0xf8, 0x22, 0x3a, 0x57, 0x45,
+	0x5a, 0x51, 0x2f, 0x5a, 0x42, 0x6b, 0x21, 0x62, 0x63, 0x24, 0xa6, 0x6f, 0x84, 0x03, 0xda, 0x5c,
+	0xf2, 0x1f, 0xdc, 0xb5, 0x48, 0x79, 0x25, 0x3e, 0x38, 0xb1, 0x7c, 0x23, 0xb4, 0xe9, 0x89, 0xf1,
+	0x12, 0x60, 0x95, 0xca, 0x78, 0xab, 0xa1, 0x22, 0xb6, 0x6f, 0x85, 0x36, 0x3d, 0x3b, 0xd1, 0xde,
+	0x0d, 0xab, 0x36, 0x75, 0x13, 0x9d, 0xc6, 0xdb, 0x32, 0x8e, 0xa0, 0xbb, 0x66, 0xb9, 0xdc, 0x29,
+	0xd2, 0xad, 0x2b, 0x47, 0xd2, 0x3d, 0x64, 0x32, 0xe1, 0xc4, 0xf1, 0x8d, 0x70, 0x48, 0xeb, 0x3d,
+	0x5e, 0x43, 0x27, 0x53, 0x22, 0xe3, 0xc4, 0xf5, 0x8d, 0xb0, 0x3f, 0x1e, 0xfd, 0x9c, 0xe4, 0x39,
+	0x17, 0xfb, 0x85, 0xc8, 0x38, 0x6d, 0x44, 0xc1, 0x0b, 0xb8, 0xed, 0x5c, 0xe8, 0x80, 0x45, 0xd9,
+	0xbb, 0xf7, 0x07, 0x87, 0xd0, 0x9b, 0x88, 0x92, 0xc7, 0x4a, 0x96, 0x07, 0xcf, 0x40, 0x17, 0xec,
+	0xa9, 0x48, 0xb9, 0x67, 0xe2, 0x00, 0xdc, 0x19, 0x57, 0x2c, 0x61, 0x8a, 0x79, 0x16, 0xf6, 0xc1,
+	0x99, 0x1f, 0xb2, 0x54, 0xe4, 0x5b, 0xcf, 0xd6, 0x9e, 0xc7, 0xfb, 0xd9, 0x62, 0xbe, 0x61, 0x65,
+	0xe2, 0x75, 0x82, 0x25, 0xb8, 0xed, 0x53, 0x48, 0xc0, 0x99, 0xf3, 0x58, 0xe6, 0x49, 0x55, 0xa7,
+	0x6b, 0xd1, 0x16, 0xf1, 0x0e, 0xfe, 0x4d, 0x4b, 0x16, 0x2b, 0x21, 0x73, 0x96, 0x3e, 0xb1, 0x5c,
+	0x56, 0x47, 0x9d, 0x0e, 0xd5, 0xa1, 0xbf, 0x17, 0x83, 0xab, 0xef, 0x2e, 0x74, 0x6a, 0x33, 0x91,
+	0xf1, 0xe3, 0xd7, 0x19, 0x61, 0x8f, 0x9e, 0xf8, 0xa1, 0xbf, 0xec, 0x35, 0xb3, 0xbf, 0x16, 0xab,
+	0xaf, 0x01, 0x00, 0xec, 0x65, 0xa3, 0x8b, 0x05, 0x02, 0x00, 0x00,
 }
@@ -6,8 +6,11 @@ package unixfs
 import (
 	"errors"
 	"fmt"
+	"os"
+	"time"
 
 	proto "github.com/gogo/protobuf/proto"
+	"github.com/ipfs/boxo/files"
 	dag "github.com/ipfs/boxo/ipld/merkledag"
 
 	pb "github.com/ipfs/boxo/ipld/unixfs/pb"
@@ -303,6 +306,68 @@ func (n *FSNode) IsDir() bool {
 	}
 }
 
+// Mode returns the optionally stored file permissions. The file type bits
+// (os.ModeDir, os.ModeSymlink) are set according to the node type when
+// permissions are present. Zero is returned when no mode is stored.
+func (n *FSNode) Mode() os.FileMode {
+	perms := n.format.GetMode() & 0o7777
+	if perms == 0 {
+		return 0
+	}
+
+	m := files.UnixPermsToModePerms(perms)
+	switch n.Type() {
+	case pb.Data_Directory, pb.Data_HAMTShard:
+		m |= os.ModeDir
+	case pb.Data_Symlink:
+		m |= os.ModeSymlink
+	}
+	return m
+}
+
+// SetMode stores the permission bits of the given mode, or removes the stored
+// mode when there are none. The remaining (file type) bits are ignored, as
+// they are implied by the node type.
+func (n *FSNode) SetMode(m os.FileMode) {
+	n.SetModeFromUnixPermissions(files.ModePermsToUnixPerms(m))
+}
+
+// SetModeFromUnixPermissions stores the given POSIX permissions (lower 12
+// bits). The upper 20 bits of a previously stored mode, which are reserved
+// for future extensions, are preserved.
+func (n *FSNode) SetModeFromUnixPermissions(unixPerms uint32) {
+	mode := (n.format.GetMode() &^ 0o7777) | (unixPerms & 0o7777)
+	if mode == 0 {
+		n.format.Mode = nil
+		return
+	}
+	n.format.Mode = &mode
+}
+
+// ModTime returns the optionally stored modification time, or the zero
+// time.Time if there is none.
+func (n *FSNode) ModTime() time.Time {
+	ts := n.format.GetMtime()
+	if ts == nil || ts.Seconds == nil {
+		return time.Time{}
+	}
+	return time.Unix(ts.GetSeconds(), int64(ts.GetFractionalNanoseconds()))
+}
+
+// SetModTime stores the given modification time, or removes the stored
+// one when ts is the zero time.Time.
+func (n *FSNode) SetModTime(ts time.Time) {
+	if ts.IsZero() {
+		n.format.Mtime = nil
+		return
+	}
+
+	n.format.Mtime = &pb.UnixTime{Seconds: proto.Int64(ts.Unix())}
+	if nanos := ts.Nanosecond(); nanos > 0 {
+		n.format.Mtime.FractionalNanoseconds = proto.Uint32(uint32(nanos))
+	}
+}
+
 // Metadata is used to store additional FSNode information.
 type Metadata struct {
 	MimeType string
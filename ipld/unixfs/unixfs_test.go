@@ -2,7 +2,9 @@ package unixfs
 
 import (
 	"bytes"
+	"os"
 	"testing"
+	"time"
 
 	proto "github.com/gogo/protobuf/proto"
 
@@ -183,3 +185,57 @@ func TestIsDir(t *testing.T) {
 		}
 	}
 }
+
+func TestModeAndModTime(t *testing.T) {
+	fsn := NewFSNode(TFile)
+	if fsn.Mode() != 0 || !fsn.ModTime().IsZero() {
+		t.Fatal("new node should not have a mode or modification time")
+	}
+
+	mode := os.FileMode(0o755) | os.ModeSetuid | os.ModeSticky
+	mtime := time.Unix(1638111600, 76552)
+	fsn.SetMode(mode)
+	fsn.SetModTime(mtime)
+
+	b, err := fsn.GetBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pbn := new(pb.Data)
+	if err := proto.Unmarshal(b, pbn); err != nil {
+		t.Fatal(err)
+	}
+	if pbn.GetMode() != 0o5755 {
+		t.Fatalf("expected stored mode %o, got %o", 0o5755, pbn.GetMode())
+	}
+
+	fsn, err = FSNodeFromBytes(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fsn.Mode() != mode {
+		t.Fatalf("expected mode %s, got %s", mode, fsn.Mode())
+	}
+	if !fsn.ModTime().Equal(mtime) {
+		t.Fatalf("expected modification time %s, got %s", mtime, fsn.ModTime())
+	}
+
+	dir := NewFSNode(TDirectory)
+	dir.SetModeFromUnixPermissions(0o700)
+	if dir.Mode() != os.ModeDir|0o700 {
+		t.Fatalf("expected directory mode, got %s", dir.Mode())
+	}
+
+	fsn.SetMode(0)
+	fsn.SetModTime(time.Time{})
+	b, err = fsn.GetBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := proto.Unmarshal(b, pbn); err != nil {
+		t.Fatal(err)
+	}
+	if pbn.Mode != nil || pbn.Mtime != nil {
+		t.Fatal("expected mode and modification time to be removed")
+	}
+}
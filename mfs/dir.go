@@ -43,6 +43,10 @@ type Directory struct {
 	unixfsDir uio.Directory
 
 	modTime time.Time
+
+	// Optional UnixFS attributes persisted in the directory node.
+	mode  os.FileMode
+	mtime time.Time
 }
 
 // NewDirectory constructs a new MFS directory.
@@ -55,6 +59,11 @@ func NewDirectory(ctx context.Context, name string, node ipld.Node, parent paren
 		return nil, err
 	}
 
+	fsn, err := ft.ExtractFSNode(node)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Directory{
 		inode: inode{
 			name:       name,
@@ -65,6 +74,8 @@ func NewDirectory(ctx context.Context, name string, node ipld.Node, parent paren
 		unixfsDir:    db,
 		entriesCache: make(map[string]FSNode),
 		modTime:      time.Now(),
+		mode:         fsn.Mode(),
+		mtime:        fsn.ModTime(),
 	}, nil
 }
 
@@ -109,7 +120,7 @@ func (d *Directory) localUpdate(c child) (*dag.ProtoNode, error) {
 	// TODO: Clearly define how are we propagating changes to lower layers
 	// like UnixFS.
 
-	nd, err := d.unixfsDir.GetNode()
+	nd, err := d.getNodeWithAttributes()
 	if err != nil {
 		return nil, err
 	}
@@ -291,7 +302,21 @@ func (d *Directory) ForEachEntry(ctx context.Context, f func(NodeListing) error)
 	})
 }
 
+// Mkdir creates a directory with the given name in this directory. It
+// returns os.ErrExist, along with the existing directory if any, if the
+// name is taken.
 func (d *Directory) Mkdir(name string) (*Directory, error) {
+	j := journalOf(d)
+	defer j.beginOp()()
+
+	dir, err := d.mkdir(name)
+	if err != nil {
+		return dir, err
+	}
+	return dir, j.record(d.ctx, journalEntry{Op: opMkdir, Path: path.Join(d.Path(), name)})
+}
+
+func (d *Directory) mkdir(name string) (*Directory, error) {
 	d.lock.Lock()
 	defer d.lock.Unlock()
 
@@ -329,7 +354,18 @@ func (d *Directory) Mkdir(name string) (*Directory, error) {
 	return dirobj, nil
 }
 
+// Unlink removes the entry with the given name from this directory.
 func (d *Directory) Unlink(name string) error {
+	j := journalOf(d)
+	defer j.beginOp()()
+
+	if err := d.unlink(name); err != nil {
+		return err
+	}
+	return j.record(d.ctx, journalEntry{Op: opUnlink, Path: path.Join(d.Path(), name)})
+}
+
+func (d *Directory) unlink(name string) error {
 	d.lock.Lock()
 	defer d.lock.Unlock()
 
@@ -349,6 +385,16 @@ func (d *Directory) Flush() error {
 
 // AddChild adds the node 'nd' under this directory giving it the name 'name'
 func (d *Directory) AddChild(name string, nd ipld.Node) error {
+	j := journalOf(d)
+	defer j.beginOp()()
+
+	if err := d.addChild(name, nd); err != nil {
+		return err
+	}
+	return j.record(d.ctx, journalEntry{Op: opPutNode, Path: path.Join(d.Path(), name), Cid: nd.Cid().String()})
+}
+
+func (d *Directory) addChild(name string, nd ipld.Node) error {
 	d.lock.Lock()
 	defer d.lock.Unlock()
 
@@ -415,7 +461,7 @@ func (d *Directory) GetNode() (ipld.Node, error) {
 		return nil, err
 	}
 
-	nd, err := d.unixfsDir.GetNode()
+	nd, err := d.getNodeWithAttributes()
 	if err != nil {
 		return nil, err
 	}
@@ -427,3 +473,91 @@ func (d *Directory) GetNode() (ipld.Node, error) {
 
 	return nd.Copy(), err
 }
+
+// Mode returns the permissions stored in the directory node, or 0 if there
+// are none.
+func (d *Directory) Mode() (os.FileMode, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.mode, nil
+}
+
+// SetMode stores the permissions of the given mode in the directory node (0
+// removes them) and propagates the change to the parent.
+func (d *Directory) SetMode(mode os.FileMode) error {
+	j := journalOf(d)
+	defer j.beginOp()()
+
+	d.lock.Lock()
+	if mode != 0 {
+		mode |= os.ModeDir
+	}
+	d.mode = mode
+	d.lock.Unlock()
+
+	if err := d.Flush(); err != nil {
+		return err
+	}
+	return j.record(d.ctx, journalEntry{Op: opMode, Path: d.Path(), Mode: mode})
+}
+
+// ModTime returns the modification time stored in the directory node, or the
+// zero time.Time if there is none.
+func (d *Directory) ModTime() (time.Time, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.mtime, nil
+}
+
+// SetModTime stores the given modification time in the directory node (the
+// zero time.Time removes it) and propagates the change to the parent.
+func (d *Directory) SetModTime(ts time.Time) error {
+	j := journalOf(d)
+	defer j.beginOp()()
+
+	d.lock.Lock()
+	d.mtime = ts
+	d.lock.Unlock()
+
+	if err := d.Flush(); err != nil {
+		return err
+	}
+	return j.record(d.ctx, journalEntry{Op: opModTime, Path: d.Path(), ModTime: &ts})
+}
+
+// getNodeWithAttributes returns the node of the underlying UnixFS directory
+// with the mode and modification time of this directory stored in it. The
+// UnixFS directory regenerates its data (e.g. when sharded), so the
+// attributes are applied every time.
+//
+// It must be called with the directory lock taken.
+func (d *Directory) getNodeWithAttributes() (ipld.Node, error) {
+	nd, err := d.unixfsDir.GetNode()
+	if err != nil {
+		return nil, err
+	}
+
+	pbnd, ok := nd.(*dag.ProtoNode)
+	if !ok {
+		return nil, dag.ErrNotProtobuf
+	}
+
+	fsn, err := ft.FSNodeFromBytes(pbnd.Data())
+	if err != nil {
+		return nil, err
+	}
+	if fsn.Mode() == d.mode && fsn.ModTime().Equal(d.mtime) {
+		return nd, nil
+	}
+
+	fsn.SetMode(d.mode)
+	fsn.SetModTime(d.mtime)
+	data, err := fsn.GetBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	pbnd = pbnd.Copy().(*dag.ProtoNode)
+	pbnd.SetData(data)
+	return pbnd, nil
+}
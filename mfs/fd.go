@@ -48,6 +48,17 @@ type fileDescriptor struct {
 	flags Flags
 
 	state state
+
+	// journal records the writes made through this descriptor, at path. The
+	// descriptor is flushed when the journal is checkpointed, so all its
+	// methods hold off checkpoints while they run.
+	journal *Journal
+	path    string
+}
+
+// record journals a write or truncation made through this descriptor.
+func (fi *fileDescriptor) record(op journalOp, offset int64, data []byte) error {
+	return fi.journal.record(context.TODO(), journalEntry{Op: op, Path: fi.path, Offset: offset, Data: data})
 }
 
 func (fi *fileDescriptor) checkWrite() error {
@@ -72,6 +83,7 @@ func (fi *fileDescriptor) checkRead() error {
 
 // Size returns the size of the file referred to by this descriptor
 func (fi *fileDescriptor) Size() (int64, error) {
+	defer fi.journal.beginOp()()
 	return fi.mod.Size()
 }
 
@@ -80,8 +92,12 @@ func (fi *fileDescriptor) Truncate(size int64) error {
 	if err := fi.checkWrite(); err != nil {
 		return fmt.Errorf("truncate failed: %s", err)
 	}
+	defer fi.journal.beginOp()()
 	fi.state = stateDirty
-	return fi.mod.Truncate(size)
+	if err := fi.mod.Truncate(size); err != nil {
+		return err
+	}
+	return fi.record(opTruncate, size, nil)
 }
 
 // Write writes the given data to the file at its current offset
@@ -89,8 +105,21 @@ func (fi *fileDescriptor) Write(b []byte) (int, error) {
 	if err := fi.checkWrite(); err != nil {
 		return 0, fmt.Errorf("write failed: %s", err)
 	}
+	defer fi.journal.beginOp()()
 	fi.state = stateDirty
-	return fi.mod.Write(b)
+	var offset int64
+	if fi.journal != nil {
+		var err error
+		offset, err = fi.mod.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return 0, err
+		}
+	}
+	n, err := fi.mod.Write(b)
+	if err != nil {
+		return n, err
+	}
+	return n, fi.record(opWrite, offset, b[:n])
 }
 
 // Read reads into the given buffer from the current offset
@@ -98,6 +127,7 @@ func (fi *fileDescriptor) Read(b []byte) (int, error) {
 	if err := fi.checkRead(); err != nil {
 		return 0, fmt.Errorf("read failed: %s", err)
 	}
+	defer fi.journal.beginOp()()
 	return fi.mod.Read(b)
 }
 
@@ -106,6 +136,7 @@ func (fi *fileDescriptor) CtxReadFull(ctx context.Context, b []byte) (int, error
 	if err := fi.checkRead(); err != nil {
 		return 0, fmt.Errorf("read failed: %s", err)
 	}
+	defer fi.journal.beginOp()()
 	return fi.mod.CtxReadFull(ctx, b)
 }
 
@@ -120,8 +151,12 @@ func (fi *fileDescriptor) Close() error {
 	} else if fi.flags.Read {
 		defer fi.inode.desclock.RUnlock()
 	}
+	defer fi.journal.beginOp()()
 	err := fi.flushUp(fi.flags.Sync)
 	fi.state = stateClosed
+	if fi.journal != nil {
+		fi.journal.close(fi)
+	}
 	return err
 }
 
@@ -130,6 +165,7 @@ func (fi *fileDescriptor) Close() error {
 // the entry in the parent directory (setting `fullSync` to
 // propagate the update all the way to the root).
 func (fi *fileDescriptor) Flush() error {
+	defer fi.journal.beginOp()()
 	return fi.flushUp(true)
 }
 
@@ -185,6 +221,7 @@ func (fi *fileDescriptor) Seek(offset int64, whence int) (int64, error) {
 	if fi.state == stateClosed {
 		return 0, fmt.Errorf("seek failed: %s", ErrClosed)
 	}
+	defer fi.journal.beginOp()()
 	return fi.mod.Seek(offset, whence)
 }
 
@@ -193,6 +230,11 @@ func (fi *fileDescriptor) WriteAt(b []byte, at int64) (int, error) {
 	if err := fi.checkWrite(); err != nil {
 		return 0, fmt.Errorf("write-at failed: %s", err)
 	}
+	defer fi.journal.beginOp()()
 	fi.state = stateDirty
-	return fi.mod.WriteAt(b, at)
+	n, err := fi.mod.WriteAt(b, at)
+	if err != nil {
+		return n, err
+	}
+	return n, fi.record(opWrite, at, b[:n])
 }
@@ -3,13 +3,17 @@ package mfs
 import (
 	"context"
 	"errors"
+	"os"
+	"path"
 	"sync"
+	"time"
 
 	dag "github.com/ipfs/boxo/ipld/merkledag"
 	ft "github.com/ipfs/boxo/ipld/unixfs"
 	mod "github.com/ipfs/boxo/ipld/unixfs/mod"
 
 	chunker "github.com/ipfs/boxo/chunker"
+	cid "github.com/ipfs/go-cid"
 	ipld "github.com/ipfs/go-ipld-format"
 )
 
@@ -106,12 +110,22 @@ func (fi *File) Open(flags Flags) (_ FileDescriptor, _retErr error) {
 	}
 	dmod.RawLeaves = fi.RawLeaves
 
-	return &fileDescriptor{
+	fd := &fileDescriptor{
 		inode: fi,
 		flags: flags,
 		mod:   dmod,
 		state: stateCreated,
-	}, nil
+	}
+	if flags.Write {
+		if dir, ok := fi.parent.(*Directory); ok {
+			fd.journal = journalOf(dir)
+			fd.path = path.Join(dir.Path(), fi.name)
+		}
+		if fd.journal != nil {
+			fd.journal.open(fd)
+		}
+	}
+	return fd, nil
 }
 
 // Size returns the size of this file
@@ -177,3 +191,131 @@ func (fi *File) Sync() error {
 func (fi *File) Type() NodeType {
 	return TFile
 }
+
+// Mode returns the permissions stored in the file node, or 0 if there are
+// none.
+func (fi *File) Mode() (os.FileMode, error) {
+	fi.nodeLock.RLock()
+	defer fi.nodeLock.RUnlock()
+
+	fsn, err := fileFSNode(fi.node)
+	if err != nil || fsn == nil {
+		return 0, err
+	}
+	return fsn.Mode(), nil
+}
+
+// SetMode stores the permissions of the given mode in the file node (0
+// removes them) and propagates the change to the parent.
+func (fi *File) SetMode(mode os.FileMode) error {
+	return fi.setAttributes(func(fsn *ft.FSNode) {
+		fsn.SetMode(mode)
+	}, journalEntry{Op: opMode, Mode: mode})
+}
+
+// ModTime returns the modification time stored in the file node, or the
+// zero time.Time if there is none.
+func (fi *File) ModTime() (time.Time, error) {
+	fi.nodeLock.RLock()
+	defer fi.nodeLock.RUnlock()
+
+	fsn, err := fileFSNode(fi.node)
+	if err != nil || fsn == nil {
+		return time.Time{}, err
+	}
+	return fsn.ModTime(), nil
+}
+
+// SetModTime stores the given modification time in the file node (the zero
+// time.Time removes it) and propagates the change to the parent.
+func (fi *File) SetModTime(ts time.Time) error {
+	return fi.setAttributes(func(fsn *ft.FSNode) {
+		fsn.SetModTime(ts)
+	}, journalEntry{Op: opModTime, ModTime: &ts})
+}
+
+// setAttributes applies `set` to the UnixFS node of the file, storing the
+// resulting node in the DAG service and updating the parent with it. Raw
+// nodes can't hold attributes so they are first wrapped in a UnixFS file
+// node that links to them. The change is journaled as e, at the path of the
+// file.
+func (fi *File) setAttributes(set func(fsn *ft.FSNode), e journalEntry) error {
+	// Take the descriptor lock to make sure no one is writing to the file.
+	fi.desclock.Lock()
+	defer fi.desclock.Unlock()
+
+	// The journal is locked after the descriptor lock, like writes made
+	// through descriptors.
+	var j *Journal
+	if dir, ok := fi.parent.(*Directory); ok {
+		j = journalOf(dir)
+		e.Path = path.Join(dir.Path(), fi.name)
+	}
+	defer j.beginOp()()
+
+	fi.nodeLock.Lock()
+	var nd *dag.ProtoNode
+	switch node := fi.node.(type) {
+	case *dag.ProtoNode:
+		fsn, err := ft.FSNodeFromBytes(node.Data())
+		if err != nil {
+			fi.nodeLock.Unlock()
+			return err
+		}
+		set(fsn)
+		data, err := fsn.GetBytes()
+		if err != nil {
+			fi.nodeLock.Unlock()
+			return err
+		}
+		nd = node.Copy().(*dag.ProtoNode)
+		nd.SetData(data)
+	case *dag.RawNode:
+		fsn := ft.NewFSNode(ft.TFile)
+		fsn.AddBlockSize(uint64(len(node.RawData())))
+		set(fsn)
+		data, err := fsn.GetBytes()
+		if err != nil {
+			fi.nodeLock.Unlock()
+			return err
+		}
+		prefix := node.Cid().Prefix()
+		prefix.Codec = cid.DagProtobuf
+		nd = dag.NodeWithData(data)
+		nd.SetCidBuilder(prefix)
+		if err := nd.AddNodeLink("", node); err != nil {
+			fi.nodeLock.Unlock()
+			return err
+		}
+	default:
+		fi.nodeLock.Unlock()
+		return errors.New("unrecognized node type in mfs/file.setAttributes()")
+	}
+
+	if err := fi.dagService.Add(context.TODO(), nd); err != nil {
+		fi.nodeLock.Unlock()
+		return err
+	}
+	fi.node = nd
+	parent := fi.inode.parent
+	name := fi.inode.name
+	fi.nodeLock.Unlock()
+
+	if err := parent.updateChildEntry(child{name, nd}); err != nil {
+		return err
+	}
+	return j.record(context.TODO(), e)
+}
+
+// fileFSNode returns the UnixFS node of a file, or nil for raw nodes
+// (which don't have one).
+func fileFSNode(node ipld.Node) (*ft.FSNode, error) {
+	switch node := node.(type) {
+	case *dag.ProtoNode:
+		return ft.FSNodeFromBytes(node.Data())
+	case *dag.RawNode:
+		return nil, nil
+	default:
+		return nil, errors.New("unrecognized node type in mfs/file")
+	}
+}
@@ -0,0 +1,419 @@
+package mfs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	gopath "path"
+	"strconv"
+	"sync"
+	"time"
+
+	cid "github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+)
+
+var (
+	journalRootKey    = datastore.NewKey("/mfs/journal/root")
+	journalEntriesKey = datastore.NewKey("/mfs/journal/entries")
+)
+
+// RecoveryMode determines what a journaled `Root` does with the operations
+// recorded after the last checkpoint when it is created.
+type RecoveryMode int
+
+const (
+	// Replay applies the journaled operations on top of the last checkpoint,
+	// skipping the ones that were already applied or fail.
+	Replay RecoveryMode = iota
+	// Rollback discards the journaled operations and restores the last
+	// checkpoint.
+	Rollback
+)
+
+type journalOp string
+
+const (
+	opMv       journalOp = "mv"
+	opMkdir    journalOp = "mkdir"
+	opPutNode  journalOp = "put"
+	opUnlink   journalOp = "unlink"
+	opWrite    journalOp = "write"
+	opTruncate journalOp = "truncate"
+	opMode     journalOp = "mode"
+	opModTime  journalOp = "mtime"
+)
+
+// journalEntry is a single MFS operation as stored in the journal.
+type journalEntry struct {
+	Op   journalOp
+	Path string
+	// Dst is the destination of a move.
+	Dst string `json:",omitempty"`
+	// Cid is the node inserted by a PutNode.
+	Cid string `json:",omitempty"`
+	// Mkparents and Prefix are the options of a Mkdir.
+	Mkparents bool   `json:",omitempty"`
+	Prefix    []byte `json:",omitempty"`
+	// Offset and Data are the position and content of a write, Offset is
+	// also the size of a truncation.
+	Offset int64  `json:",omitempty"`
+	Data   []byte `json:",omitempty"`
+	// Mode and ModTime are the attributes set on a file or directory.
+	Mode    os.FileMode `json:",omitempty"`
+	ModTime *time.Time  `json:",omitempty"`
+}
+
+// Journal is a log of the operations made on a `Root`, backed by a datastore.
+// It records the CID of the root directory every time the `Root` is flushed,
+// along with the operations made since then, so that a process restarting
+// after a crash can recover the edits that were not flushed (see
+// `NewRootWithJournal`).
+//
+// Operations are recorded right after they are applied in memory, and before
+// they return: an operation which returned successfully survives a crash, one
+// interrupted by a crash may be lost.
+//
+// The blocks of the checkpointed root are expected to be kept in the DAG
+// service of the `Root`, e.g. by pinning it from the `PubFunc`.
+type Journal struct {
+	ds datastore.Datastore
+
+	lk   sync.Mutex
+	next uint64
+	// fds are the open descriptors writing to the root, which are flushed
+	// before checkpointing.
+	fds map[*fileDescriptor]struct{}
+
+	// oplk is held while operations are applied and journaled, and
+	// exclusively while checkpointing, so that every operation is either
+	// part of a checkpoint or journaled after it.
+	oplk sync.RWMutex
+}
+
+// NewJournal opens the journal stored in the given datastore.
+func NewJournal(ctx context.Context, ds datastore.Datastore) (*Journal, error) {
+	j := &Journal{ds: ds, fds: make(map[*fileDescriptor]struct{})}
+
+	entries, err := j.entries(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) > 0 {
+		j.next = entries[len(entries)-1].seq + 1
+	}
+	return j, nil
+}
+
+// Checkpoint returns the last root CID recorded in the journal, if any.
+func (j *Journal) Checkpoint(ctx context.Context) (cid.Cid, bool, error) {
+	b, err := j.ds.Get(ctx, journalRootKey)
+	if errors.Is(err, datastore.ErrNotFound) {
+		return cid.Undef, false, nil
+	}
+	if err != nil {
+		return cid.Undef, false, err
+	}
+	c, err := cid.Cast(b)
+	if err != nil {
+		return cid.Undef, false, fmt.Errorf("invalid journal checkpoint: %w", err)
+	}
+	return c, true, nil
+}
+
+// beginOp prevents checkpoints until the returned function is called. It is
+// taken before applying an operation, and released once it is journaled. It
+// does nothing on a nil journal.
+func (j *Journal) beginOp() (end func()) {
+	if j == nil {
+		return func() {}
+	}
+	j.oplk.RLock()
+	return j.oplk.RUnlock
+}
+
+// record journals an operation, if the journal is not nil.
+func (j *Journal) record(ctx context.Context, e journalEntry) error {
+	if j == nil {
+		return nil
+	}
+	return j.append(ctx, e)
+}
+
+// append durably records an operation.
+func (j *Journal) append(ctx context.Context, e journalEntry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	j.lk.Lock()
+	defer j.lk.Unlock()
+
+	key := journalEntriesKey.ChildString(fmt.Sprintf("%020d", j.next))
+	if err := j.ds.Put(ctx, key, b); err != nil {
+		return fmt.Errorf("cannot journal mfs operation: %w", err)
+	}
+	if err := j.ds.Sync(ctx, key); err != nil {
+		return fmt.Errorf("cannot journal mfs operation: %w", err)
+	}
+	j.next++
+	return nil
+}
+
+// open tracks a descriptor writing to the root until it is closed.
+func (j *Journal) open(fd *fileDescriptor) {
+	j.lk.Lock()
+	defer j.lk.Unlock()
+	j.fds[fd] = struct{}{}
+}
+
+// close stops tracking a descriptor.
+func (j *Journal) close(fd *fileDescriptor) {
+	j.lk.Lock()
+	defer j.lk.Unlock()
+	delete(j.fds, fd)
+}
+
+// flushDescriptors propagates the writes buffered in the open descriptors
+// to the root, so that the checkpoint includes them before their journal
+// entries are dropped. It must be called with oplk held exclusively, which
+// keeps the descriptors from being used meanwhile.
+func (j *Journal) flushDescriptors() error {
+	j.lk.Lock()
+	fds := make([]*fileDescriptor, 0, len(j.fds))
+	for fd := range j.fds {
+		fds = append(fds, fd)
+	}
+	j.lk.Unlock()
+
+	for _, fd := range fds {
+		if err := fd.flushUp(true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mark returns the position of the next operation, to be passed to
+// checkpoint once the root has been computed.
+func (j *Journal) mark() uint64 {
+	j.lk.Lock()
+	defer j.lk.Unlock()
+	return j.next
+}
+
+// checkpoint records c as the root CID which includes every operation before
+// mark, and drops these operations from the journal.
+func (j *Journal) checkpoint(ctx context.Context, c cid.Cid, mark uint64) error {
+	if err := j.ds.Put(ctx, journalRootKey, c.Bytes()); err != nil {
+		return err
+	}
+	if err := j.ds.Sync(ctx, journalRootKey); err != nil {
+		return err
+	}
+
+	entries, err := j.entries(ctx)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.seq >= mark {
+			break
+		}
+		if err := j.ds.Delete(ctx, e.key); err != nil {
+			return err
+		}
+	}
+	return j.ds.Sync(ctx, journalEntriesKey)
+}
+
+type storedEntry struct {
+	journalEntry
+	key datastore.Key
+	seq uint64
+}
+
+// entries returns the journaled operations in order.
+func (j *Journal) entries(ctx context.Context) ([]storedEntry, error) {
+	results, err := j.ds.Query(ctx, query.Query{
+		Prefix: journalEntriesKey.String(),
+		Orders: []query.Order{query.OrderByKey{}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	var entries []storedEntry
+	for r := range results.Next() {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		key := datastore.RawKey(r.Key)
+		seq, err := strconv.ParseUint(key.Name(), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid journal key %s: %w", key, err)
+		}
+		e := storedEntry{key: key, seq: seq}
+		if err := json.Unmarshal(r.Value, &e.journalEntry); err != nil {
+			return nil, fmt.Errorf("invalid journal entry %s: %w", key, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// replay applies the journaled operations to a root which isn't journaled.
+// Operations which were already applied, e.g. when the process crashed while
+// checkpointing, or which fail are skipped.
+func (j *Journal) replay(ctx context.Context, r *Root) error {
+	entries, err := j.entries(ctx)
+	if err != nil {
+		return err
+	}
+	for i, e := range entries {
+		if err := applyEntry(ctx, r, e.journalEntry); err != nil {
+			if isApplied(ctx, r, e.journalEntry, err) {
+				log.Debugf("skipping mfs journal operation %d of %d (%s %s), already applied", i+1, len(entries), e.Op, e.Path)
+				continue
+			}
+			log.Warnf("skipping mfs journal operation %d of %d (%s %s): %s", i+1, len(entries), e.Op, e.Path, err)
+		}
+	}
+	return nil
+}
+
+// isApplied returns whether an operation which failed with err was already
+// applied to the root. Writes, truncations and attributes can be applied
+// again, so only the operations on directory entries are checked.
+func isApplied(ctx context.Context, r *Root, e journalEntry, err error) bool {
+	switch e.Op {
+	case opMkdir:
+		fsn, lerr := Lookup(r, e.Path)
+		return errors.Is(err, os.ErrExist) && lerr == nil && fsn.Type() == TDir
+	case opUnlink:
+		return errors.Is(err, os.ErrNotExist)
+	case opPutNode:
+		fsn, lerr := Lookup(r, e.Path)
+		if lerr != nil {
+			return false
+		}
+		nd, lerr := fsn.GetNode()
+		return lerr == nil && nd.Cid().String() == e.Cid
+	case opMv:
+		// The source is gone and the destination exists.
+		if _, lerr := Lookup(r, e.Path); !errors.Is(lerr, os.ErrNotExist) {
+			return false
+		}
+		dst := e.Dst
+		if dst[len(dst)-1] == '/' {
+			dst = gopath.Join(dst, gopath.Base(e.Path))
+		}
+		_, lerr := Lookup(r, dst)
+		return lerr == nil
+	default:
+		return false
+	}
+}
+
+// applyEntry applies a journaled operation.
+func applyEntry(ctx context.Context, r *Root, e journalEntry) error {
+	switch e.Op {
+	case opMv:
+		return Mv(r, e.Path, e.Dst)
+	case opMkdir:
+		opts := MkdirOpts{Mkparents: e.Mkparents}
+		if e.Prefix != nil {
+			prefix, err := cid.PrefixFromBytes(e.Prefix)
+			if err != nil {
+				return err
+			}
+			opts.CidBuilder = prefix
+		}
+		return Mkdir(r, e.Path, opts)
+	case opPutNode:
+		c, err := cid.Decode(e.Cid)
+		if err != nil {
+			return err
+		}
+		nd, err := r.GetDirectory().dagService.Get(ctx, c)
+		if err != nil {
+			return err
+		}
+		return PutNode(r, e.Path, nd)
+	case opUnlink:
+		dirp, name := gopath.Split(e.Path)
+		dir, err := lookupDir(r, dirp)
+		if err != nil {
+			return err
+		}
+		return dir.Unlink(name)
+	case opWrite, opTruncate:
+		fsn, err := Lookup(r, e.Path)
+		if err != nil {
+			return err
+		}
+		fi, ok := fsn.(*File)
+		if !ok {
+			return fmt.Errorf("%s is not a file", e.Path)
+		}
+		fd, err := fi.Open(Flags{Write: true, Sync: true})
+		if err != nil {
+			return err
+		}
+		if e.Op == opWrite {
+			_, err = fd.WriteAt(e.Data, e.Offset)
+		} else {
+			err = fd.Truncate(e.Offset)
+		}
+		if err != nil {
+			fd.Close()
+			return err
+		}
+		return fd.Close()
+	case opMode, opModTime:
+		fsn, err := Lookup(r, e.Path)
+		if err != nil {
+			return err
+		}
+		var mtime time.Time
+		if e.ModTime != nil {
+			mtime = *e.ModTime
+		}
+		switch fsn := fsn.(type) {
+		case *File:
+			if e.Op == opMode {
+				return fsn.SetMode(e.Mode)
+			}
+			return fsn.SetModTime(mtime)
+		case *Directory:
+			if e.Op == opMode {
+				return fsn.SetMode(e.Mode)
+			}
+			return fsn.SetModTime(mtime)
+		default:
+			return fmt.Errorf("unexpected type at path: %s", e.Path)
+		}
+	default:
+		return fmt.Errorf("unknown operation %q", e.Op)
+	}
+}
+
+// journalOf returns the journal of the root the given parent belongs to, if
+// any.
+func journalOf(p parent) *Journal {
+	for {
+		switch cur := p.(type) {
+		case *Directory:
+			p = cur.parent
+		case *Root:
+			return cur.journal
+		default:
+			return nil
+		}
+	}
+}
@@ -0,0 +1,404 @@
+package mfs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	dag "github.com/ipfs/boxo/ipld/merkledag"
+	ft "github.com/ipfs/boxo/ipld/unixfs"
+
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+func newJournaledRoot(ctx context.Context, t *testing.T, dserv ipld.DAGService, jds ds.Datastore, mode RecoveryMode) *Root {
+	t.Helper()
+
+	j, err := NewJournal(ctx, jds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt, err := NewRootWithJournal(ctx, dserv, emptyDirNode(), nil, j, mode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rt
+}
+
+func readAll(t *testing.T, rt *Root, pth string) string {
+	t.Helper()
+
+	fsn, err := Lookup(rt, pth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fd, err := fsn.(*File).Open(Flags{Read: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+	b, err := io.ReadAll(fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func writeAt(t *testing.T, rt *Root, pth string, data string, offset int64) {
+	t.Helper()
+
+	fsn, err := Lookup(rt, pth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fd, err := fsn.(*File).Open(Flags{Write: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offset < 0 {
+		if _, err := fd.Write([]byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	} else if _, err := fd.WriteAt([]byte(data), offset); err != nil {
+		t.Fatal(err)
+	}
+	// Closing without syncing doesn't propagate the write to the root.
+	if err := fd.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// editTree makes every kind of journaled operation, without flushing.
+func editTree(ctx context.Context, t *testing.T, rt *Root) {
+	t.Helper()
+
+	if err := Mkdir(rt, "/a/b", MkdirOpts{Mkparents: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Mkdir(rt, "/c", MkdirOpts{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := PutNode(rt, "/a/file", dag.NodeWithData(ft.FilePBData(nil, 0))); err != nil {
+		t.Fatal(err)
+	}
+	writeAt(t, rt, "/a/file", "hello world", -1)
+	writeAt(t, rt, "/a/file", "HELLO", 0)
+
+	fsn, err := Lookup(rt, "/a/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fd, err := fsn.(*File).Open(Flags{Write: true, Sync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fd.Truncate(8); err != nil {
+		t.Fatal(err)
+	}
+	if err := fd.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Mv(rt, "/a/file", "/a/b/moved"); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.GetDirectory().Unlink("c"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestJournalReplay(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dserv := getDagserv(t)
+	jds := dssync.MutexWrap(ds.NewMapDatastore())
+
+	rt := newJournaledRoot(ctx, t, dserv, jds, Replay)
+	editTree(ctx, t, rt)
+	expected, err := rt.GetDirectory().GetNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate a crash by dropping the root without flushing it.
+	rt = newJournaledRoot(ctx, t, dserv, jds, Replay)
+
+	nd, err := rt.GetDirectory().GetNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !nd.Cid().Equals(expected.Cid()) {
+		t.Fatalf("replayed root %s, expected %s", nd.Cid(), expected.Cid())
+	}
+	if got := readAll(t, rt, "/a/b/moved"); got != "HELLO wo" {
+		t.Fatalf("unexpected file content %q", got)
+	}
+	if _, err := Lookup(rt, "/c"); err != os.ErrNotExist {
+		t.Fatalf("expected /c to be removed, got %v", err)
+	}
+
+	j, err := NewJournal(ctx, jds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := j.entries(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("expected the journal to be empty after recovery, got %d entries", len(entries))
+	}
+	c, ok, err := j.Checkpoint(ctx)
+	if err != nil || !ok || !c.Equals(expected.Cid()) {
+		t.Fatalf("unexpected checkpoint %s, %t, %v", c, ok, err)
+	}
+}
+
+func TestJournalRollback(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dserv := getDagserv(t)
+	jds := dssync.MutexWrap(ds.NewMapDatastore())
+
+	rt := newJournaledRoot(ctx, t, dserv, jds, Rollback)
+	if err := Mkdir(rt, "/kept", MkdirOpts{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	editTree(ctx, t, rt)
+
+	rt = newJournaledRoot(ctx, t, dserv, jds, Rollback)
+	if err := assertDirAtPath(rt.GetDirectory(), "/", []string{"kept"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestJournalFlush(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dserv := getDagserv(t)
+	jds := dssync.MutexWrap(ds.NewMapDatastore())
+
+	rt := newJournaledRoot(ctx, t, dserv, jds, Replay)
+	editTree(ctx, t, rt)
+
+	entries, err := rt.journal.entries(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 8 {
+		t.Fatalf("expected 8 journaled operations, got %d", len(entries))
+	}
+
+	if err := rt.Close(); err != nil {
+		t.Fatal(err)
+	}
+	entries, err = rt.journal.entries(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("expected the journal to be empty after closing the root, got %d entries", len(entries))
+	}
+}
+
+func TestJournalAttributesAndDirectoryOps(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dserv := getDagserv(t)
+	jds := dssync.MutexWrap(ds.NewMapDatastore())
+
+	rt := newJournaledRoot(ctx, t, dserv, jds, Replay)
+	dir, err := rt.GetDirectory().Mkdir("dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dir.AddChild("file", dag.NodeWithData(ft.FilePBData([]byte("data"), 4))); err != nil {
+		t.Fatal(err)
+	}
+	if err := dir.SetMode(0o750); err != nil {
+		t.Fatal(err)
+	}
+	fsn, err := dir.Child("file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Unix(1638111600, 0)
+	if err := fsn.(*File).SetModTime(mtime); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsn.(*File).SetMode(0o600); err != nil {
+		t.Fatal(err)
+	}
+	expected, err := rt.GetDirectory().GetNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rt = newJournaledRoot(ctx, t, dserv, jds, Replay)
+	nd, err := rt.GetDirectory().GetNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !nd.Cid().Equals(expected.Cid()) {
+		t.Fatalf("replayed root %s, expected %s", nd.Cid(), expected.Cid())
+	}
+	fsn, err = Lookup(rt, "/dir/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode, _ := fsn.(*File).Mode(); mode != 0o600 {
+		t.Fatalf("unexpected mode %s", mode)
+	}
+	if ts, _ := fsn.(*File).ModTime(); !ts.Equal(mtime) {
+		t.Fatalf("unexpected modification time %s", ts)
+	}
+}
+
+func TestJournalReplaySkipsAppliedOps(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dserv := getDagserv(t)
+	jds := dssync.MutexWrap(ds.NewMapDatastore())
+
+	rt := newJournaledRoot(ctx, t, dserv, jds, Replay)
+	if err := Mkdir(rt, "/a", MkdirOpts{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := PutNode(rt, "/a/file", dag.NodeWithData(ft.FilePBData(nil, 0))); err != nil {
+		t.Fatal(err)
+	}
+	if err := Mkdir(rt, "/gone", MkdirOpts{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.GetDirectory().Unlink("gone"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Mv(rt, "/a/file", "/moved"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate a crash between recording a checkpoint and dropping the
+	// operations it includes.
+	nd, err := rt.GetDirectory().GetNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := jds.Put(ctx, journalRootKey, nd.Cid().Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.journal.append(ctx, journalEntry{Op: opMkdir, Path: "/b"}); err != nil {
+		t.Fatal(err)
+	}
+
+	rt = newJournaledRoot(ctx, t, dserv, jds, Replay)
+	if err := assertDirAtPath(rt.GetDirectory(), "/", []string{"a", "b", "moved"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestJournalConcurrentFlush(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dserv := getDagserv(t)
+	jds := dssync.MutexWrap(ds.NewMapDatastore())
+
+	rt := newJournaledRoot(ctx, t, dserv, jds, Replay)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			if err := Mkdir(rt, fmt.Sprintf("/d%d", i), MkdirOpts{}); err != nil {
+				errs <- err
+				return
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			if err := rt.Flush(); err != nil {
+				errs <- err
+				return
+			}
+		}
+	}()
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+	expected, err := rt.GetDirectory().GetNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rt = newJournaledRoot(ctx, t, dserv, jds, Replay)
+	nd, err := rt.GetDirectory().GetNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !nd.Cid().Equals(expected.Cid()) {
+		t.Fatalf("replayed root %s, expected %s", nd.Cid(), expected.Cid())
+	}
+}
+
+func TestJournalFlushWithOpenDescriptor(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dserv := getDagserv(t)
+	jds := dssync.MutexWrap(ds.NewMapDatastore())
+
+	rt := newJournaledRoot(ctx, t, dserv, jds, Replay)
+	if err := PutNode(rt, "/file", dag.NodeWithData(ft.FilePBData(nil, 0))); err != nil {
+		t.Fatal(err)
+	}
+	fsn, err := Lookup(rt, "/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fd, err := fsn.(*File).Open(Flags{Write: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fd.Write([]byte("precious")); err != nil {
+		t.Fatal(err)
+	}
+
+	// The write is still buffered in the descriptor when the root is
+	// flushed, and the process crashes before it is closed.
+	if err := rt.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	rt = newJournaledRoot(ctx, t, dserv, jds, Replay)
+	if s := readAll(t, rt, "/file"); s != "precious" {
+		t.Fatalf("expected %q after recovery, got %q", "precious", s)
+	}
+
+	// The original descriptor keeps working once the root was flushed.
+	if _, err := fd.Write([]byte(" data")); err != nil {
+		t.Fatal(err)
+	}
+	if err := fd.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1423,6 +1423,118 @@ func TestFSNodeType(t *testing.T) {
 	}
 }
 
+func TestModeAndModTime(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ds, rt := setupRoot(ctx, t)
+	rootdir := rt.GetDirectory()
+
+	dir, err := rootdir.Mkdir("dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := []byte("some file contents")
+	raw := dag.NewRawNode(data)
+	if err := dir.AddChild("raw", raw); err != nil {
+		t.Fatal(err)
+	}
+	if err := dir.AddChild("pb", fileNodeFromReader(t, ds, bytes.NewReader(data))); err != nil {
+		t.Fatal(err)
+	}
+
+	mtime := time.Unix(1638111600, 76552)
+	if err := dir.SetMode(0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := dir.SetModTime(mtime); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"raw", "pb"} {
+		fsn, err := dir.Child(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fi := fsn.(*File)
+		if err := fi.SetMode(0o604); err != nil {
+			t.Fatal(err)
+		}
+		if err := fi.SetModTime(mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := rootdir.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reload the tree from the DAG to check the attributes were persisted.
+	rootnd, err := rootdir.GetNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt2, err := NewRoot(ctx, ds, rootnd.(*dag.ProtoNode), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dirfsn, err := Lookup(rt2, "/dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir = dirfsn.(*Directory)
+	if mode, _ := dir.Mode(); mode != os.ModeDir|0o700 {
+		t.Fatalf("expected directory mode %s, got %s", os.ModeDir|0o700, mode)
+	}
+	if ts, _ := dir.ModTime(); !ts.Equal(mtime) {
+		t.Fatalf("expected directory mtime %s, got %s", mtime, ts)
+	}
+
+	for _, name := range []string{"raw", "pb"} {
+		fsn, err := Lookup(rt2, "/dir/"+name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fi := fsn.(*File)
+		if mode, _ := fi.Mode(); mode != 0o604 {
+			t.Fatalf("%s: expected file mode %s, got %s", name, os.FileMode(0o604), mode)
+		}
+		if ts, _ := fi.ModTime(); !ts.Equal(mtime) {
+			t.Fatalf("%s: expected file mtime %s, got %s", name, mtime, ts)
+		}
+
+		fd, err := fi.Open(Flags{Read: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := io.ReadAll(fd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fd.Close()
+		if !bytes.Equal(out, data) {
+			t.Fatalf("%s: file contents changed", name)
+		}
+	}
+
+	// Removing the attributes restores the original directory node.
+	if err := dir.SetMode(0); err != nil {
+		t.Fatal(err)
+	}
+	if err := dir.SetModTime(time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	nd, err := dir.GetNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsn, err := ft.ExtractFSNode(nd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fsn.Mode() != 0 || !fsn.ModTime().IsZero() {
+		t.Fatal("expected directory attributes to be removed")
+	}
+}
+
 func getParentDir(root *Root, dir string) (*Directory, error) {
 	parent, err := Lookup(root, dir)
 	if err != nil {
@@ -20,6 +20,8 @@ import (
 // Mv moves the file or directory at 'src' to 'dst'
 // TODO: Document what the strings 'src' and 'dst' represent.
 func Mv(r *Root, src, dst string) error {
+	defer r.journal.beginOp()()
+
 	srcDirName, srcFname := gopath.Split(src)
 
 	var dstDirName string
@@ -56,7 +58,7 @@ func Mv(r *Root, src, dst string) error {
 	if err == nil {
 		switch n := fsn.(type) {
 		case *File:
-			_ = dstDir.Unlink(dstFname)
+			_ = dstDir.unlink(dstFname)
 		case *Directory:
 			dstDir = n
 			dstFname = srcFname
@@ -67,16 +69,18 @@ func Mv(r *Root, src, dst string) error {
 		return err
 	}
 
-	err = dstDir.AddChild(dstFname, nd)
+	err = dstDir.addChild(dstFname, nd)
 	if err != nil {
 		return err
 	}
 
-	if srcDir.name == dstDir.name && srcFname == dstFname {
-		return nil
+	if srcDir.name != dstDir.name || srcFname != dstFname {
+		if err := srcDir.unlink(srcFname); err != nil {
+			return err
+		}
 	}
 
-	return srcDir.Unlink(srcFname)
+	return r.record(journalEntry{Op: opMv, Path: src, Dst: dst})
 }
 
 func lookupDir(r *Root, path string) (*Directory, error) {
@@ -109,12 +113,18 @@ func PutNode(r *Root, path string, nd ipld.Node) error {
 		return errors.New("cannot create file with empty name")
 	}
 
+	defer r.journal.beginOp()()
+
 	pdir, err := lookupDir(r, dirp)
 	if err != nil {
 		return err
 	}
 
-	return pdir.AddChild(filename, nd)
+	if err := pdir.addChild(filename, nd); err != nil {
+		return err
+	}
+
+	return r.record(journalEntry{Op: opPutNode, Path: path, Cid: nd.Cid().String()})
 }
 
 // MkdirOpts is used by Mkdir
@@ -148,11 +158,13 @@ func Mkdir(r *Root, pth string, opts MkdirOpts) error {
 		return errors.New("cannot create directory '/': Already exists")
 	}
 
+	defer r.journal.beginOp()()
+
 	cur := r.GetDirectory()
 	for i, d := range parts[:len(parts)-1] {
 		fsn, err := cur.Child(d)
 		if err == os.ErrNotExist && opts.Mkparents {
-			mkd, err := cur.Mkdir(d)
+			mkd, err := cur.mkdir(d)
 			if err != nil {
 				return err
 			}
@@ -171,7 +183,7 @@ func Mkdir(r *Root, pth string, opts MkdirOpts) error {
 		cur = next
 	}
 
-	final, err := cur.Mkdir(parts[len(parts)-1])
+	final, err := cur.mkdir(parts[len(parts)-1])
 	if err != nil {
 		if !opts.Mkparents || err != os.ErrExist || final == nil {
 			return err
@@ -188,7 +200,18 @@ func Mkdir(r *Root, pth string, opts MkdirOpts) error {
 		}
 	}
 
-	return nil
+	if r.journal == nil {
+		return nil
+	}
+	entry := journalEntry{Op: opMkdir, Path: pth, Mkparents: opts.Mkparents}
+	if opts.CidBuilder != nil {
+		c, err := opts.CidBuilder.Sum(nil)
+		if err != nil {
+			return err
+		}
+		entry.Prefix = c.Prefix().Bytes()
+	}
+	return r.record(entry)
 }
 
 // Lookup extracts the root directory and performs a lookup under it.
@@ -91,10 +91,60 @@ type Root struct {
 	dir *Directory
 
 	repub *Republisher
+
+	// Optional journal of the operations made since the last flush.
+	journal *Journal
 }
 
 // NewRoot creates a new Root and starts up a republisher routine for it.
 func NewRoot(parent context.Context, ds ipld.DAGService, node *dag.ProtoNode, pf PubFunc) (*Root, error) {
+	return newRoot(parent, ds, node, pf)
+}
+
+// NewRootWithJournal creates a new Root whose operations are recorded in the
+// given journal, so that they survive a crash before the next `Flush`.
+//
+// If the journal has a checkpoint, the root is restored from it instead of
+// `node`, and the operations journaled after it are applied or discarded
+// according to `mode`. The recovered root is checkpointed and published.
+func NewRootWithJournal(parent context.Context, ds ipld.DAGService, node *dag.ProtoNode, pf PubFunc, j *Journal, mode RecoveryMode) (*Root, error) {
+	c, ok, err := j.Checkpoint(parent)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		nd, err := ds.Get(parent, c)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load mfs journal checkpoint %s: %w", c, err)
+		}
+		pbnd, ok := nd.(*dag.ProtoNode)
+		if !ok {
+			return nil, dag.ErrNotProtobuf
+		}
+		node = pbnd
+	}
+
+	root, err := newRoot(parent, ds, node, pf)
+	if err != nil {
+		return nil, err
+	}
+
+	if mode == Replay {
+		if err := j.replay(parent, root); err != nil {
+			return nil, err
+		}
+	}
+
+	// Checkpointing the recovered root drops the journaled operations,
+	// including the ones which could not be replayed.
+	root.journal = j
+	if err := root.Flush(); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+func newRoot(parent context.Context, ds ipld.DAGService, node *dag.ProtoNode, pf PubFunc) (*Root, error) {
 	var repub *Republisher
 	if pf != nil {
 		repub = NewRepublisher(parent, pf, time.Millisecond*300, time.Second*3)
@@ -143,7 +193,7 @@ func (kr *Root) GetDirectory() *Directory {
 // and updates the Root republisher.
 // TODO: We are definitely abusing the "flush" terminology here.
 func (kr *Root) Flush() error {
-	nd, err := kr.GetDirectory().GetNode()
+	nd, err := kr.getNodeAndCheckpoint()
 	if err != nil {
 		return err
 	}
@@ -204,7 +254,7 @@ func (kr *Root) updateChildEntry(c child) error {
 }
 
 func (kr *Root) Close() error {
-	nd, err := kr.GetDirectory().GetNode()
+	nd, err := kr.getNodeAndCheckpoint()
 	if err != nil {
 		return err
 	}
@@ -216,3 +266,34 @@ func (kr *Root) Close() error {
 
 	return nil
 }
+
+// record appends an operation to the journal of the root, if any.
+func (kr *Root) record(e journalEntry) error {
+	return kr.journal.record(context.TODO(), e)
+}
+
+// getNodeAndCheckpoint returns the node of the root directory and records it
+// as the checkpoint of the journal, if any.
+func (kr *Root) getNodeAndCheckpoint() (ipld.Node, error) {
+	if kr.journal == nil {
+		return kr.GetDirectory().GetNode()
+	}
+
+	// No operation can be applied until the checkpoint is recorded, otherwise
+	// it could be both part of the checkpoint and journaled after it.
+	kr.journal.oplk.Lock()
+	defer kr.journal.oplk.Unlock()
+
+	if err := kr.journal.flushDescriptors(); err != nil {
+		return nil, err
+	}
+	mark := kr.journal.mark()
+	nd, err := kr.GetDirectory().GetNode()
+	if err != nil {
+		return nil, err
+	}
+	if err := kr.journal.checkpoint(context.TODO(), nd.Cid(), mark); err != nil {
+		return nil, fmt.Errorf("cannot checkpoint mfs journal: %w", err)
+	}
+	return nd, nil
+}
@@ -0,0 +1,279 @@
+package namesys
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/ipfs/boxo/ipns"
+	"github.com/ipfs/go-datastore"
+	record "github.com/libp2p/go-libp2p-record"
+	"github.com/libp2p/go-libp2p/core/routing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// NamedValueStore is a [routing.ValueStore] with a name identifying it in
+// the errors of a [CompositeValueStore], e.g. "dht" or the URL of a delegated
+// routing endpoint.
+type NamedValueStore struct {
+	Name string
+	routing.ValueStore
+}
+
+// RouterError is the error returned by one of the routers of a
+// [CompositeValueStore].
+type RouterError struct {
+	Router string
+	Err    error
+}
+
+func (e *RouterError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Router, e.Err)
+}
+
+func (e *RouterError) Unwrap() error {
+	return e.Err
+}
+
+// RouterErrors are the errors returned by the routers of a
+// [CompositeValueStore] that failed an operation. They can be inspected with
+// [errors.As] and [errors.Is].
+type RouterErrors []*RouterError
+
+func (e RouterErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("%d routers failed: %s", len(e), strings.Join(msgs, "; "))
+}
+
+func (e RouterErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
+
+// CompositeValueStore is a [routing.ValueStore] for IPNS records which queries
+// several routers in parallel, such as the DHT and one or more delegated
+// routing endpoints (see [github.com/ipfs/boxo/routing/http/contentrouter]).
+//
+// Records are published to every router. When resolving, the records returned
+// by the routers are validated and the best one, with the highest sequence
+// number, is kept. Routers returning [routing.ErrNotSupported] for a key, such
+// as delegated routers for public keys, are ignored for that key.
+type CompositeValueStore struct {
+	stores    []NamedValueStore
+	validator record.Validator
+}
+
+var _ routing.ValueStore = (*CompositeValueStore)(nil)
+
+// NewCompositeValueStore creates a [CompositeValueStore] querying the given
+// routers.
+func NewCompositeValueStore(stores ...NamedValueStore) *CompositeValueStore {
+	return &CompositeValueStore{
+		stores: stores,
+		validator: record.NamespacedValidator{
+			"ipns": ipns.Validator{},
+			"pk":   record.PublicKeyValidator{},
+		},
+	}
+}
+
+// PutValue stores the record in every router. If any of them fails, a
+// [RouterErrors] is returned, and the record is only stored by the routers
+// not listed in it.
+func (c *CompositeValueStore) PutValue(ctx context.Context, key string, val []byte, opts ...routing.Option) error {
+	ctx, span := startSpan(ctx, "CompositeValueStore.PutValue", trace.WithAttributes(attribute.String("Key", key)))
+	defer span.End()
+
+	if err := c.validator.Validate(key, val); err != nil {
+		return err
+	}
+
+	errs := c.fanOut(func(s NamedValueStore) error {
+		return s.PutValue(ctx, key, val, opts...)
+	})
+	return c.reduce(errs, nil)
+}
+
+// GetValue returns the best valid record found by the routers. An error is
+// only returned if none of them found a valid record: [routing.ErrNotFound]
+// if every router returned it, a [RouterErrors] otherwise.
+func (c *CompositeValueStore) GetValue(ctx context.Context, key string, opts ...routing.Option) ([]byte, error) {
+	ctx, span := startSpan(ctx, "CompositeValueStore.GetValue", trace.WithAttributes(attribute.String("Key", key)))
+	defer span.End()
+
+	var (
+		lk   sync.Mutex
+		best []byte
+	)
+	errs := c.fanOut(func(s NamedValueStore) error {
+		val, err := s.GetValue(ctx, key, opts...)
+		if err != nil {
+			return err
+		}
+		if err := c.validator.Validate(key, val); err != nil {
+			return err
+		}
+
+		lk.Lock()
+		defer lk.Unlock()
+		if best, err = c.selectBest(key, best, val); err != nil {
+			return err
+		}
+		return nil
+	})
+
+	if best != nil {
+		if len(errs) > 0 {
+			log.Debugf("some routers failed to get %q: %s", key, errs)
+		}
+		return best, nil
+	}
+	return nil, c.reduce(errs, routing.ErrNotFound)
+}
+
+// SearchValue searches every router for the record, emitting valid records
+// on the returned channel as better ones are found. The channel is closed
+// once all the searches are done.
+func (c *CompositeValueStore) SearchValue(ctx context.Context, key string, opts ...routing.Option) (<-chan []byte, error) {
+	ctx, span := startSpan(ctx, "CompositeValueStore.SearchValue", trace.WithAttributes(attribute.String("Key", key)))
+	defer span.End()
+
+	var (
+		chans []<-chan []byte
+		errs  RouterErrors
+	)
+	for _, s := range c.stores {
+		ch, err := s.SearchValue(ctx, key, opts...)
+		if err != nil {
+			errs = append(errs, &RouterError{Router: s.Name, Err: err})
+			continue
+		}
+		chans = append(chans, ch)
+	}
+	if len(chans) == 0 {
+		return nil, c.reduce(errs, routing.ErrNotFound)
+	}
+	if len(errs) > 0 {
+		log.Debugf("some routers failed to search %q: %s", key, errs)
+	}
+
+	vals := make(chan []byte)
+	var wg sync.WaitGroup
+	for _, ch := range chans {
+		wg.Add(1)
+		go func(ch <-chan []byte) {
+			defer wg.Done()
+			for val := range ch {
+				select {
+				case vals <- val:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(ch)
+	}
+	go func() {
+		wg.Wait()
+		close(vals)
+	}()
+
+	out := make(chan []byte)
+	go func() {
+		defer close(out)
+		var best []byte
+		for val := range vals {
+			if err := c.validator.Validate(key, val); err != nil {
+				log.Debugf("ignoring invalid record for %q: %s", key, err)
+				continue
+			}
+			newBest, err := c.selectBest(key, best, val)
+			if err != nil || bytes.Equal(newBest, best) {
+				continue
+			}
+			best = newBest
+			select {
+			case out <- best:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// fanOut calls fn for every router in parallel, returning the errors.
+func (c *CompositeValueStore) fanOut(fn func(NamedValueStore) error) RouterErrors {
+	var (
+		wg   sync.WaitGroup
+		lk   sync.Mutex
+		errs RouterErrors
+	)
+	for _, s := range c.stores {
+		wg.Add(1)
+		go func(s NamedValueStore) {
+			defer wg.Done()
+			if err := fn(s); err != nil {
+				lk.Lock()
+				errs = append(errs, &RouterError{Router: s.Name, Err: err})
+				lk.Unlock()
+			}
+		}(s)
+	}
+	wg.Wait()
+	return errs
+}
+
+// selectBest returns the best of the two valid records, best possibly being
+// nil.
+func (c *CompositeValueStore) selectBest(key string, best, val []byte) ([]byte, error) {
+	if best == nil {
+		return val, nil
+	}
+	vals := [][]byte{best, val}
+	i, err := c.validator.Select(key, vals)
+	if err != nil {
+		return best, err
+	}
+	return vals[i], nil
+}
+
+// reduce returns the error of an operation which failed on the routers in
+// errs. Routers which don't support the key are ignored, unless none does,
+// and so are routers which failed with ok, which is returned if no other
+// router failed or if there are no routers. Routers backed by a datastore,
+// such as the offline router, may report [datastore.ErrNotFound] instead of
+// [routing.ErrNotFound].
+func (c *CompositeValueStore) reduce(errs RouterErrors, ok error) error {
+	var failed RouterErrors
+	unsupported := 0
+	for _, err := range errs {
+		switch {
+		case errors.Is(err, routing.ErrNotSupported):
+			unsupported++
+		case ok == nil:
+			failed = append(failed, err)
+		case errors.Is(err, ok):
+		case ok == routing.ErrNotFound && errors.Is(err, datastore.ErrNotFound):
+		default:
+			failed = append(failed, err)
+		}
+	}
+	if len(failed) > 0 {
+		return failed
+	}
+	if len(c.stores) > 0 && unsupported == len(c.stores) {
+		return routing.ErrNotSupported
+	}
+	return ok
+}
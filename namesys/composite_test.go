@@ -0,0 +1,226 @@
+package namesys
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ipfs/boxo/ipns"
+	"github.com/ipfs/boxo/path"
+	"github.com/ipfs/boxo/routing/http/client"
+	"github.com/ipfs/boxo/routing/http/contentrouter"
+	"github.com/ipfs/boxo/routing/http/server"
+	"github.com/ipfs/boxo/routing/offline"
+	"github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	record "github.com/libp2p/go-libp2p-record"
+	tnet "github.com/libp2p/go-libp2p-testing/net"
+	"github.com/libp2p/go-libp2p/core/routing"
+	"github.com/stretchr/testify/require"
+)
+
+// ipnsRouter is a delegated router which only stores IPNS records.
+type ipnsRouter struct {
+	server.ContentRouter
+
+	lk      sync.Mutex
+	records map[string]*ipns.Record
+}
+
+func (r *ipnsRouter) GetIPNS(ctx context.Context, name ipns.Name) (*ipns.Record, error) {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	rec, ok := r.records[name.String()]
+	if !ok {
+		return nil, routing.ErrNotFound
+	}
+	return rec, nil
+}
+
+func (r *ipnsRouter) PutIPNS(ctx context.Context, name ipns.Name, rec *ipns.Record) error {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	r.records[name.String()] = rec
+	return nil
+}
+
+// newDelegatedValueStore starts a delegated routing server backed by an
+// [ipnsRouter] and returns a [routing.ValueStore] querying it.
+func newDelegatedValueStore(t *testing.T) (*ipnsRouter, NamedValueStore) {
+	router := &ipnsRouter{records: map[string]*ipns.Record{}}
+	srv := httptest.NewServer(server.Handler(router))
+	t.Cleanup(srv.Close)
+
+	c, err := client.New(srv.URL)
+	require.NoError(t, err)
+	return router, NamedValueStore{Name: srv.URL, ValueStore: contentrouter.NewContentRoutingClient(c)}
+}
+
+func newOfflineValueStore() routing.ValueStore {
+	return offline.NewOfflineRouter(dssync.MutexWrap(ds.NewMapDatastore()), record.NamespacedValidator{
+		"ipns": ipns.Validator{},
+		"pk":   record.PublicKeyValidator{},
+	})
+}
+
+func TestCompositeValueStore(t *testing.T) {
+	t.Parallel()
+
+	pathCat := path.FromCid(cid.MustParse("bafkqabddmf2au"))
+	pathDog := path.FromCid(cid.MustParse("bafkqabden5tqu"))
+	pathFish := path.FromCid(cid.MustParse("bafkqabdgnfzwq"))
+	ctx := context.Background()
+
+	newRecord := func(t *testing.T, id tnet.Identity, value path.Path, seq uint64) []byte {
+		rec, err := ipns.NewRecord(id.PrivateKey(), value, seq, time.Now().Add(time.Hour), time.Minute)
+		require.NoError(t, err)
+		raw, err := ipns.MarshalRecord(rec)
+		require.NoError(t, err)
+		return raw
+	}
+
+	t.Run("Publish and resolve through every router", func(t *testing.T) {
+		t.Parallel()
+
+		id := tnet.RandIdentityOrFatal(t)
+		name := ipns.NameFromPeer(id.ID())
+		dht := newOfflineValueStore()
+		router1, store1 := newDelegatedValueStore(t)
+		router2, store2 := newDelegatedValueStore(t)
+
+		ns, err := NewNameSystem(dht, WithValueStores(store1, store2))
+		require.NoError(t, err)
+		require.NoError(t, ns.Publish(ctx, id.PrivateKey(), pathCat))
+
+		_, err = dht.GetValue(ctx, string(name.RoutingKey()))
+		require.NoError(t, err)
+		for _, router := range []*ipnsRouter{router1, router2} {
+			rec, err := router.GetIPNS(ctx, name)
+			require.NoError(t, err)
+			value, err := rec.Value()
+			require.NoError(t, err)
+			require.Equal(t, pathCat, value)
+		}
+
+		// A name system only using the delegated routers resolves the name.
+		ns, err = NewNameSystem(nil, WithValueStores(store2))
+		require.NoError(t, err)
+		res, err := ns.Resolve(ctx, name.AsPath())
+		require.NoError(t, err)
+		require.Equal(t, pathCat, res.Path)
+	})
+
+	t.Run("Resolve keeps the highest sequence", func(t *testing.T) {
+		t.Parallel()
+
+		id := tnet.RandIdentityOrFatal(t)
+		key := string(ipns.NameFromPeer(id.ID()).RoutingKey())
+		dht := newOfflineValueStore()
+		_, store1 := newDelegatedValueStore(t)
+		_, store2 := newDelegatedValueStore(t)
+
+		require.NoError(t, dht.PutValue(ctx, key, newRecord(t, id, pathCat, 1)))
+		require.NoError(t, store1.PutValue(ctx, key, newRecord(t, id, pathFish, 3)))
+		require.NoError(t, store2.PutValue(ctx, key, newRecord(t, id, pathDog, 2)))
+
+		vs := NewCompositeValueStore(NamedValueStore{Name: "dht", ValueStore: dht}, store1, store2)
+		val, err := vs.GetValue(ctx, key)
+		require.NoError(t, err)
+		rec, err := ipns.UnmarshalRecord(val)
+		require.NoError(t, err)
+		seq, err := rec.Sequence()
+		require.NoError(t, err)
+		require.Equal(t, uint64(3), seq)
+
+		res, err := NewIPNSResolver(vs).Resolve(ctx, ipns.NameFromPeer(id.ID()).AsPath())
+		require.NoError(t, err)
+		require.Equal(t, pathFish, res.Path)
+
+		// The search ends with the best record.
+		ch, err := vs.SearchValue(ctx, key)
+		require.NoError(t, err)
+		var last []byte
+		for val := range ch {
+			last = val
+		}
+		require.Equal(t, val, last)
+	})
+
+	t.Run("Invalid records are ignored", func(t *testing.T) {
+		t.Parallel()
+
+		id := tnet.RandIdentityOrFatal(t)
+		other := tnet.RandIdentityOrFatal(t)
+		key := string(ipns.NameFromPeer(id.ID()).RoutingKey())
+		dht := newOfflineValueStore()
+		// A router returning a record signed by another key, with a higher
+		// sequence.
+		bad := offline.NewOfflineRouter(dssync.MutexWrap(ds.NewMapDatastore()), noFailValidator{})
+
+		require.NoError(t, dht.PutValue(ctx, key, newRecord(t, id, pathCat, 1)))
+		require.NoError(t, bad.PutValue(ctx, key, newRecord(t, other, pathDog, 5)))
+
+		vs := NewCompositeValueStore(NamedValueStore{Name: "dht", ValueStore: dht}, NamedValueStore{Name: "bad", ValueStore: bad})
+		res, err := NewIPNSResolver(vs).Resolve(ctx, ipns.NameFromPeer(id.ID()).AsPath())
+		require.NoError(t, err)
+		require.Equal(t, pathCat, res.Path)
+
+		// Invalid records are not published.
+		err = vs.PutValue(ctx, key, newRecord(t, other, pathDog, 6))
+		require.Error(t, err)
+	})
+
+	t.Run("Errors are reported per router", func(t *testing.T) {
+		t.Parallel()
+
+		id := tnet.RandIdentityOrFatal(t)
+		name := ipns.NameFromPeer(id.ID())
+		key := string(name.RoutingKey())
+		dht := newOfflineValueStore()
+		_, store := newDelegatedValueStore(t)
+
+		failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		}))
+		t.Cleanup(failing.Close)
+		c, err := client.New(failing.URL)
+		require.NoError(t, err)
+		broken := NamedValueStore{Name: "broken", ValueStore: contentrouter.NewContentRoutingClient(c)}
+
+		vs := NewCompositeValueStore(NamedValueStore{Name: "dht", ValueStore: dht}, store, broken)
+
+		// Nothing was published yet.
+		_, err = vs.GetValue(ctx, key)
+		var errs RouterErrors
+		require.ErrorAs(t, err, &errs)
+		require.Len(t, errs, 1)
+		require.Equal(t, "broken", errs[0].Router)
+
+		err = vs.PutValue(ctx, key, newRecord(t, id, pathCat, 1))
+		require.ErrorAs(t, err, &errs)
+		require.Len(t, errs, 1)
+		require.Equal(t, "broken", errs[0].Router)
+
+		// The record was stored by the working routers.
+		for _, s := range []routing.ValueStore{dht, store} {
+			_, err := s.GetValue(ctx, key)
+			require.NoError(t, err)
+		}
+		_, err = vs.GetValue(ctx, key)
+		require.NoError(t, err)
+
+		// Keys unsupported by delegated routers are only stored in the DHT.
+		_, err = vs.GetValue(ctx, PkRoutingKey(id.ID()))
+		require.ErrorIs(t, err, routing.ErrNotFound)
+		require.False(t, errors.Is(err, routing.ErrNotSupported))
+
+		vs = NewCompositeValueStore(store)
+		_, err = vs.GetValue(ctx, PkRoutingKey(id.ID()))
+		require.ErrorIs(t, err, routing.ErrNotSupported)
+	})
+}
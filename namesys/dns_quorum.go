@@ -0,0 +1,87 @@
+package namesys
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+// ErrNoDNSQuorum is returned by the lookup functions created with
+// [NewQuorumLookupTXT] when not enough DNS resolvers agree on an answer.
+var ErrNoDNSQuorum = fmt.Errorf("%w: DNS resolvers do not agree", ErrResolveFailed)
+
+type quorumAnswer struct {
+	txt           []string
+	authenticated bool
+	err           error
+}
+
+// NewQuorumLookupTXT returns an [AuthenticatedLookupTXTFunc] that asks all
+// the given lookup functions in parallel, and only returns the TXT records
+// that at least quorum of them returned, in any order. A name that quorum of
+// them do not find is not found.
+//
+// The records are authenticated if any of the lookup functions that returned
+// them authenticated them.
+func NewQuorumLookupTXT(quorum int, lookups ...AuthenticatedLookupTXTFunc) (AuthenticatedLookupTXTFunc, error) {
+	if quorum < 1 || quorum > len(lookups) {
+		return nil, fmt.Errorf("invalid quorum %d; must be between 1 and the number of lookup functions, %d", quorum, len(lookups))
+	}
+
+	return func(ctx context.Context, name string) ([]string, bool, error) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		answers := make(chan quorumAnswer, len(lookups))
+		for _, lookup := range lookups {
+			go func(lookup AuthenticatedLookupTXTFunc) {
+				txt, authenticated, err := lookup(ctx, name)
+				answers <- quorumAnswer{txt: txt, authenticated: authenticated, err: err}
+			}(lookup)
+		}
+
+		// Answers are grouped by their sorted records, or as not found
+		const notFound = "\x00not found"
+		votes := make(map[string]int)
+		authenticated := make(map[string]bool)
+		var errs []error
+		for i := 0; i < len(lookups); i++ {
+			var a quorumAnswer
+			select {
+			case a = <-answers:
+			case <-ctx.Done():
+				return nil, false, ctx.Err()
+			}
+
+			var key string
+			var dnsErr *net.DNSError
+			switch {
+			case a.err == nil:
+				txt := append([]string(nil), a.txt...)
+				sort.Strings(txt)
+				key = strings.Join(txt, "\x00")
+			case errors.As(a.err, &dnsErr) && dnsErr.IsNotFound:
+				key = notFound
+			default:
+				errs = append(errs, a.err)
+				continue
+			}
+
+			votes[key]++
+			authenticated[key] = authenticated[key] || a.authenticated
+			if votes[key] < quorum {
+				continue
+			}
+			if key == notFound {
+				return nil, false, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+			}
+			return a.txt, authenticated[key], nil
+		}
+
+		log.Debugf("no DNS quorum for %q: %d answers, errors: %v", name, len(votes), errs)
+		return nil, false, ErrNoDNSQuorum
+	}, nil
+}
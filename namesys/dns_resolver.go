@@ -20,9 +20,23 @@ import (
 // LookupTXTFunc is a function that lookups TXT record values.
 type LookupTXTFunc func(ctx context.Context, name string) (txt []string, err error)
 
+// AuthenticatedLookupTXTFunc is a function that lookups TXT record values, and
+// reports whether they were authenticated with DNSSEC.
+type AuthenticatedLookupTXTFunc func(ctx context.Context, name string) (txt []string, authenticated bool, err error)
+
+// UnauthenticatedLookupTXT returns an [AuthenticatedLookupTXTFunc] that never
+// authenticates the TXT records returned by lookup.
+func UnauthenticatedLookupTXT(lookup LookupTXTFunc) AuthenticatedLookupTXTFunc {
+	return func(ctx context.Context, name string) ([]string, bool, error) {
+		txt, err := lookup(ctx, name)
+		return txt, false, err
+	}
+}
+
 // DNSResolver implements [Resolver] on DNS domains.
 type DNSResolver struct {
-	lookupTXT LookupTXTFunc
+	lookupTXT              LookupTXTFunc
+	lookupAuthenticatedTXT AuthenticatedLookupTXTFunc
 }
 
 var _ Resolver = &DNSResolver{}
@@ -32,6 +46,13 @@ func NewDNSResolver(lookup LookupTXTFunc) *DNSResolver {
 	return &DNSResolver{lookupTXT: lookup}
 }
 
+// NewAuthenticatedDNSResolver constructs a name resolver using DNS TXT records
+// that reports whether they were authenticated in [Result.Authenticated], for
+// example with a [DNSSECResolver] or [NewQuorumLookupTXT].
+func NewAuthenticatedDNSResolver(lookup AuthenticatedLookupTXTFunc) *DNSResolver {
+	return &DNSResolver{lookupAuthenticatedTXT: lookup}
+}
+
 func (r *DNSResolver) Resolve(ctx context.Context, p path.Path, options ...ResolveOption) (Result, error) {
 	ctx, span := startSpan(ctx, "DNSResolver.Resolve", trace.WithAttributes(attribute.Stringer("Path", p)))
 	defer span.End()
@@ -85,7 +106,7 @@ func (r *DNSResolver) resolveOnceAsync(ctx context.Context, p path.Path, options
 			}
 			if subRes.Err == nil {
 				p, err := joinPaths(subRes.Path, p)
-				emitOnceResult(ctx, out, AsyncResult{Path: p, LastMod: time.Now(), Err: err})
+				emitOnceResult(ctx, out, AsyncResult{Path: p, LastMod: time.Now(), Err: err, Authenticated: subRes.Authenticated, dnslink: true})
 				// Return without waiting for rootRes, since this result
 				// (for "_dnslink."+fqdn) takes precedence
 			} else {
@@ -107,7 +128,7 @@ func workDomain(ctx context.Context, r *DNSResolver, name string, res chan Async
 
 	defer close(res)
 
-	txt, err := r.lookupTXT(ctx, name)
+	txt, authenticated, err := r.lookup(ctx, name)
 	if err != nil {
 		var dnsErr *net.DNSError
 		if errors.As(err, &dnsErr) {
@@ -143,13 +164,21 @@ func workDomain(ctx context.Context, r *DNSResolver, name string, res chan Async
 		res <- AsyncResult{Err: ErrMissingDNSLinkRecord}
 	case 1:
 		// Found 1 valid! Return it.
-		res <- AsyncResult{Path: paths[0]}
+		res <- AsyncResult{Path: paths[0], Authenticated: authenticated}
 	default:
 		// Found more than 1 IPFS/IPNS path.
 		res <- AsyncResult{Err: ErrMultipleDNSLinkRecords}
 	}
 }
 
+func (r *DNSResolver) lookup(ctx context.Context, name string) ([]string, bool, error) {
+	if r.lookupAuthenticatedTXT != nil {
+		return r.lookupAuthenticatedTXT(ctx, name)
+	}
+	txt, err := r.lookupTXT(ctx, name)
+	return txt, false, err
+}
+
 func parseEntry(txt string) (path.Path, error) {
 	p, err := path.NewPath(txt) // bare IPFS multihashes
 	if err == nil {
@@ -0,0 +1,377 @@
+package namesys
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// ErrDNSSECBogus is returned by [DNSSECResolver] when the DNSSEC signatures of
+// an answer cannot be validated.
+var ErrDNSSECBogus = errors.New("DNSSEC validation failed")
+
+// errDNSSECInsecure signals that an answer is not signed, or that the chain of
+// trust is interrupted by an unsigned delegation.
+var errDNSSECInsecure = errors.New("answer is not signed")
+
+// maxCNAMEChain is the maximum number of CNAMEs followed in an answer.
+const maxCNAMEChain = 8
+
+// DefaultDNSSECTrustAnchors are the DS records of the root zone key signing
+// keys, KSK-2017 and KSK-2024.
+var DefaultDNSSECTrustAnchors = []*dns.DS{
+	{
+		Hdr:        dns.RR_Header{Name: ".", Rrtype: dns.TypeDS, Class: dns.ClassINET},
+		KeyTag:     20326,
+		Algorithm:  dns.RSASHA256,
+		DigestType: dns.SHA256,
+		Digest:     "E06D44B80B8F1D39A95C0B0D7C65D08458E880409BBC683457104237C7F8EC8D",
+	},
+	{
+		Hdr:        dns.RR_Header{Name: ".", Rrtype: dns.TypeDS, Class: dns.ClassINET},
+		KeyTag:     38696,
+		Algorithm:  dns.RSASHA256,
+		DigestType: dns.SHA256,
+		Digest:     "683D2D0ACB8C9B712A1948B27F741219298D0A450D612C483AF444A4C0FB2B16",
+	},
+}
+
+// DNSSECResolver lookups TXT records through a recursive DNS server, and
+// validates their DNSSEC signatures up to the trust anchors of the root zone.
+// Its LookupTXT method can be used with [NewAuthenticatedDNSResolver].
+//
+// Answers without signatures are returned as not authenticated, unless
+// [WithRequireDNSSEC] is set. Answers whose signatures are invalid are
+// rejected with [ErrDNSSECBogus].
+type DNSSECResolver struct {
+	server       string
+	client       *dns.Client
+	trustAnchors []*dns.DS
+	require      bool
+
+	keysLk sync.Mutex
+	keys   map[string]zoneKeys
+}
+
+// zoneKeys are the validated DNSKEYs of a zone.
+type zoneKeys struct {
+	keys    []*dns.DNSKEY
+	expires time.Time
+}
+
+// DNSSECOption configures a [DNSSECResolver].
+type DNSSECOption func(*DNSSECResolver)
+
+// WithTrustAnchors replaces [DefaultDNSSECTrustAnchors] with the given DS
+// records of the root zone keys.
+func WithTrustAnchors(anchors ...*dns.DS) DNSSECOption {
+	return func(r *DNSSECResolver) {
+		r.trustAnchors = anchors
+	}
+}
+
+// WithRequireDNSSEC rejects the answers that are not signed.
+func WithRequireDNSSEC() DNSSECOption {
+	return func(r *DNSSECResolver) {
+		r.require = true
+	}
+}
+
+// WithDNSClient sets the client used to query the DNS server. Truncated
+// answers are queried again over TCP.
+func WithDNSClient(c *dns.Client) DNSSECOption {
+	return func(r *DNSSECResolver) {
+		r.client = c
+	}
+}
+
+// NewDNSSECResolver creates a [DNSSECResolver] that queries the recursive DNS
+// server at the given address, like "1.1.1.1:53".
+func NewDNSSECResolver(server string, opts ...DNSSECOption) *DNSSECResolver {
+	r := &DNSSECResolver{
+		server:       server,
+		client:       &dns.Client{},
+		trustAnchors: DefaultDNSSECTrustAnchors,
+		keys:         make(map[string]zoneKeys),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// LookupTXT returns the TXT records of name, and whether they were
+// authenticated with DNSSEC. It implements [AuthenticatedLookupTXTFunc].
+func (r *DNSSECResolver) LookupTXT(ctx context.Context, name string) ([]string, bool, error) {
+	name = dns.Fqdn(name)
+	msg, err := r.query(ctx, name, dns.TypeTXT)
+	if err != nil {
+		return nil, false, err
+	}
+	if msg.Rcode == dns.RcodeNameError {
+		return nil, false, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+
+	// Only the TXT records of name, or of the end of a chain of CNAMEs
+	// starting at name, are accepted, and every RRset of the chain is
+	// validated. Any other record of the answer is ignored, as a server could
+	// add records signed by a zone it controls.
+	sets := rrsets(msg.Answer)
+	owner := dns.CanonicalName(name)
+	authenticated := true
+	var txt []string
+	for hops := 0; txt == nil; hops++ {
+		set, ok := sets[rrsetKey{owner, dns.TypeTXT}]
+		if !ok {
+			set, ok = sets[rrsetKey{owner, dns.TypeCNAME}]
+			if !ok || hops == maxCNAMEChain {
+				return nil, false, &net.DNSError{Err: "no TXT records", Name: name, IsNotFound: true}
+			}
+		}
+
+		err = r.validate(ctx, set.rrs, set.sigs)
+		if errors.Is(err, errDNSSECInsecure) {
+			authenticated = false
+		} else if err != nil {
+			return nil, false, fmt.Errorf("%w for %s: %s", ErrDNSSECBogus, name, err)
+		}
+
+		if cname, ok := set.rrs[0].(*dns.CNAME); ok {
+			owner = dns.CanonicalName(cname.Target)
+			continue
+		}
+		txt = make([]string, 0, len(set.rrs))
+		for _, rr := range set.rrs {
+			txt = append(txt, strings.Join(rr.(*dns.TXT).Txt, ""))
+		}
+	}
+	if !authenticated && r.require {
+		return nil, false, fmt.Errorf("%w for %s: %s", ErrDNSSECBogus, name, errDNSSECInsecure)
+	}
+	return txt, authenticated, nil
+}
+
+// query sends a question with the DNSSEC OK bit to the server. Checking is
+// disabled so that the server returns the answers it could not validate.
+func (r *DNSSECResolver) query(ctx context.Context, name string, qtype uint16) (*dns.Msg, error) {
+	m := new(dns.Msg)
+	m.SetQuestion(name, qtype)
+	m.SetEdns0(4096, true)
+	m.CheckingDisabled = true
+
+	resp, _, err := r.client.ExchangeContext(ctx, m, r.server)
+	if err == nil && resp.Truncated && r.client.Net != "tcp" {
+		tcp := *r.client
+		tcp.Net = "tcp"
+		resp, _, err = tcp.ExchangeContext(ctx, m, r.server)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if resp.Rcode != dns.RcodeSuccess && resp.Rcode != dns.RcodeNameError {
+		return nil, fmt.Errorf("DNS query for %s failed: %s", name, dns.RcodeToString[resp.Rcode])
+	}
+	return resp, nil
+}
+
+// validate checks that one of the signatures of rrs was made by a validated
+// key of the signer zone.
+func (r *DNSSECResolver) validate(ctx context.Context, rrs []dns.RR, sigs []*dns.RRSIG) error {
+	if len(sigs) == 0 {
+		return errDNSSECInsecure
+	}
+
+	owner := rrs[0].Header().Name
+	now := time.Now()
+	errs := make([]string, 0, len(sigs))
+	for _, sig := range sigs {
+		if !dns.IsSubDomain(sig.SignerName, owner) {
+			errs = append(errs, fmt.Sprintf("signer %s is not a parent of %s", sig.SignerName, owner))
+			continue
+		}
+		if !sig.ValidityPeriod(now) {
+			errs = append(errs, fmt.Sprintf("signature %d of %s expired", sig.KeyTag, owner))
+			continue
+		}
+
+		keys, err := r.zoneKeys(ctx, dns.CanonicalName(sig.SignerName))
+		if err != nil {
+			if errors.Is(err, errDNSSECInsecure) {
+				return err
+			}
+			errs = append(errs, err.Error())
+			continue
+		}
+		for _, key := range keys {
+			if key.KeyTag() == sig.KeyTag && key.Algorithm == sig.Algorithm && sig.Verify(key, rrs) == nil {
+				return nil
+			}
+		}
+		errs = append(errs, fmt.Sprintf("no key of %s verifies the signature of %s", sig.SignerName, owner))
+	}
+	return errors.New(strings.Join(errs, "; "))
+}
+
+// zoneKeys returns the DNSKEYs of zone, once validated by the DS records of
+// the parent zone, or the trust anchors for the root zone.
+func (r *DNSSECResolver) zoneKeys(ctx context.Context, zone string) ([]*dns.DNSKEY, error) {
+	r.keysLk.Lock()
+	zk, ok := r.keys[zone]
+	r.keysLk.Unlock()
+	if ok && time.Now().Before(zk.expires) {
+		return zk.keys, nil
+	}
+
+	var anchors []*dns.DS
+	if zone == "." {
+		anchors = r.trustAnchors
+	} else {
+		var err error
+		anchors, err = r.delegation(ctx, zone)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	msg, err := r.query(ctx, zone, dns.TypeDNSKEY)
+	if err != nil {
+		return nil, err
+	}
+	var keys []*dns.DNSKEY
+	var rrs []dns.RR
+	var sigs []*dns.RRSIG
+	ttl := uint32(3600)
+	for _, rr := range msg.Answer {
+		if !strings.EqualFold(rr.Header().Name, zone) {
+			continue
+		}
+		switch rr := rr.(type) {
+		case *dns.DNSKEY:
+			keys = append(keys, rr)
+			rrs = append(rrs, rr)
+			if rr.Hdr.Ttl < ttl {
+				ttl = rr.Hdr.Ttl
+			}
+		case *dns.RRSIG:
+			if rr.TypeCovered == dns.TypeDNSKEY {
+				sigs = append(sigs, rr)
+			}
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("zone %s has no DNSKEY", zone)
+	}
+
+	// The key set must be signed by a key matching an anchor
+	now := time.Now()
+	for _, sig := range sigs {
+		if !sig.ValidityPeriod(now) {
+			continue
+		}
+		for _, key := range keys {
+			if key.KeyTag() != sig.KeyTag || key.Algorithm != sig.Algorithm || !matchesDS(key, anchors) {
+				continue
+			}
+			if sig.Verify(key, rrs) == nil {
+				r.keysLk.Lock()
+				r.keys[zone] = zoneKeys{keys: keys, expires: now.Add(time.Duration(ttl) * time.Second)}
+				r.keysLk.Unlock()
+				return keys, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("no DNSKEY of %s is signed by a trusted key", zone)
+}
+
+// delegation returns the validated DS records of zone, from its parent zone.
+func (r *DNSSECResolver) delegation(ctx context.Context, zone string) ([]*dns.DS, error) {
+	msg, err := r.query(ctx, zone, dns.TypeDS)
+	if err != nil {
+		return nil, err
+	}
+
+	var ds []*dns.DS
+	var rrs []dns.RR
+	var sigs []*dns.RRSIG
+	for _, rr := range msg.Answer {
+		if !strings.EqualFold(rr.Header().Name, zone) {
+			continue
+		}
+		switch rr := rr.(type) {
+		case *dns.DS:
+			ds = append(ds, rr)
+			rrs = append(rrs, rr)
+		case *dns.RRSIG:
+			if rr.TypeCovered == dns.TypeDS {
+				sigs = append(sigs, rr)
+			}
+		}
+	}
+	if len(ds) == 0 {
+		// Proving that the delegation is insecure would require validating
+		// the NSEC records of the parent zone.
+		return nil, errDNSSECInsecure
+	}
+	if err = r.validate(ctx, rrs, sigs); err != nil {
+		if errors.Is(err, errDNSSECInsecure) {
+			return nil, fmt.Errorf("DS records of %s are not signed", zone)
+		}
+		return nil, err
+	}
+	return ds, nil
+}
+
+func matchesDS(key *dns.DNSKEY, anchors []*dns.DS) bool {
+	for _, ds := range anchors {
+		if ds.KeyTag != key.KeyTag() || ds.Algorithm != key.Algorithm {
+			continue
+		}
+		if computed := key.ToDS(ds.DigestType); computed != nil && strings.EqualFold(computed.Digest, ds.Digest) {
+			return true
+		}
+	}
+	return false
+}
+
+type rrset struct {
+	rrs  []dns.RR
+	sigs []*dns.RRSIG
+}
+
+type rrsetKey struct {
+	name  string
+	rtype uint16
+}
+
+// rrsets groups the records of an answer by canonical name and type, with
+// their signatures. Signatures without records are ignored.
+func rrsets(answer []dns.RR) map[rrsetKey]rrset {
+	sets := make(map[rrsetKey]rrset)
+	for _, rr := range answer {
+		name := dns.CanonicalName(rr.Header().Name)
+		if sig, ok := rr.(*dns.RRSIG); ok {
+			k := rrsetKey{name, sig.TypeCovered}
+			set := sets[k]
+			set.sigs = append(set.sigs, sig)
+			sets[k] = set
+			continue
+		}
+		k := rrsetKey{name, rr.Header().Rrtype}
+		set := sets[k]
+		set.rrs = append(set.rrs, rr)
+		sets[k] = set
+	}
+
+	for k, set := range sets {
+		if len(set.rrs) == 0 {
+			delete(sets, k)
+		}
+	}
+	return sets
+}
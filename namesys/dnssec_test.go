@@ -0,0 +1,368 @@
+package namesys
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ipfs/boxo/ipns"
+	"github.com/ipfs/boxo/path"
+	offroute "github.com/ipfs/boxo/routing/offline"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+)
+
+const testDNSLink = "dnslink=/ipfs/bafkqabddmf2au"
+
+type testZone struct {
+	name string
+	key  *dns.DNSKEY
+	priv crypto.Signer
+}
+
+func newTestZone(t *testing.T, name string) *testZone {
+	key := &dns.DNSKEY{
+		Hdr:       dns.RR_Header{Name: name, Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: 3600},
+		Flags:     257,
+		Protocol:  3,
+		Algorithm: dns.ECDSAP256SHA256,
+	}
+	priv, err := key.Generate(256)
+	require.NoError(t, err)
+	return &testZone{name: name, key: key, priv: priv.(crypto.Signer)}
+}
+
+func (z *testZone) sign(t *testing.T, rrs ...dns.RR) *dns.RRSIG {
+	hdr := rrs[0].Header()
+	sig := &dns.RRSIG{
+		Hdr:         dns.RR_Header{Name: hdr.Name, Rrtype: dns.TypeRRSIG, Class: dns.ClassINET, Ttl: hdr.Ttl},
+		TypeCovered: hdr.Rrtype,
+		Algorithm:   z.key.Algorithm,
+		Labels:      uint8(dns.CountLabel(hdr.Name)),
+		OrigTtl:     hdr.Ttl,
+		Expiration:  uint32(time.Now().Add(time.Hour).Unix()),
+		Inception:   uint32(time.Now().Add(-time.Hour).Unix()),
+		KeyTag:      z.key.KeyTag(),
+		SignerName:  z.name,
+	}
+	require.NoError(t, sig.Sign(z.priv, rrs))
+	return sig
+}
+
+// testDNSServer is an in-process DNS server answering from its records, as a
+// recursive server would.
+type testDNSServer struct {
+	addr string
+
+	lk       sync.Mutex
+	records  map[string][]dns.RR
+	names    map[string]bool
+	injected map[string][]dns.RR
+	queries  int
+}
+
+func newTestDNSServer(t *testing.T) *testDNSServer {
+	s := &testDNSServer{
+		records:  make(map[string][]dns.RR),
+		names:    make(map[string]bool),
+		injected: make(map[string][]dns.RR),
+	}
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s.addr = pc.LocalAddr().String()
+	started := make(chan struct{})
+	server := &dns.Server{PacketConn: pc, Handler: dns.HandlerFunc(s.serve), NotifyStartedFunc: func() { close(started) }}
+	go func() { _ = server.ActivateAndServe() }()
+	<-started
+	t.Cleanup(func() { _ = server.Shutdown() })
+	return s
+}
+
+// add adds the RRset rrs, signed by zone if it is not nil.
+func (s *testDNSServer) add(t *testing.T, zone *testZone, rrs ...dns.RR) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	hdr := rrs[0].Header()
+	key := strings.ToLower(hdr.Name) + dns.TypeToString[hdr.Rrtype]
+	s.records[key] = append(s.records[key], rrs...)
+	if zone != nil {
+		s.records[key] = append(s.records[key], zone.sign(t, rrs...))
+	}
+	s.names[strings.ToLower(hdr.Name)] = true
+}
+
+// inject adds rrs to the answers to the queries of name.
+func (s *testDNSServer) inject(name string, rrs ...dns.RR) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	s.injected[name] = append(s.injected[name], rrs...)
+	s.names[name] = true
+}
+
+func (s *testDNSServer) serve(w dns.ResponseWriter, req *dns.Msg) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	s.queries++
+
+	q := req.Question[0]
+	name := strings.ToLower(q.Name)
+	resp := new(dns.Msg)
+	resp.SetReply(req)
+
+	rrs, ok := s.records[name+dns.TypeToString[q.Qtype]]
+	if !ok {
+		if cname, ok := s.records[name+"CNAME"]; ok {
+			rrs = append(rrs, cname...)
+			target := strings.ToLower(cname[0].(*dns.CNAME).Target)
+			rrs = append(rrs, s.records[target+dns.TypeToString[q.Qtype]]...)
+		}
+	}
+	resp.Answer = append(append([]dns.RR(nil), rrs...), s.injected[name]...)
+	if len(rrs) == 0 && !s.names[name] {
+		resp.Rcode = dns.RcodeNameError
+	}
+	_ = w.WriteMsg(resp)
+}
+
+// newTestDNSSECServer serves the signed zones ".", "test." and
+// "signed.test.", and the unsigned zone "unsigned.test.", and returns the
+// trust anchor of the root zone.
+func newTestDNSSECServer(t *testing.T) (*testDNSServer, *dns.DS) {
+	s := newTestDNSServer(t)
+	root := newTestZone(t, ".")
+	tld := newTestZone(t, "test.")
+	signed := newTestZone(t, "signed.test.")
+
+	for _, z := range []*testZone{root, tld, signed} {
+		s.add(t, z, z.key)
+	}
+	delegate := func(parent, child *testZone) {
+		ds := child.key.ToDS(dns.SHA256)
+		ds.Hdr = dns.RR_Header{Name: child.name, Rrtype: dns.TypeDS, Class: dns.ClassINET, Ttl: 3600}
+		s.add(t, parent, ds)
+	}
+	delegate(root, tld)
+	delegate(tld, signed)
+
+	txt := func(name, value string) *dns.TXT {
+		return &dns.TXT{Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: 300}, Txt: []string{value}}
+	}
+	s.add(t, signed, txt("_dnslink.signed.test.", testDNSLink))
+	s.add(t, signed, &dns.CNAME{
+		Hdr:    dns.RR_Header{Name: "_dnslink.alias.signed.test.", Rrtype: dns.TypeCNAME, Class: dns.ClassINET, Ttl: 300},
+		Target: "_dnslink.signed.test.",
+	})
+	s.add(t, nil, txt("_dnslink.unsigned.test.", testDNSLink))
+
+	// A record whose content does not match its signature
+	bogus := txt("_dnslink.bogus.signed.test.", testDNSLink)
+	sig := signed.sign(t, bogus)
+	bogus.Txt = []string{"dnslink=/ipfs/bafkqabden5tqu"}
+	s.add(t, nil, bogus, sig)
+
+	// An answer holding the signed TXT records of another name, as a
+	// malicious server could return
+	evil := txt("_dnslink.evil.signed.test.", "dnslink=/ipfs/bafkqabden5tqu")
+	s.inject("_dnslink.injected.signed.test.", evil, signed.sign(t, evil))
+
+	anchor := root.key.ToDS(dns.SHA256)
+	anchor.Hdr = dns.RR_Header{Name: ".", Rrtype: dns.TypeDS, Class: dns.ClassINET}
+	return s, anchor
+}
+
+func TestDNSSECResolver(t *testing.T) {
+	ctx := context.Background()
+	s, anchor := newTestDNSSECServer(t)
+	r := NewDNSSECResolver(s.addr, WithTrustAnchors(anchor))
+
+	for _, name := range []string{"_dnslink.signed.test", "_dnslink.alias.signed.test."} {
+		txt, authenticated, err := r.LookupTXT(ctx, name)
+		require.NoError(t, err, name)
+		require.Equal(t, []string{testDNSLink}, txt, name)
+		require.True(t, authenticated, name)
+	}
+
+	// The validated keys are cached
+	s.lk.Lock()
+	queries := s.queries
+	s.lk.Unlock()
+	_, _, err := r.LookupTXT(ctx, "_dnslink.signed.test")
+	require.NoError(t, err)
+	s.lk.Lock()
+	require.Equal(t, queries+1, s.queries)
+	s.lk.Unlock()
+
+	txt, authenticated, err := r.LookupTXT(ctx, "_dnslink.unsigned.test")
+	require.NoError(t, err)
+	require.Equal(t, []string{testDNSLink}, txt)
+	require.False(t, authenticated)
+
+	_, _, err = r.LookupTXT(ctx, "_dnslink.bogus.signed.test")
+	require.ErrorIs(t, err, ErrDNSSECBogus)
+
+	var dnsErr *net.DNSError
+	for _, name := range []string{"_dnslink.missing.test", "_dnslink.injected.signed.test"} {
+		_, _, err = r.LookupTXT(ctx, name)
+		require.True(t, errors.As(err, &dnsErr) && dnsErr.IsNotFound, "unexpected error %v for %s", err, name)
+	}
+
+	strict := NewDNSSECResolver(s.addr, WithTrustAnchors(anchor), WithRequireDNSSEC())
+	_, _, err = strict.LookupTXT(ctx, "_dnslink.unsigned.test")
+	require.ErrorIs(t, err, ErrDNSSECBogus)
+
+	// Signatures are not trusted without the right anchor
+	other := newTestZone(t, ".").key.ToDS(dns.SHA256)
+	_, _, err = NewDNSSECResolver(s.addr, WithTrustAnchors(other)).LookupTXT(ctx, "_dnslink.signed.test")
+	require.ErrorIs(t, err, ErrDNSSECBogus)
+}
+
+func TestAuthenticatedDNSLink(t *testing.T) {
+	ctx := context.Background()
+	s, anchor := newTestDNSSECServer(t)
+	r := NewDNSSECResolver(s.addr, WithTrustAnchors(anchor))
+
+	nsys, err := NewNameSystem(offroute.NewOfflineRouter(ds.NewMapDatastore(), ipns.Validator{}), WithAuthenticatedDNSResolver(r.LookupTXT))
+	require.NoError(t, err)
+
+	for name, authenticated := range map[string]bool{
+		"/ipns/signed.test":       true,
+		"/ipns/alias.signed.test": true,
+		"/ipns/unsigned.test":     false,
+	} {
+		p, err := path.NewPath(name)
+		require.NoError(t, err)
+		res, err := nsys.Resolve(ctx, p)
+		require.NoError(t, err, name)
+		require.Equal(t, "/ipfs/bafkqabddmf2au", res.Path.String(), name)
+		require.Equal(t, authenticated, res.Authenticated, name)
+	}
+
+	p, err := path.NewPath("/ipns/bogus.signed.test")
+	require.NoError(t, err)
+	_, err = nsys.Resolve(ctx, p)
+	require.ErrorIs(t, err, ErrDNSSECBogus)
+}
+
+func TestAuthenticatedDNSLinkCache(t *testing.T) {
+	ctx := context.Background()
+	s, anchor := newTestDNSSECServer(t)
+	r := NewDNSSECResolver(s.addr, WithTrustAnchors(anchor))
+	store := dssync.MutexWrap(ds.NewMapDatastore())
+	p := mustPath(t, "/ipns/signed.test")
+
+	nsys, err := NewNameSystem(offroute.NewOfflineRouter(ds.NewMapDatastore(), ipns.Validator{}),
+		WithCache(16), WithPersistentCache(store), WithStaleWhileRevalidate(time.Hour), WithAuthenticatedDNSResolver(r.LookupTXT))
+	require.NoError(t, err)
+	res, err := nsys.Resolve(ctx, p)
+	require.NoError(t, err)
+	require.True(t, res.Authenticated)
+
+	// Cached in memory
+	res, err = nsys.Resolve(ctx, p)
+	require.NoError(t, err)
+	require.True(t, res.Authenticated)
+
+	// Stale entries are not authenticated
+	ns := nsys.(*namesys)
+	entry, ok := ns.cache.Get(p.String())
+	require.True(t, ok)
+	entry.cacheEOL = time.Now().Add(-time.Minute)
+	ns.cache.Add(p.String(), entry)
+	entry, ok = ns.cacheGetStale(ctx, p.String())
+	require.True(t, ok)
+	require.False(t, entry.authenticated)
+
+	// Entries loaded from the persistent cache are not authenticated
+	failing := func(context.Context, string) ([]string, bool, error) {
+		return nil, false, errors.New("offline")
+	}
+	nsys, err = NewNameSystem(offroute.NewOfflineRouter(ds.NewMapDatastore(), ipns.Validator{}),
+		WithPersistentCache(store), WithAuthenticatedDNSResolver(failing))
+	require.NoError(t, err)
+	res, err = nsys.Resolve(ctx, p)
+	require.NoError(t, err)
+	require.Equal(t, "/ipfs/bafkqabddmf2au", res.Path.String())
+	require.False(t, res.Authenticated)
+}
+
+func fakeLookupTXT(records map[string][]string, authenticated bool) AuthenticatedLookupTXTFunc {
+	return func(_ context.Context, name string) ([]string, bool, error) {
+		txt, ok := records[name]
+		if !ok {
+			return nil, false, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+		}
+		return txt, authenticated, nil
+	}
+}
+
+func TestQuorumLookupTXT(t *testing.T) {
+	ctx := context.Background()
+	good := map[string][]string{
+		"_dnslink.example.com.": {testDNSLink, "other"},
+		"_dnslink.chain.com.":   {"dnslink=/ipns/example.com"},
+	}
+	reordered := map[string][]string{
+		"_dnslink.example.com.": {"other", testDNSLink},
+		"_dnslink.chain.com.":   {"dnslink=/ipns/example.com"},
+	}
+	evil := map[string][]string{
+		"_dnslink.example.com.": {"dnslink=/ipfs/bafkqabden5tqu"},
+	}
+	failing := func(context.Context, string) ([]string, bool, error) {
+		return nil, false, errors.New("timeout")
+	}
+
+	_, err := NewQuorumLookupTXT(0, fakeLookupTXT(good, false))
+	require.Error(t, err)
+	_, err = NewQuorumLookupTXT(2, fakeLookupTXT(good, false))
+	require.Error(t, err)
+
+	lookup, err := NewQuorumLookupTXT(2, fakeLookupTXT(good, false), fakeLookupTXT(evil, false), fakeLookupTXT(reordered, true))
+	require.NoError(t, err)
+	txt, authenticated, err := lookup(ctx, "_dnslink.example.com.")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{testDNSLink, "other"}, txt)
+	require.True(t, authenticated, "one of the agreeing lookups authenticated the records")
+
+	_, _, err = lookup(ctx, "_dnslink.missing.com.")
+	var dnsErr *net.DNSError
+	require.True(t, errors.As(err, &dnsErr) && dnsErr.IsNotFound, "unexpected error %v", err)
+
+	lookup, err = NewQuorumLookupTXT(2, fakeLookupTXT(good, false), fakeLookupTXT(evil, false), failing)
+	require.NoError(t, err)
+	_, _, err = lookup(ctx, "_dnslink.example.com.")
+	require.ErrorIs(t, err, ErrNoDNSQuorum)
+
+	// Resolving through several DNSLink names is authenticated if all of
+	// them are
+	for _, tc := range []struct {
+		lookups       []AuthenticatedLookupTXTFunc
+		authenticated bool
+	}{
+		{[]AuthenticatedLookupTXTFunc{fakeLookupTXT(good, true), fakeLookupTXT(reordered, false)}, true},
+		{[]AuthenticatedLookupTXTFunc{fakeLookupTXT(good, false), fakeLookupTXT(reordered, false)}, false},
+	} {
+		lookup, err := NewQuorumLookupTXT(2, tc.lookups...)
+		require.NoError(t, err)
+		res, err := NewAuthenticatedDNSResolver(lookup).Resolve(ctx, mustPath(t, "/ipns/chain.com"))
+		require.NoError(t, err)
+		require.Equal(t, "/ipfs/bafkqabddmf2au", res.Path.String())
+		require.Equal(t, tc.authenticated, res.Authenticated)
+	}
+}
+
+func mustPath(t *testing.T, s string) path.Path {
+	p, err := path.NewPath(s)
+	require.NoError(t, err)
+	return p
+}
@@ -70,6 +70,12 @@ type Result struct {
 	Path    path.Path
 	TTL     time.Duration
 	LastMod time.Time
+
+	// Authenticated is set when the path was resolved through DNSLink, and
+	// all the DNSLink records were authenticated with DNSSEC. See
+	// [NewAuthenticatedDNSResolver]. Stale results and results loaded from
+	// the persistent cache are not authenticated.
+	Authenticated bool
 }
 
 // AsyncResult is the return type for [Resolver.ResolveAsync].
@@ -78,6 +84,14 @@ type AsyncResult struct {
 	TTL     time.Duration
 	LastMod time.Time
 	Err     error
+
+	// Authenticated is the same as [Result.Authenticated].
+	Authenticated bool
+
+	// record is the IPNS record the path was resolved from, if any.
+	record *ipns.Record
+	// dnslink is set when the path was resolved through DNSLink.
+	dnslink bool
 }
 
 // Resolver is an object capable of resolving names.
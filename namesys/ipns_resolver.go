@@ -123,7 +123,7 @@ func (r *IPNSResolver) resolveOnceAsync(ctx context.Context, p path.Path, option
 
 				// TODO: in the future it would be interesting to set the last modified date
 				// as the date in which the record has been signed.
-				emitOnceResult(ctx, out, AsyncResult{Path: resolvedBase, TTL: ttl, LastMod: time.Now()})
+				emitOnceResult(ctx, out, AsyncResult{Path: resolvedBase, TTL: ttl, LastMod: time.Now(), record: rec})
 			case <-ctx.Done():
 				return
 			}
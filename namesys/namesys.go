@@ -17,6 +17,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	lru "github.com/hashicorp/golang-lru/v2"
@@ -48,8 +49,15 @@ type namesys struct {
 	dnsResolver, ipnsResolver resolver
 	ipnsPublisher             Publisher
 
-	staticMap map[string]*cacheEntry
-	cache     *lru.Cache[string, cacheEntry]
+	staticMap  map[string]*cacheEntry
+	cache      *lru.Cache[string, cacheEntry]
+	cacheStore ds.Datastore
+	cacheGrace time.Duration
+
+	revalidatingLk sync.Mutex
+	revalidating   map[string]struct{}
+
+	valueStores []NamedValueStore
 }
 
 var _ NameSystem = &namesys{}
@@ -73,6 +81,31 @@ func WithCache(size int) Option {
 	}
 }
 
+// WithPersistentCache is an option that keeps the resolved names in the given
+// datastore, in addition to the cache set by [WithCache], so that they are
+// not resolved again after a restart. The IPNS records are stored and
+// validated again when they are loaded. DNSLink results, which have no TTL,
+// are kept for [DefaultResolverCacheTTL].
+func WithPersistentCache(d ds.Datastore) Option {
+	return func(ns *namesys) error {
+		ns.cacheStore = d
+		return nil
+	}
+}
+
+// WithStaleWhileRevalidate is an option that serves cached names for the given
+// grace period after their TTL expires, while they are resolved again in the
+// background. IPNS names are never served past the EOL of their record.
+func WithStaleWhileRevalidate(grace time.Duration) Option {
+	return func(ns *namesys) error {
+		if grace < 0 {
+			return fmt.Errorf("invalid grace period %s; must be >= 0", grace)
+		}
+		ns.cacheGrace = grace
+		return nil
+	}
+}
+
 // WithDNSResolver is an option that supplies a custom DNS resolver to use instead
 // of the system default.
 func WithDNSResolver(rslv madns.BasicResolver) Option {
@@ -82,6 +115,16 @@ func WithDNSResolver(rslv madns.BasicResolver) Option {
 	}
 }
 
+// WithAuthenticatedDNSResolver is an option that resolves DNSLink names with
+// the given lookup function, which can authenticate the DNSLink records, like
+// [DNSSECResolver.LookupTXT] or [NewQuorumLookupTXT].
+func WithAuthenticatedDNSResolver(lookup AuthenticatedLookupTXTFunc) Option {
+	return func(ns *namesys) error {
+		ns.dnsResolver = NewAuthenticatedDNSResolver(lookup)
+		return nil
+	}
+}
+
 // WithDatastore is an option that supplies a datastore to use instead of an in-memory map datastore.
 // The datastore is used to store published IPNS Records and make them available for querying.
 func WithDatastore(ds ds.Datastore) Option {
@@ -91,6 +134,18 @@ func WithDatastore(ds ds.Datastore) Option {
 	}
 }
 
+// WithValueStores is an option that adds routers to the [routing.ValueStore]
+// given to [NewNameSystem], such as delegated routing endpoints. IPNS records
+// are then published to and resolved from all of them in parallel, using a
+// [CompositeValueStore] in which the given [routing.ValueStore] is named
+// "default".
+func WithValueStores(stores ...NamedValueStore) Option {
+	return func(ns *namesys) error {
+		ns.valueStores = append(ns.valueStores, stores...)
+		return nil
+	}
+}
+
 // NewNameSystem constructs an IPFS [NameSystem] based on the given [routing.ValueStore].
 func NewNameSystem(r routing.ValueStore, opts ...Option) (NameSystem, error) {
 	var staticMap map[string]*cacheEntry
@@ -113,7 +168,8 @@ func NewNameSystem(r routing.ValueStore, opts ...Option) (NameSystem, error) {
 	}
 
 	ns := &namesys{
-		staticMap: staticMap,
+		staticMap:    staticMap,
+		revalidating: make(map[string]struct{}),
 	}
 
 	for _, opt := range opts {
@@ -131,6 +187,14 @@ func NewNameSystem(r routing.ValueStore, opts ...Option) (NameSystem, error) {
 		ns.dnsResolver = NewDNSResolver(madns.DefaultResolver.LookupTXT)
 	}
 
+	if len(ns.valueStores) > 0 {
+		stores := ns.valueStores
+		if r != nil {
+			stores = append([]NamedValueStore{{Name: "default", ValueStore: r}}, stores...)
+		}
+		r = NewCompositeValueStore(stores...)
+	}
+
 	ns.ipnsResolver = NewIPNSResolver(r)
 	ns.ipnsPublisher = NewIPNSPublisher(r, ns.ds)
 
@@ -172,11 +236,13 @@ func (ns *namesys) resolveOnceAsync(ctx context.Context, p path.Path, options Re
 		return out
 	}
 
-	if resolvedBase, ttl, lastMod, ok := ns.cacheGet(resolvablePath.String()); ok {
-		p, err = joinPaths(resolvedBase, p)
+	cacheKey := resolvablePath.String()
+	if entry, ok := ns.cacheGet(ctx, cacheKey); ok {
+		res := entry.result(cacheKey)
+		res.Path, res.Err = joinPaths(entry.val, p)
 		span.SetAttributes(attribute.Bool("CacheHit", true))
-		span.RecordError(err)
-		out <- AsyncResult{Path: p, TTL: ttl, LastMod: lastMod, Err: err}
+		span.RecordError(res.Err)
+		out <- res
 		close(out)
 		return out
 	} else {
@@ -209,6 +275,18 @@ func (ns *namesys) resolveOnceAsync(ctx context.Context, p path.Path, options Re
 		return out
 	}
 
+	if entry, ok := ns.cacheGetStale(ctx, cacheKey); ok {
+		ns.revalidate(res, resolvablePath, options)
+		stale := entry.result(cacheKey)
+		stale.TTL = 0
+		stale.Path, stale.Err = joinPaths(entry.val, p)
+		span.SetAttributes(attribute.Bool("CacheStale", true))
+		span.RecordError(stale.Err)
+		out <- stale
+		close(out)
+		return out
+	}
+
 	resCh := res.resolveOnceAsync(ctx, resolvablePath, options)
 	var best AsyncResult
 	go func() {
@@ -218,7 +296,7 @@ func (ns *namesys) resolveOnceAsync(ctx context.Context, p path.Path, options Re
 			case res, ok := <-resCh:
 				if !ok {
 					if best != (AsyncResult{}) {
-						ns.cacheSet(resolvablePath.String(), best.Path, best.TTL, best.LastMod)
+						ns.cacheSet(cacheKey, best)
 					}
 					return
 				}
@@ -233,7 +311,8 @@ func (ns *namesys) resolveOnceAsync(ctx context.Context, p path.Path, options Re
 					res.Err = multierr.Combine(err, res.Err)
 				}
 
-				emitOnceResult(ctx, out, AsyncResult{Path: p, TTL: res.TTL, LastMod: res.LastMod, Err: res.Err})
+				res.Path = p
+				emitOnceResult(ctx, out, res)
 			case <-ctx.Done():
 				return
 			}
@@ -243,6 +322,50 @@ func (ns *namesys) resolveOnceAsync(ctx context.Context, p path.Path, options Re
 	return out
 }
 
+// revalidate resolves p again in the background to update its stale cache
+// entry, unless it is already being resolved.
+func (ns *namesys) revalidate(res resolver, p path.Path, options ResolveOptions) {
+	cacheKey := p.String()
+	ns.revalidatingLk.Lock()
+	if _, ok := ns.revalidating[cacheKey]; ok {
+		ns.revalidatingLk.Unlock()
+		return
+	}
+	ns.revalidating[cacheKey] = struct{}{}
+	ns.revalidatingLk.Unlock()
+
+	timeout := options.DhtTimeout
+	if timeout == 0 {
+		timeout = DefaultResolverDhtTimeout
+	}
+
+	go func() {
+		defer func() {
+			ns.revalidatingLk.Lock()
+			delete(ns.revalidating, cacheKey)
+			ns.revalidatingLk.Unlock()
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		var best AsyncResult
+		for r := range res.resolveOnceAsync(ctx, p, options) {
+			if r.Err == nil {
+				best = r
+			}
+		}
+		if best.Path == nil {
+			log.Debugf("could not revalidate %q", cacheKey)
+			return
+		}
+		if !ns.cacheSet(cacheKey, best) {
+			// Do not serve the stale entry again
+			ns.cacheInvalidate(cacheKey)
+		}
+	}()
+}
+
 func emitOnceResult(ctx context.Context, outCh chan<- AsyncResult, r AsyncResult) {
 	select {
 	case outCh <- r:
@@ -287,7 +410,11 @@ func (ns *namesys) Publish(ctx context.Context, name ci.PrivKey, value path.Path
 	if ttEOL := time.Until(publishOpts.EOL); ttEOL < ttl {
 		ttl = ttEOL
 	}
-	ns.cacheSet(cacheKey, value, ttl, time.Now())
+	ns.cacheSet(cacheKey, AsyncResult{Path: value, TTL: ttl, LastMod: time.Now()})
+	if ns.cacheStore != nil {
+		// The persistent cache entry of the name holds the previous record
+		ns.deleteCacheEntry(ctx, ipnsName.AsPath().String())
+	}
 	return nil
 }
 
@@ -1,49 +1,135 @@
 package namesys
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"time"
 
+	"github.com/ipfs/boxo/datastore/dshelp"
+	"github.com/ipfs/boxo/ipns"
 	"github.com/ipfs/boxo/path"
+	ds "github.com/ipfs/go-datastore"
 )
 
+// persistentCachePrefix is the datastore namespace of the persistent cache.
+var persistentCachePrefix = ds.NewKey("/namesys/cache")
+
 type cacheEntry struct {
 	val      path.Path     // is the value of this entry
 	ttl      time.Duration // is the ttl of this entry
 	lastMod  time.Time     // is the last time this entry was modified
 	cacheEOL time.Time     // is until when we keep this entry in cache
+	eol      time.Time     // is the EOL of the IPNS record of this entry, if any
+
+	authenticated bool // is whether the DNSLink records of this entry were authenticated
+}
+
+// result returns the entry as a resolution result of name.
+func (e cacheEntry) result(name string) AsyncResult {
+	_, err := ipns.NameFromString(name)
+	return AsyncResult{
+		Path:          e.val,
+		TTL:           e.ttl,
+		LastMod:       e.lastMod,
+		Authenticated: e.authenticated,
+		dnslink:       err != nil,
+	}
 }
 
-func (ns *namesys) cacheGet(name string) (path.Path, time.Duration, time.Time, bool) {
+// persistedCacheEntry is a cacheEntry as stored in the persistent cache. The
+// value of IPNS names is only stored in their signed record. Whether DNSLink
+// records were authenticated is not stored, as it can't be verified again when
+// loaded: they are not authenticated until they are resolved again.
+type persistedCacheEntry struct {
+	Value    string `json:",omitempty"`
+	Record   []byte `json:",omitempty"`
+	TTL      time.Duration
+	LastMod  time.Time
+	CacheEOL time.Time
+}
+
+func (ns *namesys) cacheGet(ctx context.Context, name string) (cacheEntry, bool) {
 	// existence of optional mapping defined via IPFS_NS_MAP is checked first
 	if ns.staticMap != nil {
 		entry, ok := ns.staticMap[name]
 		if ok {
-			return entry.val, entry.ttl, entry.lastMod, true
+			return *entry, true
 		}
 	}
 
-	if ns.cache == nil {
-		return nil, 0, time.Now(), false
-	}
-
-	entry, ok := ns.cache.Get(name)
+	entry, ok := ns.cacheLookup(ctx, name)
 	if !ok {
-		return nil, 0, time.Now(), false
+		return cacheEntry{}, false
 	}
 
 	if time.Now().Before(entry.cacheEOL) {
-		return entry.val, entry.ttl, entry.lastMod, true
+		return entry, true
 	}
 
 	// We do not delete the entry from the cache. Removals are handled by the
 	// backing cache system. It is useful to keep it since cacheSet can use
-	// previously existing values to heuristically update a cache entry.
-	return nil, 0, time.Now(), false
+	// previously existing values to heuristically update a cache entry, and
+	// cacheGetStale can serve it during the grace period.
+	return cacheEntry{}, false
 }
 
-func (ns *namesys) cacheSet(name string, val path.Path, ttl time.Duration, lastMod time.Time) {
-	if ns.cache == nil || ttl <= 0 {
-		return
+// cacheGetStale returns an expired entry that is still within the grace
+// period set by WithStaleWhileRevalidate.
+func (ns *namesys) cacheGetStale(ctx context.Context, name string) (cacheEntry, bool) {
+	if ns.cacheGrace <= 0 {
+		return cacheEntry{}, false
+	}
+
+	entry, ok := ns.cacheLookup(ctx, name)
+	if !ok {
+		return cacheEntry{}, false
+	}
+
+	now := time.Now()
+	if now.After(entry.cacheEOL.Add(ns.cacheGrace)) || (!entry.eol.IsZero() && now.After(entry.eol)) {
+		return cacheEntry{}, false
+	}
+	// The signatures of the DNSLink records may have expired since.
+	entry.authenticated = false
+	return entry, true
+}
+
+// cacheLookup returns the entry of name from the in-memory cache, or from the
+// persistent cache, whether it expired or not.
+func (ns *namesys) cacheLookup(ctx context.Context, name string) (cacheEntry, bool) {
+	if ns.cache != nil {
+		if entry, ok := ns.cache.Get(name); ok {
+			return entry, true
+		}
+	}
+
+	if ns.cacheStore == nil {
+		return cacheEntry{}, false
+	}
+	entry, ok := ns.loadCacheEntry(ctx, name)
+	if ok && ns.cache != nil {
+		ns.cache.Add(name, entry)
+	}
+	return entry, ok
+}
+
+// cacheSet caches the resolution result of name, and returns whether it was
+// cached.
+func (ns *namesys) cacheSet(name string, res AsyncResult) bool {
+	if ns.cache == nil && ns.cacheStore == nil {
+		return false
+	}
+
+	val, ttl, lastMod := res.Path, res.TTL, res.LastMod
+	cacheTTL := ttl
+	if ttl <= 0 {
+		// DNSLink results have no TTL. The persistent cache keeps them for
+		// DefaultResolverCacheTTL, as they can't be revalidated on load.
+		if _, err := ipns.NameFromString(name); err == nil || ns.cacheStore == nil {
+			return false
+		}
+		cacheTTL = DefaultResolverCacheTTL
 	}
 
 	// Set the current date if there's no lastMod.
@@ -53,26 +139,158 @@ func (ns *namesys) cacheSet(name string, val path.Path, ttl time.Duration, lastM
 
 	// If there's an already cached version with the same path, but
 	// different lastMod date, keep the oldest.
-	entry, ok := ns.cache.Get(name)
-	if ok && entry.val.String() == val.String() {
-		if lastMod.After(entry.lastMod) {
-			lastMod = entry.lastMod
+	if ns.cache != nil {
+		entry, ok := ns.cache.Get(name)
+		if ok && entry.val.String() == val.String() {
+			if lastMod.After(entry.lastMod) {
+				lastMod = entry.lastMod
+			}
 		}
 	}
 
-	// Add automatically evicts previous entry, so it works for updating.
-	ns.cache.Add(name, cacheEntry{
+	entry := cacheEntry{
 		val:      val,
 		ttl:      ttl,
 		lastMod:  lastMod,
-		cacheEOL: time.Now().Add(ttl),
-	})
+		cacheEOL: time.Now().Add(cacheTTL),
+
+		authenticated: res.Authenticated,
+	}
+	if res.record != nil {
+		if eol, err := res.record.Validity(); err == nil {
+			entry.eol = eol
+		}
+	}
+
+	if ns.cache != nil {
+		// Add automatically evicts previous entry, so it works for updating.
+		ns.cache.Add(name, entry)
+	}
+	if ns.cacheStore != nil {
+		// The entry is stored even if the resolution was canceled since.
+		ns.storeCacheEntry(context.Background(), name, entry, res.record)
+	}
+	return true
 }
 
 func (ns *namesys) cacheInvalidate(name string) {
-	if ns.cache == nil {
+	if ns.cache != nil {
+		ns.cache.Remove(name)
+	}
+
+	if ns.cacheStore != nil {
+		ns.deleteCacheEntry(context.Background(), name)
+	}
+}
+
+func persistentCacheKey(name string) ds.Key {
+	return persistentCachePrefix.Child(dshelp.NewKeyFromBinary([]byte(name)))
+}
+
+// storeCacheEntry writes entry to the persistent cache. The entries of IPNS
+// names are only stored with their record, so that they can be validated
+// again when loaded.
+func (ns *namesys) storeCacheEntry(ctx context.Context, name string, entry cacheEntry, rec *ipns.Record) {
+	pe := persistedCacheEntry{
+		TTL:      entry.ttl,
+		LastMod:  entry.lastMod,
+		CacheEOL: entry.cacheEOL,
+	}
+	if _, err := ipns.NameFromString(name); err == nil {
+		if rec == nil {
+			// Published entries have no record, forget the outdated one
+			ns.deleteCacheEntry(ctx, name)
+			return
+		}
+		data, err := ipns.MarshalRecord(rec)
+		if err != nil {
+			log.Debugf("cannot marshal IPNS record of %q for the persistent cache: %s", name, err)
+			return
+		}
+		pe.Record = data
+	} else {
+		pe.Value = entry.val.String()
+	}
+
+	data, err := json.Marshal(pe)
+	if err != nil {
+		log.Debugf("cannot marshal cache entry of %q: %s", name, err)
 		return
 	}
+	if err = ns.cacheStore.Put(ctx, persistentCacheKey(name), data); err != nil {
+		log.Warnf("cannot write cache entry of %q: %s", name, err)
+	}
+}
 
-	ns.cache.Remove(name)
+// loadCacheEntry reads the entry of name from the persistent cache. Entries
+// that are past their grace period, or whose IPNS record is not valid
+// anymore, are removed.
+func (ns *namesys) loadCacheEntry(ctx context.Context, name string) (cacheEntry, bool) {
+	data, err := ns.cacheStore.Get(ctx, persistentCacheKey(name))
+	if err != nil {
+		if !errors.Is(err, ds.ErrNotFound) {
+			log.Warnf("cannot read cache entry of %q: %s", name, err)
+		}
+		return cacheEntry{}, false
+	}
+
+	entry, err := decodeCacheEntry(name, data)
+	if err == nil && time.Now().After(entry.cacheEOL.Add(ns.cacheGrace)) {
+		err = errors.New("entry expired")
+	}
+	if err != nil {
+		log.Debugf("discarding cache entry of %q: %s", name, err)
+		ns.deleteCacheEntry(ctx, name)
+		return cacheEntry{}, false
+	}
+	return entry, true
+}
+
+func (ns *namesys) deleteCacheEntry(ctx context.Context, name string) {
+	if err := ns.cacheStore.Delete(ctx, persistentCacheKey(name)); err != nil {
+		log.Warnf("cannot remove cache entry of %q: %s", name, err)
+	}
+}
+
+// decodeCacheEntry decodes an entry of the persistent cache. The IPNS record
+// of IPNS names is validated again, as the datastore may not be trusted.
+func decodeCacheEntry(name string, data []byte) (cacheEntry, error) {
+	var pe persistedCacheEntry
+	if err := json.Unmarshal(data, &pe); err != nil {
+		return cacheEntry{}, err
+	}
+	entry := cacheEntry{
+		ttl:      pe.TTL,
+		lastMod:  pe.LastMod,
+		cacheEOL: pe.CacheEOL,
+	}
+
+	ipnsName, err := ipns.NameFromString(name)
+	if err != nil {
+		entry.val, err = path.NewPath(pe.Value)
+		return entry, err
+	}
+
+	if len(pe.Record) == 0 {
+		return cacheEntry{}, errors.New("missing IPNS record")
+	}
+	rec, err := ipns.UnmarshalRecord(pe.Record)
+	if err != nil {
+		return cacheEntry{}, err
+	}
+	if err = ipns.ValidateWithName(rec, ipnsName); err != nil {
+		return cacheEntry{}, err
+	}
+	entry.val, err = rec.Value()
+	if err != nil {
+		return cacheEntry{}, err
+	}
+	entry.eol, err = rec.Validity()
+	if err != nil {
+		return cacheEntry{}, err
+	}
+	if entry.eol.Before(entry.cacheEOL) {
+		entry.cacheEOL = entry.eol
+	}
+	return entry, nil
 }
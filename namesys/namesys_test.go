@@ -2,6 +2,9 @@ package namesys
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"net"
 	"testing"
 	"time"
 
@@ -13,6 +16,7 @@ import (
 	record "github.com/libp2p/go-libp2p-record"
 	ci "github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/routing"
 	"github.com/stretchr/testify/require"
 )
 
@@ -163,3 +167,137 @@ func TestPublishWithTTL(t *testing.T) {
 	require.True(t, ok)
 	require.LessOrEqual(t, entry.cacheEOL.Sub(eol), 10*time.Millisecond)
 }
+
+func TestPersistentCache(t *testing.T) {
+	ctx := context.Background()
+	cacheStore := dssync.MutexWrap(ds.NewMapDatastore())
+	newRouting := func() routing.ValueStore {
+		return offroute.NewOfflineRouter(dssync.MutexWrap(ds.NewMapDatastore()), record.NamespacedValidator{
+			"ipns": ipns.Validator{},
+			"pk":   record.PublicKeyValidator{},
+		})
+	}
+
+	priv, _, err := ci.GenerateKeyPair(ci.Ed25519, 0)
+	require.NoError(t, err)
+	pid, err := peer.IDFromPrivateKey(priv)
+	require.NoError(t, err)
+	name := ipns.NameFromPeer(pid).AsPath()
+
+	p, err := path.NewPath("/ipfs/QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn")
+	require.NoError(t, err)
+	dnslink, err := path.NewPath("/ipns/ipfs.io")
+	require.NoError(t, err)
+
+	routing := newRouting()
+	require.NoError(t, NewIPNSPublisher(routing, ds.NewMapDatastore()).Publish(ctx, priv, p, PublishWithTTL(time.Hour)))
+	lookups := 0
+	nsys, err := NewNameSystem(routing, WithPersistentCache(cacheStore), WithDNSResolver(&mockDNSResolver{func(string) ([]string, error) {
+		lookups++
+		return []string{"dnslink=" + p.String()}, nil
+	}}))
+	require.NoError(t, err)
+
+	res, err := nsys.Resolve(ctx, name)
+	require.NoError(t, err)
+	require.Equal(t, p.String(), res.Path.String())
+	res, err = nsys.Resolve(ctx, dnslink)
+	require.NoError(t, err)
+	require.Equal(t, p.String(), res.Path.String())
+	_, err = nsys.Resolve(ctx, dnslink)
+	require.NoError(t, err)
+	require.Equal(t, 1, lookups, "DNSLink result is cached")
+
+	// After a restart, names are resolved from the persistent cache
+	nsys, err = NewNameSystem(newRouting(), WithPersistentCache(cacheStore), WithDNSResolver(&mockDNSResolver{func(string) ([]string, error) {
+		return nil, errors.New("offline")
+	}}))
+	require.NoError(t, err)
+	res, err = nsys.Resolve(ctx, name)
+	require.NoError(t, err)
+	require.Equal(t, p.String(), res.Path.String())
+	require.LessOrEqual(t, res.TTL, time.Hour)
+	res, err = nsys.Resolve(ctx, dnslink)
+	require.NoError(t, err)
+	require.Equal(t, p.String(), res.Path.String())
+
+	// Records are validated again when loaded
+	otherPriv, _, err := ci.GenerateKeyPair(ci.Ed25519, 0)
+	require.NoError(t, err)
+	otherRec, err := ipns.NewRecord(otherPriv, p, 1, time.Now().Add(time.Hour), time.Hour)
+	require.NoError(t, err)
+	otherData, err := ipns.MarshalRecord(otherRec)
+	require.NoError(t, err)
+	data, err := json.Marshal(persistedCacheEntry{Record: otherData, TTL: time.Hour, CacheEOL: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+	require.NoError(t, cacheStore.Put(ctx, persistentCacheKey(name.String()), data))
+
+	nsys, err = NewNameSystem(newRouting(), WithPersistentCache(cacheStore))
+	require.NoError(t, err)
+	_, err = nsys.Resolve(ctx, name)
+	require.ErrorIs(t, err, ErrResolveFailed)
+	has, err := cacheStore.Has(ctx, persistentCacheKey(name.String()))
+	require.NoError(t, err)
+	require.False(t, has, "invalid entry is removed")
+}
+
+func TestStaleWhileRevalidate(t *testing.T) {
+	ctx := context.Background()
+	oldPath, err := path.NewPath("/ipfs/QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn")
+	require.NoError(t, err)
+	newPath, err := path.NewPath("/ipfs/Qmcqtw8FfrVSBaRmbWwHxt3AuySBhJLcvmFYi3Lbc4xnwj")
+	require.NoError(t, err)
+	dnslink, err := path.NewPath("/ipns/ipfs.io")
+	require.NoError(t, err)
+
+	lookups := make(chan struct{}, 10)
+	nsys, err := NewNameSystem(offroute.NewOfflineRouter(dssync.MutexWrap(ds.NewMapDatastore()), ipns.Validator{}),
+		WithCache(16), WithPersistentCache(dssync.MutexWrap(ds.NewMapDatastore())), WithStaleWhileRevalidate(time.Hour), WithDNSResolver(&mockDNSResolver{func(string) ([]string, error) {
+			lookups <- struct{}{}
+			return []string{"dnslink=" + newPath.String()}, nil
+		}}))
+	require.NoError(t, err)
+	ns := nsys.(*namesys)
+
+	setExpired := func(age time.Duration) {
+		ns.cache.Add(dnslink.String(), cacheEntry{
+			val:      oldPath,
+			ttl:      time.Minute,
+			lastMod:  time.Now().Add(-age),
+			cacheEOL: time.Now().Add(-age),
+		})
+	}
+
+	// Within the grace period, the stale path is served and refreshed
+	setExpired(time.Minute)
+	res, err := nsys.Resolve(ctx, dnslink)
+	require.NoError(t, err)
+	require.Equal(t, oldPath.String(), res.Path.String())
+	<-lookups
+	require.Eventually(t, func() bool {
+		entry, ok := ns.cacheGet(ctx, dnslink.String())
+		return ok && entry.val.String() == newPath.String()
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Past the grace period, the name is resolved again
+	setExpired(2 * time.Hour)
+	res, err = nsys.Resolve(ctx, dnslink)
+	require.NoError(t, err)
+	require.Equal(t, newPath.String(), res.Path.String())
+	require.Len(t, lookups, 1)
+
+	_, err = NewNameSystem(offroute.NewOfflineRouter(ds.NewMapDatastore(), ipns.Validator{}), WithStaleWhileRevalidate(-time.Second))
+	require.Error(t, err)
+}
+
+type mockDNSResolver struct {
+	lookup func(name string) ([]string, error)
+}
+
+func (r *mockDNSResolver) LookupIPAddr(context.Context, string) ([]net.IPAddr, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (r *mockDNSResolver) LookupTXT(_ context.Context, name string) ([]string, error) {
+	return r.lookup(name)
+}
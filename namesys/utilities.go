@@ -23,6 +23,7 @@ func resolve(ctx context.Context, r resolver, p path.Path, options ResolveOption
 
 	for res := range resCh {
 		result.Path, result.TTL, result.LastMod, err = res.Path, res.TTL, res.LastMod, res.Err
+		result.Authenticated = res.Authenticated
 		if err != nil {
 			break
 		}
@@ -46,6 +47,8 @@ func resolveAsync(ctx context.Context, r resolver, p path.Path, options ResolveO
 
 		var subCh <-chan AsyncResult
 		var cancelSub context.CancelFunc
+		// hop is the result subCh resolves further
+		var hop AsyncResult
 		defer func() {
 			if cancelSub != nil {
 				cancelSub()
@@ -93,6 +96,7 @@ func resolveAsync(ctx context.Context, r resolver, p path.Path, options ResolveO
 				_ = cancelSub
 
 				subCh = resolveAsync(subCtx, r, res.Path, subOpts)
+				hop = res
 			case res, ok := <-subCh:
 				if !ok {
 					subCh = nil
@@ -101,7 +105,7 @@ func resolveAsync(ctx context.Context, r resolver, p path.Path, options ResolveO
 
 				// We don't bother returning here in case of context timeout as there is
 				// no good reason to do that, and we may still be able to emit a result
-				emitResult(ctx, outCh, res)
+				emitResult(ctx, outCh, chainAuthentication(hop, res))
 			case <-ctx.Done():
 				return
 			}
@@ -113,6 +117,16 @@ func resolveAsync(ctx context.Context, r resolver, p path.Path, options ResolveO
 	return outCh
 }
 
+// chainAuthentication returns res, which was resolved from the path of hop,
+// authenticated only if the DNSLink records of both were.
+func chainAuthentication(hop, res AsyncResult) AsyncResult {
+	res.Authenticated = (hop.Authenticated || !hop.dnslink) &&
+		(res.Authenticated || !res.dnslink) &&
+		(hop.dnslink || res.dnslink)
+	res.dnslink = hop.dnslink || res.dnslink
+	return res
+}
+
 func emitResult(ctx context.Context, outCh chan<- AsyncResult, r AsyncResult) {
 	select {
 	case outCh <- r:
@@ -0,0 +1,400 @@
+package dspinner
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	ipld "github.com/ipfs/go-ipld-format"
+
+	"github.com/ipfs/boxo/pinning/pinner/dsindex"
+)
+
+// Option configures a pinner created with New.
+type Option func(*pinner)
+
+// WithIndirectPinIndex enables a persistent, reference-counted index of the
+// links between the blocks of the DAGs of the recursive pins. With it,
+// finding whether a block is pinned indirectly is a few lookups instead of a
+// walk of the DAGs of all the recursive pins. Pinning and unpinning only
+// index the blocks which are reached by no other recursive pin, so that
+// updating a pin only indexes the difference between the two DAGs.
+//
+// The index is built from the blocks returned by local, which must not fetch
+// them from the network, e.g. a DAG service over the blockstore with an
+// offline exchange. A recursive pin whose DAG is not stored locally is left
+// out of the index, and found by walking its DAG, until New can index it.
+// A nil local disables the index.
+//
+// The index is built by New the first time it is enabled, and rebuilt with
+// the other indexes after an unclean shutdown.
+func WithIndirectPinIndex(local ipld.NodeGetter) Option {
+	return func(p *pinner) {
+		p.localDAG = local
+	}
+}
+
+// loadIndirectIndex builds the indirect pin index if it is enabled and was
+// not kept up to date, or forgets it if it is disabled.
+func (p *pinner) loadIndirectIndex(ctx context.Context) error {
+	if p.localDAG == nil {
+		return p.forgetIndirectIndex(ctx)
+	}
+
+	data, err := p.dstore.Get(ctx, indirectStateKey)
+	if err != nil && err != ds.ErrNotFound {
+		return err
+	}
+	if err == nil && data[0] == 1 {
+		p.indirectValid = true
+		p.indexUnindexedPins(ctx)
+		return nil
+	}
+	p.buildIndirectIndex(ctx)
+	return nil
+}
+
+// forgetIndirectIndex records that the indirect pin index is not maintained,
+// so that it is rebuilt if enabled again.
+func (p *pinner) forgetIndirectIndex(ctx context.Context) error {
+	err := p.dstore.Delete(ctx, indirectStateKey)
+	if err != nil {
+		return err
+	}
+	return p.dstore.Sync(ctx, indirectStateKey)
+}
+
+// buildIndirectIndex rebuilds the indirect pin index. The pinner keeps
+// walking DAGs if it fails.
+func (p *pinner) buildIndirectIndex(ctx context.Context) {
+	if err := p.rebuildIndirectIndex(ctx); err != nil {
+		log.Errorf("cannot build indirect pin index, indirect pins will be found by walking DAGs: %s", err)
+	}
+}
+
+// rebuildIndirectIndex recreates the indirect pin index from the DAGs of the
+// recursive pins.
+func (p *pinner) rebuildIndirectIndex(ctx context.Context) error {
+	err := p.setIndirectValid(ctx, false)
+	if err != nil {
+		return err
+	}
+
+	if _, err = p.indirectIndex.DeleteAll(ctx); err != nil {
+		return err
+	}
+	if _, err = p.unindexedIndex.DeleteAll(ctx); err != nil {
+		return err
+	}
+
+	// Every pin is unindexed until its DAG is indexed, so that indexing a
+	// DAG doesn't stop at the pins it reaches which are not indexed yet.
+	var e error
+	err = p.cidRIndex.ForEach(ctx, "", func(key, value string) bool {
+		e = p.unindexedIndex.Add(ctx, key, key)
+		return e == nil
+	})
+	if err == nil {
+		err = e
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = p.indexUnindexed(ctx); err != nil {
+		return err
+	}
+	log.Info("indexed the blocks of the recursive pins")
+	return p.setIndirectValid(ctx, true)
+}
+
+// indexUnindexedPins indexes the DAGs of the recursive pins which were not
+// stored locally when they were added.
+func (p *pinner) indexUnindexedPins(ctx context.Context) {
+	if err := p.indexUnindexed(ctx); err != nil {
+		p.invalidateIndirectIndex(ctx, err)
+	}
+}
+
+// indexUnindexed indexes the DAGs of the unindexed recursive pins, leaving
+// the ones which are still not stored locally unindexed.
+func (p *pinner) indexUnindexed(ctx context.Context) error {
+	roots, err := cidKeys(ctx, p.unindexedIndex)
+	if err != nil {
+		return err
+	}
+
+	var missing int
+	for _, root := range roots {
+		err = p.addIndirect(ctx, root)
+		if ipld.IsNotFound(err) {
+			missing++
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("cannot index pin %s: %w", root, err)
+		}
+		if err = p.unindexedIndex.Delete(ctx, root.KeyString(), root.KeyString()); err != nil {
+			return err
+		}
+	}
+	if missing != 0 {
+		log.Warnf("%d recursive pins are not stored locally, their indirect pins will be found by walking their DAGs", missing)
+	}
+	return nil
+}
+
+// cidKeys returns the CIDs which are keys of index.
+func cidKeys(ctx context.Context, index dsindex.Indexer) ([]cid.Cid, error) {
+	keys := cid.NewSet()
+	var e error
+	err := index.ForEach(ctx, "", func(key, value string) bool {
+		var c cid.Cid
+		c, e = cid.Cast([]byte(key))
+		if e != nil {
+			return false
+		}
+		keys.Add(c)
+		return true
+	})
+	if err == nil {
+		err = e
+	}
+	if err != nil {
+		return nil, err
+	}
+	return keys.Keys(), nil
+}
+
+// setIndirectValid persists whether the indirect pin index is up to date.
+func (p *pinner) setIndirectValid(ctx context.Context, valid bool) error {
+	data := []byte{0}
+	if valid {
+		data[0] = 1
+	}
+	err := p.dstore.Put(ctx, indirectStateKey, data)
+	if err != nil {
+		return err
+	}
+	err = p.dstore.Sync(ctx, indirectStateKey)
+	if err != nil {
+		return err
+	}
+	p.indirectValid = valid
+	return nil
+}
+
+// invalidateIndirectIndex stops using the indirect pin index until it is
+// rebuilt by New.
+func (p *pinner) invalidateIndirectIndex(ctx context.Context, reason error) {
+	if !p.indirectValid {
+		return
+	}
+	log.Errorf("indirect pin index disabled until restart: %s", reason)
+	if err := p.setIndirectValid(ctx, false); err != nil {
+		log.Errorf("failed to save indirect pin index state: %s", err)
+	}
+}
+
+// isIndexed returns whether the links of the block c are in the index, which
+// is the case when it is linked from an indexed block, or is an indexed
+// recursive pin.
+func (p *pinner) isIndexed(ctx context.Context, c cid.Cid) (bool, error) {
+	cidKey := c.KeyString()
+	linked, err := p.indirectIndex.HasAny(ctx, cidKey)
+	if err != nil || linked {
+		return linked, err
+	}
+	pinned, err := p.cidRIndex.HasAny(ctx, cidKey)
+	if err != nil || !pinned {
+		return false, err
+	}
+	unindexed, err := p.unindexedIndex.HasAny(ctx, cidKey)
+	return !unindexed, err
+}
+
+// addIndirect indexes the links of the DAG of root, down to the blocks which
+// are already indexed. The index is left unchanged if a block of the DAG is
+// not stored locally.
+func (p *pinner) addIndirect(ctx context.Context, root cid.Cid) error {
+	linked, err := p.indirectIndex.HasAny(ctx, root.KeyString())
+	if err != nil || linked {
+		return err
+	}
+
+	type link struct{ child, parent string }
+	var added []link
+	rollback := func() {
+		for _, l := range added {
+			if err := p.indirectIndex.Delete(ctx, l.child, l.parent); err != nil {
+				log.Errorf("cannot remove indirect pin index entry: %s", err)
+			}
+		}
+	}
+
+	stack := []cid.Cid{root}
+	for len(stack) != 0 {
+		c := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		nd, err := p.localDAG.Get(ctx, c)
+		if err != nil {
+			rollback()
+			return err
+		}
+		parentKey := c.KeyString()
+		for _, l := range nd.Links() {
+			indexed, err := p.isIndexed(ctx, l.Cid)
+			if err != nil {
+				rollback()
+				return err
+			}
+			childKey := l.Cid.KeyString()
+			if err = p.indirectIndex.Add(ctx, childKey, parentKey); err != nil {
+				rollback()
+				return err
+			}
+			added = append(added, link{childKey, parentKey})
+			if !indexed {
+				stack = append(stack, l.Cid)
+			}
+		}
+	}
+	return nil
+}
+
+// removeIndirect removes the links of the DAG of root from the index, down
+// to the blocks which are still linked from other indexed blocks, or are
+// indexed recursive pins.
+func (p *pinner) removeIndirect(ctx context.Context, root cid.Cid) error {
+	linked, err := p.indirectIndex.HasAny(ctx, root.KeyString())
+	if err != nil || linked {
+		return err
+	}
+
+	visited := cid.NewSet()
+	stack := []cid.Cid{root}
+	for len(stack) != 0 {
+		c := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		nd, err := p.localDAG.Get(ctx, c)
+		if err != nil {
+			return err
+		}
+		parentKey := c.KeyString()
+		for _, l := range nd.Links() {
+			if err = p.indirectIndex.Delete(ctx, l.Cid.KeyString(), parentKey); err != nil {
+				return err
+			}
+			indexed, err := p.isIndexed(ctx, l.Cid)
+			if err != nil {
+				return err
+			}
+			if !indexed && visited.Visit(l.Cid) {
+				stack = append(stack, l.Cid)
+			}
+		}
+	}
+	return nil
+}
+
+// indexRecursivePin updates the indirect pin index after a recursive pin of c
+// is added or removed. The index is updated when c gets its first recursive
+// pin or loses its last one.
+func (p *pinner) indexRecursivePin(ctx context.Context, c cid.Cid, added bool) {
+	if !p.indirectValid {
+		return
+	}
+	if err := p.updateIndirect(ctx, c, added); err != nil {
+		p.invalidateIndirectIndex(ctx, fmt.Errorf("indexing pin %s: %w", c, err))
+	}
+}
+
+func (p *pinner) updateIndirect(ctx context.Context, c cid.Cid, added bool) error {
+	cidKey := c.KeyString()
+	ids, err := p.cidRIndex.Search(ctx, cidKey)
+	if err != nil {
+		return err
+	}
+
+	if added && len(ids) == 1 {
+		err = p.addIndirect(ctx, c)
+		if ipld.IsNotFound(err) {
+			// The blocks are not fetched while the pinner is locked.
+			log.Debugf("pin %s is not stored locally, leaving it out of the indirect pin index", c)
+			return p.unindexedIndex.Add(ctx, cidKey, cidKey)
+		}
+		return err
+	}
+
+	if !added && len(ids) == 0 {
+		unindexed, err := p.unindexedIndex.HasAny(ctx, cidKey)
+		if err != nil {
+			return err
+		}
+		if unindexed {
+			_, err = p.unindexedIndex.DeleteKey(ctx, cidKey)
+			return err
+		}
+		return p.removeIndirect(ctx, c)
+	}
+	return nil
+}
+
+// indirectPinRoot returns an indexed recursive pin whose DAG reaches c, or
+// cid.Undef if there is none. It must only be used when the indirect pin
+// index is valid.
+func (p *pinner) indirectPinRoot(ctx context.Context, c cid.Cid) (cid.Cid, error) {
+	for cur := c; ; {
+		parent, err := p.indirectParent(ctx, cur)
+		if err != nil {
+			return cid.Undef, err
+		}
+		if !parent.Defined() {
+			if cur == c {
+				return cid.Undef, nil
+			}
+			return cid.Undef, fmt.Errorf("inconsistent indirect pin index: %s is not linked from a recursive pin", cur)
+		}
+		pinned, err := p.cidRIndex.HasAny(ctx, parent.KeyString())
+		if err != nil {
+			return cid.Undef, err
+		}
+		if pinned {
+			return parent, nil
+		}
+		cur = parent
+	}
+}
+
+// indirectParent returns an indexed block linking to c, or cid.Undef if
+// there is none.
+func (p *pinner) indirectParent(ctx context.Context, c cid.Cid) (cid.Cid, error) {
+	var parent cid.Cid
+	var e error
+	err := p.indirectIndex.ForEach(ctx, c.KeyString(), func(key, value string) bool {
+		parent, e = cid.Cast([]byte(value))
+		return false
+	})
+	if err == nil {
+		err = e
+	}
+	if err != nil {
+		return cid.Undef, err
+	}
+	return parent, nil
+}
+
+// unindexedPinRoot returns an unindexed recursive pin whose DAG reaches c, or
+// cid.Undef if there is none.
+func (p *pinner) unindexedPinRoot(ctx context.Context, c cid.Cid) (cid.Cid, error) {
+	toCheck := cid.NewSet()
+	toCheck.Add(c)
+	pinned, err := p.checkIndirectWithWalk(ctx, p.unindexedIndex, toCheck, nil)
+	if err != nil || len(pinned) == 0 {
+		return cid.Undef, err
+	}
+	return pinned[0].Via, nil
+}
@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"path"
+	"strings"
 	"sync"
 
 	"github.com/ipfs/go-cid"
@@ -25,10 +26,11 @@ import (
 )
 
 const (
-	basePath     = "/pins"
-	pinKeyPath   = "/pins/pin"
-	indexKeyPath = "/pins/index"
-	dirtyKeyPath = "/pins/state/dirty"
+	basePath             = "/pins"
+	pinKeyPath           = "/pins/pin"
+	indexKeyPath         = "/pins/index"
+	dirtyKeyPath         = "/pins/state/dirty"
+	indirectStateKeyPath = "/pins/state/indirect"
 )
 
 var (
@@ -36,11 +38,14 @@ var (
 
 	linkDirect, linkRecursive string
 
-	pinCidDIndexPath string
-	pinCidRIndexPath string
-	pinNameIndexPath string
+	pinCidDIndexPath    string
+	pinCidRIndexPath    string
+	pinNameIndexPath    string
+	pinIndirectIndexKey string
+	pinUnindexedKey     string
 
-	dirtyKey = ds.NewKey(dirtyKeyPath)
+	dirtyKey         = ds.NewKey(dirtyKeyPath)
+	indirectStateKey = ds.NewKey(indirectStateKeyPath)
 
 	pinAtl atlas.Atlas
 )
@@ -61,6 +66,8 @@ func init() {
 	pinCidRIndexPath = path.Join(indexKeyPath, "cidRindex")
 	pinCidDIndexPath = path.Join(indexKeyPath, "cidDindex")
 	pinNameIndexPath = path.Join(indexKeyPath, "nameIndex")
+	pinIndirectIndexKey = path.Join(indexKeyPath, "indirectIndex")
+	pinUnindexedKey = path.Join(indexKeyPath, "unindexedIndex")
 
 	pinAtl = atlas.MustBuild(
 		atlas.BuildEntry(pin{}).StructMap().
@@ -95,6 +102,15 @@ type pinner struct {
 	cidRIndex dsindex.Indexer
 	nameIndex dsindex.Indexer
 
+	// indirectIndex maps the blocks of the DAGs of the recursive pins to
+	// the blocks linking to them, built from localDAG when it is set. It is
+	// only used while indirectValid is set. unindexedIndex holds the
+	// recursive pins whose DAGs are not in it.
+	indirectIndex  dsindex.Indexer
+	unindexedIndex dsindex.Indexer
+	localDAG       ipld.NodeGetter
+	indirectValid  bool
+
 	clean int64
 	dirty int64
 }
@@ -113,13 +129,44 @@ func (p *pin) dsKey() ds.Key {
 	return ds.NewKey(path.Join(pinKeyPath, p.Id))
 }
 
-func newPin(c cid.Cid, mode ipfspinner.Mode, name string) *pin {
-	return &pin{
+func newPin(c cid.Cid, mode ipfspinner.Mode, name string, metadata map[string]string) *pin {
+	pp := &pin{
 		Id:   path.Base(ds.RandomKey().String()),
 		Cid:  c,
 		Name: name,
 		Mode: mode,
 	}
+	if len(metadata) != 0 {
+		pp.Metadata = make(map[string]interface{}, len(metadata))
+		for k, v := range metadata {
+			pp.Metadata[k] = v
+		}
+	}
+	return pp
+}
+
+// metadata returns the metadata of the pin that has a string value.
+func (p *pin) metadata() map[string]string {
+	if len(p.Metadata) == 0 {
+		return nil
+	}
+	md := make(map[string]string, len(p.Metadata))
+	for k, v := range p.Metadata {
+		if s, ok := v.(string); ok {
+			md[k] = s
+		}
+	}
+	return md
+}
+
+func (p *pin) pinned() ipfspinner.Pinned {
+	return ipfspinner.Pinned{
+		Key:      p.Cid,
+		Mode:     p.Mode,
+		ID:       p.Id,
+		Name:     p.Name,
+		Metadata: p.metadata(),
+	}
 }
 
 type syncDAGService interface {
@@ -133,30 +180,38 @@ type syncDAGService interface {
 // By default, changes are automatically flushed to the datastore.  This can be
 // disabled by calling SetAutosync(false), which will require that Flush be
 // called explicitly.
-func New(ctx context.Context, dstore ds.Datastore, dserv ipld.DAGService) (*pinner, error) {
+func New(ctx context.Context, dstore ds.Datastore, dserv ipld.DAGService, opts ...Option) (*pinner, error) {
 	p := &pinner{
-		autoSync:  true,
-		cidDIndex: dsindex.New(dstore, ds.NewKey(pinCidDIndexPath)),
-		cidRIndex: dsindex.New(dstore, ds.NewKey(pinCidRIndexPath)),
-		nameIndex: dsindex.New(dstore, ds.NewKey(pinNameIndexPath)),
-		dserv:     dserv,
-		dstore:    dstore,
+		autoSync:       true,
+		cidDIndex:      dsindex.New(dstore, ds.NewKey(pinCidDIndexPath)),
+		cidRIndex:      dsindex.New(dstore, ds.NewKey(pinCidRIndexPath)),
+		nameIndex:      dsindex.New(dstore, ds.NewKey(pinNameIndexPath)),
+		indirectIndex:  dsindex.New(dstore, ds.NewKey(pinIndirectIndexKey)),
+		unindexedIndex: dsindex.New(dstore, ds.NewKey(pinUnindexedKey)),
+		dserv:          dserv,
+		dstore:         dstore,
+	}
+	for _, opt := range opts {
+		opt(p)
 	}
 
 	data, err := dstore.Get(ctx, dirtyKey)
-	if err != nil {
-		if err == ds.ErrNotFound {
-			return p, nil
-		}
+	if err != nil && err != ds.ErrNotFound {
 		return nil, fmt.Errorf("cannot load dirty flag: %v", err)
 	}
-	if data[0] == 1 {
+	if err == nil && data[0] == 1 {
 		p.dirty = 1
 
 		err = p.rebuildIndexes(ctx)
 		if err != nil {
 			return nil, fmt.Errorf("cannot rebuild indexes: %v", err)
 		}
+		return p, nil
+	}
+
+	err = p.loadIndirectIndex(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load indirect pin index: %v", err)
 	}
 
 	return p, nil
@@ -174,31 +229,28 @@ func (p *pinner) SetAutosync(auto bool) bool {
 }
 
 // Pin the given node, optionally recursive
-func (p *pinner) Pin(ctx context.Context, node ipld.Node, recurse bool) error {
+func (p *pinner) Pin(ctx context.Context, node ipld.Node, recurse bool, name string, metadata map[string]string) (string, error) {
 	err := p.dserv.Add(ctx, node)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	if recurse {
-		return p.doPinRecursive(ctx, node.Cid(), true)
+		return p.doPinRecursive(ctx, node.Cid(), true, name, metadata)
 	} else {
-		return p.doPinDirect(ctx, node.Cid())
+		return p.doPinDirect(ctx, node.Cid(), name, metadata)
 	}
 }
 
-func (p *pinner) doPinRecursive(ctx context.Context, c cid.Cid, fetch bool) error {
+func (p *pinner) doPinRecursive(ctx context.Context, c cid.Cid, fetch bool, name string, metadata map[string]string) (string, error) {
 	cidKey := c.KeyString()
 
 	p.lock.Lock()
 	defer p.lock.Unlock()
 
-	found, err := p.cidRIndex.HasAny(ctx, cidKey)
-	if err != nil {
-		return err
-	}
-	if found {
-		return nil
+	id, err := p.findPin(ctx, p.cidRIndex, cidKey, name)
+	if err != nil || id != "" {
+		return id, err
 	}
 
 	dirtyBefore := p.dirty
@@ -210,47 +262,44 @@ func (p *pinner) doPinRecursive(ctx context.Context, c cid.Cid, fetch bool) erro
 		err = merkledag.FetchGraph(ctx, c, p.dserv)
 		p.lock.Lock()
 		if err != nil {
-			return err
+			return "", err
 		}
 	}
 
 	// If autosyncing, sync dag service before making any change to pins
 	err = p.flushDagService(ctx, false)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	// Only look again if something has changed.
 	if p.dirty != dirtyBefore {
-		found, err = p.cidRIndex.HasAny(ctx, cidKey)
-		if err != nil {
-			return err
-		}
-		if found {
-			return nil
+		id, err = p.findPin(ctx, p.cidRIndex, cidKey, name)
+		if err != nil || id != "" {
+			return id, err
 		}
 	}
 
-	// TODO: remove this to support multiple pins per CID
-	found, err = p.cidDIndex.HasAny(ctx, cidKey)
+	// Direct pins are redundant with a recursive pin
+	found, err := p.cidDIndex.HasAny(ctx, cidKey)
 	if err != nil {
-		return err
+		return "", err
 	}
 	if found {
 		_, err = p.removePinsForCid(ctx, c, ipfspinner.Direct)
 		if err != nil {
-			return err
+			return "", err
 		}
 	}
 
-	_, err = p.addPin(ctx, c, ipfspinner.Recursive, "")
+	id, err = p.addPin(ctx, c, ipfspinner.Recursive, name, metadata)
 	if err != nil {
-		return err
+		return "", err
 	}
-	return p.flushPins(ctx, false)
+	return id, p.flushPins(ctx, false)
 }
 
-func (p *pinner) doPinDirect(ctx context.Context, c cid.Cid) error {
+func (p *pinner) doPinDirect(ctx context.Context, c cid.Cid, name string, metadata map[string]string) (string, error) {
 	cidKey := c.KeyString()
 
 	p.lock.Lock()
@@ -258,23 +307,51 @@ func (p *pinner) doPinDirect(ctx context.Context, c cid.Cid) error {
 
 	found, err := p.cidRIndex.HasAny(ctx, cidKey)
 	if err != nil {
-		return err
+		return "", err
 	}
 	if found {
-		return fmt.Errorf("%s already pinned recursively", c.String())
+		return "", fmt.Errorf("%s already pinned recursively", c.String())
 	}
 
-	_, err = p.addPin(ctx, c, ipfspinner.Direct, "")
+	id, err := p.findPin(ctx, p.cidDIndex, cidKey, name)
+	if err != nil || id != "" {
+		return id, err
+	}
+
+	id, err = p.addPin(ctx, c, ipfspinner.Direct, name, metadata)
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	return p.flushPins(ctx, false)
+	return id, p.flushPins(ctx, false)
 }
 
-func (p *pinner) addPin(ctx context.Context, c cid.Cid, mode ipfspinner.Mode, name string) (string, error) {
+// findPin returns the ID of the pin of cidKey in index with the given name,
+// or an empty ID if there is none.
+func (p *pinner) findPin(ctx context.Context, index dsindex.Indexer, cidKey, name string) (string, error) {
+	ids, err := index.Search(ctx, cidKey)
+	if err != nil {
+		return "", err
+	}
+	for _, pid := range ids {
+		pp, err := p.loadPin(ctx, pid)
+		if err != nil {
+			if err == ds.ErrNotFound {
+				// Stale index, fixed by removePinsForCid or rebuildIndexes
+				continue
+			}
+			return "", err
+		}
+		if pp.Name == name {
+			return pp.Id, nil
+		}
+	}
+	return "", nil
+}
+
+func (p *pinner) addPin(ctx context.Context, c cid.Cid, mode ipfspinner.Mode, name string, metadata map[string]string) (string, error) {
 	// Create new pin and store in datastore
-	pp := newPin(c, mode, name)
+	pp := newPin(c, mode, name, metadata)
 
 	// Serialize pin
 	pinData, err := encodePin(pp)
@@ -322,6 +399,10 @@ func (p *pinner) addPin(ctx context.Context, c cid.Cid, mode ipfspinner.Mode, na
 		}
 	}
 
+	if mode == ipfspinner.Recursive {
+		p.indexRecursivePin(ctx, c, true)
+	}
+
 	return pp.Id, nil
 }
 
@@ -332,6 +413,9 @@ func (p *pinner) removePin(ctx context.Context, pp *pin) error {
 	// Remove cid index from datastore
 	if pp.Mode == ipfspinner.Recursive {
 		err = p.cidRIndex.Delete(ctx, pp.Cid.KeyString(), pp.Id)
+		if err == nil {
+			p.indexRecursivePin(ctx, pp.Cid, false)
+		}
 	} else {
 		err = p.cidDIndex.Delete(ctx, pp.Cid.KeyString(), pp.Id)
 	}
@@ -401,6 +485,26 @@ func (p *pinner) Unpin(ctx context.Context, c cid.Cid, recursive bool) error {
 	return p.flushPins(ctx, false)
 }
 
+// UnpinByID removes the pin with the given ID
+func (p *pinner) UnpinByID(ctx context.Context, id string) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	pp, err := p.loadPin(ctx, id)
+	if err != nil {
+		if err == ds.ErrNotFound {
+			return ipfspinner.ErrNotPinned
+		}
+		return err
+	}
+
+	if err = p.removePin(ctx, pp); err != nil {
+		return err
+	}
+
+	return p.flushPins(ctx, false)
+}
+
 // IsPinned returns whether or not the given key is pinned
 // and an explanation of why its pinned
 func (p *pinner) IsPinned(ctx context.Context, c cid.Cid) (string, bool, error) {
@@ -465,6 +569,23 @@ func (p *pinner) isPinnedWithType(ctx context.Context, c cid.Cid, mode ipfspinne
 	}
 
 	// Default is Indirect
+	if p.indirectValid {
+		rc, err := p.indirectPinRoot(ctx, c)
+		if err != nil {
+			return "", false, err
+		}
+		if !rc.Defined() {
+			rc, err = p.unindexedPinRoot(ctx, c)
+			if err != nil {
+				return "", false, err
+			}
+		}
+		if rc.Defined() {
+			return rc.String(), true, nil
+		}
+		return "", false, nil
+	}
+
 	visitedSet := cid.NewSet()
 
 	// No index for given CID, so search children of all recursive pinned CIDs
@@ -532,9 +653,50 @@ func (p *pinner) CheckIfPinned(ctx context.Context, cids ...cid.Cid) ([]ipfspinn
 		}
 	}
 
+	var err error
+	if p.indirectValid {
+		pinned, err = p.checkIndirectWithIndex(ctx, toCheck, pinned)
+	} else {
+		pinned, err = p.checkIndirectWithWalk(ctx, p.cidRIndex, toCheck, pinned)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Anything left in toCheck is not pinned
+	for _, k := range toCheck.Keys() {
+		pinned = append(pinned, ipfspinner.Pinned{Key: k, Mode: ipfspinner.NotPinned})
+	}
+
+	return pinned, nil
+}
+
+// checkIndirectWithIndex looks up the cids of toCheck in the indirect pin
+// index, and in the DAGs of the unindexed pins, removing them from toCheck
+// and appending them to pinned when they are pinned.
+func (p *pinner) checkIndirectWithIndex(ctx context.Context, toCheck *cid.Set, pinned []ipfspinner.Pinned) ([]ipfspinner.Pinned, error) {
+	for _, c := range toCheck.Keys() {
+		rk, err := p.indirectPinRoot(ctx, c)
+		if err != nil {
+			return nil, err
+		}
+		if rk.Defined() {
+			pinned = append(pinned, ipfspinner.Pinned{Key: c, Mode: ipfspinner.Indirect, Via: rk})
+			toCheck.Remove(c)
+		}
+	}
+	return p.checkIndirectWithWalk(ctx, p.unindexedIndex, toCheck, pinned)
+}
+
+// checkIndirectWithWalk is like checkIndirectWithIndex, but walks the DAGs of
+// the recursive pins which are keys of roots to find the cids.
+func (p *pinner) checkIndirectWithWalk(ctx context.Context, roots dsindex.Indexer, toCheck *cid.Set, pinned []ipfspinner.Pinned) ([]ipfspinner.Pinned, error) {
+	if toCheck.Len() == 0 {
+		return pinned, nil
+	}
 	var e error
 	visited := cid.NewSet()
-	err := p.cidRIndex.ForEach(ctx, "", func(key, value string) bool {
+	err := roots.ForEach(ctx, "", func(key, value string) bool {
 		var rk cid.Cid
 		rk, e = cid.Cast([]byte(key))
 		if e != nil {
@@ -564,11 +726,6 @@ func (p *pinner) CheckIfPinned(ctx context.Context, cids ...cid.Cid) ([]ipfspinn
 		return nil, e
 	}
 
-	// Anything left in toCheck is not pinned
-	for _, k := range toCheck.Keys() {
-		pinned = append(pinned, ipfspinner.Pinned{Key: k, Mode: ipfspinner.NotPinned})
-	}
-
 	return pinned, nil
 }
 
@@ -618,6 +775,7 @@ func (p *pinner) removePinsForCid(ctx context.Context, c cid.Cid, mode ipfspinne
 					if err != nil {
 						return false, fmt.Errorf("error deleting index: %s", err)
 					}
+					p.indexRecursivePin(ctx, c, false)
 				case ipfspinner.Direct:
 					_, err = p.cidDIndex.DeleteKey(ctx, cidKey)
 					if err != nil {
@@ -628,6 +786,7 @@ func (p *pinner) removePinsForCid(ctx context.Context, c cid.Cid, mode ipfspinne
 					if err != nil {
 						return false, fmt.Errorf("error deleting index: %s", err)
 					}
+					p.indexRecursivePin(ctx, c, false)
 					_, err = p.cidDIndex.DeleteKey(ctx, cidKey)
 					if err != nil {
 						return false, fmt.Errorf("error deleting index: %s", err)
@@ -709,6 +868,84 @@ func (p *pinner) streamIndex(ctx context.Context, index dsindex.Indexer) <-chan
 	return out
 }
 
+// Ls returns the pins with the given mode that match the filter
+func (p *pinner) Ls(ctx context.Context, mode ipfspinner.Mode, filter ipfspinner.Filter) <-chan ipfspinner.StreamedPin {
+	out := make(chan ipfspinner.StreamedPin)
+
+	go func() {
+		defer close(out)
+
+		send := func(sp ipfspinner.StreamedPin) bool {
+			select {
+			case <-ctx.Done():
+				return false
+			case out <- sp:
+				return true
+			}
+		}
+
+		var indexes []dsindex.Indexer
+		switch mode {
+		case ipfspinner.Recursive:
+			indexes = []dsindex.Indexer{p.cidRIndex}
+		case ipfspinner.Direct:
+			indexes = []dsindex.Indexer{p.cidDIndex}
+		case ipfspinner.Any:
+			indexes = []dsindex.Indexer{p.cidRIndex, p.cidDIndex}
+		default:
+			send(ipfspinner.StreamedPin{Err: fmt.Errorf("invalid pin mode '%d', must be one of {%d, %d, %d}",
+				mode, ipfspinner.Recursive, ipfspinner.Direct, ipfspinner.Any)})
+			return
+		}
+
+		// Iterate the smallest index that can be used to find the pins
+		var key string
+		if filter.Cid.Defined() {
+			key = filter.Cid.KeyString()
+		} else if filter.NamePrefix != "" {
+			indexes = []dsindex.Indexer{p.nameIndex}
+		}
+
+		p.lock.RLock()
+		defer p.lock.RUnlock()
+
+		for _, index := range indexes {
+			var e error
+			err := index.ForEach(ctx, key, func(key, value string) bool {
+				if index == p.nameIndex && !strings.HasPrefix(key, filter.NamePrefix) {
+					return true
+				}
+				pp, err := p.loadPin(ctx, value)
+				if err != nil {
+					if err == ds.ErrNotFound {
+						// Stale index
+						return true
+					}
+					e = err
+					return false
+				}
+				if mode != ipfspinner.Any && pp.Mode != mode {
+					return true
+				}
+				pinned := pp.pinned()
+				if !filter.Matches(pinned) {
+					return true
+				}
+				return send(ipfspinner.StreamedPin{Pin: pinned})
+			})
+			if err == nil {
+				err = e
+			}
+			if err != nil {
+				send(ipfspinner.StreamedPin{Err: err})
+				return
+			}
+		}
+	}()
+
+	return out
+}
+
 // InternalPins returns all cids kept pinned for the internal state of the
 // pinner
 func (p *pinner) InternalPins(ctx context.Context) <-chan ipfspinner.StreamedCid {
@@ -718,9 +955,8 @@ func (p *pinner) InternalPins(ctx context.Context) <-chan ipfspinner.StreamedCid
 }
 
 // Update updates a recursive pin from one cid to another.  This is equivalent
-// to pinning the new one and unpinning the old one.
-//
-// TODO: This will not work when multiple pins are supported
+// to pinning the new one and unpinning the old one. Every recursive pin of
+// `from` is replaced by a pin of `to` with the same name and metadata.
 func (p *pinner) Update(ctx context.Context, from, to cid.Cid, unpin bool) error {
 	p.lock.Lock()
 	defer p.lock.Unlock()
@@ -756,16 +992,30 @@ func (p *pinner) Update(ctx context.Context, from, to cid.Cid, unpin bool) error
 		return err
 	}
 
-	_, err = p.addPin(ctx, to, ipfspinner.Recursive, "")
+	// Pin `to` with the names and metadata of the pins of `from`. `to` is
+	// pinned before `from` is unpinned, so that the indirect pin index only
+	// changes for the blocks which are not in both DAGs.
+	ids, err := p.cidRIndex.Search(ctx, from.KeyString())
 	if err != nil {
 		return err
 	}
-
-	if unpin {
-		_, err = p.removePinsForCid(ctx, from, ipfspinner.Recursive)
+	for _, pid := range ids {
+		pp, err := p.loadPin(ctx, pid)
+		if err != nil {
+			if err == ds.ErrNotFound {
+				continue
+			}
+			return err
+		}
+		_, err = p.addPin(ctx, to, ipfspinner.Recursive, pp.Name, pp.metadata())
 		if err != nil {
 			return err
 		}
+		if unpin {
+			if err = p.removePin(ctx, pp); err != nil {
+				return err
+			}
+		}
 	}
 
 	return p.flushPins(ctx, false)
@@ -809,15 +1059,14 @@ func (p *pinner) Flush(ctx context.Context) error {
 
 // PinWithMode allows the user to have fine grained control over pin
 // counts
-func (p *pinner) PinWithMode(ctx context.Context, c cid.Cid, mode ipfspinner.Mode) error {
-	// TODO: remove his to support multiple pins per CID
+func (p *pinner) PinWithMode(ctx context.Context, c cid.Cid, mode ipfspinner.Mode, name string, metadata map[string]string) (string, error) {
 	switch mode {
 	case ipfspinner.Recursive:
-		return p.doPinRecursive(ctx, c, false)
+		return p.doPinRecursive(ctx, c, false, name, metadata)
 	case ipfspinner.Direct:
-		return p.doPinDirect(ctx, c)
+		return p.doPinDirect(ctx, c, name, metadata)
 	default:
-		return errors.New("unrecognized pin mode")
+		return "", errors.New("unrecognized pin mode")
 	}
 }
 
@@ -1022,5 +1271,12 @@ func (p *pinner) rebuildIndexes(ctx context.Context) error {
 	}
 
 	log.Errorf("checked %d pins for invalid indexes, repaired %d pins", checkedCount, repairedCount)
+
+	if p.localDAG != nil {
+		p.buildIndirectIndex(ctx)
+	} else if err = p.forgetIndirectIndex(ctx); err != nil {
+		return err
+	}
+
 	return p.flushPins(ctx, true)
 }
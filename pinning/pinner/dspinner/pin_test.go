@@ -120,7 +120,7 @@ func TestPinnerBasic(t *testing.T) {
 	}
 
 	// Pin A{}
-	err = p.Pin(ctx, a, false)
+	_, err = p.Pin(ctx, a, false, "", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -154,7 +154,7 @@ func TestPinnerBasic(t *testing.T) {
 	bk := b.Cid()
 
 	// recursively pin B{A,C}
-	err = p.Pin(ctx, b, true)
+	_, err = p.Pin(ctx, b, true, "", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -191,7 +191,7 @@ func TestPinnerBasic(t *testing.T) {
 	}
 
 	// Add D{A,C,E}
-	err = p.Pin(ctx, d, true)
+	_, err = p.Pin(ctx, d, true, "", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -323,7 +323,7 @@ func TestPinnerBasic(t *testing.T) {
 	fakeLog := &fakeLogger{}
 	fakeLog.StandardLogger = log
 	log = fakeLog
-	err = p.Pin(ctx, a, true)
+	_, err = p.Pin(ctx, a, true, "", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -359,7 +359,7 @@ func TestAddLoadPin(t *testing.T) {
 
 	mode := ipfspin.Recursive
 	name := "my-pin"
-	pid, err := p.addPin(ctx, ak, mode, name)
+	pid, err := p.addPin(ctx, ak, mode, name, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -436,6 +436,275 @@ func TestIsPinnedLookup(t *testing.T) {
 	assertPinned(t, p, aKeys[0], "A0 should still be pinned through C")
 }
 
+func TestIndirectPinIndex(t *testing.T) {
+	aBranchLen := 6
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bstore := blockstore.NewBlockstore(dstore)
+	bserv := bs.New(bstore, offline.Exchange(bstore))
+
+	dserv := mdag.NewDAGService(bserv)
+
+	p, err := New(ctx, dstore, dserv, WithIndirectPinIndex(dserv))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	aKeys, bk, ck, err := makeTree(ctx, aBranchLen, dserv, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertIndirect := func(c cid.Cid, failmsg string) {
+		_, pinned, err := p.IsPinnedWithType(ctx, c, ipfspin.Indirect)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !pinned {
+			t.Fatal(failmsg)
+		}
+	}
+
+	// Without A2 the DAGs can't be walked, the index must be used
+	a2, err := bstore.Get(ctx, aKeys[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = bstore.DeleteBlock(ctx, aKeys[2]); err != nil {
+		t.Fatal(err)
+	}
+	assertIndirect(aKeys[1], "A1 should be pinned indirectly")
+	assertIndirect(aKeys[4], "A4 should be pinned indirectly")
+	assertIndirect(ck, "C should be pinned indirectly through B")
+	via, _, err := p.IsPinnedWithType(ctx, aKeys[4], ipfspin.Indirect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if via != aKeys[5].String() {
+		t.Fatalf("expected A4 to be pinned via A5, got %s", via)
+	}
+	_, pinned, err := p.IsPinnedWithType(ctx, aKeys[5], ipfspin.Indirect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pinned {
+		t.Fatal("A5 should not be pinned indirectly")
+	}
+
+	unpinned, _ := randNode()
+	res, err := p.CheckIfPinned(ctx, aKeys[0], unpinned.Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pn := range res {
+		switch {
+		case pn.Key.Equals(aKeys[0]):
+			if pn.Mode != ipfspin.Indirect {
+				t.Fatalf("expected A0 to be pinned indirectly, got %s", pn)
+			}
+		case pn.Mode != ipfspin.NotPinned:
+			t.Fatalf("expected %s not to be pinned, got %s", pn.Key, pn)
+		}
+	}
+
+	if err = bstore.Put(ctx, a2); err != nil {
+		t.Fatal(err)
+	}
+
+	// Unpinning updates the index
+	if err = p.Unpin(ctx, aKeys[5], true); err != nil {
+		t.Fatal(err)
+	}
+	assertPinned(t, p, aKeys[0], "A0 should still be pinned through B")
+	assertUnpinned(t, p, aKeys[4], "A4 should be unpinned")
+
+	// Reopening without the index forgets it, and reopening with it
+	// rebuilds it
+	p, err = New(ctx, dstore, dserv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = p.Unpin(ctx, bk, true); err != nil {
+		t.Fatal(err)
+	}
+	p, err = New(ctx, dstore, dserv, WithIndirectPinIndex(dserv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.indirectValid {
+		t.Fatal("the index should have been rebuilt")
+	}
+	assertUnpinned(t, p, bk, "B should be unpinned")
+	assertUnpinned(t, p, aKeys[1], "A1 should be unpinned")
+	assertIndirect(aKeys[0], "A0 should still be pinned through C")
+
+	// An unclean shutdown rebuilds the index
+	if _, err = p.indirectIndex.DeleteAll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	p.setDirty(ctx)
+	p, err = New(ctx, dstore, dserv, WithIndirectPinIndex(dserv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertIndirect(aKeys[0], "A0 should be pinned through C after the rebuild")
+}
+
+// countingNodeGetter counts the blocks that are read.
+type countingNodeGetter struct {
+	ipld.NodeGetter
+	gets int
+}
+
+func (g *countingNodeGetter) Get(ctx context.Context, c cid.Cid) (ipld.Node, error) {
+	g.gets++
+	return g.NodeGetter.Get(ctx, c)
+}
+
+func TestIndirectPinIndexUnindexedPin(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bstore := blockstore.NewBlockstore(dstore)
+	bserv := bs.New(bstore, offline.Exchange(bstore))
+	dserv := mdag.NewDAGService(bserv)
+
+	p, err := New(ctx, dstore, dserv, WithIndirectPinIndex(dserv))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The child of the pinned root is not stored locally.
+	child, ck := randNode()
+	root, _ := randNode()
+	if err = root.AddNodeLink("child", child); err != nil {
+		t.Fatal(err)
+	}
+	rk := root.Cid()
+	if err = dserv.Add(ctx, root); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = p.PinWithMode(ctx, rk, ipfspin.Recursive, "", nil); err != nil {
+		t.Fatal(err)
+	}
+	if !p.indirectValid {
+		t.Fatal("a pin which is not stored locally should not disable the index")
+	}
+	if unindexed, err := p.unindexedIndex.HasAny(ctx, rk.KeyString()); err != nil || !unindexed {
+		t.Fatal("the pin should not be indexed", err)
+	}
+	if linked, err := p.indirectIndex.HasAny(ctx, ck.KeyString()); err != nil || linked {
+		t.Fatal("the index should not hold the links of the pin", err)
+	}
+
+	// The DAG of the unindexed pin is walked once it is stored.
+	if err = dserv.Add(ctx, child); err != nil {
+		t.Fatal(err)
+	}
+	if _, pinned, err := p.IsPinnedWithType(ctx, ck, ipfspin.Indirect); err != nil || !pinned {
+		t.Fatal("child should be pinned indirectly", err)
+	}
+
+	// Reopening the pinner indexes it.
+	p, err = New(ctx, dstore, dserv, WithIndirectPinIndex(dserv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unindexed, err := p.unindexedIndex.HasAny(ctx, ""); err != nil || unindexed {
+		t.Fatal("the pin should be indexed", err)
+	}
+	via, _, err := p.IsPinnedWithType(ctx, ck, ipfspin.Indirect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if via != rk.String() {
+		t.Fatalf("expected child to be pinned via root, got %q", via)
+	}
+
+	if err = p.Unpin(ctx, rk, true); err != nil {
+		t.Fatal(err)
+	}
+	assertUnpinned(t, p, ck, "child should be unpinned")
+}
+
+func TestIndirectPinIndexUpdate(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bstore := blockstore.NewBlockstore(dstore)
+	bserv := bs.New(bstore, offline.Exchange(bstore))
+	dserv := mdag.NewDAGService(bserv)
+
+	local := &countingNodeGetter{NodeGetter: dserv}
+	p, err := New(ctx, dstore, dserv, WithIndirectPinIndex(local))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const childCount = 20
+	from, _ := randNode()
+	var children []cid.Cid
+	for i := 0; i < childCount; i++ {
+		c, ck := randNode()
+		if err = dserv.Add(ctx, c); err != nil {
+			t.Fatal(err)
+		}
+		if err = from.AddNodeLink(fmt.Sprint(i), c); err != nil {
+			t.Fatal(err)
+		}
+		children = append(children, ck)
+	}
+	if err = dserv.Add(ctx, from); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = p.Pin(ctx, from, true, "", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Replace the first child, and add a new one
+	to := from.Copy().(*mdag.ProtoNode)
+	if err = to.RemoveNodeLink("0"); err != nil {
+		t.Fatal(err)
+	}
+	added, ak := randNode()
+	if err = dserv.Add(ctx, added); err != nil {
+		t.Fatal(err)
+	}
+	if err = to.AddNodeLink("new", added); err != nil {
+		t.Fatal(err)
+	}
+	if err = dserv.Add(ctx, to); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the blocks which differ are read: both roots, the new child
+	// and the removed one.
+	local.gets = 0
+	if err = p.Update(ctx, from.Cid(), to.Cid(), true); err != nil {
+		t.Fatal(err)
+	}
+	if local.gets > 4 {
+		t.Fatalf("updating the pin read %d blocks, expected at most 4", local.gets)
+	}
+	if !p.indirectValid {
+		t.Fatal("the index should be valid")
+	}
+
+	for _, c := range []cid.Cid{ak, children[1]} {
+		via, _, err := p.IsPinnedWithType(ctx, c, ipfspin.Indirect)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if via != to.Cid().String() {
+			t.Fatalf("expected %s to be pinned via the new root, got %q", c, via)
+		}
+	}
+	assertUnpinned(t, p, children[0], "the removed child should be unpinned")
+	assertUnpinned(t, p, from.Cid(), "the old root should be unpinned")
+}
+
 func TestDuplicateSemantics(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -457,19 +726,19 @@ func TestDuplicateSemantics(t *testing.T) {
 	}
 
 	// pin is recursively
-	err = p.Pin(ctx, a, true)
+	_, err = p.Pin(ctx, a, true, "", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	// pinning directly should fail
-	err = p.Pin(ctx, a, false)
+	_, err = p.Pin(ctx, a, false, "", nil)
 	if err == nil {
 		t.Fatal("expected direct pin to fail")
 	}
 
 	// pinning recursively again should succeed
-	err = p.Pin(ctx, a, true)
+	_, err = p.Pin(ctx, a, true, "", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -489,7 +758,7 @@ func TestFlush(t *testing.T) {
 	}
 	_, k := randNode()
 
-	p.PinWithMode(ctx, k, ipfspin.Recursive)
+	p.PinWithMode(ctx, k, ipfspin.Recursive, "", nil)
 	if err = p.Flush(ctx); err != nil {
 		t.Fatal(err)
 	}
@@ -520,7 +789,7 @@ func TestPinRecursiveFail(t *testing.T) {
 	mctx, cancel := context.WithTimeout(ctx, time.Millisecond)
 	defer cancel()
 
-	err = p.Pin(mctx, a, true)
+	_, err = p.Pin(mctx, a, true, "", nil)
 	if err == nil {
 		t.Fatal("should have failed to pin here")
 	}
@@ -538,7 +807,7 @@ func TestPinRecursiveFail(t *testing.T) {
 	// this one is time based... but shouldnt cause any issues
 	mctx, cancel = context.WithTimeout(ctx, time.Second)
 	defer cancel()
-	err = p.Pin(mctx, a, true)
+	_, err = p.Pin(mctx, a, true, "", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -568,7 +837,7 @@ func TestPinUpdate(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	if err = p.Pin(ctx, n1, true); err != nil {
+	if _, err = p.Pin(ctx, n1, true, "", nil); err != nil {
 		t.Fatal(err)
 	}
 
@@ -646,7 +915,7 @@ func TestLoadDirty(t *testing.T) {
 
 	_, bk := randNode()
 
-	err = p.Pin(ctx, a, true)
+	_, err = p.Pin(ctx, a, true, "", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -720,10 +989,146 @@ func TestLoadDirty(t *testing.T) {
 	}
 }
 
+func collectPins(t *testing.T, p ipfspin.Pinner, mode ipfspin.Mode, filter ipfspin.Filter) map[string]ipfspin.Pinned {
+	pins := make(map[string]ipfspin.Pinned)
+	for sp := range p.Ls(context.Background(), mode, filter) {
+		if sp.Err != nil {
+			t.Fatal(sp.Err)
+		}
+		pins[sp.Pin.ID] = sp.Pin
+	}
+	return pins
+}
+
+func TestNamedPins(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bstore := blockstore.NewBlockstore(dstore)
+	bserv := bs.New(bstore, offline.Exchange(bstore))
+	dserv := mdag.NewDAGService(bserv)
+
+	p, err := New(ctx, dstore, dserv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, ak := randNode()
+	_, bk := randNode()
+
+	idA1, err := p.Pin(ctx, a, true, "tenant-1/a", map[string]string{"tenant": "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	idA2, err := p.Pin(ctx, a, true, "tenant-2/a", map[string]string{"tenant": "2", "tier": "hot"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idA1 == idA2 {
+		t.Fatal("pins with different names should have different IDs")
+	}
+	id, err := p.Pin(ctx, a, true, "tenant-1/a", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != idA1 {
+		t.Fatal("pinning again with the same name should return the existing pin")
+	}
+	idB, err := p.PinWithMode(ctx, bk, ipfspin.Direct, "tenant-2/b", map[string]string{"tenant": "2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pins := collectPins(t, p, ipfspin.Any, ipfspin.Filter{})
+	if len(pins) != 3 {
+		t.Fatalf("expected 3 pins, got %d", len(pins))
+	}
+	pa := pins[idA2]
+	if !pa.Key.Equals(ak) || pa.Mode != ipfspin.Recursive || pa.Name != "tenant-2/a" || pa.Metadata["tier"] != "hot" {
+		t.Fatalf("bad pin %+v", pa)
+	}
+	if pins[idB].Mode != ipfspin.Direct {
+		t.Fatal("expected direct pin")
+	}
+
+	for _, tc := range []struct {
+		mode   ipfspin.Mode
+		filter ipfspin.Filter
+		expect []string
+	}{
+		{ipfspin.Recursive, ipfspin.Filter{}, []string{idA1, idA2}},
+		{ipfspin.Direct, ipfspin.Filter{}, []string{idB}},
+		{ipfspin.Any, ipfspin.Filter{NamePrefix: "tenant-2/"}, []string{idA2, idB}},
+		{ipfspin.Recursive, ipfspin.Filter{NamePrefix: "tenant-2/"}, []string{idA2}},
+		{ipfspin.Any, ipfspin.Filter{Metadata: map[string]string{"tenant": "1"}}, []string{idA1}},
+		{ipfspin.Any, ipfspin.Filter{Metadata: map[string]string{"tenant": "2", "tier": "hot"}}, []string{idA2}},
+		{ipfspin.Any, ipfspin.Filter{Cid: ak}, []string{idA1, idA2}},
+		{ipfspin.Any, ipfspin.Filter{Cid: bk, NamePrefix: "tenant-1/"}, nil},
+	} {
+		pins := collectPins(t, p, tc.mode, tc.filter)
+		if len(pins) != len(tc.expect) {
+			t.Fatalf("filter %+v: expected %d pins, got %d", tc.filter, len(tc.expect), len(pins))
+		}
+		for _, id := range tc.expect {
+			if _, ok := pins[id]; !ok {
+				t.Fatalf("filter %+v: missing pin %s", tc.filter, id)
+			}
+		}
+	}
+
+	// Removing one of the pins of a CID leaves it pinned
+	if err = p.UnpinByID(ctx, idA1); err != nil {
+		t.Fatal(err)
+	}
+	assertPinned(t, p, ak, "a should still be pinned by its other pin")
+	if err = p.UnpinByID(ctx, idA1); err != ipfspin.ErrNotPinned {
+		t.Fatalf("expected ErrNotPinned, got %v", err)
+	}
+
+	// Names and metadata are persisted
+	p, err = New(ctx, dstore, dserv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pins = collectPins(t, p, ipfspin.Any, ipfspin.Filter{NamePrefix: "tenant-2/"})
+	if len(pins) != 2 || pins[idA2].Metadata["tier"] != "hot" {
+		t.Fatalf("bad pins after reload: %+v", pins)
+	}
+
+	// Update keeps the names and metadata
+	c, ck := randNode()
+	if err = dserv.Add(ctx, c); err != nil {
+		t.Fatal(err)
+	}
+	if err = p.Update(ctx, ak, ck, true); err != nil {
+		t.Fatal(err)
+	}
+	assertUnpinned(t, p, ak, "a should have been unpinned by the update")
+	pins = collectPins(t, p, ipfspin.Recursive, ipfspin.Filter{Cid: ck})
+	if len(pins) != 1 {
+		t.Fatalf("expected 1 pin of c, got %d", len(pins))
+	}
+	for _, pc := range pins {
+		if pc.Name != "tenant-2/a" || pc.Metadata["tier"] != "hot" {
+			t.Fatalf("bad updated pin %+v", pc)
+		}
+	}
+
+	// Unpin removes all the pins of a CID
+	if _, err = p.Pin(ctx, c, true, "other", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err = p.Unpin(ctx, ck, true); err != nil {
+		t.Fatal(err)
+	}
+	assertUnpinned(t, p, ck, "c should not be pinned")
+}
+
 func TestEncodeDecodePin(t *testing.T) {
 	_, c := randNode()
 
-	pin := newPin(c, ipfspin.Recursive, "testpin")
+	pin := newPin(c, ipfspin.Recursive, "testpin", nil)
 	pin.Metadata = make(map[string]interface{}, 2)
 	pin.Metadata["hello"] = "world"
 	pin.Metadata["foo"] = "bar"
@@ -787,7 +1192,7 @@ func makeTree(ctx context.Context, aBranchLen int, dserv ipld.DAGService, p ipfs
 	}
 
 	// Pin last A recursively
-	if err = p.Pin(ctx, aNodes[aBranchLen-1], true); err != nil {
+	if _, err = p.Pin(ctx, aNodes[aBranchLen-1], true, "", nil); err != nil {
 		return
 	}
 
@@ -820,12 +1225,12 @@ func makeTree(ctx context.Context, aBranchLen int, dserv ipld.DAGService, p ipfs
 	bk = b.Cid()
 
 	// Pin C recursively
-	if err = p.Pin(ctx, c, true); err != nil {
+	if _, err = p.Pin(ctx, c, true, "", nil); err != nil {
 		return
 	}
 
 	// Pin B recursively
-	if err = p.Pin(ctx, b, true); err != nil {
+	if _, err = p.Pin(ctx, b, true, "", nil); err != nil {
 		return
 	}
 
@@ -857,7 +1262,7 @@ func pinNodes(nodes []ipld.Node, p ipfspin.Pinner, recursive bool) {
 	var err error
 
 	for i := range nodes {
-		err = p.Pin(ctx, nodes[i], recursive)
+		_, err = p.Pin(ctx, nodes[i], recursive, "", nil)
 		if err != nil {
 			panic(err)
 		}
@@ -975,7 +1380,7 @@ func benchmarkNthPin(b *testing.B, count int, pinner ipfspin.Pinner, dserv ipld.
 	which := count - 1
 	for i := 0; i < b.N; i++ {
 		// Pin the Nth node and Flush
-		err := pinner.Pin(ctx, nodes[which], true)
+		_, err := pinner.Pin(ctx, nodes[which], true, "", nil)
 		if err != nil {
 			panic(err)
 		}
@@ -1021,7 +1426,7 @@ func benchmarkNPins(b *testing.B, count int, pinner ipfspin.Pinner, dserv ipld.D
 	for i := 0; i < b.N; i++ {
 		// Pin all the nodes one at a time.
 		for j := range nodes {
-			err := pinner.Pin(ctx, nodes[j], true)
+			_, err := pinner.Pin(ctx, nodes[j], true, "", nil)
 			if err != nil {
 				panic(err)
 			}
@@ -1155,7 +1560,7 @@ func TestCidIndex(t *testing.T) {
 	cidKey := c.KeyString()
 
 	// Pin the cid
-	pid, err := pinner.addPin(ctx, c, ipfspin.Recursive, "")
+	pid, err := pinner.addPin(ctx, c, ipfspin.Recursive, "", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -6,6 +6,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 
 	cid "github.com/ipfs/go-cid"
 	ipld "github.com/ipfs/go-ipld-format"
@@ -92,16 +93,23 @@ type Pinner interface {
 	// given pin type, as well as returning the type of pin its pinned with.
 	IsPinnedWithType(ctx context.Context, c cid.Cid, mode Mode) (string, bool, error)
 
-	// Pin the given node, optionally recursively.
+	// Pin the given node, optionally recursively, under an optional name and
+	// with optional metadata, and return the ID of the pin.
 	// Pin will make sure that the given node and its children if recursive is set
 	// are stored locally.
-	Pin(ctx context.Context, node ipld.Node, recursive bool) error
+	// A cid may be pinned several times with different names. Pinning it
+	// again with the same name and mode returns the ID of the existing pin.
+	Pin(ctx context.Context, node ipld.Node, recursive bool, name string, metadata map[string]string) (string, error)
 
 	// Unpin the given cid. If recursive is true, removes either a recursive or
 	// a direct pin. If recursive is false, only removes a direct pin.
 	// If the pin doesn't exist, return ErrNotPinned
 	Unpin(ctx context.Context, cid cid.Cid, recursive bool) error
 
+	// UnpinByID removes the pin with the given ID, leaving the other pins of
+	// its cid. If the pin doesn't exist, return ErrNotPinned
+	UnpinByID(ctx context.Context, id string) error
+
 	// Update updates a recursive pin from one cid to another
 	// this is more efficient than simply pinning the new one and unpinning the
 	// old one
@@ -114,7 +122,7 @@ type Pinner interface {
 	// PinWithMode is for manually editing the pin structure. Use with
 	// care! If used improperly, garbage collection may not be
 	// successful.
-	PinWithMode(context.Context, cid.Cid, Mode) error
+	PinWithMode(ctx context.Context, c cid.Cid, mode Mode, name string, metadata map[string]string) (string, error)
 
 	// Flush writes the pin state to the backing datastore
 	Flush(ctx context.Context) error
@@ -125,6 +133,10 @@ type Pinner interface {
 	// RecursiveKeys returns all recursively pinned cids
 	RecursiveKeys(ctx context.Context) <-chan StreamedCid
 
+	// Ls returns the recursive, direct or Any of these pins that match the
+	// filter, with their ID, name and metadata.
+	Ls(ctx context.Context, mode Mode, filter Filter) <-chan StreamedPin
+
 	// InternalPins returns all cids kept pinned for the internal state of the
 	// pinner
 	InternalPins(ctx context.Context) <-chan StreamedCid
@@ -138,6 +150,12 @@ type Pinned struct {
 	Key  cid.Cid
 	Mode Mode
 	Via  cid.Cid
+
+	// ID, Name and Metadata are set for the recursive and direct pins
+	// returned by Ls.
+	ID       string
+	Name     string
+	Metadata map[string]string
 }
 
 // Pinned returns whether or not the given cid is pinned
@@ -163,3 +181,40 @@ type StreamedCid struct {
 	C   cid.Cid
 	Err error
 }
+
+// StreamedPin encapsulate a Pinned and an error for a function to return a
+// channel of pins.
+type StreamedPin struct {
+	Pin Pinned
+	Err error
+}
+
+// Filter selects the pins returned by Ls. The zero Filter matches all pins.
+type Filter struct {
+	// Cid, if defined, only matches the pins of this cid.
+	Cid cid.Cid
+
+	// NamePrefix only matches the pins whose name starts with it.
+	NamePrefix string
+
+	// Metadata only matches the pins that have all of its keys, with the
+	// same values.
+	Metadata map[string]string
+}
+
+// Matches returns whether p is selected by the filter. The mode is not
+// part of the filter.
+func (f Filter) Matches(p Pinned) bool {
+	if f.Cid.Defined() && !f.Cid.Equals(p.Key) {
+		return false
+	}
+	if !strings.HasPrefix(p.Name, f.NamePrefix) {
+		return false
+	}
+	for k, v := range f.Metadata {
+		if pv, ok := p.Metadata[k]; !ok || pv != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,99 @@
+// Package pinutil provides tools to move the pins of a [pin.Pinner] to another
+// one, and to verify that the DAGs of the recursive pins are stored locally.
+package pinutil
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	pin "github.com/ipfs/boxo/pinning/pinner"
+	"github.com/ipfs/go-cid"
+	logging "github.com/ipfs/go-log/v2"
+)
+
+var log = logging.Logger("pinutil")
+
+// Record is a recursive or direct pin, as written by Export and read by
+// Import, one JSON object per line.
+type Record struct {
+	Cid cid.Cid
+	// Mode is "recursive" or "direct".
+	Mode     string
+	Name     string            `json:",omitempty"`
+	Metadata map[string]string `json:",omitempty"`
+}
+
+// Export writes the recursive and direct pins of p to w as newline-delimited
+// JSON [Record]s, with their names and metadata, and returns the number of
+// exported pins.
+func Export(ctx context.Context, p pin.Pinner, w io.Writer) (int, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	var count int
+	for sp := range p.Ls(ctx, pin.Any, pin.Filter{}) {
+		if sp.Err != nil {
+			return count, sp.Err
+		}
+		mode, _ := pin.ModeToString(sp.Pin.Mode)
+		err := enc.Encode(Record{
+			Cid:      sp.Pin.Key,
+			Mode:     mode,
+			Name:     sp.Pin.Name,
+			Metadata: sp.Pin.Metadata,
+		})
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+	if err := ctx.Err(); err != nil {
+		return count, err
+	}
+	return count, bw.Flush()
+}
+
+// Import pins the [Record]s read from r, as written by Export, in p, and
+// returns the number of imported pins. Pins that already exist with the same
+// name and mode are kept as they are.
+//
+// The DAGs of the recursive pins are not fetched: Verify with WithFetcher can
+// retrieve the blocks that are not stored locally.
+func Import(ctx context.Context, p pin.Pinner, r io.Reader) (int, error) {
+	dec := json.NewDecoder(r)
+	var count int
+	for {
+		var rec Record
+		err := dec.Decode(&rec)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return count, fmt.Errorf("cannot decode pin record %d: %w", count+1, err)
+		}
+		if err = ctx.Err(); err != nil {
+			return count, err
+		}
+
+		mode, ok := pin.StringToMode(rec.Mode)
+		if !ok || (mode != pin.Recursive && mode != pin.Direct) {
+			return count, fmt.Errorf("invalid mode %q for pin of %s", rec.Mode, rec.Cid)
+		}
+		if !rec.Cid.Defined() {
+			return count, fmt.Errorf("pin record %d has no cid", count+1)
+		}
+		_, err = p.PinWithMode(ctx, rec.Cid, mode, rec.Name, rec.Metadata)
+		if err != nil {
+			return count, fmt.Errorf("cannot pin %s: %w", rec.Cid, err)
+		}
+		count++
+	}
+
+	log.Infof("imported %d pins", count)
+	return count, p.Flush(ctx)
+}
@@ -0,0 +1,172 @@
+package pinutil
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/boxo/blockservice"
+	"github.com/ipfs/boxo/blockstore"
+	offline "github.com/ipfs/boxo/exchange/offline"
+	bsfetcher "github.com/ipfs/boxo/fetcher/impl/blockservice"
+	"github.com/ipfs/boxo/ipld/merkledag"
+	pin "github.com/ipfs/boxo/pinning/pinner"
+	"github.com/ipfs/boxo/pinning/pinner/dspinner"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/stretchr/testify/require"
+)
+
+type testNode struct {
+	bs     blockstore.Blockstore
+	dserv  ipld.DAGService
+	pinner pin.Pinner
+}
+
+func newTestNode(t *testing.T) *testNode {
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	bs := blockstore.NewBlockstore(ds)
+	dserv := merkledag.NewDAGService(blockservice.New(bs, offline.Exchange(bs)))
+	pinner, err := dspinner.New(context.Background(), ds, dserv)
+	require.NoError(t, err)
+	return &testNode{bs: bs, dserv: dserv, pinner: pinner}
+}
+
+// addDAG adds a DAG root -> child -> raw leaf and returns its nodes.
+func (n *testNode) addDAG(t *testing.T, name string) (root, child, leaf ipld.Node) {
+	ctx := context.Background()
+	leaf = merkledag.NewRawNode([]byte(name + " leaf"))
+	childNode := merkledag.NodeWithData([]byte(name + " child"))
+	require.NoError(t, childNode.AddNodeLink("leaf", leaf))
+	rootNode := merkledag.NodeWithData([]byte(name + " root"))
+	require.NoError(t, rootNode.AddNodeLink("child", childNode))
+	require.NoError(t, n.dserv.AddMany(ctx, []ipld.Node{leaf, childNode, rootNode}))
+	return rootNode, childNode, leaf
+}
+
+func collectPins(t *testing.T, p pin.Pinner) map[string]pin.Pinned {
+	pins := make(map[string]pin.Pinned)
+	for sp := range p.Ls(context.Background(), pin.Any, pin.Filter{}) {
+		require.NoError(t, sp.Err)
+		pins[sp.Pin.Key.String()+"/"+sp.Pin.Name] = sp.Pin
+	}
+	return pins
+}
+
+func TestExportImport(t *testing.T) {
+	ctx := context.Background()
+	src := newTestNode(t)
+	root, _, leaf := src.addDAG(t, "a")
+
+	_, err := src.pinner.Pin(ctx, root, true, "dataset", map[string]string{"owner": "alice"})
+	require.NoError(t, err)
+	_, err = src.pinner.Pin(ctx, root, true, "", nil)
+	require.NoError(t, err)
+	_, err = src.pinner.Pin(ctx, leaf, false, "leaf", nil)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	n, err := Export(ctx, src.pinner, &buf)
+	require.NoError(t, err)
+	require.Equal(t, 3, n)
+	require.Equal(t, 3, strings.Count(buf.String(), "\n"))
+
+	dst := newTestNode(t)
+	exported := buf.String()
+	n, err = Import(ctx, dst.pinner, strings.NewReader(exported))
+	require.NoError(t, err)
+	require.Equal(t, 3, n)
+
+	srcPins := collectPins(t, src.pinner)
+	dstPins := collectPins(t, dst.pinner)
+	require.Len(t, dstPins, len(srcPins))
+	for k, p := range srcPins {
+		dp, ok := dstPins[k]
+		require.True(t, ok, "missing pin %s", k)
+		require.Equal(t, p.Mode, dp.Mode)
+		require.Equal(t, p.Metadata, dp.Metadata)
+	}
+
+	// Importing again keeps the existing pins
+	_, err = Import(ctx, dst.pinner, strings.NewReader(exported))
+	require.NoError(t, err)
+	require.Len(t, collectPins(t, dst.pinner), len(srcPins))
+
+	_, err = Import(ctx, dst.pinner, strings.NewReader(`{"Cid":{"/":"`+root.Cid().String()+`"},"Mode":"indirect"}`))
+	require.ErrorContains(t, err, "invalid mode")
+	_, err = Import(ctx, dst.pinner, strings.NewReader("not json"))
+	require.Error(t, err)
+}
+
+func TestVerify(t *testing.T) {
+	ctx := context.Background()
+	node := newTestNode(t)
+
+	okRoot, _, _ := node.addDAG(t, "ok")
+	missingRoot, missingChild, _ := node.addDAG(t, "missing")
+	corruptRoot, _, corruptLeaf := node.addDAG(t, "corrupt")
+	for _, nd := range []ipld.Node{okRoot, missingRoot, corruptRoot} {
+		_, err := node.pinner.Pin(ctx, nd, true, "", nil)
+		require.NoError(t, err)
+	}
+	_, err := node.pinner.Pin(ctx, missingRoot, true, "other", nil)
+	require.NoError(t, err)
+
+	// Keep the original blocks around to repair the DAGs
+	backup := newTestNode(t)
+	require.NoError(t, backup.dserv.AddMany(ctx, []ipld.Node{missingChild, corruptLeaf}))
+
+	require.NoError(t, node.bs.DeleteBlock(ctx, missingChild.Cid()))
+	require.NoError(t, node.bs.DeleteBlock(ctx, corruptLeaf.Cid()))
+	corrupted, err := blocks.NewBlockWithCid([]byte("corrupted"), corruptLeaf.Cid())
+	require.NoError(t, err)
+	require.NoError(t, node.bs.Put(ctx, corrupted))
+
+	results := make(map[cid.Cid][]VerifyResult)
+	for res := range Verify(ctx, node.pinner, node.bs) {
+		require.NoError(t, res.Err)
+		require.False(t, res.OK())
+		results[res.Pin.Key] = append(results[res.Pin.Key], res)
+	}
+	require.Len(t, results, 2)
+	require.Len(t, results[missingRoot.Cid()], 2, "both pins of the root are reported")
+	require.Equal(t, []cid.Cid{missingChild.Cid()}, results[missingRoot.Cid()][0].Missing)
+	require.Empty(t, results[missingRoot.Cid()][0].Corrupt)
+	require.Len(t, results[corruptRoot.Cid()], 1)
+	require.Equal(t, []cid.Cid{corruptLeaf.Cid()}, results[corruptRoot.Cid()][0].Corrupt)
+
+	// The blockstore reports corrupt blocks itself with HashOnRead
+	node.bs.HashOnRead(true)
+	var count int
+	for res := range Verify(ctx, node.pinner, node.bs, WithValidPins()) {
+		require.NoError(t, res.Err)
+		if res.Pin.Key.Equals(corruptRoot.Cid()) {
+			require.Equal(t, []cid.Cid{corruptLeaf.Cid()}, res.Corrupt)
+		}
+		count++
+	}
+	require.Equal(t, 4, count)
+	node.bs.HashOnRead(false)
+
+	// Repair the DAGs from the backup
+	fetcherFactory := bsfetcher.NewFetcherConfig(blockservice.New(node.bs, offline.Exchange(backup.bs)))
+	count = 0
+	for res := range Verify(ctx, node.pinner, node.bs, WithFetcher(fetcherFactory)) {
+		require.NoError(t, res.Err)
+		require.True(t, res.Repaired)
+		require.True(t, res.OK())
+		count++
+	}
+	require.Equal(t, 3, count)
+
+	for res := range Verify(ctx, node.pinner, node.bs) {
+		t.Fatalf("unexpected result after repair: %+v", res)
+	}
+	blk, err := node.bs.Get(ctx, corruptLeaf.Cid())
+	require.NoError(t, err)
+	require.Equal(t, corruptLeaf.RawData(), blk.RawData())
+}
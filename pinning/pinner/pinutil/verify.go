@@ -0,0 +1,282 @@
+package pinutil
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ipfs/boxo/blockstore"
+	"github.com/ipfs/boxo/fetcher"
+	"github.com/ipfs/boxo/fetcher/helpers"
+	pin "github.com/ipfs/boxo/pinning/pinner"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	dagpb "github.com/ipld/go-codec-dagpb"
+	"github.com/ipld/go-ipld-prime/datamodel"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipld/go-ipld-prime/multicodec"
+	basicnode "github.com/ipld/go-ipld-prime/node/basic"
+	"github.com/ipld/go-ipld-prime/traversal"
+	mh "github.com/multiformats/go-multihash"
+
+	// blank imports are used to register the IPLD codecs of the DAGs
+	_ "github.com/ipld/go-ipld-prime/codec/dagcbor"
+	_ "github.com/ipld/go-ipld-prime/codec/dagjson"
+	_ "github.com/ipld/go-ipld-prime/codec/raw"
+)
+
+// VerifyResult is the state of the DAG of a recursive pin.
+type VerifyResult struct {
+	// Pin is the recursive pin, with its ID, name and metadata.
+	Pin pin.Pinned
+
+	// Missing are the blocks of the DAG that are not in the blockstore. The
+	// descendants of missing blocks are not verified.
+	Missing []cid.Cid
+
+	// Corrupt are the blocks of the DAG whose data does not match their
+	// hash.
+	Corrupt []cid.Cid
+
+	// Repaired is set when the missing and corrupt blocks were fetched
+	// again and the DAG is now complete.
+	Repaired bool
+
+	// Err is set when the DAG could not be verified or repaired.
+	Err error
+}
+
+// OK returns whether the DAG of the pin is complete and valid, or was
+// repaired.
+func (r VerifyResult) OK() bool {
+	if r.Err != nil {
+		return false
+	}
+	return r.Repaired || (len(r.Missing) == 0 && len(r.Corrupt) == 0)
+}
+
+type verifyOptions struct {
+	fetcher  fetcher.Factory
+	reportOK bool
+}
+
+// VerifyOption configures a verification run.
+type VerifyOption func(*verifyOptions)
+
+// WithFetcher repairs the DAGs with missing or corrupt blocks by fetching
+// them with f, which must store the fetched blocks in the verified
+// blockstore. Corrupt blocks are removed from the blockstore first.
+func WithFetcher(f fetcher.Factory) VerifyOption {
+	return func(o *verifyOptions) {
+		o.fetcher = f
+	}
+}
+
+// WithValidPins also reports the pins whose DAG is complete and valid.
+func WithValidPins() VerifyOption {
+	return func(o *verifyOptions) {
+		o.reportOK = true
+	}
+}
+
+// Verify walks the DAG of every recursive pin of p in bs, checking that each
+// block is stored and matches its hash, and reports the pins with missing or
+// corrupt blocks. The blocks are hashed again even if bs does not hash the
+// blocks it reads.
+//
+// The output channel is closed once all the pins are verified or ctx is
+// canceled.
+func Verify(ctx context.Context, p pin.Pinner, bs blockstore.Blockstore, opts ...VerifyOption) <-chan VerifyResult {
+	var options verifyOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	out := make(chan VerifyResult)
+	go func() {
+		defer close(out)
+
+		send := func(r VerifyResult) bool {
+			select {
+			case out <- r:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		// List the pins first, rather than keeping the pinner busy for the
+		// whole verification
+		var pins []pin.Pinned
+		for sp := range p.Ls(ctx, pin.Recursive, pin.Filter{}) {
+			if sp.Err != nil {
+				send(VerifyResult{Err: sp.Err})
+				return
+			}
+			pins = append(pins, sp.Pin)
+		}
+
+		v := &verifier{bs: bs, options: &options}
+		// A cid may have several recursive pins
+		verified := make(map[cid.Cid]VerifyResult)
+		for _, pinned := range pins {
+			if ctx.Err() != nil {
+				return
+			}
+			res, ok := verified[pinned.Key]
+			if !ok {
+				res = v.verify(ctx, pinned.Key)
+				verified[pinned.Key] = res
+			}
+			if res.OK() && !res.Repaired && !options.reportOK {
+				continue
+			}
+			res.Pin = pinned
+			if !send(res) {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+type verifier struct {
+	bs      blockstore.Blockstore
+	options *verifyOptions
+}
+
+// verify checks the DAG of root, and repairs it if enabled.
+func (v *verifier) verify(ctx context.Context, root cid.Cid) VerifyResult {
+	var res VerifyResult
+	res.Missing, res.Corrupt, res.Err = v.check(ctx, root)
+	if res.Err != nil || res.OK() || v.options.fetcher == nil {
+		return res
+	}
+
+	for _, c := range res.Corrupt {
+		if err := v.bs.DeleteBlock(ctx, c); err != nil {
+			res.Err = fmt.Errorf("cannot remove corrupt block %s: %w", c, err)
+			return res
+		}
+	}
+	if err := v.fetch(ctx, root); err != nil {
+		res.Err = fmt.Errorf("cannot fetch DAG: %w", err)
+		return res
+	}
+
+	missing, corrupt, err := v.check(ctx, root)
+	switch {
+	case err != nil:
+		res.Err = err
+	case len(missing) != 0 || len(corrupt) != 0:
+		res.Err = fmt.Errorf("DAG still has %d missing and %d corrupt blocks after fetching it", len(missing), len(corrupt))
+	default:
+		res.Repaired = true
+	}
+	return res
+}
+
+// check walks the DAG of root and returns its missing and corrupt blocks.
+func (v *verifier) check(ctx context.Context, root cid.Cid) (missing, corrupt []cid.Cid, err error) {
+	visited := cid.NewSet()
+	stack := []cid.Cid{root}
+	for len(stack) != 0 {
+		c := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if !visited.Visit(c) {
+			continue
+		}
+		if err = ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+
+		blk, err := v.getBlock(ctx, c)
+		if err != nil {
+			switch {
+			case ipld.IsNotFound(err):
+				missing = append(missing, c)
+				continue
+			case errors.Is(err, blockstore.ErrHashMismatch):
+				corrupt = append(corrupt, c)
+				continue
+			default:
+				return nil, nil, fmt.Errorf("cannot read block %s: %w", c, err)
+			}
+		}
+		if !hashMatches(blk) {
+			corrupt = append(corrupt, c)
+			continue
+		}
+
+		links, err := blockLinks(blk)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot decode block %s: %w", c, err)
+		}
+		stack = append(stack, links...)
+	}
+	return missing, corrupt, nil
+}
+
+// getBlock reads the block of c from the blockstore, or from c itself if it
+// is an identity CID.
+func (v *verifier) getBlock(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	if c.Prefix().MhType == mh.IDENTITY {
+		dmh, err := mh.Decode(c.Hash())
+		if err != nil {
+			return nil, err
+		}
+		return blocks.NewBlockWithCid(dmh.Digest, c)
+	}
+	return v.bs.Get(ctx, c)
+}
+
+// fetch retrieves every block of the DAG.
+func (v *verifier) fetch(ctx context.Context, root cid.Cid) error {
+	session := v.options.fetcher.NewSession(ctx)
+	return helpers.BlockAll(ctx, session, cidlink.Link{Cid: root}, helpers.OnUniqueBlocks(func(helpers.BlockResult) error {
+		return nil
+	}))
+}
+
+func hashMatches(blk blocks.Block) bool {
+	c := blk.Cid()
+	sum, err := c.Prefix().Sum(blk.RawData())
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(sum.Hash(), c.Hash())
+}
+
+// blockLinks decodes blk and returns the cids it links to.
+func blockLinks(blk blocks.Block) ([]cid.Cid, error) {
+	codec := blk.Cid().Prefix().Codec
+	decode, err := multicodec.LookupDecoder(codec)
+	if err != nil {
+		return nil, err
+	}
+
+	var proto datamodel.NodePrototype = basicnode.Prototype.Any
+	if codec == cid.DagProtobuf {
+		proto = dagpb.Type.PBNode
+	}
+	nb := proto.NewBuilder()
+	if err = decode(nb, bytes.NewReader(blk.RawData())); err != nil {
+		return nil, err
+	}
+
+	links, err := traversal.SelectLinks(nb.Build())
+	if err != nil {
+		return nil, err
+	}
+	cids := make([]cid.Cid, 0, len(links))
+	for _, l := range links {
+		cl, ok := l.(cidlink.Link)
+		if !ok {
+			return nil, fmt.Errorf("unsupported link type %T", l)
+		}
+		cids = append(cids, cl.Cid)
+	}
+	return cids, nil
+}
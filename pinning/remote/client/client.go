@@ -2,6 +2,7 @@ package go_pinning_service_http_client
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"time"
@@ -33,10 +34,32 @@ func NewClient(url, bearerToken string) *Client {
 			URL: url,
 		},
 	}
+	config.HTTPClient = &http.Client{Transport: metaTransport{http.DefaultTransport}}
 
 	return &Client{client: openapi.NewAPIClient(config)}
 }
 
+type metaKey struct{}
+
+// metaTransport sets the meta query parameter of the requests whose context
+// holds one under metaKey. The generated client formats the meta filter of
+// list requests with fmt, whereas the specification requires JSON.
+type metaTransport struct {
+	http.RoundTripper
+}
+
+func (t metaTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	meta, ok := req.Context().Value(metaKey{}).(string)
+	if !ok {
+		return t.RoundTripper.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	query := req.URL.Query()
+	query.Set("meta", meta)
+	req.URL.RawQuery = query.Encode()
+	return t.RoundTripper.RoundTrip(req)
+}
+
 // TODO: We should probably make sure there are no duplicates sent
 type lsSettings struct {
 	cids   []string
@@ -244,6 +267,14 @@ func (c *Client) LsBatchSync(ctx context.Context, opts ...LsOption) ([]PinStatus
 }
 
 func (c *Client) lsInternal(ctx context.Context, settings *lsSettings) (pinResults, error) {
+	if settings.meta != nil {
+		meta, err := json.Marshal(settings.meta)
+		if err != nil {
+			return pinResults{}, err
+		}
+		ctx = context.WithValue(ctx, metaKey{}, string(meta))
+	}
+
 	getter := c.client.PinsApi.PinsGet(ctx)
 	if len(settings.cids) > 0 {
 		getter = getter.Cid(settings.cids)
@@ -269,10 +300,6 @@ func (c *Client) lsInternal(ctx context.Context, settings *lsSettings) (pinResul
 	if settings.after != nil {
 		getter = getter.After(*settings.after)
 	}
-	if settings.meta != nil {
-		getter = getter.Meta(settings.meta)
-	}
-
 	// TODO: Ignoring HTTP Response OK?
 	results, httpresp, err := getter.Execute()
 	if err != nil {
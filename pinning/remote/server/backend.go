@@ -0,0 +1,463 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ipfs/boxo/fetcher"
+	"github.com/ipfs/boxo/fetcher/helpers"
+	pin "github.com/ipfs/boxo/pinning/pinner"
+	"github.com/ipfs/boxo/pinning/remote/client/openapi"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/namespace"
+	"github.com/ipfs/go-datastore/query"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// DefaultConcurrency is the default number of pin requests processed at the
+// same time by a [PinnerBackend].
+const DefaultConcurrency = 4
+
+// requestsPrefix is the datastore namespace of the pin requests.
+var requestsPrefix = datastore.NewKey("/pinning-service/requests")
+
+// BackendOption configures a [PinnerBackend].
+type BackendOption func(*PinnerBackend)
+
+// WithConcurrency sets the number of pin requests processed at the same time.
+// Default is [DefaultConcurrency].
+func WithConcurrency(n int) BackendOption {
+	return func(b *PinnerBackend) {
+		if n > 0 {
+			b.concurrency = n
+		}
+	}
+}
+
+// WithDelegates sets the multiaddrs returned in the delegates of every pin
+// status, which clients connect to in order to speed up the transfer.
+func WithDelegates(addrs ...multiaddr.Multiaddr) BackendOption {
+	return func(b *PinnerBackend) {
+		b.delegates = make([]string, len(addrs))
+		for i, a := range addrs {
+			b.delegates[i] = a.String()
+		}
+	}
+}
+
+// WithPinTimeout sets the maximum time spent fetching the DAG of a pin
+// request before it fails. Default is no timeout.
+func WithPinTimeout(d time.Duration) BackendOption {
+	return func(b *PinnerBackend) {
+		b.pinTimeout = d
+	}
+}
+
+// PinnerBackend is a [Backend] which fetches the requested DAGs with a
+// [fetcher.Factory] and recursively pins them with a [pin.Pinner].
+//
+// Every pin request has its own pin, named after its request ID and holding
+// the name and metadata of the request, so that removing a request leaves the
+// other pins of its CID untouched, including the ones made by other users of
+// the pinner.
+//
+// Pin requests are persisted in a datastore, and the ones that were not done
+// when the backend was closed are resumed when it is created again.
+type PinnerBackend struct {
+	pinner         pin.Pinner
+	fetcherFactory fetcher.Factory
+	ds             datastore.Datastore
+
+	concurrency int
+	delegates   []string
+	pinTimeout  time.Duration
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	sem    chan struct{}
+
+	lk          sync.RWMutex
+	requests    map[string]*request
+	lastCreated time.Time
+}
+
+var _ Backend = (*PinnerBackend)(nil)
+
+// request is a pin request, as persisted in the datastore.
+type request struct {
+	Status openapi.PinStatus
+	// PinID is the ID of the pin of this request, once pinned.
+	PinID string `json:",omitempty"`
+	// ReplacedPinID is the ID of the pin of the replaced pin request, which
+	// is kept until this request is done.
+	ReplacedPinID string `json:",omitempty"`
+
+	cid    cid.Cid
+	cancel context.CancelFunc
+}
+
+func (r *request) done() bool {
+	return r.Status.Status == openapi.PINNED || r.Status.Status == openapi.FAILED
+}
+
+// NewPinnerBackend creates a [PinnerBackend] which stores pin requests in ds
+// and pins them with pinner. fetcherFactory must be able to fetch blocks from
+// the network, and store them where pinner can find them.
+func NewPinnerBackend(ctx context.Context, ds datastore.Datastore, pinner pin.Pinner, fetcherFactory fetcher.Factory, opts ...BackendOption) (*PinnerBackend, error) {
+	b := &PinnerBackend{
+		pinner:         pinner,
+		fetcherFactory: fetcherFactory,
+		ds:             namespace.Wrap(ds, requestsPrefix),
+		concurrency:    DefaultConcurrency,
+		delegates:      []string{},
+		requests:       make(map[string]*request),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	b.sem = make(chan struct{}, b.concurrency)
+	b.ctx, b.cancel = context.WithCancel(context.Background())
+
+	results, err := b.ds.Query(ctx, query.Query{})
+	if err != nil {
+		return nil, fmt.Errorf("cannot load pin requests: %w", err)
+	}
+	entries, err := results.Rest()
+	if err != nil {
+		return nil, fmt.Errorf("cannot load pin requests: %w", err)
+	}
+
+	for _, e := range entries {
+		req := new(request)
+		if err := json.Unmarshal(e.Value, req); err != nil {
+			return nil, fmt.Errorf("cannot decode pin request %s: %w", e.Key, err)
+		}
+		req.cid, err = cid.Decode(req.Status.Pin.Cid)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cid in pin request %s: %w", e.Key, err)
+		}
+		b.requests[req.Status.Requestid] = req
+		if req.Status.Created.After(b.lastCreated) {
+			b.lastCreated = req.Status.Created
+		}
+	}
+
+	for _, req := range b.requests {
+		if !req.done() {
+			b.start(req)
+		}
+	}
+
+	return b, nil
+}
+
+// Close stops processing pin requests. Unfinished requests are resumed by the
+// next [PinnerBackend] created with the same datastore.
+func (b *PinnerBackend) Close() error {
+	b.cancel()
+	b.wg.Wait()
+	return nil
+}
+
+func (b *PinnerBackend) Add(ctx context.Context, p openapi.Pin) (openapi.PinStatus, error) {
+	b.lk.Lock()
+	defer b.lk.Unlock()
+
+	req, err := b.newRequest(ctx, p, "")
+	if err != nil {
+		return openapi.PinStatus{}, err
+	}
+	return req.Status, nil
+}
+
+func (b *PinnerBackend) Get(ctx context.Context, requestID string) (openapi.PinStatus, error) {
+	b.lk.RLock()
+	defer b.lk.RUnlock()
+
+	req, ok := b.requests[requestID]
+	if !ok {
+		return openapi.PinStatus{}, ErrNotFound
+	}
+	return req.Status, nil
+}
+
+func (b *PinnerBackend) Replace(ctx context.Context, requestID string, p openapi.Pin) (openapi.PinStatus, error) {
+	b.lk.Lock()
+	defer b.lk.Unlock()
+
+	old, ok := b.requests[requestID]
+	if !ok {
+		return openapi.PinStatus{}, ErrNotFound
+	}
+
+	// Keep the old DAG until the new one is pinned. If the old request is not
+	// pinned yet, keep the DAG it was replacing instead.
+	replaced := old.PinID
+	if replaced == "" {
+		replaced = old.ReplacedPinID
+	}
+
+	req, err := b.newRequest(ctx, p, replaced)
+	if err != nil {
+		return openapi.PinStatus{}, err
+	}
+
+	if err := b.remove(ctx, old); err != nil {
+		return openapi.PinStatus{}, err
+	}
+	return req.Status, nil
+}
+
+func (b *PinnerBackend) Delete(ctx context.Context, requestID string) error {
+	b.lk.Lock()
+	defer b.lk.Unlock()
+
+	req, ok := b.requests[requestID]
+	if !ok {
+		return ErrNotFound
+	}
+	if err := b.remove(ctx, req); err != nil {
+		return err
+	}
+
+	b.release(ctx, req.ReplacedPinID)
+	b.release(ctx, req.PinID)
+	return nil
+}
+
+func (b *PinnerBackend) List(ctx context.Context, q Query) ([]openapi.PinStatus, int, error) {
+	b.lk.RLock()
+	defer b.lk.RUnlock()
+
+	var results []openapi.PinStatus
+	for _, req := range b.requests {
+		if q.Matches(&req.Status) {
+			results = append(results, req.Status)
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Created.After(results[j].Created)
+	})
+
+	count := len(results)
+	if q.Limit > 0 && len(results) > q.Limit {
+		results = results[:q.Limit]
+	}
+	return results, count, nil
+}
+
+// newRequest creates, persists and starts a new pin request. It must be called
+// with the lock held.
+func (b *PinnerBackend) newRequest(ctx context.Context, p openapi.Pin, replacedPinID string) (*request, error) {
+	c, err := cid.Decode(p.Cid)
+	if err != nil {
+		return nil, err
+	}
+
+	// Creation timestamps are used for pagination, so they must be unique.
+	created := time.Now().UTC()
+	if !created.After(b.lastCreated) {
+		created = b.lastCreated.Add(time.Nanosecond)
+	}
+
+	req := &request{
+		Status: openapi.PinStatus{
+			Requestid: uuid.NewString(),
+			Status:    openapi.QUEUED,
+			Created:   created,
+			Pin:       p,
+			Delegates: b.delegates,
+		},
+		ReplacedPinID: replacedPinID,
+		cid:           c,
+	}
+	if err := b.put(ctx, req); err != nil {
+		return nil, err
+	}
+
+	b.lastCreated = created
+	b.requests[req.Status.Requestid] = req
+	b.start(req)
+	return req, nil
+}
+
+// remove cancels and deletes a pin request, without unpinning its CID. It
+// must be called with the lock held.
+func (b *PinnerBackend) remove(ctx context.Context, req *request) error {
+	if err := b.ds.Delete(ctx, datastore.NewKey(req.Status.Requestid)); err != nil {
+		return err
+	}
+	if req.cancel != nil {
+		req.cancel()
+	}
+	delete(b.requests, req.Status.Requestid)
+	return nil
+}
+
+// release removes the pin with the given ID, if any. It must be called with
+// the lock held.
+func (b *PinnerBackend) release(ctx context.Context, pinID string) {
+	if pinID == "" {
+		return
+	}
+	if err := b.pinner.UnpinByID(ctx, pinID); err != nil {
+		if !errors.Is(err, pin.ErrNotPinned) {
+			logger.Errorw("failed to unpin", "pinid", pinID, "error", err)
+		}
+		return
+	}
+	if err := b.pinner.Flush(ctx); err != nil {
+		logger.Errorw("failed to flush pins", "error", err)
+	}
+}
+
+func (b *PinnerBackend) put(ctx context.Context, req *request) error {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	return b.ds.Put(ctx, datastore.NewKey(req.Status.Requestid), data)
+}
+
+// start processes the pin request in the background. It must be called with
+// the lock held.
+func (b *PinnerBackend) start(req *request) {
+	ctx, cancel := context.WithCancel(b.ctx)
+	req.cancel = cancel
+
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		defer cancel()
+
+		select {
+		case b.sem <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		defer func() { <-b.sem }()
+
+		if !b.setStatus(ctx, req, openapi.PINNING, nil) {
+			return
+		}
+
+		err := b.fetch(ctx, req.cid)
+		b.finish(ctx, req, err)
+	}()
+}
+
+// setStatus updates the status of a pin request which has not been removed.
+func (b *PinnerBackend) setStatus(ctx context.Context, req *request, status openapi.Status, info map[string]string) bool {
+	b.lk.Lock()
+	defer b.lk.Unlock()
+
+	if ctx.Err() != nil {
+		return false
+	}
+
+	b.updateStatus(ctx, req, status, info)
+	return true
+}
+
+// finish pins the fetched DAG of a pin request, unless fetching it failed or
+// the request was removed, and releases the CID it replaces.
+func (b *PinnerBackend) finish(ctx context.Context, req *request, fetchErr error) {
+	// Pin with the lock held, so that the CID can't be released by a
+	// concurrent removal of the request before it is pinned.
+	b.lk.Lock()
+	defer b.lk.Unlock()
+
+	if ctx.Err() != nil {
+		// The request was removed or the backend closed.
+		return
+	}
+
+	err := fetchErr
+	if err == nil {
+		var id string
+		id, err = b.pinner.PinWithMode(ctx, req.cid, pin.Recursive, req.Status.Requestid, pinMetadata(req.Status.Pin))
+		if err == nil {
+			req.PinID = id
+			err = b.pinner.Flush(ctx)
+		}
+	}
+
+	if err != nil {
+		logger.Infow("failed to pin", "requestid", req.Status.Requestid, "cid", req.cid, "error", err)
+		b.updateStatus(ctx, req, openapi.FAILED, map[string]string{"status_details": err.Error()})
+	} else {
+		b.updateStatus(ctx, req, openapi.PINNED, nil)
+	}
+
+	if req.ReplacedPinID != "" {
+		replaced := req.ReplacedPinID
+		req.ReplacedPinID = ""
+		b.persist(ctx, req)
+		b.release(ctx, replaced)
+	}
+}
+
+// pinMetadata returns the metadata of the pin of a request: its metadata and,
+// under the "name" key unless it is already set, its name.
+func pinMetadata(p openapi.Pin) map[string]string {
+	var meta map[string]string
+	if p.Meta != nil {
+		meta = make(map[string]string, len(*p.Meta)+1)
+		for k, v := range *p.Meta {
+			meta[k] = v
+		}
+	}
+	if p.Name != nil && *p.Name != "" {
+		if meta == nil {
+			meta = make(map[string]string, 1)
+		}
+		if _, ok := meta["name"]; !ok {
+			meta["name"] = *p.Name
+		}
+	}
+	return meta
+}
+
+// updateStatus updates and persists the status of a pin request. It must be
+// called with the lock held.
+func (b *PinnerBackend) updateStatus(ctx context.Context, req *request, status openapi.Status, info map[string]string) {
+	req.Status.Status = status
+	if info != nil {
+		req.Status.Info = &info
+	}
+	b.persist(ctx, req)
+}
+
+func (b *PinnerBackend) persist(ctx context.Context, req *request) {
+	if err := b.put(ctx, req); err != nil {
+		logger.Errorw("failed to persist pin request", "requestid", req.Status.Requestid, "error", err)
+	}
+}
+
+// fetch retrieves every block of the DAG.
+func (b *PinnerBackend) fetch(ctx context.Context, c cid.Cid) error {
+	if b.pinTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, b.pinTimeout)
+		defer cancel()
+	}
+
+	session := b.fetcherFactory.NewSession(ctx)
+	err := helpers.BlockAll(ctx, session, cidlink.Link{Cid: c}, helpers.OnUniqueBlocks(func(helpers.BlockResult) error {
+		return nil
+	}))
+	if err != nil {
+		return fmt.Errorf("cannot fetch DAG: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,436 @@
+// Package server implements the [IPFS Pinning Service API] as an [http.Handler].
+//
+// The handler takes care of authentication, validation and encoding, and
+// delegates the storage of pin requests to a [Backend]. [PinnerBackend] is a
+// [Backend] that pins the requested DAGs with a [pin.Pinner].
+//
+// [IPFS Pinning Service API]: https://ipfs.github.io/pinning-services-api-spec/
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/ipfs/boxo/pinning/remote/client/openapi"
+	"github.com/ipfs/go-cid"
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/multiformats/go-multiaddr"
+)
+
+var logger = logging.Logger("pinning/remote/server")
+
+const (
+	pinsPath = "/pins"
+	pinPath  = "/pins/{requestid}"
+
+	mediaTypeJSON = "application/json"
+
+	// DefaultLimit is the number of results returned by a list request
+	// without a limit.
+	DefaultLimit = 10
+	// MaxLimit is the highest limit accepted in a list request.
+	MaxLimit = 1000
+
+	maxNameSize = 255
+	maxCIDs     = 10
+	maxBodySize = 1 << 20
+)
+
+// ErrNotFound is returned by a [Backend] when there is no pin request with
+// the given request ID.
+var ErrNotFound = errors.New("pin request not found")
+
+// MatchMode is the way a [Query] name is matched against pin names.
+type MatchMode string
+
+const (
+	MatchExact    MatchMode = "exact"
+	MatchIExact   MatchMode = "iexact"
+	MatchPartial  MatchMode = "partial"
+	MatchIPartial MatchMode = "ipartial"
+)
+
+// Matches reports whether name matches the query name with this mode.
+func (m MatchMode) Matches(query, name string) bool {
+	switch m {
+	case MatchIExact:
+		return strings.EqualFold(query, name)
+	case MatchPartial:
+		return strings.Contains(name, query)
+	case MatchIPartial:
+		return strings.Contains(strings.ToLower(name), strings.ToLower(query))
+	default:
+		return query == name
+	}
+}
+
+// Query is a validated list request.
+type Query struct {
+	// Cids restricts the results to pins of these CIDs, if not empty.
+	Cids []cid.Cid
+	// Name restricts the results to pins with a matching name, if not empty.
+	Name  string
+	Match MatchMode
+	// Statuses restricts the results to pins with these statuses. It is never
+	// empty, and only contains [openapi.PINNED] if no status was requested.
+	Statuses []openapi.Status
+	// Before and After restrict the results to pins created in this interval.
+	Before *time.Time
+	After  *time.Time
+	// Limit is the maximum number of results to return.
+	Limit int
+	// Meta restricts the results to pins whose metadata contains all of these
+	// key-value pairs.
+	Meta map[string]string
+}
+
+// Matches reports whether the given pin status satisfies the query filters.
+// The limit is not taken into account.
+func (q *Query) Matches(ps *openapi.PinStatus) bool {
+	if len(q.Cids) > 0 {
+		c, err := cid.Decode(ps.Pin.Cid)
+		if err != nil {
+			return false
+		}
+		found := false
+		for _, qc := range q.Cids {
+			if qc.Equals(c) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if q.Name != "" && !q.Match.Matches(q.Name, ps.Pin.GetName()) {
+		return false
+	}
+
+	found := false
+	for _, s := range q.Statuses {
+		if s == ps.Status {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return false
+	}
+
+	if q.Before != nil && !ps.Created.Before(*q.Before) {
+		return false
+	}
+	if q.After != nil && !ps.Created.After(*q.After) {
+		return false
+	}
+
+	meta := ps.Pin.GetMeta()
+	for k, v := range q.Meta {
+		if mv, ok := meta[k]; !ok || mv != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Backend stores pin requests and processes them.
+//
+// The handler validates requests before calling the backend: pins always have
+// a valid CID, a name of at most 255 bytes and multiaddr origins.
+type Backend interface {
+	// Add creates a new pin request and returns its status.
+	Add(ctx context.Context, pin openapi.Pin) (openapi.PinStatus, error)
+
+	// Get returns the status of the given pin request, or [ErrNotFound].
+	Get(ctx context.Context, requestID string) (openapi.PinStatus, error)
+
+	// Replace creates a new pin request which replaces the given one, or
+	// returns [ErrNotFound]. The data pinned by the old request must be kept
+	// until the new request is done, so that common blocks are not lost.
+	Replace(ctx context.Context, requestID string, pin openapi.Pin) (openapi.PinStatus, error)
+
+	// Delete removes the given pin request, or returns [ErrNotFound].
+	Delete(ctx context.Context, requestID string) error
+
+	// List returns the pin requests matching the query, from the most to the
+	// least recently created, along with the total number of matching pin
+	// requests regardless of the limit.
+	List(ctx context.Context, query Query) ([]openapi.PinStatus, int, error)
+}
+
+type Option func(s *server)
+
+// WithAuthorizer sets the function used to check the access token of every
+// request, instead of comparing it to the access tokens passed to [Handler].
+func WithAuthorizer(authorize func(r *http.Request, token string) bool) Option {
+	return func(s *server) {
+		s.authorize = authorize
+	}
+}
+
+// Handler returns an [http.Handler] serving the Pinning Service API with the
+// given backend. Requests must have an "Authorization: Bearer <token>" header
+// with one of the given access tokens.
+func Handler(backend Backend, accessTokens []string, opts ...Option) http.Handler {
+	s := &server{
+		backend: backend,
+	}
+	s.authorize = func(_ *http.Request, token string) bool {
+		for _, t := range accessTokens {
+			if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	r := mux.NewRouter()
+	r.HandleFunc(pinsPath, s.list).Methods(http.MethodGet)
+	r.HandleFunc(pinsPath, s.add).Methods(http.MethodPost)
+	r.HandleFunc(pinPath, s.get).Methods(http.MethodGet)
+	r.HandleFunc(pinPath, s.replace).Methods(http.MethodPost)
+	r.HandleFunc(pinPath, s.delete).Methods(http.MethodDelete)
+	r.Use(s.authenticate)
+
+	return r
+}
+
+type server struct {
+	backend   Backend
+	authorize func(r *http.Request, token string) bool
+}
+
+func (s *server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+			writeErr(w, http.StatusUnauthorized, errors.New("missing bearer access token"))
+			return
+		}
+		if !s.authorize(r, token) {
+			writeErr(w, http.StatusUnauthorized, errors.New("invalid access token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *server) list(w http.ResponseWriter, r *http.Request) {
+	query, err := parseQuery(r)
+	if err != nil {
+		writeErr(w, http.StatusBadRequest, err)
+		return
+	}
+
+	results, count, err := s.backend.List(r.Context(), query)
+	if err != nil {
+		writeBackendErr(w, "List", err)
+		return
+	}
+	if results == nil {
+		results = []openapi.PinStatus{}
+	}
+
+	writeJSON(w, http.StatusOK, openapi.PinResults{Count: int32(count), Results: results})
+}
+
+func (s *server) add(w http.ResponseWriter, r *http.Request) {
+	pin, err := parsePin(r)
+	if err != nil {
+		writeErr(w, http.StatusBadRequest, err)
+		return
+	}
+
+	status, err := s.backend.Add(r.Context(), pin)
+	if err != nil {
+		writeBackendErr(w, "Add", err)
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, status)
+}
+
+func (s *server) get(w http.ResponseWriter, r *http.Request) {
+	status, err := s.backend.Get(r.Context(), mux.Vars(r)["requestid"])
+	if err != nil {
+		writeBackendErr(w, "Get", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, status)
+}
+
+func (s *server) replace(w http.ResponseWriter, r *http.Request) {
+	pin, err := parsePin(r)
+	if err != nil {
+		writeErr(w, http.StatusBadRequest, err)
+		return
+	}
+
+	status, err := s.backend.Replace(r.Context(), mux.Vars(r)["requestid"], pin)
+	if err != nil {
+		writeBackendErr(w, "Replace", err)
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, status)
+}
+
+func (s *server) delete(w http.ResponseWriter, r *http.Request) {
+	err := s.backend.Delete(r.Context(), mux.Vars(r)["requestid"])
+	if err != nil {
+		writeBackendErr(w, "Delete", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func parsePin(r *http.Request) (openapi.Pin, error) {
+	var pin openapi.Pin
+	dec := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxBodySize))
+	if err := dec.Decode(&pin); err != nil {
+		return pin, fmt.Errorf("invalid pin object: %w", err)
+	}
+
+	if pin.Cid == "" {
+		return pin, errors.New("missing cid")
+	}
+	if _, err := cid.Decode(pin.Cid); err != nil {
+		return pin, fmt.Errorf("invalid cid %q: %w", pin.Cid, err)
+	}
+	if len(pin.GetName()) > maxNameSize {
+		return pin, fmt.Errorf("name cannot be longer than %d", maxNameSize)
+	}
+	for _, o := range pin.GetOrigins() {
+		if _, err := multiaddr.NewMultiaddr(o); err != nil {
+			return pin, fmt.Errorf("invalid origin %q: %w", o, err)
+		}
+	}
+
+	return pin, nil
+}
+
+func parseQuery(r *http.Request) (Query, error) {
+	params := r.URL.Query()
+	query := Query{
+		Name:     params.Get("name"),
+		Match:    MatchExact,
+		Statuses: []openapi.Status{openapi.PINNED},
+		Limit:    DefaultLimit,
+	}
+
+	if v := params.Get("cid"); v != "" {
+		strs := strings.Split(v, ",")
+		if len(strs) > maxCIDs {
+			return query, fmt.Errorf("cannot filter by more than %d cids", maxCIDs)
+		}
+		for _, s := range strs {
+			c, err := cid.Decode(s)
+			if err != nil {
+				return query, fmt.Errorf("invalid cid %q: %w", s, err)
+			}
+			query.Cids = append(query.Cids, c)
+		}
+	}
+
+	if len(query.Name) > maxNameSize {
+		return query, fmt.Errorf("name cannot be longer than %d", maxNameSize)
+	}
+
+	if v := params.Get("match"); v != "" {
+		switch m := MatchMode(v); m {
+		case MatchExact, MatchIExact, MatchPartial, MatchIPartial:
+			query.Match = m
+		default:
+			return query, fmt.Errorf("invalid match %q", v)
+		}
+	}
+
+	if v := params.Get("status"); v != "" {
+		query.Statuses = nil
+		for _, s := range strings.Split(v, ",") {
+			switch status := openapi.Status(s); status {
+			case openapi.QUEUED, openapi.PINNING, openapi.PINNED, openapi.FAILED:
+				query.Statuses = append(query.Statuses, status)
+			default:
+				return query, fmt.Errorf("invalid status %q", s)
+			}
+		}
+	}
+
+	for name, dst := range map[string]**time.Time{"before": &query.Before, "after": &query.After} {
+		if v := params.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				return query, fmt.Errorf("invalid %s timestamp: %w", name, err)
+			}
+			*dst = &t
+		}
+	}
+
+	if v := params.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > MaxLimit {
+			return query, fmt.Errorf("limit must be between 1 and %d", MaxLimit)
+		}
+		query.Limit = limit
+	}
+
+	if v := params.Get("meta"); v != "" {
+		if err := json.Unmarshal([]byte(v), &query.Meta); err != nil {
+			return query, fmt.Errorf("invalid meta: %w", err)
+		}
+	}
+
+	return query, nil
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, val any) {
+	b, err := json.Marshal(val)
+	if err != nil {
+		writeErr(w, http.StatusInternalServerError, fmt.Errorf("marshaling response: %w", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", mediaTypeJSON)
+	w.WriteHeader(statusCode)
+	if _, err := w.Write(b); err != nil {
+		logger.Infow("error writing response body", "Error", err)
+	}
+}
+
+func writeBackendErr(w http.ResponseWriter, method string, err error) {
+	if errors.Is(err, ErrNotFound) {
+		writeErr(w, http.StatusNotFound, err)
+		return
+	}
+
+	logger.Warnw("backend error", "Method", method, "Error", err)
+	writeErr(w, http.StatusInternalServerError, err)
+}
+
+// writeErr writes a Failure object, whose reason is derived from the status
+// code as suggested by the specification.
+func writeErr(w http.ResponseWriter, statusCode int, cause error) {
+	reason := strings.ToUpper(strings.ReplaceAll(http.StatusText(statusCode), " ", "_"))
+	details := cause.Error()
+	writeJSON(w, statusCode, openapi.Failure{
+		Error: openapi.FailureError{Reason: reason, Details: &details},
+	})
+}
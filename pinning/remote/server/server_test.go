@@ -0,0 +1,337 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ipfs/boxo/blockservice"
+	"github.com/ipfs/boxo/blockstore"
+	offline "github.com/ipfs/boxo/exchange/offline"
+	bsfetcher "github.com/ipfs/boxo/fetcher/impl/blockservice"
+	"github.com/ipfs/boxo/ipld/merkledag"
+	pin "github.com/ipfs/boxo/pinning/pinner"
+	"github.com/ipfs/boxo/pinning/pinner/dspinner"
+	pinclient "github.com/ipfs/boxo/pinning/remote/client"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testToken = "secret"
+
+type testEnv struct {
+	ds      datastore.Batching
+	pinner  pin.Pinner
+	dserv   ipld.DAGService
+	fetcher bsfetcher.FetcherConfig
+	backend *PinnerBackend
+	url     string
+	client  *pinclient.Client
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	ctx := context.Background()
+
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	bs := blockstore.NewBlockstore(ds)
+	bsrv := blockservice.New(bs, offline.Exchange(bs))
+	dserv := merkledag.NewDAGService(bsrv)
+
+	pinner, err := dspinner.New(ctx, ds, dserv)
+	require.NoError(t, err)
+
+	env := &testEnv{
+		ds:      ds,
+		pinner:  pinner,
+		dserv:   dserv,
+		fetcher: bsfetcher.NewFetcherConfig(bsrv),
+	}
+	env.startBackend(t)
+	return env
+}
+
+func (env *testEnv) startBackend(t *testing.T) {
+	backend, err := NewPinnerBackend(context.Background(), env.ds, env.pinner, env.fetcher)
+	require.NoError(t, err)
+	t.Cleanup(func() { backend.Close() })
+
+	ts := httptest.NewServer(Handler(backend, []string{testToken}))
+	t.Cleanup(ts.Close)
+
+	env.backend = backend
+	env.url = ts.URL
+	env.client = pinclient.NewClient(ts.URL, testToken)
+}
+
+// addDAG stores a DAG with a root and a child, and returns their CIDs.
+func (env *testEnv) addDAG(t *testing.T, data string) (cid.Cid, cid.Cid) {
+	ctx := context.Background()
+	child := merkledag.NodeWithData([]byte(data + " child"))
+	root := merkledag.NodeWithData([]byte(data))
+	require.NoError(t, root.AddNodeLink("child", child))
+	require.NoError(t, env.dserv.AddMany(ctx, []ipld.Node{child, root}))
+	return root.Cid(), child.Cid()
+}
+
+func (env *testEnv) requirePinned(t *testing.T, c cid.Cid, expected bool) {
+	_, pinned, err := env.pinner.IsPinned(context.Background(), c)
+	require.NoError(t, err)
+	require.Equal(t, expected, pinned, "pin of %s", c)
+}
+
+func waitForStatus(t *testing.T, client *pinclient.Client, requestID string, status pinclient.Status) pinclient.PinStatusGetter {
+	var ps pinclient.PinStatusGetter
+	require.Eventually(t, func() bool {
+		var err error
+		ps, err = client.GetStatusByID(context.Background(), requestID)
+		require.NoError(t, err)
+		return ps.GetStatus() == status
+	}, 5*time.Second, 10*time.Millisecond)
+	return ps
+}
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Add, get and delete a pin", func(t *testing.T) {
+		env := newTestEnv(t)
+		root, child := env.addDAG(t, "add")
+
+		ps, err := env.client.Add(ctx, root, pinclient.PinOpts.WithName("add"), pinclient.PinOpts.AddMeta(map[string]string{"app": "test"}))
+		require.NoError(t, err)
+		assert.NotEmpty(t, ps.GetRequestId())
+		assert.Equal(t, root, ps.GetPin().GetCid())
+		assert.Equal(t, "add", ps.GetPin().GetName())
+
+		ps = waitForStatus(t, env.client, ps.GetRequestId(), pinclient.StatusPinned)
+		assert.Equal(t, map[string]string{"app": "test"}, ps.GetPin().GetMeta())
+		env.requirePinned(t, root, true)
+		env.requirePinned(t, child, true)
+
+		require.NoError(t, env.client.DeleteByID(ctx, ps.GetRequestId()))
+		env.requirePinned(t, root, false)
+
+		_, err = env.client.GetStatusByID(ctx, ps.GetRequestId())
+		assert.ErrorContains(t, err, "NOT_FOUND")
+		assert.ErrorContains(t, env.client.DeleteByID(ctx, ps.GetRequestId()), "NOT_FOUND")
+	})
+
+	t.Run("Missing DAGs fail to pin", func(t *testing.T) {
+		env := newTestEnv(t)
+		missing := merkledag.NodeWithData([]byte("missing")).Cid()
+
+		ps, err := env.client.Add(ctx, missing)
+		require.NoError(t, err)
+		ps = waitForStatus(t, env.client, ps.GetRequestId(), pinclient.StatusFailed)
+		assert.Contains(t, ps.GetInfo()["status_details"], "cannot fetch DAG")
+		env.requirePinned(t, missing, false)
+	})
+
+	t.Run("Replace keeps the new DAG only", func(t *testing.T) {
+		env := newTestEnv(t)
+		oldRoot, _ := env.addDAG(t, "old")
+		newRoot, _ := env.addDAG(t, "new")
+
+		old, err := env.client.Add(ctx, oldRoot)
+		require.NoError(t, err)
+		waitForStatus(t, env.client, old.GetRequestId(), pinclient.StatusPinned)
+
+		ps, err := env.client.Replace(ctx, old.GetRequestId(), newRoot)
+		require.NoError(t, err)
+		assert.NotEqual(t, old.GetRequestId(), ps.GetRequestId())
+		waitForStatus(t, env.client, ps.GetRequestId(), pinclient.StatusPinned)
+
+		env.requirePinned(t, newRoot, true)
+		env.requirePinned(t, oldRoot, false)
+		_, err = env.client.GetStatusByID(ctx, old.GetRequestId())
+		assert.ErrorContains(t, err, "NOT_FOUND")
+	})
+
+	t.Run("A CID is pinned until all its requests are deleted", func(t *testing.T) {
+		env := newTestEnv(t)
+		root, _ := env.addDAG(t, "shared")
+
+		var ids []string
+		for i := 0; i < 2; i++ {
+			ps, err := env.client.Add(ctx, root)
+			require.NoError(t, err)
+			waitForStatus(t, env.client, ps.GetRequestId(), pinclient.StatusPinned)
+			ids = append(ids, ps.GetRequestId())
+		}
+		assert.NotEqual(t, ids[0], ids[1])
+
+		require.NoError(t, env.client.DeleteByID(ctx, ids[0]))
+		env.requirePinned(t, root, true)
+		require.NoError(t, env.client.DeleteByID(ctx, ids[1]))
+		env.requirePinned(t, root, false)
+	})
+
+	t.Run("Requests have their own named pins", func(t *testing.T) {
+		env := newTestEnv(t)
+		root, _ := env.addDAG(t, "named")
+
+		// Another user of the pinner pins the same CID.
+		rootNode, err := env.dserv.Get(ctx, root)
+		require.NoError(t, err)
+		_, err = env.pinner.Pin(ctx, rootNode, true, "other", nil)
+		require.NoError(t, err)
+
+		ps, err := env.client.Add(ctx, root, pinclient.PinOpts.WithName("named"), pinclient.PinOpts.AddMeta(map[string]string{"app": "test"}))
+		require.NoError(t, err)
+		waitForStatus(t, env.client, ps.GetRequestId(), pinclient.StatusPinned)
+
+		var pins []pin.Pinned
+		for sp := range env.pinner.Ls(ctx, pin.Recursive, pin.Filter{NamePrefix: ps.GetRequestId()}) {
+			require.NoError(t, sp.Err)
+			pins = append(pins, sp.Pin)
+		}
+		require.Len(t, pins, 1)
+		assert.Equal(t, root, pins[0].Key)
+		assert.Equal(t, map[string]string{"app": "test", "name": "named"}, pins[0].Metadata)
+
+		require.NoError(t, env.client.DeleteByID(ctx, ps.GetRequestId()))
+		env.requirePinned(t, root, true)
+		for sp := range env.pinner.Ls(ctx, pin.Recursive, pin.Filter{Cid: root}) {
+			require.NoError(t, sp.Err)
+			assert.Equal(t, "other", sp.Pin.Name)
+		}
+	})
+
+	t.Run("List filters and paginates", func(t *testing.T) {
+		env := newTestEnv(t)
+
+		const n = 15
+		var roots []cid.Cid
+		for i := 0; i < n; i++ {
+			root, _ := env.addDAG(t, fmt.Sprintf("list %d", i))
+			ps, err := env.client.Add(ctx, root, pinclient.PinOpts.WithName(fmt.Sprintf("pin-%d", i)), pinclient.PinOpts.AddMeta(map[string]string{"even": fmt.Sprint(i%2 == 0)}))
+			require.NoError(t, err)
+			waitForStatus(t, env.client, ps.GetRequestId(), pinclient.StatusPinned)
+			roots = append(roots, root)
+		}
+		missing, err := env.client.Add(ctx, merkledag.NodeWithData([]byte("missing")).Cid())
+		require.NoError(t, err)
+		waitForStatus(t, env.client, missing.GetRequestId(), pinclient.StatusFailed)
+
+		// Only pinned requests are listed by default, over multiple pages.
+		res, err := env.client.LsSync(ctx)
+		require.NoError(t, err)
+		require.Len(t, res, n)
+		for i, ps := range res {
+			assert.Equal(t, roots[n-1-i], ps.GetPin().GetCid())
+		}
+
+		res, count, err := env.client.LsBatchSync(ctx, pinclient.PinOpts.Limit(3))
+		require.NoError(t, err)
+		assert.Len(t, res, 3)
+		assert.Equal(t, n, count)
+
+		res, err = env.client.LsSync(ctx, pinclient.PinOpts.FilterStatus(pinclient.StatusFailed))
+		require.NoError(t, err)
+		require.Len(t, res, 1)
+		assert.Equal(t, missing.GetRequestId(), res[0].GetRequestId())
+
+		res, err = env.client.LsSync(ctx, pinclient.PinOpts.FilterCIDs(roots[2], roots[4]))
+		require.NoError(t, err)
+		assert.Len(t, res, 2)
+
+		res, err = env.client.LsSync(ctx, pinclient.PinOpts.FilterName("pin-7"))
+		require.NoError(t, err)
+		require.Len(t, res, 1)
+		assert.Equal(t, roots[7], res[0].GetPin().GetCid())
+
+		res, err = env.client.LsSync(ctx, pinclient.PinOpts.LsMeta(map[string]string{"even": "true"}))
+		require.NoError(t, err)
+		assert.Len(t, res, 8)
+
+		res, err = env.client.LsSync(ctx, pinclient.PinOpts.FilterAfter(res[4].GetCreated()), pinclient.PinOpts.FilterBefore(res[0].GetCreated()))
+		require.NoError(t, err)
+		assert.Len(t, res, 7)
+	})
+
+	t.Run("Pin requests are persisted", func(t *testing.T) {
+		env := newTestEnv(t)
+		root, _ := env.addDAG(t, "persisted")
+
+		ps, err := env.client.Add(ctx, root)
+		require.NoError(t, err)
+		waitForStatus(t, env.client, ps.GetRequestId(), pinclient.StatusPinned)
+		require.NoError(t, env.backend.Close())
+
+		env.startBackend(t)
+		res, err := env.client.LsSync(ctx)
+		require.NoError(t, err)
+		require.Len(t, res, 1)
+		assert.Equal(t, ps.GetRequestId(), res[0].GetRequestId())
+		assert.Equal(t, ps.GetCreated(), res[0].GetCreated())
+	})
+}
+
+func TestHandler(t *testing.T) {
+	env := newTestEnv(t)
+
+	for _, tc := range []struct {
+		name       string
+		method     string
+		path       string
+		token      string
+		statusCode int
+	}{
+		{"missing token", http.MethodGet, "/pins", "", http.StatusUnauthorized},
+		{"invalid token", http.MethodGet, "/pins", "Bearer wrong", http.StatusUnauthorized},
+		{"invalid scheme", http.MethodGet, "/pins", "Basic " + testToken, http.StatusUnauthorized},
+		{"valid token", http.MethodGet, "/pins", "Bearer " + testToken, http.StatusOK},
+		{"invalid limit", http.MethodGet, "/pins?limit=1001", "Bearer " + testToken, http.StatusBadRequest},
+		{"invalid status", http.MethodGet, "/pins?status=lost", "Bearer " + testToken, http.StatusBadRequest},
+		{"invalid match", http.MethodGet, "/pins?match=fuzzy", "Bearer " + testToken, http.StatusBadRequest},
+		{"invalid cid filter", http.MethodGet, "/pins?cid=nope", "Bearer " + testToken, http.StatusBadRequest},
+		{"invalid meta filter", http.MethodGet, "/pins?meta=nope", "Bearer " + testToken, http.StatusBadRequest},
+		{"unknown request", http.MethodGet, "/pins/nope", "Bearer " + testToken, http.StatusNotFound},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(tc.method, env.url+tc.path, nil)
+			require.NoError(t, err)
+			if tc.token != "" {
+				req.Header.Set("Authorization", tc.token)
+			}
+			res, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer res.Body.Close()
+			assert.Equal(t, tc.statusCode, res.StatusCode)
+			assert.Equal(t, mediaTypeJSON, res.Header.Get("Content-Type"))
+		})
+	}
+
+	t.Run("invalid pins are rejected", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, env.url+"/pins", strings.NewReader(`{"cid":"nope"}`))
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	})
+
+	t.Run("failure reasons are returned to the client", func(t *testing.T) {
+		_, err := pinclient.NewClient(env.url, "wrong").Add(context.Background(), merkledag.NodeWithData(nil).Cid())
+		assert.ErrorContains(t, err, "UNAUTHORIZED")
+	})
+}
+
+func TestMatchMode(t *testing.T) {
+	assert.True(t, MatchExact.Matches("Foo", "Foo"))
+	assert.False(t, MatchExact.Matches("foo", "Foo"))
+	assert.True(t, MatchIExact.Matches("foo", "Foo"))
+	assert.False(t, MatchIExact.Matches("fo", "Foo"))
+	assert.True(t, MatchPartial.Matches("oo", "Foo"))
+	assert.False(t, MatchPartial.Matches("Fo", "foo"))
+	assert.True(t, MatchIPartial.Matches("fO", "Foo"))
+}
@@ -9,6 +9,7 @@ import (
 	"io"
 	"mime"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
@@ -24,6 +25,7 @@ import (
 	logging "github.com/ipfs/go-log/v2"
 	"github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/routing"
 	"github.com/multiformats/go-multiaddr"
 )
 
@@ -51,9 +53,15 @@ type Client struct {
 	clock      clock.Clock
 	accepts    string
 
-	peerID   peer.ID
-	addrs    []types.Multiaddr
-	identity crypto.PrivKey
+	peerID    peer.ID
+	addrs     []types.Multiaddr
+	protocols []string
+	identity  crypto.PrivKey
+
+	// Filters sent with FindProviders and FindPeers requests, see [WithProtocolFilter]
+	// and [WithAddrFilter].
+	protocolFilter []string
+	addrFilter     []string
 
 	// Called immediately after signing a provide request. It is used
 	// for testing, e.g., testing the server with a mangled signature.
@@ -111,6 +119,39 @@ func WithProviderInfo(peerID peer.ID, addrs []multiaddr.Multiaddr) Option {
 	}
 }
 
+// WithProviderProtocols sets the transport protocols, e.g. "transport-bitswap"
+// or "transport-ipfs-gateway-http", that are announced by [Client.ProvidePeer].
+func WithProviderProtocols(protocols []string) Option {
+	return func(c *Client) {
+		c.protocols = protocols
+	}
+}
+
+// WithProtocolFilter sets the transport protocols that the server should use to
+// filter the results of [Client.FindProviders] and [Client.FindPeers]. Prefix a
+// protocol with "!" to exclude it, and use "unknown" to include records without
+// protocols. See [IPIP-484].
+//
+// [IPIP-484]: https://github.com/ipfs/specs/pull/484
+func WithProtocolFilter(protocolFilter []string) Option {
+	return func(c *Client) {
+		c.protocolFilter = protocolFilter
+	}
+}
+
+// WithAddrFilter sets the multiaddr protocols, e.g. "tcp", "quic-v1" or
+// "!p2p-circuit", that the server should use to filter the addresses of the
+// results of [Client.FindProviders] and [Client.FindPeers]. Records without
+// matching addresses are omitted. Use "unknown" to include records without
+// addresses. See [IPIP-484].
+//
+// [IPIP-484]: https://github.com/ipfs/specs/pull/484
+func WithAddrFilter(addrFilter []string) Option {
+	return func(c *Client) {
+		c.addrFilter = addrFilter
+	}
+}
+
 func WithStreamResultsRequired() Option {
 	return func(c *Client) {
 		c.accepts = mediaTypeNDJSON
@@ -166,7 +207,7 @@ func (c *Client) FindProviders(ctx context.Context, key cid.Cid) (providers iter
 	// TODO test measurements
 	m := newMeasurement("FindProviders")
 
-	url := c.baseURL + "/routing/v1/providers/" + key.String()
+	url := c.baseURL + "/routing/v1/providers/" + key.String() + c.filtersQuery()
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
@@ -282,63 +323,140 @@ func (c *Client) ProvideBitswap(ctx context.Context, keys []cid.Cid, ttl time.Du
 	return advisoryTTL, err
 }
 
-// ProvideAsync makes a provide request to a delegated router
-//
 //lint:ignore SA1019 // ignore staticcheck
 func (c *Client) provideSignedBitswapRecord(ctx context.Context, bswp *types.WriteBitswapRecord) (time.Duration, error) {
+	res, err := c.provideSignedRecord(ctx, bswp)
+	if err != nil {
+		return 0, err
+	}
+
 	//lint:ignore SA1019 // ignore staticcheck
-	req := jsontypes.WriteProvidersRequest{Providers: []types.Record{bswp}}
+	v, ok := res.(*types.WriteBitswapRecordResponse)
+	if !ok {
+		return 0, errors.New("expected AdvisoryTTL field")
+	}
+
+	if v.AdvisoryTTL != nil {
+		return v.AdvisoryTTL.Duration, nil
+	}
+
+	return 0, nil
+}
+
+// ProvidePeer announces to the delegated router that the peer set with
+// [WithProviderInfo] provides the given keys over the protocols set with
+// [WithProviderProtocols], with a [types.WritePeerRecord] signed by the
+// identity set with [WithIdentity].
+func (c *Client) ProvidePeer(ctx context.Context, keys []cid.Cid, ttl time.Duration) (time.Duration, error) {
+	if c.identity == nil {
+		return 0, errors.New("cannot provide peer records without an identity")
+	}
+	if c.peerID.Size() == 0 {
+		return 0, errors.New("cannot provide peer records without a peer ID")
+	}
+
+	ks := make([]types.CID, len(keys))
+	for i, c := range keys {
+		ks[i] = types.CID{Cid: c}
+	}
+
+	now := c.clock.Now()
+
+	req := types.WritePeerRecord{
+		Schema: types.SchemaPeer,
+		Payload: types.PeerPayload{
+			Keys:        ks,
+			AdvisoryTTL: &types.Duration{Duration: ttl},
+			Timestamp:   &types.Time{Time: now},
+			ID:          &c.peerID,
+			Addrs:       c.addrs,
+			Protocols:   c.protocols,
+		},
+	}
+	err := req.Sign(c.peerID, c.identity)
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := c.provideSignedRecord(ctx, &req)
+	if err != nil {
+		return 0, err
+	}
+
+	v, ok := res.(*types.WritePeerRecordResponse)
+	if !ok {
+		return 0, errors.New("expected AdvisoryTTL field")
+	}
+
+	if v.AdvisoryTTL != nil {
+		return v.AdvisoryTTL.Duration, nil
+	}
+
+	return 0, nil
+}
+
+// provideSignedRecord makes a provide request to a delegated router with a
+// single signed record and returns its result.
+func (c *Client) provideSignedRecord(ctx context.Context, record types.Record) (types.Record, error) {
+	//lint:ignore SA1019 // ignore staticcheck
+	req := jsontypes.WriteProvidersRequest{Providers: []types.Record{record}}
 
 	url := c.baseURL + "/routing/v1/providers/"
 
 	b, err := drjson.MarshalJSONBytes(req)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
 	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPut, url, bytes.NewBuffer(b))
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
 	resp, err := c.httpClient.Do(httpReq)
 	if err != nil {
-		return 0, fmt.Errorf("making HTTP req to provide a signed record: %w", err)
+		return nil, fmt.Errorf("making HTTP req to provide a signed record: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return 0, httpError(resp.StatusCode, resp.Body)
+		return nil, httpError(resp.StatusCode, resp.Body)
 	}
 
 	//lint:ignore SA1019 // ignore staticcheck
 	var provideResult jsontypes.WriteProvidersResponse
 	err = json.NewDecoder(resp.Body).Decode(&provideResult)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 	if len(provideResult.ProvideResults) != 1 {
-		return 0, fmt.Errorf("expected 1 result but got %d", len(provideResult.ProvideResults))
+		return nil, fmt.Errorf("expected 1 result but got %d", len(provideResult.ProvideResults))
 	}
 
-	//lint:ignore SA1019 // ignore staticcheck
-	v, ok := provideResult.ProvideResults[0].(*types.WriteBitswapRecordResponse)
-	if !ok {
-		return 0, errors.New("expected AdvisoryTTL field")
-	}
+	return provideResult.ProvideResults[0], nil
+}
 
-	if v.AdvisoryTTL != nil {
-		return v.AdvisoryTTL.Duration, nil
+// filtersQuery returns the query string with the filters set with
+// [WithProtocolFilter] and [WithAddrFilter], if any.
+func (c *Client) filtersQuery() string {
+	query := url.Values{}
+	if len(c.protocolFilter) > 0 {
+		query.Set("filter-protocols", strings.Join(c.protocolFilter, ","))
 	}
-
-	return 0, nil
+	if len(c.addrFilter) > 0 {
+		query.Set("filter-addrs", strings.Join(c.addrFilter, ","))
+	}
+	if len(query) == 0 {
+		return ""
+	}
+	return "?" + query.Encode()
 }
 
 // FindPeers searches for information for the given [peer.ID].
 func (c *Client) FindPeers(ctx context.Context, pid peer.ID) (peers iter.ResultIter[*types.PeerRecord], err error) {
 	m := newMeasurement("FindPeers")
 
-	url := c.baseURL + "/routing/v1/peers/" + peer.ToCid(pid).String()
+	url := c.baseURL + "/routing/v1/peers/" + peer.ToCid(pid).String() + c.filtersQuery()
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
@@ -426,6 +544,9 @@ func (c *Client) GetIPNS(ctx context.Context, name ipns.Name) (*ipns.Record, err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, routing.ErrNotFound
+	}
 	if resp.StatusCode != http.StatusOK {
 		return nil, httpError(resp.StatusCode, resp.Body)
 	}
@@ -7,6 +7,7 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"runtime"
 	"testing"
 	"time"
@@ -42,6 +43,11 @@ func (m *mockContentRouter) ProvideBitswap(ctx context.Context, req *server.Bits
 	return args.Get(0).(time.Duration), args.Error(1)
 }
 
+func (m *mockContentRouter) ProvidePeer(ctx context.Context, req *server.PeerWriteProvideRequest) (time.Duration, error) {
+	args := m.Called(ctx, req)
+	return args.Get(0).(time.Duration), args.Error(1)
+}
+
 func (m *mockContentRouter) FindPeers(ctx context.Context, pid peer.ID, limit int) (iter.ResultIter[*types.PeerRecord], error) {
 	args := m.Called(ctx, pid, limit)
 	return args.Get(0).(iter.ResultIter[*types.PeerRecord]), args.Error(1)
@@ -484,6 +490,85 @@ func TestClient_Provide(t *testing.T) {
 	}
 }
 
+func TestClient_ProvidePeer(t *testing.T) {
+	t.Run("happy case", func(t *testing.T) {
+		deps := makeTestDeps(t, []Option{WithProviderProtocols([]string{"transport-ipfs-gateway-http"})}, nil)
+		client := deps.client
+
+		clock := clock.NewMock()
+		clock.Set(time.Now())
+		client.clock = clock
+
+		cids := []cid.Cid{makeCID()}
+		deps.router.On("ProvidePeer", mock.Anything, &server.PeerWriteProvideRequest{
+			Keys:        cids,
+			Timestamp:   clock.Now().Truncate(time.Millisecond),
+			AdvisoryTTL: time.Hour,
+			ID:          deps.peerID,
+			Addrs:       deps.addrs,
+			Protocols:   []string{"transport-ipfs-gateway-http"},
+		}).Return(time.Minute, nil)
+
+		advisoryTTL, err := client.ProvidePeer(context.Background(), cids, time.Hour)
+		require.NoError(t, err)
+		assert.Equal(t, time.Minute, advisoryTTL)
+	})
+
+	t.Run("should return error if identity is not provided", func(t *testing.T) {
+		deps := makeTestDeps(t, nil, nil)
+		deps.client.identity = nil
+
+		_, err := deps.client.ProvidePeer(context.Background(), []cid.Cid{makeCID()}, time.Hour)
+		require.ErrorContains(t, err, "cannot provide peer records without an identity")
+	})
+
+	t.Run("returns an error if the router fails", func(t *testing.T) {
+		deps := makeTestDeps(t, nil, nil)
+		deps.router.On("ProvidePeer", mock.Anything, mock.Anything).Return(time.Duration(0), errors.New("boom"))
+
+		_, err := deps.client.ProvidePeer(context.Background(), []cid.Cid{makeCID()}, time.Hour)
+		require.ErrorContains(t, err, "HTTP error with StatusCode=500: delegate error: boom")
+	})
+}
+
+func TestClient_Filters(t *testing.T) {
+	deps := makeTestDeps(t, []Option{
+		WithProtocolFilter([]string{"transport-ipfs-gateway-http", "!transport-bitswap"}),
+		WithAddrFilter([]string{"tcp"}),
+	}, nil)
+
+	var query url.Values
+	deps.recordingHandler.f = append(deps.recordingHandler.f, func(r *http.Request) {
+		query = r.URL.Query()
+	})
+
+	peerRecord := makePeerRecord()
+	httpRecord := makePeerRecord()
+	httpRecord.Protocols = []string{"transport-ipfs-gateway-http"}
+
+	cid := makeCID()
+	deps.router.On("FindProviders", mock.Anything, cid, 0).
+		Return(iter.FromSlice([]iter.Result[types.Record]{{Val: &peerRecord}, {Val: &httpRecord}}), nil)
+
+	provsIter, err := deps.client.FindProviders(context.Background(), cid)
+	require.NoError(t, err)
+	results := iter.ReadAll[iter.Result[types.Record]](provsIter)
+
+	assert.Equal(t, "transport-ipfs-gateway-http,!transport-bitswap", query.Get("filter-protocols"))
+	assert.Equal(t, "tcp", query.Get("filter-addrs"))
+	require.Len(t, results, 1)
+	require.NoError(t, results[0].Err)
+	assert.Equal(t, httpRecord.ID, results[0].Val.(*types.PeerRecord).ID)
+
+	deps.router.On("FindPeers", mock.Anything, *peerRecord.ID, 0).
+		Return(iter.FromSlice([]iter.Result[*types.PeerRecord]{{Val: &peerRecord}}), nil)
+
+	peersIter, err := deps.client.FindPeers(context.Background(), *peerRecord.ID)
+	require.NoError(t, err)
+	assert.Empty(t, iter.ReadAll[iter.Result[*types.PeerRecord]](peersIter))
+	assert.Equal(t, "transport-ipfs-gateway-http,!transport-bitswap", query.Get("filter-protocols"))
+}
+
 func TestClient_FindPeers(t *testing.T) {
 	peerRecord := makePeerRecord()
 	peerRecords := []iter.Result[*types.PeerRecord]{
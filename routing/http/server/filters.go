@@ -0,0 +1,190 @@
+package server
+
+import (
+	"strings"
+
+	"github.com/ipfs/boxo/routing/http/types"
+	"github.com/ipfs/boxo/routing/http/types/iter"
+	"github.com/multiformats/go-multiaddr"
+)
+
+const (
+	filterAddrsParam     = "filter-addrs"
+	filterProtocolsParam = "filter-protocols"
+
+	// filterUnknown matches records without protocols, or without addresses.
+	filterUnknown = "unknown"
+)
+
+// parseFilter splits a comma-separated filter query parameter into its values.
+func parseFilter(param string) []string {
+	var filters []string
+	for _, f := range strings.Split(param, ",") {
+		f = strings.TrimSpace(f)
+		if f != "" {
+			filters = append(filters, f)
+		}
+	}
+	return filters
+}
+
+// filteredIter is a [iter.ResultIter] that omits the records that do not match
+// the address and protocol filters, and the addresses that do not match the
+// address filters. Errors are passed through untouched.
+type filteredIter[T types.Record] struct {
+	iter.ResultIter[T]
+
+	filterAddrs     []string
+	filterProtocols []string
+
+	val iter.Result[T]
+}
+
+// applyFilters wraps the given iterator so that only the records matching the
+// given filters are returned. If there are no filters, the iterator is returned
+// as-is.
+func applyFilters[T types.Record](recordsIter iter.ResultIter[T], filterAddrs, filterProtocols []string) iter.ResultIter[T] {
+	if len(filterAddrs) == 0 && len(filterProtocols) == 0 {
+		return recordsIter
+	}
+
+	return &filteredIter[T]{
+		ResultIter:      recordsIter,
+		filterAddrs:     filterAddrs,
+		filterProtocols: filterProtocols,
+	}
+}
+
+func (it *filteredIter[T]) Next() bool {
+	for it.ResultIter.Next() {
+		res := it.ResultIter.Val()
+		if res.Err != nil {
+			it.val = res
+			return true
+		}
+
+		record, ok := filterRecord(res.Val, it.filterAddrs, it.filterProtocols)
+		if !ok {
+			continue
+		}
+
+		it.val = iter.Result[T]{Val: record.(T)}
+		return true
+	}
+	return false
+}
+
+func (it *filteredIter[T]) Val() iter.Result[T] {
+	return it.val
+}
+
+// filterRecord returns a copy of the record with only the addresses matching
+// filterAddrs. It returns false if the record does not match filterProtocols or
+// if none of its addresses match filterAddrs.
+func filterRecord(record types.Record, filterAddrs, filterProtocols []string) (types.Record, bool) {
+	switch r := record.(type) {
+	case *types.PeerRecord:
+		if !matchesFilters(r.Protocols, filterProtocols) {
+			return nil, false
+		}
+		addrs, ok := applyAddrFilter(r.Addrs, filterAddrs)
+		if !ok {
+			return nil, false
+		}
+		cp := *r
+		cp.Addrs = addrs
+		return &cp, true
+	//lint:ignore SA1019 // ignore staticcheck
+	case *types.BitswapRecord:
+		var protocols []string
+		if r.Protocol != "" {
+			protocols = []string{r.Protocol}
+		}
+		if !matchesFilters(protocols, filterProtocols) {
+			return nil, false
+		}
+		addrs, ok := applyAddrFilter(r.Addrs, filterAddrs)
+		if !ok {
+			return nil, false
+		}
+		cp := *r
+		cp.Addrs = addrs
+		return &cp, true
+	default:
+		// Records of unknown schemas have neither known protocols nor addresses.
+		if !matchesFilters(nil, filterProtocols) || !matchesFilters(nil, filterAddrs) {
+			return nil, false
+		}
+		return record, true
+	}
+}
+
+// applyAddrFilter returns the addresses that match the filters, using the names
+// of their multiaddr protocols (e.g. "tcp", "quic-v1" or "p2p-circuit"). It
+// returns false if none of the addresses match.
+func applyAddrFilter(addrs []types.Multiaddr, filters []string) ([]types.Multiaddr, bool) {
+	if len(filters) == 0 {
+		return addrs, true
+	}
+
+	if len(addrs) == 0 {
+		return addrs, matchesFilters(nil, filters)
+	}
+
+	var filtered []types.Multiaddr
+	for _, addr := range addrs {
+		if addr.Multiaddr == nil {
+			continue
+		}
+
+		var protocols []string
+		multiaddr.ForEach(addr.Multiaddr, func(c multiaddr.Component) bool {
+			protocols = append(protocols, c.Protocol().Name)
+			return true
+		})
+
+		if matchesFilters(protocols, filters) {
+			filtered = append(filtered, addr)
+		}
+	}
+	return filtered, len(filtered) > 0
+}
+
+// matchesFilters returns true if the given protocols match the filters. A value
+// prefixed with "!" excludes anything that has it. If there is any other value,
+// at least one of them is required. An empty list of protocols is matched as
+// [filterUnknown].
+func matchesFilters(protocols, filters []string) bool {
+	if len(filters) == 0 {
+		return true
+	}
+
+	if len(protocols) == 0 {
+		protocols = []string{filterUnknown}
+	}
+
+	var hasPositive, matched bool
+	for _, f := range filters {
+		if negated, ok := strings.CutPrefix(f, "!"); ok {
+			if contains(protocols, negated) {
+				return false
+			}
+			continue
+		}
+
+		hasPositive = true
+		if contains(protocols, f) {
+			matched = true
+		}
+	}
+	return matched || !hasPositive
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
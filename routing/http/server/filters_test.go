@@ -0,0 +1,100 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/ipfs/boxo/routing/http/types"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFilter(t *testing.T) {
+	assert.Nil(t, parseFilter(""))
+	assert.Equal(t, []string{"tcp", "!p2p-circuit"}, parseFilter("tcp, !p2p-circuit,"))
+}
+
+func TestMatchesFilters(t *testing.T) {
+	for _, c := range []struct {
+		protocols []string
+		filters   []string
+		expected  bool
+	}{
+		{[]string{"transport-bitswap"}, nil, true},
+		{[]string{"transport-bitswap"}, []string{"transport-bitswap"}, true},
+		{[]string{"transport-bitswap"}, []string{"transport-ipfs-gateway-http"}, false},
+		{[]string{"transport-bitswap", "transport-ipfs-gateway-http"}, []string{"transport-ipfs-gateway-http"}, true},
+		{[]string{"transport-bitswap"}, []string{"!transport-bitswap"}, false},
+		{[]string{"transport-bitswap"}, []string{"!transport-graphsync-filecoinv1"}, true},
+		{[]string{"transport-bitswap", "transport-ipfs-gateway-http"}, []string{"transport-ipfs-gateway-http", "!transport-bitswap"}, false},
+		{nil, []string{"transport-bitswap"}, false},
+		{nil, []string{"transport-bitswap", "unknown"}, true},
+		{nil, []string{"!unknown"}, false},
+		{nil, []string{"!transport-bitswap"}, true},
+	} {
+		assert.Equal(t, c.expected, matchesFilters(c.protocols, c.filters), "protocols %v with filters %v", c.protocols, c.filters)
+	}
+}
+
+func TestFilterRecord(t *testing.T) {
+	pid, err := peer.Decode("12D3KooWM8sovaEGU1bmiWGWAzvs47DEcXKZZTuJnpQyVTkRs2Vn")
+	require.NoError(t, err)
+
+	mustMultiaddr := func(s string) types.Multiaddr {
+		ma, err := multiaddr.NewMultiaddr(s)
+		require.NoError(t, err)
+		return types.Multiaddr{Multiaddr: ma}
+	}
+
+	tcp := mustMultiaddr("/ip4/127.0.0.1/tcp/4001")
+	quic := mustMultiaddr("/ip4/127.0.0.1/udp/4001/quic-v1")
+	relay := mustMultiaddr("/ip4/127.0.0.1/tcp/4001/p2p/12D3KooWM8sovaEGU1bmiWGWAzvs47DEcXKZZTuJnpQyVTkRs2Vz/p2p-circuit")
+
+	record := &types.PeerRecord{
+		Schema:    types.SchemaPeer,
+		ID:        &pid,
+		Protocols: []string{"transport-bitswap"},
+		Addrs:     []types.Multiaddr{tcp, quic, relay},
+	}
+
+	t.Run("Addresses are filtered", func(t *testing.T) {
+		res, ok := filterRecord(record, []string{"tcp", "!p2p-circuit"}, nil)
+		require.True(t, ok)
+		assert.Equal(t, []types.Multiaddr{tcp}, res.(*types.PeerRecord).Addrs)
+
+		// The original record is not modified.
+		assert.Len(t, record.Addrs, 3)
+	})
+
+	t.Run("Record without matching addresses is omitted", func(t *testing.T) {
+		_, ok := filterRecord(record, []string{"webtransport"}, nil)
+		assert.False(t, ok)
+	})
+
+	t.Run("Record without matching protocols is omitted", func(t *testing.T) {
+		_, ok := filterRecord(record, nil, []string{"transport-ipfs-gateway-http"})
+		assert.False(t, ok)
+	})
+
+	t.Run("Bitswap record protocol is matched", func(t *testing.T) {
+		//lint:ignore SA1019 // ignore staticcheck
+		_, ok := filterRecord(&types.BitswapRecord{
+			//lint:ignore SA1019 // ignore staticcheck
+			Schema:   types.SchemaBitswap,
+			ID:       &pid,
+			Protocol: "transport-bitswap",
+		}, nil, []string{"transport-bitswap"})
+		assert.True(t, ok)
+	})
+
+	t.Run("Unknown record only matches unknown", func(t *testing.T) {
+		unknown := &types.UnknownRecord{Schema: "unknown-schema"}
+
+		_, ok := filterRecord(unknown, nil, []string{"transport-bitswap"})
+		assert.False(t, ok)
+
+		_, ok = filterRecord(unknown, []string{"unknown"}, []string{"unknown"})
+		assert.True(t, ok)
+	})
+}
@@ -22,6 +22,7 @@ import (
 	jsontypes "github.com/ipfs/boxo/routing/http/types/json"
 	"github.com/ipfs/go-cid"
 	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/routing"
 	"github.com/multiformats/go-multiaddr"
 
 	logging "github.com/ipfs/go-log/v2"
@@ -61,6 +62,10 @@ type ContentRouter interface {
 	// [IPIP-378]: https://github.com/ipfs/specs/pull/378
 	ProvideBitswap(ctx context.Context, req *BitswapWriteProvideRequest) (time.Duration, error)
 
+	// ProvidePeer stores the provider record for the given [PeerWriteProvideRequest].
+	// It is guaranteed that the request signature matches the provided peer.
+	ProvidePeer(ctx context.Context, req *PeerWriteProvideRequest) (time.Duration, error)
+
 	// FindPeers searches for peers who have the provided [peer.ID].
 	// Limit indicates the maximum amount of results to return; 0 means unbounded.
 	FindPeers(ctx context.Context, pid peer.ID, limit int) (iter.ResultIter[*types.PeerRecord], error)
@@ -84,6 +89,17 @@ type BitswapWriteProvideRequest struct {
 	Addrs       []multiaddr.Multiaddr
 }
 
+// PeerWriteProvideRequest is a verified request to provide keys with a
+// [types.WritePeerRecord].
+type PeerWriteProvideRequest struct {
+	Keys        []cid.Cid
+	Timestamp   time.Time
+	AdvisoryTTL time.Duration
+	ID          peer.ID
+	Addrs       []multiaddr.Multiaddr
+	Protocols   []string
+}
+
 // Deprecated: protocol-agnostic provide is being worked on in [IPIP-378]:
 //
 // [IPIP-378]: https://github.com/ipfs/specs/pull/378
@@ -212,13 +228,17 @@ func (s *server) findProviders(w http.ResponseWriter, httpReq *http.Request) {
 		recordsLimit = s.recordsLimit
 	}
 
+	query := httpReq.URL.Query()
+	filterAddrs := parseFilter(query.Get(filterAddrsParam))
+	filterProtocols := parseFilter(query.Get(filterProtocolsParam))
+
 	provIter, err := s.svc.FindProviders(httpReq.Context(), cid, recordsLimit)
 	if err != nil {
 		writeErr(w, "FindProviders", http.StatusInternalServerError, fmt.Errorf("delegate error: %w", err))
 		return
 	}
 
-	handlerFunc(w, provIter)
+	handlerFunc(w, applyFilters(provIter, filterAddrs, filterProtocols))
 }
 
 func (s *server) findProvidersJSON(w http.ResponseWriter, provIter iter.ResultIter[types.Record]) {
@@ -279,13 +299,17 @@ func (s *server) findPeers(w http.ResponseWriter, r *http.Request) {
 		recordsLimit = s.recordsLimit
 	}
 
+	query := r.URL.Query()
+	filterAddrs := parseFilter(query.Get(filterAddrsParam))
+	filterProtocols := parseFilter(query.Get(filterProtocolsParam))
+
 	provIter, err := s.svc.FindPeers(r.Context(), pid, recordsLimit)
 	if err != nil {
 		writeErr(w, "FindPeers", http.StatusInternalServerError, fmt.Errorf("delegate error: %w", err))
 		return
 	}
 
-	handlerFunc(w, provIter)
+	handlerFunc(w, applyFilters(provIter, filterAddrs, filterProtocols))
 }
 
 func (s *server) provide(w http.ResponseWriter, httpReq *http.Request) {
@@ -339,8 +363,52 @@ func (s *server) provide(w http.ResponseWriter, httpReq *http.Request) {
 					AdvisoryTTL: &types.Duration{Duration: advisoryTTL},
 				},
 			)
+		case *types.WritePeerRecord:
+			err := v.Verify()
+			if err != nil {
+				logErr("Provide", "signature verification failed", err)
+				writeErr(w, "Provide", http.StatusForbidden, errors.New("signature verification failed"))
+				return
+			}
+
+			keys := make([]cid.Cid, len(v.Payload.Keys))
+			for i, k := range v.Payload.Keys {
+				keys[i] = k.Cid
+			}
+			addrs := make([]multiaddr.Multiaddr, len(v.Payload.Addrs))
+			for i, a := range v.Payload.Addrs {
+				addrs[i] = a.Multiaddr
+			}
+			var (
+				timestamp   time.Time
+				advisoryTTL time.Duration
+			)
+			if v.Payload.Timestamp != nil {
+				timestamp = v.Payload.Timestamp.Time
+			}
+			if v.Payload.AdvisoryTTL != nil {
+				advisoryTTL = v.Payload.AdvisoryTTL.Duration
+			}
+			advisoryTTL, err = s.svc.ProvidePeer(httpReq.Context(), &PeerWriteProvideRequest{
+				Keys:        keys,
+				Timestamp:   timestamp,
+				AdvisoryTTL: advisoryTTL,
+				ID:          *v.Payload.ID,
+				Addrs:       addrs,
+				Protocols:   v.Payload.Protocols,
+			})
+			if err != nil {
+				writeErr(w, "Provide", http.StatusInternalServerError, fmt.Errorf("delegate error: %w", err))
+				return
+			}
+			resp.ProvideResults = append(resp.ProvideResults,
+				&types.WritePeerRecordResponse{
+					Schema:      v.Schema,
+					AdvisoryTTL: &types.Duration{Duration: advisoryTTL},
+				},
+			)
 		default:
-			writeErr(w, "Provide", http.StatusBadRequest, fmt.Errorf("provider record %d is not bitswap", i))
+			writeErr(w, "Provide", http.StatusBadRequest, fmt.Errorf("provider record %d has unsupported schema %q", i, prov.GetSchema()))
 			return
 		}
 	}
@@ -386,6 +454,10 @@ func (s *server) GetIPNS(w http.ResponseWriter, r *http.Request) {
 	}
 
 	record, err := s.svc.GetIPNS(r.Context(), name)
+	if errors.Is(err, routing.ErrNotFound) {
+		writeErr(w, "GetIPNS", http.StatusNotFound, err)
+		return
+	}
 	if err != nil {
 		writeErr(w, "GetIPNS", http.StatusInternalServerError, fmt.Errorf("delegate error: %w", err))
 		return
@@ -12,12 +12,15 @@ import (
 
 	"github.com/ipfs/boxo/ipns"
 	"github.com/ipfs/boxo/path"
+	"github.com/ipfs/boxo/routing/http/internal/drjson"
 	"github.com/ipfs/boxo/routing/http/types"
 	"github.com/ipfs/boxo/routing/http/types/iter"
+	jsontypes "github.com/ipfs/boxo/routing/http/types/json"
 	"github.com/ipfs/go-cid"
 	"github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/libp2p/go-libp2p/core/peer"
 	b58 "github.com/mr-tron/base58/base58"
+	"github.com/multiformats/go-multiaddr"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
@@ -135,6 +138,129 @@ func TestProviders(t *testing.T) {
 	})
 }
 
+func TestProvidersWithFilters(t *testing.T) {
+	pid, err := peer.Decode("12D3KooWM8sovaEGU1bmiWGWAzvs47DEcXKZZTuJnpQyVTkRs2Vn")
+	require.NoError(t, err)
+	pid2, err := peer.Decode("12D3KooWM8sovaEGU1bmiWGWAzvs47DEcXKZZTuJnpQyVTkRs2Vz")
+	require.NoError(t, err)
+
+	cidStr := "bafkreifjjcie6lypi6ny7amxnfftagclbuxndqonfipmb64f2km2devei4"
+	cid, err := cid.Decode(cidStr)
+	require.NoError(t, err)
+
+	tcp, err := multiaddr.NewMultiaddr("/ip4/127.0.0.1/tcp/4001")
+	require.NoError(t, err)
+	quic, err := multiaddr.NewMultiaddr("/ip4/127.0.0.1/udp/4001/quic-v1")
+	require.NoError(t, err)
+
+	results := iter.FromSlice([]iter.Result[types.Record]{
+		{Val: &types.PeerRecord{
+			Schema:    types.SchemaPeer,
+			ID:        &pid,
+			Protocols: []string{"transport-bitswap"},
+			Addrs:     []types.Multiaddr{{Multiaddr: tcp}},
+		}},
+		{Val: &types.PeerRecord{
+			Schema:    types.SchemaPeer,
+			ID:        &pid2,
+			Protocols: []string{"transport-ipfs-gateway-http"},
+			Addrs:     []types.Multiaddr{{Multiaddr: tcp}, {Multiaddr: quic}},
+		}}},
+	)
+
+	router := &mockContentRouter{}
+	server := httptest.NewServer(Handler(router))
+	t.Cleanup(server.Close)
+	serverAddr := "http://" + server.Listener.Addr().String()
+	router.On("FindProviders", mock.Anything, cid, DefaultStreamingRecordsLimit).Return(results, nil)
+
+	req, err := http.NewRequest(http.MethodGet, serverAddr+"/routing/v1/providers/"+cidStr+"?filter-protocols=transport-ipfs-gateway-http&filter-addrs=quic-v1", nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept", mediaTypeNDJSON)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.Equal(t, 200, resp.StatusCode)
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, `{"Addrs":["/ip4/127.0.0.1/udp/4001/quic-v1"],"ID":"12D3KooWM8sovaEGU1bmiWGWAzvs47DEcXKZZTuJnpQyVTkRs2Vz","Protocols":["transport-ipfs-gateway-http"],"Schema":"peer"}`+"\n", string(body))
+}
+
+func TestProvidePeer(t *testing.T) {
+	sk, pid := makePeerID(t)
+
+	cidStr := "bafkreifjjcie6lypi6ny7amxnfftagclbuxndqonfipmb64f2km2devei4"
+	c, err := cid.Decode(cidStr)
+	require.NoError(t, err)
+
+	tcp, err := multiaddr.NewMultiaddr("/ip4/127.0.0.1/tcp/4001")
+	require.NoError(t, err)
+
+	now := time.UnixMilli(time.Now().UnixMilli())
+	makeRecord := func(t *testing.T) *types.WritePeerRecord {
+		rec := &types.WritePeerRecord{
+			Schema: types.SchemaPeer,
+			Payload: types.PeerPayload{
+				Keys:        []types.CID{{Cid: c}},
+				Timestamp:   &types.Time{Time: now},
+				AdvisoryTTL: &types.Duration{Duration: time.Hour},
+				ID:          &pid,
+				Addrs:       []types.Multiaddr{{Multiaddr: tcp}},
+				Protocols:   []string{"transport-ipfs-gateway-http"},
+			},
+		}
+		require.NoError(t, rec.Sign(pid, sk))
+		return rec
+	}
+
+	makeRequest := func(t *testing.T, router *mockContentRouter, rec *types.WritePeerRecord) *http.Response {
+		server := httptest.NewServer(Handler(router))
+		t.Cleanup(server.Close)
+
+		b, err := drjson.MarshalJSONBytes(jsontypes.WriteProvidersRequest{Providers: []types.Record{rec}})
+		require.NoError(t, err)
+
+		req, err := http.NewRequest(http.MethodPut, "http://"+server.Listener.Addr().String()+"/routing/v1/providers/", bytes.NewReader(b))
+		require.NoError(t, err)
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	t.Run("Signed record is provided", func(t *testing.T) {
+		t.Parallel()
+
+		router := &mockContentRouter{}
+		router.On("ProvidePeer", mock.Anything, &PeerWriteProvideRequest{
+			Keys:        []cid.Cid{c},
+			Timestamp:   now,
+			AdvisoryTTL: time.Hour,
+			ID:          pid,
+			Addrs:       []multiaddr.Multiaddr{tcp},
+			Protocols:   []string{"transport-ipfs-gateway-http"},
+		}).Return(time.Minute, nil)
+
+		resp := makeRequest(t, router, makeRecord(t))
+		require.Equal(t, 200, resp.StatusCode)
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Equal(t, `{"ProvideResults":[{"Schema":"peer","AdvisoryTTL":60000000000}]}`, string(body))
+	})
+
+	t.Run("Mangled signature is rejected", func(t *testing.T) {
+		t.Parallel()
+
+		rec := makeRecord(t)
+		rec.Signature = "m" + rec.Signature[2:]
+
+		resp := makeRequest(t, &mockContentRouter{}, rec)
+		require.Equal(t, 403, resp.StatusCode)
+	})
+}
+
 func TestPeers(t *testing.T) {
 	makeRequest := func(t *testing.T, router *mockContentRouter, contentType, arg string) *http.Response {
 		server := httptest.NewServer(Handler(router))
@@ -374,6 +500,11 @@ func (m *mockContentRouter) ProvideBitswap(ctx context.Context, req *BitswapWrit
 	return args.Get(0).(time.Duration), args.Error(1)
 }
 
+func (m *mockContentRouter) ProvidePeer(ctx context.Context, req *PeerWriteProvideRequest) (time.Duration, error) {
+	args := m.Called(ctx, req)
+	return args.Get(0).(time.Duration), args.Error(1)
+}
+
 func (m *mockContentRouter) FindPeers(ctx context.Context, pid peer.ID, limit int) (iter.ResultIter[*types.PeerRecord], error) {
 	args := m.Called(ctx, pid, limit)
 	return args.Get(0).(iter.ResultIter[*types.PeerRecord]), args.Error(1)
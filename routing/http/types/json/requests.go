@@ -38,6 +38,13 @@ func (r *WriteProvidersRequest) UnmarshalJSON(b []byte) error {
 				return err
 			}
 			r.Providers = append(r.Providers, &prov)
+		case types.SchemaPeer:
+			var prov types.WritePeerRecord
+			err := json.Unmarshal(rawProv.Bytes, &prov)
+			if err != nil {
+				return err
+			}
+			r.Providers = append(r.Providers, &prov)
 		default:
 			var prov types.UnknownRecord
 			err := json.Unmarshal(b, &prov)
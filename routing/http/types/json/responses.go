@@ -89,6 +89,13 @@ func (r *WriteProvidersResponse) UnmarshalJSON(b []byte) error {
 				return err
 			}
 			r.ProvideResults = append(r.ProvideResults, &prov)
+		case types.SchemaPeer:
+			var prov types.WritePeerRecordResponse
+			err := json.Unmarshal(rawProv.Bytes, &prov)
+			if err != nil {
+				return err
+			}
+			r.ProvideResults = append(r.ProvideResults, &prov)
 		default:
 			r.ProvideResults = append(r.ProvideResults, &rawProv)
 		}
@@ -1,7 +1,6 @@
 package types
 
 import (
-	"crypto/sha256"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -9,7 +8,6 @@ import (
 	"github.com/ipfs/boxo/routing/http/internal/drjson"
 	"github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/libp2p/go-libp2p/core/peer"
-	"github.com/multiformats/go-multibase"
 )
 
 // Deprecated: use the more versatile [SchemaPeer] instead. For more information, read [IPIP-417].
@@ -115,19 +113,9 @@ func (p *WriteBitswapRecord) Sign(peerID peer.ID, key crypto.PrivKey) error {
 	if err != nil {
 		return err
 	}
-	hash := sha256.Sum256([]byte(p.RawPayload))
-	sig, err := key.Sign(hash[:])
-	if err != nil {
-		return err
-	}
 
-	sigStr, err := multibase.Encode(multibase.Base64, sig)
-	if err != nil {
-		return fmt.Errorf("multibase-encoding signature: %w", err)
-	}
-
-	p.Signature = sigStr
-	return nil
+	p.Signature, err = signRawPayload(key, p.RawPayload)
+	return err
 }
 
 func (p *WriteBitswapRecord) Verify() error {
@@ -148,26 +136,7 @@ func (p *WriteBitswapRecord) Verify() error {
 		}
 	}
 
-	pk, err := p.Payload.ID.ExtractPublicKey()
-	if err != nil {
-		return fmt.Errorf("extracing public key from peer ID: %w", err)
-	}
-
-	_, sigBytes, err := multibase.Decode(p.Signature)
-	if err != nil {
-		return fmt.Errorf("multibase-decoding signature to verify: %w", err)
-	}
-
-	hash := sha256.Sum256([]byte(p.RawPayload))
-	ok, err := pk.Verify(hash[:], sigBytes)
-	if err != nil {
-		return fmt.Errorf("verifying hash with signature: %w", err)
-	}
-	if !ok {
-		return errors.New("signature failed to verify")
-	}
-
-	return nil
+	return verifyRawPayload(*p.Payload.ID, p.Signature, p.RawPayload)
 }
 
 var _ Record = &WriteBitswapRecordResponse{}
@@ -1,10 +1,15 @@
 package types
 
 import (
+	"crypto/sha256"
 	"encoding/json"
+	"errors"
+	"fmt"
 
 	"github.com/ipfs/boxo/routing/http/internal/drjson"
+	"github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multibase"
 )
 
 const SchemaPeer = "peer"
@@ -79,3 +84,160 @@ func (pr PeerRecord) MarshalJSON() ([]byte, error) {
 
 	return drjson.MarshalJSONBytes(m)
 }
+
+var _ Record = &WritePeerRecord{}
+
+// WritePeerRecord is a signed request to announce that the peer described in
+// the payload provides the given keys.
+type WritePeerRecord struct {
+	Schema    string
+	Signature string
+
+	// this content must be untouched because it is signed and we need to verify it
+	RawPayload json.RawMessage `json:"Payload"`
+	Payload    PeerPayload     `json:"-"`
+}
+
+type PeerPayload struct {
+	Keys        []CID
+	Timestamp   *Time
+	AdvisoryTTL *Duration
+	ID          *peer.ID
+	Addrs       []Multiaddr
+	Protocols   []string
+}
+
+func (wr *WritePeerRecord) GetSchema() string {
+	return wr.Schema
+}
+
+type tmpWPR WritePeerRecord
+
+func (p *WritePeerRecord) UnmarshalJSON(b []byte) error {
+	var wpr tmpWPR
+	err := json.Unmarshal(b, &wpr)
+	if err != nil {
+		return err
+	}
+
+	p.Schema = wpr.Schema
+	p.Signature = wpr.Signature
+	p.RawPayload = wpr.RawPayload
+
+	return json.Unmarshal(wpr.RawPayload, &p.Payload)
+}
+
+func (p *WritePeerRecord) IsSigned() bool {
+	return p.Signature != ""
+}
+
+func (p *WritePeerRecord) setRawPayload() error {
+	payloadBytes, err := drjson.MarshalJSONBytes(p.Payload)
+	if err != nil {
+		return fmt.Errorf("marshaling peer write provider payload: %w", err)
+	}
+
+	p.RawPayload = payloadBytes
+
+	return nil
+}
+
+func (p *WritePeerRecord) Sign(peerID peer.ID, key crypto.PrivKey) error {
+	if p.IsSigned() {
+		return errors.New("already signed")
+	}
+
+	if key == nil {
+		return errors.New("no key provided")
+	}
+
+	sid, err := peer.IDFromPrivateKey(key)
+	if err != nil {
+		return err
+	}
+	if sid != peerID {
+		return errors.New("not the correct signing key")
+	}
+
+	err = p.setRawPayload()
+	if err != nil {
+		return err
+	}
+
+	p.Signature, err = signRawPayload(key, p.RawPayload)
+	return err
+}
+
+func (p *WritePeerRecord) Verify() error {
+	if !p.IsSigned() {
+		return errors.New("not signed")
+	}
+
+	if p.Payload.ID == nil {
+		return errors.New("peer ID must be specified")
+	}
+
+	// note that we only generate and set the payload if it hasn't already been set
+	// to allow for passing through the payload untouched if it is already provided
+	if p.RawPayload == nil {
+		err := p.setRawPayload()
+		if err != nil {
+			return err
+		}
+	}
+
+	return verifyRawPayload(*p.Payload.ID, p.Signature, p.RawPayload)
+}
+
+var _ Record = &WritePeerRecordResponse{}
+
+type WritePeerRecordResponse struct {
+	Schema      string
+	AdvisoryTTL *Duration
+}
+
+func (r *WritePeerRecordResponse) GetSchema() string {
+	return r.Schema
+}
+
+// signRawPayload signs the SHA-256 hash of a raw write record payload and
+// returns the multibase-encoded signature.
+func signRawPayload(key crypto.PrivKey, rawPayload json.RawMessage) (string, error) {
+	hash := sha256.Sum256([]byte(rawPayload))
+	sig, err := key.Sign(hash[:])
+	if err != nil {
+		return "", err
+	}
+
+	sigStr, err := multibase.Encode(multibase.Base64, sig)
+	if err != nil {
+		return "", fmt.Errorf("multibase-encoding signature: %w", err)
+	}
+
+	return sigStr, nil
+}
+
+// verifyRawPayload verifies that the multibase-encoded signature of a raw
+// write record payload was made by the given peer.
+func verifyRawPayload(id peer.ID, signature string, rawPayload json.RawMessage) error {
+	pk, err := id.ExtractPublicKey()
+	if err != nil {
+		return fmt.Errorf("extracing public key from peer ID: %w", err)
+	}
+
+	_, sigBytes, err := multibase.Decode(signature)
+	if err != nil {
+		return fmt.Errorf("multibase-decoding signature to verify: %w", err)
+	}
+
+	hash := sha256.Sum256([]byte(rawPayload))
+	ok, err := pk.Verify(hash[:], sigBytes)
+	if err != nil {
+		return fmt.Errorf("verifying hash with signature: %w", err)
+	}
+	if !ok {
+		return errors.New("signature failed to verify")
+	}
+
+	return nil
+}
@@ -8,6 +8,7 @@ import (
 	"os"
 	fp "path/filepath"
 	"strings"
+	"time"
 )
 
 var (
@@ -28,6 +29,10 @@ var (
 //
 // Overwriting: Extraction of files and symlinks will result in overwriting the existing objects with the same name
 // when possible (i.e. other files, symlinks, and empty directories).
+//
+// Metadata: The permissions and modification times recorded in the tar headers are restored for files and
+// directories (symlinks are left with the defaults of the platform). Directories are updated once all the entries
+// have been extracted so that creating their children doesn't alter them.
 type Extractor struct {
 	Path     string
 	Progress func(int64) int64
@@ -44,6 +49,9 @@ func (te *Extractor) Extract(reader io.Reader) error {
 
 	var firstObjectWasDir bool
 
+	// directories whose metadata is restored once extraction is done
+	var dirs []extractedDir
+
 	header, err := tarReader.Next()
 	if err != nil && err != io.EOF {
 		return err
@@ -85,6 +93,7 @@ func (te *Extractor) Extract(reader io.Reader) error {
 		if err := te.extractDir(rootOutputPath); err != nil {
 			return err
 		}
+		dirs = append(dirs, extractedDir{rootOutputPath, header})
 	case tar.TypeReg, tar.TypeSymlink:
 		// Check if the output path already exists, so we know whether we should
 		// create our output with that name, or if we should put the output inside
@@ -114,7 +123,7 @@ func (te *Extractor) Extract(reader io.Reader) error {
 
 		// If an object with the target name already exists overwrite it
 		if header.Typeflag == tar.TypeReg {
-			if err := te.extractFile(outputPath, tarReader); err != nil {
+			if err := te.extractFile(outputPath, tarReader, header); err != nil {
 				return err
 			}
 		} else if err := te.extractSymlink(outputPath, header); err != nil {
@@ -173,8 +182,9 @@ func (te *Extractor) Extract(reader io.Reader) error {
 			if err := te.extractDir(outputPath); err != nil {
 				return err
 			}
+			dirs = append(dirs, extractedDir{outputPath, header})
 		case tar.TypeReg:
-			if err := te.extractFile(outputPath, tarReader); err != nil {
+			if err := te.extractFile(outputPath, tarReader, header); err != nil {
 				return err
 			}
 		case tar.TypeSymlink:
@@ -185,6 +195,37 @@ func (te *Extractor) Extract(reader io.Reader) error {
 			return fmt.Errorf("unrecognized tar header type: %d", header.Typeflag)
 		}
 	}
+
+	// Restore directory metadata deepest first, as changing the permissions
+	// of a parent could prevent updating its children.
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := setMetadata(dirs[i].path, dirs[i].header); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// extractedDir is a directory pending to have its metadata restored.
+type extractedDir struct {
+	path   string
+	header *tar.Header
+}
+
+// setMetadata restores the permissions and modification time in the tar
+// header (if set) on the object at path. The setuid, setgid and sticky bits
+// are not restored, as archives may not be trusted.
+func setMetadata(path string, h *tar.Header) error {
+	if mode := h.FileInfo().Mode().Perm(); mode != 0 {
+		if err := os.Chmod(path, mode); err != nil {
+			return err
+		}
+	}
+	if !h.ModTime.IsZero() {
+		if err := os.Chtimes(path, time.Now(), h.ModTime); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -275,7 +316,7 @@ func (te *Extractor) extractSymlink(path string, h *tar.Header) error {
 	return os.Symlink(h.Linkname, path)
 }
 
-func (te *Extractor) extractFile(path string, r *tar.Reader) error {
+func (te *Extractor) extractFile(path string, r *tar.Reader, h *tar.Header) error {
 	// Attempt removing the target so we can overwrite files, symlinks and empty directories
 	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
 		return err
@@ -298,6 +339,11 @@ func (te *Extractor) extractFile(path string, r *tar.Reader) error {
 		return err
 	}
 
+	if err := setMetadata(tmpfile.Name(), h); err != nil {
+		_ = os.Remove(tmpfile.Name())
+		return err
+	}
+
 	if err := os.Rename(tmpfile.Name(), path); err != nil {
 		_ = os.Remove(tmpfile.Name())
 		return err
@@ -280,6 +280,42 @@ func TestExternalSymlinkTraverse(t *testing.T) {
 	)
 }
 
+func TestMetadataRestored(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("POSIX permissions are not supported on this platform")
+	}
+	dirMtime := time.Unix(1638111600, 0)
+	fileMtime := time.Unix(1638025200, 0)
+	testTarExtraction(t, nil, []tarEntry{
+		&metaTarEntry{&tar.Header{Name: "root", Typeflag: tar.TypeDir, Mode: 0o750, ModTime: dirMtime}, nil},
+		&metaTarEntry{&tar.Header{Name: "root/ro", Typeflag: tar.TypeDir, Mode: 0o500, ModTime: dirMtime}, nil},
+		&metaTarEntry{&tar.Header{Name: "root/ro/file", Typeflag: tar.TypeReg, Mode: 0o640, ModTime: fileMtime, Size: 4}, []byte("data")},
+		&metaTarEntry{&tar.Header{Name: "root/ro/setuid", Typeflag: tar.TypeReg, Mode: 0o4755 | 0o2000 | 0o1000, ModTime: fileMtime, Size: 4}, []byte("data")},
+	},
+		func(t *testing.T, extractDir string) {
+			for _, exp := range []struct {
+				path  string
+				mode  os.FileMode
+				mtime time.Time
+			}{
+				{extractDir, os.ModeDir | 0o750, dirMtime},
+				{fp.Join(extractDir, "ro"), os.ModeDir | 0o500, dirMtime},
+				{fp.Join(extractDir, "ro", "file"), 0o640, fileMtime},
+				// Special bits are dropped
+				{fp.Join(extractDir, "ro", "setuid"), 0o755, fileMtime},
+			} {
+				fi, err := os.Stat(exp.path)
+				assert.NoError(t, err)
+				assert.Equal(t, exp.mode, fi.Mode(), exp.path)
+				assert.True(t, exp.mtime.Equal(fi.ModTime()), exp.path)
+			}
+			// Allow the temporary directory to be cleaned up.
+			assert.NoError(t, os.Chmod(fp.Join(extractDir, "ro"), 0o700))
+		},
+		nil,
+	)
+}
+
 func TestLastElementOverwrite(t *testing.T) {
 	if !symlinksEnabled {
 		t.Skip("symlinks disabled on this platform", symlinksEnabledErr)
@@ -427,6 +463,19 @@ func (e *dirTarEntry) write(tw *tar.Writer) error {
 	})
 }
 
+type metaTarEntry struct {
+	header *tar.Header
+	buf    []byte
+}
+
+func (e *metaTarEntry) write(tw *tar.Writer) error {
+	if err := tw.WriteHeader(e.header); err != nil {
+		return err
+	}
+	_, err := tw.Write(e.buf)
+	return err
+}
+
 type symlinkTarEntry struct {
 	target string
 	path   string